@@ -0,0 +1,295 @@
+package hca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ACB is cue names of an ACB file
+// ACB 是 ACB 文件中的 cue 名称, 用于为从 AWB 中提取的 HCA 文件命名或填写标签
+type ACB struct {
+	Name string         // ACB 的名称 (通常是音效组或专辑的名称)
+	Cues map[int]string // 波形 ID (在 AWB 中的 ID) 对应的 cue 名称, 一个波形被多个 cue 引用时使用第一个
+}
+
+// ReadACB parse cue names of an ACB file
+// ReadACB 读取 ACB 文件, 按 cue 的引用 (波形, synth 与 sequence) 找到每个 cue 播放的波形.
+// 波形使用流式 AWB (.awb 文件) 时取 StreamAwbId, 只在内存中时取 MemoryAwbId
+func ReadACB(r io.Reader) (*ACB, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	top, err := parseUTF(data)
+	if err != nil {
+		return nil, err
+	}
+	if top.rows < 1 {
+		return nil, fmt.Errorf("%w: empty header table", ErrInvalidACB)
+	}
+	a := &acbTables{}
+	for _, sub := range []struct {
+		t    **utfTable
+		name string
+	}{
+		{&a.cueNames, "CueNameTable"}, {&a.cues, "CueTable"}, {&a.waveforms, "WaveformTable"},
+		{&a.synths, "SynthTable"}, {&a.sequences, "SequenceTable"}, {&a.tracks, "TrackTable"},
+		{&a.events, "TrackEventTable"},
+	} {
+		b := top.data(0, sub.name)
+		if len(b) == 0 && sub.name == "TrackEventTable" { // 旧版本的轨道事件在 CommandTable 中
+			b = top.data(0, "CommandTable")
+		}
+		if len(b) == 0 {
+			continue
+		}
+		if *sub.t, err = parseUTF(b); err != nil {
+			return nil, fmt.Errorf("%s: %w", sub.name, err)
+		}
+	}
+	if a.cueNames == nil || a.cues == nil || a.waveforms == nil {
+		return nil, fmt.Errorf("%w: missing cue or waveform table", ErrInvalidACB)
+	}
+
+	acb := &ACB{Name: top.str(0, "Name"), Cues: make(map[int]string)}
+	for row := range a.cueNames.rows {
+		name := a.cueNames.str(row, "CueName")
+		cue := a.cueNames.uint(row, "CueIndex")
+		var ids []int
+		a.resolve(a.cues.uint(cue, "ReferenceType"), a.cues.uint(cue, "ReferenceIndex"), 0, &ids)
+		for _, id := range ids {
+			if _, ok := acb.Cues[id]; !ok {
+				acb.Cues[id] = name
+			}
+		}
+	}
+	return acb, nil
+}
+
+// acbTables 是 ACB 中与 cue 有关的子表, 不存在的表为 nil
+type acbTables struct {
+	cueNames, cues, waveforms, synths, sequences, tracks, events *utfTable
+}
+
+// 引用的类型 (CueTable.ReferenceType, SynthTable.ReferenceItems 与轨道事件中的引用)
+const (
+	acbWaveform = 1
+	acbSynth    = 2
+	acbSequence = 3
+)
+
+// resolve 将 typ 类型的第 index 项引用的波形 ID 添加到 ids, depth 限制嵌套的层数 (防止循环引用)
+func (a *acbTables) resolve(typ, index, depth int, ids *[]int) {
+	if depth > 8 {
+		return
+	}
+	switch typ {
+	case acbWaveform:
+		if index >= a.waveforms.rows {
+			return
+		}
+		if a.waveforms.has("Id") { // 旧版本只有一个 ID
+			*ids = append(*ids, a.waveforms.uint(index, "Id"))
+		} else if a.waveforms.uint(index, "Streaming") == 0 {
+			*ids = append(*ids, a.waveforms.uint(index, "MemoryAwbId"))
+		} else {
+			*ids = append(*ids, a.waveforms.uint(index, "StreamAwbId"))
+		}
+	case acbSynth:
+		items := a.synths.data(index, "ReferenceItems")
+		for ; len(items) >= 4; items = items[4:] {
+			a.resolve(int(binary.BigEndian.Uint16(items)), int(binary.BigEndian.Uint16(items[2:])), depth+1, ids)
+		}
+	case acbSequence:
+		tracks := a.sequences.data(index, "TrackIndex")
+		for n := a.sequences.uint(index, "NumTracks"); n > 0 && len(tracks) >= 2; n, tracks = n-1, tracks[2:] {
+			event := a.tracks.uint(int(binary.BigEndian.Uint16(tracks)), "EventIndex")
+			a.resolveCommands(a.events.data(event, "Command"), depth, ids)
+		}
+	}
+}
+
+// resolveCommands 解析轨道事件的命令 (2 字节命令码, 1 字节长度与参数), 按其中的播放命令解析引用
+func (a *acbTables) resolveCommands(cmd []byte, depth int, ids *[]int) {
+	for len(cmd) >= 3 {
+		code, size := binary.BigEndian.Uint16(cmd), int(cmd[2])
+		if len(cmd) < 3+size {
+			return
+		}
+		if args := cmd[3 : 3+size]; (code == 2000 || code == 2003) && size >= 4 { // noteOn 与 noteOnWithNo
+			a.resolve(int(binary.BigEndian.Uint16(args)), int(binary.BigEndian.Uint16(args[2:])), depth+1, ids)
+		}
+		cmd = cmd[3+size:]
+	}
+}
+
+// @UTF 表的列标志
+const (
+	utfHasName    = 0x10 // 有列名
+	utfHasDefault = 0x20 // 值保存在列定义中, 所有行相同
+	utfPerRow     = 0x40 // 值保存在每一行中
+)
+
+// @UTF 表的列类型
+const (
+	utfString = 0x0A // 字符串表中的偏移量
+	utfData   = 0x0B // 数据区中的偏移量与大小
+)
+
+// utfTable 是 CRI 的 @UTF 表, 所有数值都是大端序
+type utfTable struct {
+	b        []byte // 表的内容 (从 @UTF 与大小之后开始, 各偏移量都相对于这里)
+	rows     int
+	rowsAt   int // 行数据的偏移量
+	rowWidth int
+	strings  int // 字符串表的偏移量
+	dataAt   int // 数据区的偏移量
+	columns  map[string]utfColumn
+}
+
+// utfColumn 是 @UTF 表的一列
+type utfColumn struct {
+	typ    byte
+	value  []byte // utfHasDefault 时的值
+	offset int    // utfPerRow 时在行中的偏移量, 否则为 -1
+}
+
+// utfSize 返回类型的字节数, 未知的类型返回 0
+func utfSize(typ byte) int {
+	switch typ {
+	case 0, 1:
+		return 1
+	case 2, 3:
+		return 2
+	case 4, 5, 8, utfString:
+		return 4
+	case 6, 7, 9, utfData:
+		return 8
+	}
+	return 0
+}
+
+// parseUTF 解析 b 开始的 @UTF 表
+func parseUTF(b []byte) (*utfTable, error) {
+	if len(b) < 8 || string(b[:4]) != "@UTF" {
+		return nil, fmt.Errorf("%w: not a @UTF table", ErrInvalidACB)
+	}
+	size := int64(binary.BigEndian.Uint32(b[4:]))
+	if size < 24 || size > int64(len(b)-8) {
+		return nil, fmt.Errorf("%w: @UTF table size %d", ErrInvalidACB, size)
+	}
+	b = b[8 : 8+size]
+	t := &utfTable{
+		b:        b,
+		rowsAt:   int(binary.BigEndian.Uint16(b[2:])),
+		strings:  int(binary.BigEndian.Uint32(b[4:])),
+		dataAt:   int(binary.BigEndian.Uint32(b[8:])),
+		rowWidth: int(binary.BigEndian.Uint16(b[18:])),
+		rows:     int(binary.BigEndian.Uint32(b[20:])),
+		columns:  make(map[string]utfColumn),
+	}
+	if t.rowsAt > len(b) || t.strings > len(b) || t.dataAt > len(b) || int64(t.rows)*int64(t.rowWidth) > int64(len(b)-t.rowsAt) {
+		return nil, fmt.Errorf("%w: @UTF offsets out of range", ErrInvalidACB)
+	}
+	pos, width := 24, 0
+	for range int(binary.BigEndian.Uint16(b[16:])) {
+		if pos+5 > len(b) {
+			return nil, fmt.Errorf("%w: @UTF schema truncated", ErrInvalidACB)
+		}
+		flags := b[pos]
+		c := utfColumn{typ: flags & 0x0F, offset: -1}
+		n := utfSize(c.typ)
+		if flags&utfHasName == 0 || n == 0 || flags&(utfHasDefault|utfPerRow) == utfHasDefault|utfPerRow {
+			return nil, fmt.Errorf("%w: @UTF column flags %#x", ErrInvalidACB, flags)
+		}
+		name := t.cstring(int(binary.BigEndian.Uint32(b[pos+1:])))
+		pos += 5
+		if flags&utfHasDefault != 0 {
+			if pos+n > len(b) {
+				return nil, fmt.Errorf("%w: @UTF schema truncated", ErrInvalidACB)
+			}
+			c.value = b[pos : pos+n]
+			pos += n
+		}
+		if flags&utfPerRow != 0 {
+			c.offset = width
+			width += n
+		}
+		t.columns[name] = c
+	}
+	if width > t.rowWidth {
+		return nil, fmt.Errorf("%w: @UTF row width %d, columns need %d", ErrInvalidACB, t.rowWidth, width)
+	}
+	return t, nil
+}
+
+// cstring 返回字符串表中偏移量 off 处以 0 结束的字符串
+func (t *utfTable) cstring(off int) string {
+	if off < 0 || t.strings+off >= len(t.b) {
+		return ""
+	}
+	s := t.b[t.strings+off:]
+	if i := bytes.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return string(s)
+}
+
+// has 判断表中是否有 name 列
+func (t *utfTable) has(name string) bool {
+	_, ok := t.columns[name]
+	return ok
+}
+
+// value 返回 row 行 name 列的原始值与类型, 表为 nil, 行或列不存在时返回 nil
+func (t *utfTable) value(row int, name string) ([]byte, byte) {
+	if t == nil || row < 0 || row >= t.rows {
+		return nil, 0
+	}
+	c, ok := t.columns[name]
+	if !ok {
+		return nil, 0
+	}
+	if c.offset < 0 {
+		return c.value, c.typ
+	}
+	at := t.rowsAt + row*t.rowWidth + c.offset
+	return t.b[at : at+utfSize(c.typ)], c.typ
+}
+
+// uint 返回整数列的值, 不存在或不是整数时返回 0
+func (t *utfTable) uint(row int, name string) int {
+	v, typ := t.value(row, name)
+	if v == nil || typ > 7 {
+		return 0
+	}
+	n := 0
+	for _, b := range v {
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+// str 返回字符串列的值
+func (t *utfTable) str(row int, name string) string {
+	v, typ := t.value(row, name)
+	if typ != utfString || v == nil {
+		return ""
+	}
+	return t.cstring(int(binary.BigEndian.Uint32(v)))
+}
+
+// data 返回数据列的内容, 超出数据区时返回 nil
+func (t *utfTable) data(row int, name string) []byte {
+	v, typ := t.value(row, name)
+	if typ != utfData || v == nil {
+		return nil
+	}
+	off, size := int64(binary.BigEndian.Uint32(v)), int64(binary.BigEndian.Uint32(v[4:]))
+	if int64(t.dataAt)+off+size > int64(len(t.b)) {
+		return nil
+	}
+	return t.b[int64(t.dataAt)+off : int64(t.dataAt)+off+size]
+}
@@ -0,0 +1,132 @@
+package hca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"testing"
+)
+
+// utfCol 是 buildUTF 的一列, 整数列的值是 int, 字符串列是 string, 数据列是 []byte; constant 时只使用第一个值
+type utfCol struct {
+	name     string
+	typ      byte
+	constant bool
+	values   []any
+}
+
+// buildUTF 生成 @UTF 表
+func buildUTF(name string, rows int, cols ...utfCol) []byte {
+	var strs, data []byte
+	addString := func(s string) uint32 {
+		off := uint32(len(strs))
+		strs = append(append(strs, s...), 0)
+		return off
+	}
+	value := func(b []byte, typ byte, v any) []byte {
+		switch typ {
+		case 0:
+			return append(b, byte(v.(int)))
+		case 2:
+			return binary.BigEndian.AppendUint16(b, uint16(v.(int)))
+		case 4:
+			return binary.BigEndian.AppendUint32(b, uint32(v.(int)))
+		case utfString:
+			return binary.BigEndian.AppendUint32(b, addString(v.(string)))
+		default: // utfData
+			b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+			data = append(data, v.([]byte)...)
+			return binary.BigEndian.AppendUint32(b, uint32(len(v.([]byte))))
+		}
+	}
+	nameOff := addString(name)
+	var schema, rowData []byte
+	width := 0
+	for _, c := range cols {
+		if c.constant {
+			schema = append(schema, utfHasName|utfHasDefault|c.typ)
+			schema = binary.BigEndian.AppendUint32(schema, addString(c.name))
+			schema = value(schema, c.typ, c.values[0])
+		} else {
+			schema = append(schema, utfHasName|utfPerRow|c.typ)
+			schema = binary.BigEndian.AppendUint32(schema, addString(c.name))
+			width += utfSize(c.typ)
+		}
+	}
+	for row := range rows {
+		for _, c := range cols {
+			if !c.constant {
+				rowData = value(rowData, c.typ, c.values[row])
+			}
+		}
+	}
+	rowsAt := 24 + len(schema)
+	stringsAt := rowsAt + len(rowData)
+	dataAt := stringsAt + len(strs)
+	b := binary.BigEndian.AppendUint16(nil, 1)
+	b = binary.BigEndian.AppendUint16(b, uint16(rowsAt))
+	b = binary.BigEndian.AppendUint32(b, uint32(stringsAt))
+	b = binary.BigEndian.AppendUint32(b, uint32(dataAt))
+	b = binary.BigEndian.AppendUint32(b, nameOff)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(cols)))
+	b = binary.BigEndian.AppendUint16(b, uint16(width))
+	b = binary.BigEndian.AppendUint32(b, uint32(rows))
+	b = append(append(append(append(b, schema...), rowData...), strs...), data...)
+	return append(binary.BigEndian.AppendUint32([]byte("@UTF"), uint32(len(b))), b...)
+}
+
+// u16s 返回大端序的 uint16 数组
+func u16s(v ...int) []byte {
+	var b []byte
+	for _, x := range v {
+		b = binary.BigEndian.AppendUint16(b, uint16(x))
+	}
+	return b
+}
+
+// TestReadACB 检查按波形, synth 与 sequence 的引用找到 cue 对应的波形 ID, 先出现的 cue 名称优先
+func TestReadACB(t *testing.T) {
+	cueNames := buildUTF("CueName", 4,
+		utfCol{"CueName", utfString, false, []any{"title", "battle", "ending", "title_alt"}},
+		utfCol{"CueIndex", 2, false, []any{0, 1, 2, 3}})
+	cues := buildUTF("Cue", 4,
+		utfCol{"ReferenceType", 0, false, []any{acbWaveform, acbSynth, acbSequence, acbWaveform}},
+		utfCol{"ReferenceIndex", 2, false, []any{0, 0, 0, 0}})
+	waveforms := buildUTF("Waveform", 3,
+		utfCol{"Streaming", 0, false, []any{1, 0, 1}},
+		utfCol{"MemoryAwbId", 2, false, []any{0xFFFF, 7, 0xFFFF}},
+		utfCol{"StreamAwbId", 2, false, []any{5, 0xFFFF, 9}})
+	synths := buildUTF("Synth", 1, utfCol{"ReferenceItems", utfData, false, []any{u16s(acbWaveform, 1)}})
+	sequences := buildUTF("Sequence", 1,
+		utfCol{"NumTracks", 2, true, []any{2}},
+		utfCol{"TrackIndex", utfData, false, []any{u16s(0, 1)}})
+	tracks := buildUTF("Track", 2, utfCol{"EventIndex", 2, false, []any{0, 1}})
+	events := buildUTF("TrackEvent", 2, utfCol{"Command", utfData, false, []any{
+		append([]byte{0x0F, 0xA0, 1, 0}, append([]byte{0x07, 0xD0, 4}, u16s(acbWaveform, 2)...)...), // 其他命令之后的 noteOn
+		append([]byte{0x07, 0xD0, 4}, u16s(acbWaveform, 0)...),
+	}})
+	acb := buildUTF("Header", 1,
+		utfCol{"Name", utfString, false, []any{"BGM"}},
+		utfCol{"CueNameTable", utfData, false, []any{cueNames}},
+		utfCol{"CueTable", utfData, false, []any{cues}},
+		utfCol{"WaveformTable", utfData, false, []any{waveforms}},
+		utfCol{"SynthTable", utfData, false, []any{synths}},
+		utfCol{"SequenceTable", utfData, false, []any{sequences}},
+		utfCol{"TrackTable", utfData, false, []any{tracks}},
+		utfCol{"TrackEventTable", utfData, false, []any{events}})
+
+	got, err := ReadACB(bytes.NewReader(acb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]string{5: "title", 7: "battle", 9: "ending"}; got.Name != "BGM" || !maps.Equal(got.Cues, want) {
+		t.Errorf("got %q %v, want BGM %v", got.Name, got.Cues, want)
+	}
+
+	for _, bad := range [][]byte{nil, []byte("@UTF\x00\x00\x00\xFF"), acb[:len(acb)/2], buildUTF("Header", 1, utfCol{"Name", utfString, false, []any{"x"}})} {
+		if _, err := ReadACB(bytes.NewReader(bad)); !errors.Is(err, ErrInvalidACB) {
+			t.Errorf("%q: got %v, want ErrInvalidACB", bad, err)
+		}
+	}
+}
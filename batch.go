@@ -0,0 +1,150 @@
+package hca
+
+import (
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Job is one file decoded by DecodeFiles
+// Job 是 DecodeFiles 解码的一个文件
+type Job struct {
+	Src string // 输入的 HCA 文件
+	Dst string // 输出文件, 扩展名是注册的输出格式且 Decoder 没有设置 Format 时使用该格式 (与 DecodeFile 相同)
+
+	// Decoder 提供这个文件的密钥与输出选项, 为 nil 时使用 NewDecoder() 的默认设置.
+	// 解码使用它的副本 (Clone), 不会改变它的状态, 多个任务可以共用同一个 Decoder,
+	// 此时它的回调 (例如 Progress) 会被多个 goroutine 同时调用
+	Decoder *Hca
+
+	// Create 创建输出文件 Dst, 为 nil 时使用 os.Create. 可以用来包装输出 (例如限制写入速度),
+	// 返回的 Writer 可以 Seek 时解码结束后回写头部. 任务失败时仍然删除 Dst
+	Create func(name string) (io.WriteCloser, error)
+
+	// Done 在任务结束 (包括失败与没有开始) 时以它的结果调用, 可能被多个 goroutine 同时调用
+	Done func(Result)
+}
+
+// Result is outcome of one Job
+// Result 是一个任务的处理结果
+type Result struct {
+	Job     Job
+	Info    Info          // 文件的头部信息, 没有读取到头部时为零值
+	Stats   Stats         // 解码的统计 (Decoder 设置了 Analyze 时包含各通道的电平)
+	Elapsed time.Duration // 解码用时, 没有开始时为 0
+	Err     error         // 解码失败, 被取消或没有开始 (ctx 已结束) 时的错误, 恢复模式下的截断也会记录在这里
+}
+
+// DecodeFiles decode jobs with a pool of workers
+// DecodeFiles 使用 workers 个 goroutine 并行解码 jobs, 按 jobs 的顺序返回每个任务的结果.
+// workers 不大于 0 时使用 runtime.GOMAXPROCS(0). ctx 结束后不再开始新的任务,
+// 正在解码的任务在读取下一段数据时中止, 它们与未开始的任务的 Err 为 ctx.Err().
+// 失败的任务删除不完整的输出文件 (与 DecodeFile 相同), 不影响其他任务
+func DecodeFiles(ctx context.Context, jobs []Job, workers int) []Result {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(jobs)) // 并行数不需要超过任务数
+	results := make([]Result, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = decodeJob(ctx, jobs[i])
+				if jobs[i].Done != nil {
+					jobs[i].Done(results[i])
+				}
+			}
+		}()
+	}
+	i := 0
+feed:
+	for ; i < len(jobs); i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	for ; i < len(jobs); i++ { // 没有开始的任务
+		results[i] = Result{Job: jobs[i], Err: ctx.Err()}
+		if jobs[i].Done != nil {
+			jobs[i].Done(results[i])
+		}
+	}
+	return results
+}
+
+// decodeJob 解码一个任务, 读取输入前检查 ctx
+func decodeJob(ctx context.Context, job Job) Result {
+	res := Result{Job: job}
+	if err := ctx.Err(); err != nil {
+		res.Err = err
+		return res
+	}
+	var d *Hca
+	if job.Decoder != nil {
+		d = job.Decoder.Clone()
+	} else {
+		d = NewDecoder()
+	}
+	start := time.Now()
+	res.Err = d.decodeFileContext(ctx, job.Src, job.Dst, d.formatFor(job.Dst), job.Create)
+	res.Elapsed = time.Since(start)
+	res.Info, res.Stats = d.Info(), d.Stats()
+	return res
+}
+
+// decodeFileContext 解码文件 src 并以 format 格式写入 create 创建的文件 dst (create 为 nil 时使用 os.Create), ctx 结束时中止, 失败时删除 dst
+func (h *Hca) decodeFileContext(ctx context.Context, src, dst, format string, create func(string) (io.WriteCloser, error)) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if create == nil {
+		create = createFile
+	}
+	out, err := create(dst)
+	if err != nil {
+		return err
+	}
+	err = h.decodeBuffer(&ctxReader{ctx: ctx, r: f}, out, format)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil && !h.recovered(err) { // 解码失败 (恢复模式下的截断除外)
+		os.Remove(dst) // 删除不完整或错误的输出文件
+	}
+	return err
+}
+
+// createFile 以 os.Create 创建输出文件
+func createFile(name string) (io.WriteCloser, error) {
+	return os.Create(name)
+}
+
+// ctxReader 在 ctx 结束后停止读取
+type ctxReader struct {
+	ctx context.Context
+	r   io.ReadSeeker
+}
+
+func (c *ctxReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+func (c *ctxReader) Seek(offset int64, whence int) (int64, error) {
+	return c.r.Seek(offset, whence)
+}
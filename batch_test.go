@@ -0,0 +1,103 @@
+package hca
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestDecodeFiles 检查结果按任务的顺序返回, 与各任务的完成顺序无关, 失败的任务不影响其他任务
+func TestDecodeFiles(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"stereo", "mono_loop", "missing", "stereo", "mono_loop"}
+	jobs := make([]Job, len(names))
+	var mu sync.Mutex
+	var finished []int // Done 被调用的顺序
+	for i, name := range names {
+		jobs[i] = Job{
+			Src: filepath.Join("testdata", name+".hca"),
+			Dst: filepath.Join(dir, name+string(rune('0'+i))+".wav"),
+			Done: func(Result) {
+				mu.Lock()
+				finished = append(finished, i)
+				mu.Unlock()
+			},
+		}
+	}
+	results := DecodeFiles(context.Background(), jobs, 3)
+	if len(results) != len(jobs) || len(finished) != len(jobs) {
+		t.Fatalf("got %d results, %d Done calls, want %d", len(results), len(finished), len(jobs))
+	}
+	for i, r := range results {
+		if r.Job.Src != jobs[i].Src || r.Job.Dst != jobs[i].Dst {
+			t.Errorf("result %d is for %s, want %s", i, r.Job.Src, jobs[i].Src)
+		}
+		_, statErr := os.Stat(jobs[i].Dst)
+		if names[i] == "missing" {
+			if !errors.Is(r.Err, os.ErrNotExist) || statErr == nil {
+				t.Errorf("result %d: got %v, output exists %v, want a missing input error", i, r.Err, statErr == nil)
+			}
+			continue
+		}
+		if r.Err != nil || statErr != nil {
+			t.Errorf("result %d: %v, %v", i, r.Err, statErr)
+		}
+		if r.Info.Blocks == 0 || r.Stats.Blocks != int(r.Info.Blocks) {
+			t.Errorf("result %d: decoded %d of %d blocks", i, r.Stats.Blocks, r.Info.Blocks)
+		}
+	}
+	if results[0].Stats.BytesWritten != results[3].Stats.BytesWritten {
+		t.Errorf("same input decoded to %d and %d bytes", results[0].Stats.BytesWritten, results[3].Stats.BytesWritten)
+	}
+}
+
+// TestDecodeFilesCancel 检查 ctx 结束后不再开始新的任务, 没有开始的任务的 Err 为 ctx.Err() 且不创建输出文件
+func TestDecodeFilesCancel(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobs := make([]Job, 4)
+	for i := range jobs {
+		jobs[i] = Job{
+			Src: filepath.Join("testdata", "stereo.hca"),
+			Dst: filepath.Join(dir, string(rune('0'+i))+".wav"),
+		}
+	}
+	jobs[0].Done = func(Result) { cancel() } // 第一个任务结束后取消
+	results := DecodeFiles(ctx, jobs, 1)
+	if results[0].Err != nil {
+		t.Fatalf("first job: %v", results[0].Err)
+	}
+	for i, r := range results[1:] {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("job %d: got %v, want context.Canceled", i+1, r.Err)
+		}
+		if _, err := os.Stat(r.Job.Dst); err == nil {
+			t.Errorf("job %d: output created after cancel", i+1)
+		}
+	}
+
+	// 已经结束的 ctx: 所有任务都没有开始
+	for _, r := range DecodeFiles(ctx, jobs[1:], 2) {
+		if !errors.Is(r.Err, context.Canceled) || r.Elapsed != 0 {
+			t.Errorf("%s: got %v after %v, want context.Canceled before start", r.Job.Dst, r.Err, r.Elapsed)
+		}
+	}
+
+	// 正在解码的任务在读取下一段数据时中止, 并删除不完整的输出文件
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	d := NewDecoder()
+	d.Progress = func(blocks, total int) { cancel() }
+	job := Job{Src: filepath.Join("testdata", "stereo.hca"), Dst: filepath.Join(dir, "running.wav"), Decoder: d}
+	r := DecodeFiles(ctx, []Job{job}, 1)[0]
+	if !errors.Is(r.Err, context.Canceled) || r.Stats.Blocks == 0 || r.Stats.Blocks >= int(r.Info.Blocks) {
+		t.Errorf("running job: got %v after %d blocks, want context.Canceled during decoding", r.Err, r.Stats.Blocks)
+	}
+	if _, err := os.Stat(job.Dst); err == nil {
+		t.Error("running job: partial output kept")
+	}
+}
@@ -0,0 +1,107 @@
+package hca
+
+import "io"
+
+// BlockDecoder decode single HCA blocks driven by the caller
+// BlockDecoder 由调用方驱动的逐块解码器, 用于游戏引擎等自行管理读取与播放循环的场合:
+// 调用方按顺序把数据块交给 DecodeBlock, 得到解密, 校验与逆 MDCT 之后的交错浮点样本.
+// 每个块的输出依赖前一个块的重叠状态, 不按顺序解码 (Seek) 时应先调用 Reset 并解码目标的前一个块.
+// 只应用音量 (rva, Volume, GainDB), ChannelMap 与 ChannelGainDB, 不能并发使用
+type BlockDecoder struct {
+	d    Hca   // 解码器的副本, 保存头部信息与解码状态
+	next int64 // 下一个块的索引, 用于错误信息与 SpectrumTap
+}
+
+// NewBlockDecoder read header from r and create BlockDecoder
+// NewBlockDecoder 从 r 读取头部 (读取位置停在数据偏移量处) 并返回 BlockDecoder,
+// 使用 h 当前的密钥, 音量与容错设置, 之后修改 h 不影响 BlockDecoder.
+// 不会检查密钥是否正确, 密钥错误时 DecodeBlock 返回 ErrInvalidBlockMagic 或 ErrInvalidBlockData
+func (h *Hca) NewBlockDecoder(r io.Reader) (*BlockDecoder, error) {
+	if h.closed { // 解码器已关闭
+		return nil, ErrClosed
+	}
+	if err := h.checkOptions(); err != nil {
+		return nil, err
+	}
+	b := &BlockDecoder{d: *h}
+	d := &b.d
+	d.fileState = fileState{}
+	d.TargetLoudness = 0
+	if err := d.loadHeader(r); err != nil {
+		return nil, err
+	}
+	if err := d.checkStream(); err != nil {
+		return nil, err
+	}
+	if err := d.checkChannelMap(); err != nil {
+		return nil, err
+	}
+	d.gain = d.rvaVolume * d.volume()
+	if d.unityGain {
+		d.gain = 1
+	}
+	d.stats.Gain = d.gain
+	return b, nil
+}
+
+// DecodeBlock decode one block and return interleaved float samples
+// DecodeBlock 解码一个数据块 (BlockSize 字节), 返回 1024 个样本帧交错排列的浮点样本 (范围 -1 到 1),
+// 返回的切片在下一次调用时被复用. 损坏的块按 ChecksumPolicy 与 MagicPolicy 处理:
+// 严格模式下返回 BlockError, 丢弃或以静音替代时返回静音, BlockRepeat 时返回上一个块, BlockDecode 时返回仍然解码的内容
+func (b *BlockDecoder) DecodeBlock(block []byte) ([]float32, error) {
+	index := b.next
+	b.next++
+	return b.decodeAt(block, index)
+}
+
+// decodeAt 解码第 index 个块
+func (b *BlockDecoder) decodeAt(block []byte, index int64) ([]float32, error) {
+	d := &b.d
+	d.position = index * samplesPerBlock
+	emit, err := d.decode(block, d.blockAddress(uint32(index)))
+	if err != nil {
+		return nil, err
+	}
+	if !emit { // 丢弃的块以静音代替, 保持样本位置
+		d.decoder.mute()
+	}
+	d.stats.Blocks++
+	d.stats.Samples += samplesPerBlock
+	serial := d.decoder.waveSerialize(d.gain, d.ChannelMap, len(d.ChannelGainDB) == 0)
+	if len(d.ChannelGainDB) != 0 {
+		d.applyBalance(serial)
+		clamp(serial)
+	}
+	return serial, nil
+}
+
+// Reset clear overlap state, the next block is index
+// Reset 清除解码器的重叠状态, 之后 DecodeBlock 解码的块视为第 index 个块
+func (b *BlockDecoder) Reset(index int) {
+	b.d.decoder.mute()
+	b.next = int64(index)
+}
+
+// Info return header information
+// Info 返回文件的头部信息, 数据块从 DataOffset 开始, 每个 BlockSize 字节
+func (b *BlockDecoder) Info() Info {
+	return b.d.info()
+}
+
+// DataOffset return file offset of the first block
+// DataOffset 返回第一个数据块在文件中的偏移量
+func (b *BlockDecoder) DataOffset() int64 {
+	return int64(b.d.dataOffset)
+}
+
+// Channels return channels of decoded samples
+// Channels 返回 DecodeBlock 输出的通道数 (应用 ChannelMap 之后)
+func (b *BlockDecoder) Channels() int {
+	return int(b.d.outputChannels())
+}
+
+// Stats return statistics of decoded blocks
+// Stats 返回已解码的块与损坏块的统计
+func (b *BlockDecoder) Stats() Stats {
+	return b.d.stats
+}
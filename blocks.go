@@ -0,0 +1,64 @@
+package hca
+
+import (
+	"errors"
+	"io"
+	"iter"
+)
+
+// errStopBlocks 表示 Blocks 的调用方提前结束了循环
+var errStopBlocks = errors.New("hca: blocks iteration stopped")
+
+// Blocks iterate decoded PCM of r block by block
+// Blocks 返回逐块解码 r 的迭代器, 每次产生一个块交错排列的浮点样本 (范围 -1 到 1), 可以用 for range 读取:
+//
+//	for samples, err := range dec.Blocks(f) {
+//		if err != nil {
+//			return err
+//		}
+//		// 使用 samples
+//	}
+//
+// 样本已应用 h 的循环, 截取范围, 音量, 通道映射, 重采样与效果设置 (忽略 Mode, Headerless 与 Format),
+// 在下一次迭代时会被复用. 解码在调用方的 goroutine 中进行, 提前 break 时立即停止解码.
+// 出错时最后产生一次 (nil, err)
+func (h *Hca) Blocks(r io.ReadSeeker) iter.Seq2[[]float32, error] {
+	return func(yield func([]float32, error) bool) {
+		d := *h // 使用副本解码, 不修改 h 的输出设置
+		d.Format, d.Headerless, d.Mode = "", true, ModeFloat
+		stopped := false
+		d.capture = func(io.Writer, Info, SinkOptions) (Sink, error) {
+			return blockSink(func(samples []float32) error {
+				if len(samples) == 0 { // 截取范围之外的块
+					return nil
+				}
+				if !yield(samples, nil) {
+					stopped = true
+					return errStopBlocks
+				}
+				return nil
+			}), nil
+		}
+		err := d.DecodeWithWriter(r, io.Discard)
+		h.fileState = d.fileState // 保留头部信息与统计, 供 Info 与 Stats 使用
+		if stopped {
+			return
+		}
+		if err != nil && !d.recovered(err) {
+			yield(nil, err)
+		}
+	}
+}
+
+// blockSink 将每次写出的样本交给函数
+type blockSink func(samples []float32) error
+
+// WriteSamples 调用 s
+func (s blockSink) WriteSamples(samples []float32) error {
+	return s(samples)
+}
+
+// Close 不做任何事
+func (s blockSink) Close() error {
+	return nil
+}
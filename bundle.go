@@ -0,0 +1,174 @@
+package hca
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// BundleOptions is ExportBundle options
+// BundleOptions 是 ExportBundle 的选项
+type BundleOptions struct {
+	// PageFrames 将 PCM 按每 PageFrames 个样本帧分页写入多个文件 (name_0000.pcm, name_0001.pcm, ...),
+	// 0 表示写入单个文件 name.pcm
+	PageFrames int
+	// ApplyGain 将 rva 音量与 Volume 应用到 PCM 中 (描述文件的 gain 为 1), 默认由引擎在播放时应用
+	ApplyGain bool
+}
+
+// Bundle is the JSON descriptor written by ExportBundle
+// Bundle 是 ExportBundle 写入的 JSON 描述文件
+type Bundle struct {
+	Format     string   `json:"format"` // 总是 "f32le": 交错的 32 位小端序浮点数
+	SampleRate int      `json:"sample_rate"`
+	Channels   int      `json:"channels"`
+	Frames     int64    `json:"frames"` // 每个通道的样本数
+	Loop       bool     `json:"loop"`
+	LoopStart  int64    `json:"loop_start"` // 循环开始的样本帧 (包含)
+	LoopEnd    int64    `json:"loop_end"`   // 循环结束的样本帧 (不包含)
+	Gain       float32  `json:"gain"`       // 播放时应用的线性增益
+	PageFrames int      `json:"page_frames,omitempty"`
+	Files      []string `json:"files"` // PCM 文件, 相对于描述文件所在的目录
+}
+
+// ExportBundle write float PCM and JSON descriptor of r into dir
+// ExportBundle 将 r 解码为交错的浮点 PCM 写入 dir, 并写入描述文件 dir/name.json.
+// 不展开循环也不截取 (忽略 Loop, StartSample, SampleCount, MaxDuration 与 FadeOut), 循环点写入描述文件
+func (h *Hca) ExportBundle(r io.ReadSeeker, dir, name string, opts BundleOptions) (*Bundle, error) {
+	if opts.PageFrames < 0 {
+		return nil, fmt.Errorf("%w: page frames %d", ErrInvalidOption, opts.PageFrames)
+	}
+	d := *h // 使用副本解码, 不修改 h 的输出设置
+	d.Mode = ModeFloat
+	d.Headerless = true
+	d.Format = ""
+	d.SampleRate = 0
+	d.ChannelMap = nil
+	d.TargetLoudness = 0
+	d.Loop = 0
+	d.StartSample, d.SampleCount, d.MaxDuration, d.FadeOut = 0, 0, 0, 0
+	d.unityGain = !opts.ApplyGain
+
+	info, err := d.Probe(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	pw := &pageWriter{dir: dir, name: name, pageSize: int64(opts.PageFrames) * int64(info.Channels) * 4}
+	err = d.DecodeWithWriter(r, pw)
+	if cerr := pw.close(); err == nil {
+		err = cerr
+	}
+	h.fileState = d.fileState // 保留头部信息与统计, 供 Info 与 Stats 使用
+	if err != nil && !d.recovered(err) {
+		pw.remove()
+		return nil, err
+	}
+
+	b := &Bundle{
+		Format:     "f32le",
+		SampleRate: info.SamplingRate,
+		Channels:   info.Channels,
+		Frames:     d.stats.Samples,
+		Loop:       info.Loop,
+		Gain:       1,
+		PageFrames: opts.PageFrames,
+		Files:      pw.files,
+	}
+	if info.Loop {
+		b.LoopStart = min(int64(info.LoopStart)*samplesPerBlock, b.Frames)
+		b.LoopEnd = min(int64(info.LoopEnd)*samplesPerBlock, b.Frames)
+	}
+	if !opts.ApplyGain {
+		b.Gain = info.Volume * h.volume()
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), append(data, '\n'), 0644); err != nil {
+		pw.remove()
+		return nil, err
+	}
+	return b, nil
+}
+
+// pageWriter 将数据写入 dir 中的 PCM 文件, pageSize 大于 0 时每 pageSize 字节换一个文件
+type pageWriter struct {
+	dir      string
+	name     string
+	pageSize int64
+
+	f     *os.File
+	n     int64 // 当前文件已写入的字节数
+	files []string
+}
+
+func (p *pageWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		if p.f == nil || p.pageSize > 0 && p.n >= p.pageSize {
+			if err := p.next(); err != nil {
+				return written, err
+			}
+		}
+		chunk := b
+		if p.pageSize > 0 {
+			chunk = b[:min(int64(len(b)), p.pageSize-p.n)]
+		}
+		n, err := p.f.Write(chunk)
+		written += n
+		p.n += int64(n)
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// next 关闭当前文件并创建下一个文件
+func (p *pageWriter) next() error {
+	if p.f != nil {
+		if err := p.f.Close(); err != nil {
+			return err
+		}
+	}
+	name := p.name + ".pcm"
+	if p.pageSize > 0 {
+		name = fmt.Sprintf("%s_%04d.pcm", p.name, len(p.files))
+	}
+	f, err := os.Create(filepath.Join(p.dir, name))
+	if err != nil {
+		return err
+	}
+	p.f, p.n = f, 0
+	p.files = append(p.files, name)
+	return nil
+}
+
+// close 关闭当前文件; 没有写出任何数据时创建空文件, 保证描述文件中至少有一个文件
+func (p *pageWriter) close() error {
+	if p.f == nil {
+		if len(p.files) > 0 {
+			return nil
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	err := p.f.Close()
+	p.f = nil
+	return err
+}
+
+// remove 删除已写出的文件
+func (p *pageWriter) remove() {
+	for _, name := range p.files {
+		os.Remove(filepath.Join(p.dir, name))
+	}
+}
@@ -90,8 +90,8 @@ var (
 	scaleFloat = uint2float1D(scaleInt)
 )
 
-// Init set value, scale and base
-func (ch *stChannel) Init(data *clData, a uint32, b int, ath []byte) {
+// Init set value, scale and base, return false when scale factors are out of range
+func (ch *stChannel) Init(data *clData, a uint32, b int, ath []byte) bool {
 	v := data.GetBit(3)
 
 	if v >= 6 {
@@ -110,6 +110,9 @@ func (ch *stChannel) Init(data *clData, a uint32, b int, ath []byte) {
 			} else {
 				v1 = data.GetBit(6)
 			}
+			if v1 < 0 || v1 >= len(valueFloat) {
+				return false
+			}
 			ch.value[i] = int8(v1)
 		}
 	} else {
@@ -127,6 +130,9 @@ func (ch *stChannel) Init(data *clData, a uint32, b int, ath []byte) {
 			}
 		}
 	} else {
+		if ch.valueIndex+a > uint32(len(ch.value)) {
+			return false
+		}
 		for i := uint32(0); i < a; i++ {
 			ch.value[ch.valueIndex+i] = int8(data.GetBit(6))
 		}
@@ -154,6 +160,7 @@ func (ch *stChannel) Init(data *clData, a uint32, b int, ath []byte) {
 	for i := uint32(0); i < ch.count; i++ {
 		ch.base[i] = valueFloat[ch.value[i]] * scaleFloat[ch.scale[i]]
 	}
+	return true
 }
 
 var (
@@ -238,7 +245,7 @@ func (ch *stChannel) BlockSet(a, b, c, d uint32) {
 		k := c
 		l := c - 1
 		for i := uint32(0); i < a; i++ {
-			for j := uint32(0); j < b && k < d; j++ {
+			for j := uint32(0); j < b && k < d && l < c; j++ { // l 减到 0 以下时回绕, 没有可以复制的低频段
 				ch.block[k] = d3listFloat[64+(ch.value[ch.valueIndex+i]-ch.value[l])] * ch.block[l]
 				k++
 				l--
@@ -282,7 +289,12 @@ func (ch *stChannel) MixBlock(nextChan *stChannel, index int, a, b, c uint32) {
 }
 
 func calcBlock(b []float32) {
-	blockTemp := make([]float32, len(b))
+	if useSIMD { // 向量化实现, 见 imdct_simd.go
+		calcBlockSIMD((*[0x80]float32)(b))
+		return
+	}
+	var tmp [0x80]float32
+	blockTemp := tmp[:len(b)] // 使用栈上的数组, 不分配内存 (b 总是一个子块的 0x80 个样本)
 
 	s := 0
 	sliceCount := 1
@@ -495,6 +507,10 @@ var (
 
 // buildWaveBytes set wavTmp and wave
 func (ch *stChannel) buildWaveBytes(index int) {
+	if useSIMD {
+		ch.buildWaveBytesSIMD(index)
+		return
+	}
 	// wave set
 	ch.wave[index] = waveCalc(ch.block, ch.wavTmp)
 
@@ -1,5 +1,7 @@
 package hca
 
+import "sync"
+
 type channelDecoder struct {
 	param1 uint32
 	param2 uint32
@@ -8,6 +10,19 @@ type channelDecoder struct {
 	param5 uint32
 
 	channel []*stChannel
+	serial  []float32 // waveSerialize 的输出缓冲, 按本文件的通道数分配
+
+	spectrum [][8][SpectrumBins]float32 // 不为 nil 时记录每个通道各子块 MDCT 系数的绝对值
+
+	parallel int                // 大于 1 时并行完成各通道逆变换的 goroutine 数
+	pending  [][8][0x80]float32 // 并行时保存每个通道各子块解析出的 MDCT 系数, 解析完整个块后再逆变换
+	wg       sync.WaitGroup     // 等待并行的逆变换完成
+}
+
+// setParallel 设置并行完成逆变换的 goroutine 数
+func (d *channelDecoder) setParallel(n int) {
+	d.parallel = n
+	d.pending = make([][8][0x80]float32, len(d.channel))
 }
 
 func newChannelDecoder(channelCount, compCount, compOption, param1, param2, param3, param4, param5 uint32) *channelDecoder {
@@ -72,15 +87,19 @@ func newChannelDecoder(channelCount, compCount, compOption, param1, param2, para
 	d.param3 = param3
 	d.param4 = param4
 	d.param5 = param5
+	d.serial = make([]float32, 8*0x80*channelCount)
 
 	return &d
 }
 
-func (d *channelDecoder) decode(bitData *clData, athTable []byte) {
+// decode decode one block, return false when the block data is invalid
+func (d *channelDecoder) decode(bitData *clData, athTable []byte) bool {
 	a := (bitData.GetBit(9) << 8) - bitData.GetBit(7)
 	// block header
 	for _, ch := range d.channel {
-		ch.Init(bitData, d.param5, a, athTable)
+		if !ch.Init(bitData, d.param5, a, athTable) {
+			return false
+		}
 	}
 	// block decode wave datas
 	for waveLine := 0; waveLine < 8; waveLine++ {
@@ -91,25 +110,92 @@ func (d *channelDecoder) decode(bitData *clData, athTable []byte) {
 		for i := 0; i < (len(d.channel) - 1); i++ {
 			d.channel[i].MixBlock(d.channel[i+1], waveLine, d.param1-d.param2, d.param2, d.param3)
 		}
-		for _, ch := range d.channel {
-			calcBlock(ch.block)
-			ch.buildWaveBytes(waveLine)
+		for c, ch := range d.channel {
+			if d.pending != nil { // 留到整个块解析完后并行逆变换
+				copy(d.pending[c][waveLine][:], ch.block)
+				continue
+			}
+			d.transform(c, waveLine)
 		}
 	}
+	if d.pending != nil {
+		d.transformParallel()
+	}
+	return true
+}
+
+// transform 对第 c 个通道的第 waveLine 个子块做逆变换与加窗
+func (d *channelDecoder) transform(c, waveLine int) {
+	ch := d.channel[c]
+	if d.spectrum != nil { // 在逆变换之前记录频域系数
+		for i, v := range ch.block {
+			d.spectrum[c][waveLine][i] = max(v, -v)
+		}
+	}
+	calcBlock(ch.block)
+	ch.buildWaveBytes(waveLine)
+}
+
+// transformParallel 将通道分给 d.parallel 个 goroutine, 各自按顺序逆变换 8 个子块 (加窗依赖前一个子块)
+func (d *channelDecoder) transformParallel() {
+	d.wg.Add(d.parallel - 1)
+	for w := 1; w < d.parallel; w++ {
+		go d.transformWorker(w)
+	}
+	d.transformChannels(0) // 当前 goroutine 也处理一份
+	d.wg.Wait()
 }
 
-func (d *channelDecoder) waveSerialize(volume float32) []float32 {
+// transformWorker 在单独的 goroutine 中逆变换第 w 份的通道
+func (d *channelDecoder) transformWorker(w int) {
+	defer d.wg.Done()
+	d.transformChannels(w)
+}
+
+// transformChannels 逆变换第 w 份的通道 (序号除以 d.parallel 余 w)
+func (d *channelDecoder) transformChannels(w int) {
+	for c := w; c < len(d.channel); c += d.parallel {
+		for waveLine := range d.pending[c] {
+			copy(d.channel[c].block, d.pending[c][waveLine][:])
+			d.transform(c, waveLine)
+		}
+	}
+}
+
+// mute clear wave and overlap state
+func (d *channelDecoder) mute() {
+	for _, ch := range d.channel {
+		for i := range ch.wave {
+			ch.wave[i] = [0x80]float32{}
+		}
+		for i := range ch.wavTmp {
+			ch.wavTmp[i] = 0
+		}
+	}
+}
+
+// waveSerialize interleave channels, the result is reused by next call
+// channelMap 不为 nil 时第 k 个输出通道取 channelMap[k] 个通道, clamp 为 false 时不限制在 -1 到 1 (由调用方统计后削波)
+func (d *channelDecoder) waveSerialize(volume float32, channelMap []int, clamp bool) []float32 {
 	channelCount := len(d.channel)
-	serialData := make([]float32, 8*0x80*channelCount)
+	if channelMap != nil {
+		channelCount = len(channelMap)
+	}
+	if n := 8 * 0x80 * channelCount; len(d.serial) < n { // 复制通道时输出多于文件的通道数
+		d.serial = make([]float32, n)
+	}
+	serialData := d.serial[:8*0x80*channelCount]
 
 	for i := 0; i < 8; i++ {
 		for j := 0; j < 0x80; j++ {
 			for k := 0; k < channelCount; k++ {
-				f := d.channel[k].wave[i][j] * volume
-				if f > 1 {
-					f = 1
-				} else if f < -1 {
-					f = -1
+				src := k
+				if channelMap != nil {
+					src = channelMap[k]
+				}
+				f := d.channel[src].wave[i][j] * volume
+				if clamp {
+					f = max(-1, min(1, f))
 				}
 				serialData[(i*0x80+j)*channelCount+k] = f
 			}
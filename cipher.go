@@ -1,15 +1,20 @@
 package hca
 
 // Cipher is hca byte cipher
+// Cipher 是 HCA 的字节替换密码, 应使用 NewCipher 创建, 解密表在使用相同密钥的解码器之间共享
 type Cipher struct {
-	table [0x100]byte
+	table *[0x100]byte // 共享的只读表
 }
 
+var (
+	cipherTable0 = newCipherTable0()
+	cipherTable1 = newCipherTable1()
+	cipherTables tableCache[[2]uint32, *[0x100]byte] // type 56 的表, 按密钥共享
+)
+
 // NewCipher is default mask bind
 func NewCipher() *Cipher {
-	var ci Cipher
-	ci.init0()
-	return &ci
+	return &Cipher{table: cipherTable0}
 }
 
 // Init is Cipher key initialize
@@ -19,11 +24,11 @@ func (ci *Cipher) Init(t int, key1, key2 uint32) bool {
 	}
 	switch t {
 	case 0:
-		ci.init0()
+		ci.table = cipherTable0
 	case 1:
-		ci.init1()
+		ci.table = cipherTable1
 	case 56:
-		ci.init56(key1, key2)
+		ci.table = cipherTables.get([2]uint32{key1, key2}, func() *[0x100]byte { return newCipherTable56(key1, key2) })
 	default:
 		return false
 	}
@@ -40,25 +45,33 @@ func (ci *Cipher) Mask(data []byte) []byte {
 	return mask
 }
 
-func (ci *Cipher) init0() {
-	for i := range ci.table {
-		ci.table[i] = byte(i)
+// newCipherTable0 生成不加密时的表
+func newCipherTable0() *[0x100]byte {
+	var table [0x100]byte
+	for i := range table {
+		table[i] = byte(i)
 	}
+	return &table
 }
 
-func (ci *Cipher) init1() {
+// newCipherTable1 生成 type 1 固定密钥的表
+func newCipherTable1() *[0x100]byte {
+	var table [0x100]byte
 	for i, v := 1, 0; i < 0xFF; i++ {
 		v = (v*13 + 11) & 0xFF
 		if v == 0 || v == 0xFF {
 			v = (v*13 + 11) & 0xFF
 		}
-		ci.table[i] = byte(v)
+		table[i] = byte(v)
 	}
-	ci.table[0] = 0
-	ci.table[0xFF] = 0xFF
+	table[0] = 0
+	table[0xFF] = 0xFF
+	return &table
 }
 
-func (ci *Cipher) init56(key1, key2 uint32) {
+// newCipherTable56 生成 type 56 使用 64 位密钥 (key2 为高 32 位) 的表
+func newCipherTable56(key1, key2 uint32) *[0x100]byte {
+	var table [0x100]byte
 	// create table1
 	t1 := make([]byte, 8)
 	if key1 == 0 {
@@ -96,12 +109,13 @@ func (ci *Cipher) init56(key1, key2 uint32) {
 		v = (v + 0x11) & 0xFF
 		a := t3[v]
 		if a != 0 && a != 0xFF {
-			ci.table[i] = a
+			table[i] = a
 			i++
 		}
 	}
-	ci.table[0] = 0
-	ci.table[0xFF] = 0xFF
+	table[0] = 0
+	table[0xFF] = 0xFF
+	return &table
 }
 
 func init56CreateTable(table []byte, key byte) {
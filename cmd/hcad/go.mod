@@ -0,0 +1,28 @@
+module github.com/WJQSERVER/hca/cmd/hcad
+
+go 1.24.4
+
+require (
+	github.com/WJQSERVER/hca v0.0.0
+	github.com/WJQSERVER/hca/hcametrics v0.0.0
+	github.com/prometheus/client_golang v1.22.0
+	google.golang.org/grpc v1.73.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.62.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	golang.org/x/net v0.38.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250324211829-b45e905df463 // indirect
+)
+
+replace github.com/WJQSERVER/hca => ../../
+
+replace github.com/WJQSERVER/hca/hcametrics => ../../hcametrics
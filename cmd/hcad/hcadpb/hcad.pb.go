@@ -0,0 +1,717 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: hcad/v1/hcad.proto
+
+// hcad 解码服务
+
+package hcadpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Format 输出格式
+type Format int32
+
+const (
+	Format_FORMAT_WAV Format = 0 // 带 WAV 头部
+	Format_FORMAT_RAW Format = 1 // 不带头部的 PCM
+)
+
+// Enum value maps for Format.
+var (
+	Format_name = map[int32]string{
+		0: "FORMAT_WAV",
+		1: "FORMAT_RAW",
+	}
+	Format_value = map[string]int32{
+		"FORMAT_WAV": 0,
+		"FORMAT_RAW": 1,
+	}
+)
+
+func (x Format) Enum() *Format {
+	p := new(Format)
+	*p = x
+	return p
+}
+
+func (x Format) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Format) Descriptor() protoreflect.EnumDescriptor {
+	return file_hcad_v1_hcad_proto_enumTypes[0].Descriptor()
+}
+
+func (Format) Type() protoreflect.EnumType {
+	return &file_hcad_v1_hcad_proto_enumTypes[0]
+}
+
+func (x Format) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Format.Descriptor instead.
+func (Format) EnumDescriptor() ([]byte, []int) {
+	return file_hcad_v1_hcad_proto_rawDescGZIP(), []int{0}
+}
+
+// SampleFormat 样本格式
+type SampleFormat int32
+
+const (
+	SampleFormat_SAMPLE_FORMAT_S16     SampleFormat = 0 // 16 位整数
+	SampleFormat_SAMPLE_FORMAT_FLOAT32 SampleFormat = 1
+	SampleFormat_SAMPLE_FORMAT_U8      SampleFormat = 2
+	SampleFormat_SAMPLE_FORMAT_S24     SampleFormat = 3
+	SampleFormat_SAMPLE_FORMAT_S32     SampleFormat = 4
+)
+
+// Enum value maps for SampleFormat.
+var (
+	SampleFormat_name = map[int32]string{
+		0: "SAMPLE_FORMAT_S16",
+		1: "SAMPLE_FORMAT_FLOAT32",
+		2: "SAMPLE_FORMAT_U8",
+		3: "SAMPLE_FORMAT_S24",
+		4: "SAMPLE_FORMAT_S32",
+	}
+	SampleFormat_value = map[string]int32{
+		"SAMPLE_FORMAT_S16":     0,
+		"SAMPLE_FORMAT_FLOAT32": 1,
+		"SAMPLE_FORMAT_U8":      2,
+		"SAMPLE_FORMAT_S24":     3,
+		"SAMPLE_FORMAT_S32":     4,
+	}
+)
+
+func (x SampleFormat) Enum() *SampleFormat {
+	p := new(SampleFormat)
+	*p = x
+	return p
+}
+
+func (x SampleFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SampleFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_hcad_v1_hcad_proto_enumTypes[1].Descriptor()
+}
+
+func (SampleFormat) Type() protoreflect.EnumType {
+	return &file_hcad_v1_hcad_proto_enumTypes[1]
+}
+
+func (x SampleFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SampleFormat.Descriptor instead.
+func (SampleFormat) EnumDescriptor() ([]byte, []int) {
+	return file_hcad_v1_hcad_proto_rawDescGZIP(), []int{1}
+}
+
+type DecodeOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// path 引用服务器 -root 目录中的文件, 为空时解码客户端发送的数据
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// 密钥: key_name 使用服务器管理的密钥, key 直接指定 64 位密钥, game 使用内置的游戏密钥;
+	// 都不设置时使用默认密钥
+	//
+	// Types that are valid to be assigned to KeySelection:
+	//
+	//	*DecodeOptions_KeyName
+	//	*DecodeOptions_Key
+	//	*DecodeOptions_Game
+	KeySelection  isDecodeOptions_KeySelection `protobuf_oneof:"key_selection"`
+	Subkey        uint32                       `protobuf:"varint,5,opt,name=subkey,proto3" json:"subkey,omitempty"`
+	Format        Format                       `protobuf:"varint,6,opt,name=format,proto3,enum=hcad.v1.Format" json:"format,omitempty"`
+	SampleFormat  SampleFormat                 `protobuf:"varint,7,opt,name=sample_format,json=sampleFormat,proto3,enum=hcad.v1.SampleFormat" json:"sample_format,omitempty"`
+	Loop          int32                        `protobuf:"varint,8,opt,name=loop,proto3" json:"loop,omitempty"`      // 循环次数, 0 表示不展开循环
+	Volume        float32                      `protobuf:"fixed32,9,opt,name=volume,proto3" json:"volume,omitempty"` // 音量, 0 表示 1.0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecodeOptions) Reset() {
+	*x = DecodeOptions{}
+	mi := &file_hcad_v1_hcad_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecodeOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeOptions) ProtoMessage() {}
+
+func (x *DecodeOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_hcad_v1_hcad_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeOptions.ProtoReflect.Descriptor instead.
+func (*DecodeOptions) Descriptor() ([]byte, []int) {
+	return file_hcad_v1_hcad_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DecodeOptions) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DecodeOptions) GetKeySelection() isDecodeOptions_KeySelection {
+	if x != nil {
+		return x.KeySelection
+	}
+	return nil
+}
+
+func (x *DecodeOptions) GetKeyName() string {
+	if x != nil {
+		if x, ok := x.KeySelection.(*DecodeOptions_KeyName); ok {
+			return x.KeyName
+		}
+	}
+	return ""
+}
+
+func (x *DecodeOptions) GetKey() uint64 {
+	if x != nil {
+		if x, ok := x.KeySelection.(*DecodeOptions_Key); ok {
+			return x.Key
+		}
+	}
+	return 0
+}
+
+func (x *DecodeOptions) GetGame() string {
+	if x != nil {
+		if x, ok := x.KeySelection.(*DecodeOptions_Game); ok {
+			return x.Game
+		}
+	}
+	return ""
+}
+
+func (x *DecodeOptions) GetSubkey() uint32 {
+	if x != nil {
+		return x.Subkey
+	}
+	return 0
+}
+
+func (x *DecodeOptions) GetFormat() Format {
+	if x != nil {
+		return x.Format
+	}
+	return Format_FORMAT_WAV
+}
+
+func (x *DecodeOptions) GetSampleFormat() SampleFormat {
+	if x != nil {
+		return x.SampleFormat
+	}
+	return SampleFormat_SAMPLE_FORMAT_S16
+}
+
+func (x *DecodeOptions) GetLoop() int32 {
+	if x != nil {
+		return x.Loop
+	}
+	return 0
+}
+
+func (x *DecodeOptions) GetVolume() float32 {
+	if x != nil {
+		return x.Volume
+	}
+	return 0
+}
+
+type isDecodeOptions_KeySelection interface {
+	isDecodeOptions_KeySelection()
+}
+
+type DecodeOptions_KeyName struct {
+	KeyName string `protobuf:"bytes,2,opt,name=key_name,json=keyName,proto3,oneof"`
+}
+
+type DecodeOptions_Key struct {
+	Key uint64 `protobuf:"varint,3,opt,name=key,proto3,oneof"`
+}
+
+type DecodeOptions_Game struct {
+	Game string `protobuf:"bytes,4,opt,name=game,proto3,oneof"`
+}
+
+func (*DecodeOptions_KeyName) isDecodeOptions_KeySelection() {}
+
+func (*DecodeOptions_Key) isDecodeOptions_KeySelection() {}
+
+func (*DecodeOptions_Game) isDecodeOptions_KeySelection() {}
+
+type DecodeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*DecodeRequest_Options
+	//	*DecodeRequest_Data
+	Payload       isDecodeRequest_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecodeRequest) Reset() {
+	*x = DecodeRequest{}
+	mi := &file_hcad_v1_hcad_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeRequest) ProtoMessage() {}
+
+func (x *DecodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hcad_v1_hcad_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeRequest.ProtoReflect.Descriptor instead.
+func (*DecodeRequest) Descriptor() ([]byte, []int) {
+	return file_hcad_v1_hcad_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DecodeRequest) GetPayload() isDecodeRequest_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *DecodeRequest) GetOptions() *DecodeOptions {
+	if x != nil {
+		if x, ok := x.Payload.(*DecodeRequest_Options); ok {
+			return x.Options
+		}
+	}
+	return nil
+}
+
+func (x *DecodeRequest) GetData() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*DecodeRequest_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+type isDecodeRequest_Payload interface {
+	isDecodeRequest_Payload()
+}
+
+type DecodeRequest_Options struct {
+	Options *DecodeOptions `protobuf:"bytes,1,opt,name=options,proto3,oneof"`
+}
+
+type DecodeRequest_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*DecodeRequest_Options) isDecodeRequest_Payload() {}
+
+func (*DecodeRequest_Data) isDecodeRequest_Payload() {}
+
+// StreamInfo 在输出数据之前返回
+type StreamInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Channels      int32                  `protobuf:"varint,1,opt,name=channels,proto3" json:"channels,omitempty"`
+	SampleRate    int32                  `protobuf:"varint,2,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	Samples       int64                  `protobuf:"varint,3,opt,name=samples,proto3" json:"samples,omitempty"` // 每个通道的样本数 (不展开循环)
+	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`       // 预计输出的字节数, 数据块被丢弃时实际输出会更短
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamInfo) Reset() {
+	*x = StreamInfo{}
+	mi := &file_hcad_v1_hcad_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamInfo) ProtoMessage() {}
+
+func (x *StreamInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_hcad_v1_hcad_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamInfo.ProtoReflect.Descriptor instead.
+func (*StreamInfo) Descriptor() ([]byte, []int) {
+	return file_hcad_v1_hcad_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StreamInfo) GetChannels() int32 {
+	if x != nil {
+		return x.Channels
+	}
+	return 0
+}
+
+func (x *StreamInfo) GetSampleRate() int32 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *StreamInfo) GetSamples() int64 {
+	if x != nil {
+		return x.Samples
+	}
+	return 0
+}
+
+func (x *StreamInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type DecodeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*DecodeResponse_Info
+	//	*DecodeResponse_Data
+	Payload       isDecodeResponse_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecodeResponse) Reset() {
+	*x = DecodeResponse{}
+	mi := &file_hcad_v1_hcad_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeResponse) ProtoMessage() {}
+
+func (x *DecodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hcad_v1_hcad_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeResponse.ProtoReflect.Descriptor instead.
+func (*DecodeResponse) Descriptor() ([]byte, []int) {
+	return file_hcad_v1_hcad_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DecodeResponse) GetPayload() isDecodeResponse_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *DecodeResponse) GetInfo() *StreamInfo {
+	if x != nil {
+		if x, ok := x.Payload.(*DecodeResponse_Info); ok {
+			return x.Info
+		}
+	}
+	return nil
+}
+
+func (x *DecodeResponse) GetData() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*DecodeResponse_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+type isDecodeResponse_Payload interface {
+	isDecodeResponse_Payload()
+}
+
+type DecodeResponse_Info struct {
+	Info *StreamInfo `protobuf:"bytes,1,opt,name=info,proto3,oneof"`
+}
+
+type DecodeResponse_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*DecodeResponse_Info) isDecodeResponse_Payload() {}
+
+func (*DecodeResponse_Data) isDecodeResponse_Payload() {}
+
+type ListKeysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListKeysRequest) Reset() {
+	*x = ListKeysRequest{}
+	mi := &file_hcad_v1_hcad_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListKeysRequest) ProtoMessage() {}
+
+func (x *ListKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hcad_v1_hcad_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListKeysRequest.ProtoReflect.Descriptor instead.
+func (*ListKeysRequest) Descriptor() ([]byte, []int) {
+	return file_hcad_v1_hcad_proto_rawDescGZIP(), []int{4}
+}
+
+type ListKeysResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Names         []string               `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListKeysResponse) Reset() {
+	*x = ListKeysResponse{}
+	mi := &file_hcad_v1_hcad_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListKeysResponse) ProtoMessage() {}
+
+func (x *ListKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hcad_v1_hcad_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListKeysResponse.ProtoReflect.Descriptor instead.
+func (*ListKeysResponse) Descriptor() ([]byte, []int) {
+	return file_hcad_v1_hcad_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListKeysResponse) GetNames() []string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+var File_hcad_v1_hcad_proto protoreflect.FileDescriptor
+
+const file_hcad_v1_hcad_proto_rawDesc = "" +
+	"\n" +
+	"\x12hcad/v1/hcad.proto\x12\ahcad.v1\"\xa4\x02\n" +
+	"\rDecodeOptions\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1b\n" +
+	"\bkey_name\x18\x02 \x01(\tH\x00R\akeyName\x12\x12\n" +
+	"\x03key\x18\x03 \x01(\x04H\x00R\x03key\x12\x14\n" +
+	"\x04game\x18\x04 \x01(\tH\x00R\x04game\x12\x16\n" +
+	"\x06subkey\x18\x05 \x01(\rR\x06subkey\x12'\n" +
+	"\x06format\x18\x06 \x01(\x0e2\x0f.hcad.v1.FormatR\x06format\x12:\n" +
+	"\rsample_format\x18\a \x01(\x0e2\x15.hcad.v1.SampleFormatR\fsampleFormat\x12\x12\n" +
+	"\x04loop\x18\b \x01(\x05R\x04loop\x12\x16\n" +
+	"\x06volume\x18\t \x01(\x02R\x06volumeB\x0f\n" +
+	"\rkey_selection\"d\n" +
+	"\rDecodeRequest\x122\n" +
+	"\aoptions\x18\x01 \x01(\v2\x16.hcad.v1.DecodeOptionsH\x00R\aoptions\x12\x14\n" +
+	"\x04data\x18\x02 \x01(\fH\x00R\x04dataB\t\n" +
+	"\apayload\"w\n" +
+	"\n" +
+	"StreamInfo\x12\x1a\n" +
+	"\bchannels\x18\x01 \x01(\x05R\bchannels\x12\x1f\n" +
+	"\vsample_rate\x18\x02 \x01(\x05R\n" +
+	"sampleRate\x12\x18\n" +
+	"\asamples\x18\x03 \x01(\x03R\asamples\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\"\\\n" +
+	"\x0eDecodeResponse\x12)\n" +
+	"\x04info\x18\x01 \x01(\v2\x13.hcad.v1.StreamInfoH\x00R\x04info\x12\x14\n" +
+	"\x04data\x18\x02 \x01(\fH\x00R\x04dataB\t\n" +
+	"\apayload\"\x11\n" +
+	"\x0fListKeysRequest\"(\n" +
+	"\x10ListKeysResponse\x12\x14\n" +
+	"\x05names\x18\x01 \x03(\tR\x05names*(\n" +
+	"\x06Format\x12\x0e\n" +
+	"\n" +
+	"FORMAT_WAV\x10\x00\x12\x0e\n" +
+	"\n" +
+	"FORMAT_RAW\x10\x01*\x84\x01\n" +
+	"\fSampleFormat\x12\x15\n" +
+	"\x11SAMPLE_FORMAT_S16\x10\x00\x12\x19\n" +
+	"\x15SAMPLE_FORMAT_FLOAT32\x10\x01\x12\x14\n" +
+	"\x10SAMPLE_FORMAT_U8\x10\x02\x12\x15\n" +
+	"\x11SAMPLE_FORMAT_S24\x10\x03\x12\x15\n" +
+	"\x11SAMPLE_FORMAT_S32\x10\x042\x89\x01\n" +
+	"\aDecoder\x12=\n" +
+	"\x06Decode\x12\x16.hcad.v1.DecodeRequest\x1a\x17.hcad.v1.DecodeResponse(\x010\x01\x12?\n" +
+	"\bListKeys\x12\x18.hcad.v1.ListKeysRequest\x1a\x19.hcad.v1.ListKeysResponseB*Z(github.com/WJQSERVER/hca/cmd/hcad/hcadpbb\x06proto3"
+
+var (
+	file_hcad_v1_hcad_proto_rawDescOnce sync.Once
+	file_hcad_v1_hcad_proto_rawDescData []byte
+)
+
+func file_hcad_v1_hcad_proto_rawDescGZIP() []byte {
+	file_hcad_v1_hcad_proto_rawDescOnce.Do(func() {
+		file_hcad_v1_hcad_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_hcad_v1_hcad_proto_rawDesc), len(file_hcad_v1_hcad_proto_rawDesc)))
+	})
+	return file_hcad_v1_hcad_proto_rawDescData
+}
+
+var file_hcad_v1_hcad_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_hcad_v1_hcad_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_hcad_v1_hcad_proto_goTypes = []any{
+	(Format)(0),              // 0: hcad.v1.Format
+	(SampleFormat)(0),        // 1: hcad.v1.SampleFormat
+	(*DecodeOptions)(nil),    // 2: hcad.v1.DecodeOptions
+	(*DecodeRequest)(nil),    // 3: hcad.v1.DecodeRequest
+	(*StreamInfo)(nil),       // 4: hcad.v1.StreamInfo
+	(*DecodeResponse)(nil),   // 5: hcad.v1.DecodeResponse
+	(*ListKeysRequest)(nil),  // 6: hcad.v1.ListKeysRequest
+	(*ListKeysResponse)(nil), // 7: hcad.v1.ListKeysResponse
+}
+var file_hcad_v1_hcad_proto_depIdxs = []int32{
+	0, // 0: hcad.v1.DecodeOptions.format:type_name -> hcad.v1.Format
+	1, // 1: hcad.v1.DecodeOptions.sample_format:type_name -> hcad.v1.SampleFormat
+	2, // 2: hcad.v1.DecodeRequest.options:type_name -> hcad.v1.DecodeOptions
+	4, // 3: hcad.v1.DecodeResponse.info:type_name -> hcad.v1.StreamInfo
+	3, // 4: hcad.v1.Decoder.Decode:input_type -> hcad.v1.DecodeRequest
+	6, // 5: hcad.v1.Decoder.ListKeys:input_type -> hcad.v1.ListKeysRequest
+	5, // 6: hcad.v1.Decoder.Decode:output_type -> hcad.v1.DecodeResponse
+	7, // 7: hcad.v1.Decoder.ListKeys:output_type -> hcad.v1.ListKeysResponse
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_hcad_v1_hcad_proto_init() }
+func file_hcad_v1_hcad_proto_init() {
+	if File_hcad_v1_hcad_proto != nil {
+		return
+	}
+	file_hcad_v1_hcad_proto_msgTypes[0].OneofWrappers = []any{
+		(*DecodeOptions_KeyName)(nil),
+		(*DecodeOptions_Key)(nil),
+		(*DecodeOptions_Game)(nil),
+	}
+	file_hcad_v1_hcad_proto_msgTypes[1].OneofWrappers = []any{
+		(*DecodeRequest_Options)(nil),
+		(*DecodeRequest_Data)(nil),
+	}
+	file_hcad_v1_hcad_proto_msgTypes[3].OneofWrappers = []any{
+		(*DecodeResponse_Info)(nil),
+		(*DecodeResponse_Data)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_hcad_v1_hcad_proto_rawDesc), len(file_hcad_v1_hcad_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_hcad_v1_hcad_proto_goTypes,
+		DependencyIndexes: file_hcad_v1_hcad_proto_depIdxs,
+		EnumInfos:         file_hcad_v1_hcad_proto_enumTypes,
+		MessageInfos:      file_hcad_v1_hcad_proto_msgTypes,
+	}.Build()
+	File_hcad_v1_hcad_proto = out.File
+	file_hcad_v1_hcad_proto_goTypes = nil
+	file_hcad_v1_hcad_proto_depIdxs = nil
+}
@@ -0,0 +1,168 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: hcad/v1/hcad.proto
+
+// hcad 解码服务
+
+package hcadpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Decoder_Decode_FullMethodName   = "/hcad.v1.Decoder/Decode"
+	Decoder_ListKeys_FullMethodName = "/hcad.v1.Decoder/ListKeys"
+)
+
+// DecoderClient is the client API for Decoder service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Decoder decodes HCA files
+// Decoder 解码 HCA 文件
+type DecoderClient interface {
+	// Decode 第一条请求携带 DecodeOptions, 之后的请求携带 HCA 数据 (options 中设置 path 时不需要发送数据).
+	// 服务器先返回 StreamInfo, 然后分块返回解码后的 WAV 或 PCM 数据
+	Decode(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DecodeRequest, DecodeResponse], error)
+	// ListKeys 返回服务器管理的密钥名称 (不返回密钥本身)
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error)
+}
+
+type decoderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDecoderClient(cc grpc.ClientConnInterface) DecoderClient {
+	return &decoderClient{cc}
+}
+
+func (c *decoderClient) Decode(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DecodeRequest, DecodeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Decoder_ServiceDesc.Streams[0], Decoder_Decode_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DecodeRequest, DecodeResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Decoder_DecodeClient = grpc.BidiStreamingClient[DecodeRequest, DecodeResponse]
+
+func (c *decoderClient) ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListKeysResponse)
+	err := c.cc.Invoke(ctx, Decoder_ListKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DecoderServer is the server API for Decoder service.
+// All implementations must embed UnimplementedDecoderServer
+// for forward compatibility.
+//
+// Decoder decodes HCA files
+// Decoder 解码 HCA 文件
+type DecoderServer interface {
+	// Decode 第一条请求携带 DecodeOptions, 之后的请求携带 HCA 数据 (options 中设置 path 时不需要发送数据).
+	// 服务器先返回 StreamInfo, 然后分块返回解码后的 WAV 或 PCM 数据
+	Decode(grpc.BidiStreamingServer[DecodeRequest, DecodeResponse]) error
+	// ListKeys 返回服务器管理的密钥名称 (不返回密钥本身)
+	ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error)
+	mustEmbedUnimplementedDecoderServer()
+}
+
+// UnimplementedDecoderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDecoderServer struct{}
+
+func (UnimplementedDecoderServer) Decode(grpc.BidiStreamingServer[DecodeRequest, DecodeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Decode not implemented")
+}
+func (UnimplementedDecoderServer) ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedDecoderServer) mustEmbedUnimplementedDecoderServer() {}
+func (UnimplementedDecoderServer) testEmbeddedByValue()                 {}
+
+// UnsafeDecoderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DecoderServer will
+// result in compilation errors.
+type UnsafeDecoderServer interface {
+	mustEmbedUnimplementedDecoderServer()
+}
+
+func RegisterDecoderServer(s grpc.ServiceRegistrar, srv DecoderServer) {
+	// If the following call pancis, it indicates UnimplementedDecoderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Decoder_ServiceDesc, srv)
+}
+
+func _Decoder_Decode_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DecoderServer).Decode(&grpc.GenericServerStream[DecodeRequest, DecodeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Decoder_DecodeServer = grpc.BidiStreamingServer[DecodeRequest, DecodeResponse]
+
+func _Decoder_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DecoderServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Decoder_ListKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DecoderServer).ListKeys(ctx, req.(*ListKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Decoder_ServiceDesc is the grpc.ServiceDesc for Decoder service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Decoder_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hcad.v1.Decoder",
+	HandlerType: (*DecoderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListKeys",
+			Handler:    _Decoder_ListKeys_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Decode",
+			Handler:       _Decoder_Decode_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "hcad/v1/hcad.proto",
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadKeys 读取密钥文件: 每行 "名称 密钥" 或 "名称 = 密钥", 密钥为十进制或 0x 十六进制, # 开头为注释
+func loadKeys(path string) (map[string]uint64, error) {
+	keys := map[string]uint64{}
+	if path == "" {
+		return keys, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.Replace(s, "=", " ", 1))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: 需要 \"名称 密钥\"", path, line)
+		}
+		key, err := strconv.ParseUint(fields[1], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: 无效的密钥 %q", path, line, fields[1])
+		}
+		keys[fields[0]] = key
+	}
+	return keys, sc.Err()
+}
+
+// keyNames 返回排序后的密钥名称
+func keyNames(keys map[string]uint64) []string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,59 @@
+// Command hcad runs a gRPC service decoding HCA files.
+// hcad 运行 HCA 解码的 gRPC 服务, 供团队共享解码后端使用. 接口定义见 proto/hcad/v1/hcad.proto
+package main
+
+//go:generate buf generate
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/WJQSERVER/hca/cmd/hcad/hcadpb"
+	"github.com/WJQSERVER/hca/hcametrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":7420", "监听地址")
+	root := flag.String("root", "", "允许通过 path 引用的文件目录, 为空时只能发送数据")
+	keysFile := flag.String("keys", "", "密钥文件, 每行 \"名称 密钥\"")
+	allowRawKey := flag.Bool("allow-raw-key", true, "允许请求直接指定 64 位密钥或游戏名称")
+	concurrent := flag.Int("max-concurrent", 4, "同时进行的解码数量, 超出时排队等待")
+	maxInput := flag.Int64("max-input", 512<<20, "客户端发送的 HCA 数据的大小上限 (字节)")
+	metricsAddr := flag.String("metrics", "", "Prometheus 指标的监听地址 (例如 :9420), 为空时不导出")
+	flag.Parse()
+
+	keys, err := loadKeys(*keysFile)
+	if err != nil {
+		log.Fatalf("读取密钥文件失败: %v", err)
+	}
+	if *concurrent < 1 {
+		log.Fatal("-max-concurrent 必须大于 0")
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var metrics *hcametrics.Collector
+	if *metricsAddr != "" {
+		metrics = hcametrics.New("hcad")
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(metrics)
+		go func() {
+			log.Printf("指标监听 %s/metrics", *metricsAddr)
+			log.Fatal(http.ListenAndServe(*metricsAddr, promhttp.HandlerFor(reg, promhttp.HandlerOpts{})))
+		}()
+	}
+
+	s := grpc.NewServer()
+	hcadpb.RegisterDecoderServer(s, newServer(*root, keys, *allowRawKey, *concurrent, *maxInput, metrics))
+	log.Printf("hcad 监听 %s", lis.Addr())
+	if err := s.Serve(lis); err != nil {
+		log.Fatal(err)
+	}
+}
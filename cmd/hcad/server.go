@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/WJQSERVER/hca"
+	"github.com/WJQSERVER/hca/cmd/hcad/hcadpb"
+	"github.com/WJQSERVER/hca/hcametrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chunkSize 是每条响应携带的最大数据量
+const chunkSize = 64 << 10
+
+type server struct {
+	hcadpb.UnimplementedDecoderServer
+
+	root        fs.FS // 为 nil 时不允许 path
+	keys        map[string]uint64
+	allowRawKey bool
+	slots       chan struct{} // 限制同时进行的解码数量
+	maxInput    int64
+	metrics     *hcametrics.Collector // 为 nil 时不统计
+}
+
+func newServer(root string, keys map[string]uint64, allowRawKey bool, concurrent int, maxInput int64, metrics *hcametrics.Collector) *server {
+	s := &server{keys: keys, allowRawKey: allowRawKey, slots: make(chan struct{}, concurrent), maxInput: maxInput, metrics: metrics}
+	if root != "" {
+		s.root = os.DirFS(root)
+	}
+	return s
+}
+
+func (s *server) ListKeys(context.Context, *hcadpb.ListKeysRequest) (*hcadpb.ListKeysResponse, error) {
+	return &hcadpb.ListKeysResponse{Names: keyNames(s.keys)}, nil
+}
+
+func (s *server) Decode(stream grpc.BidiStreamingServer[hcadpb.DecodeRequest, hcadpb.DecodeResponse]) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	opts := req.GetOptions()
+	if opts == nil {
+		return status.Error(codes.InvalidArgument, "first message must carry options")
+	}
+	d, err := s.decoder(opts)
+	if err != nil {
+		return err
+	}
+
+	// 排队等待空闲的解码槽
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-stream.Context().Done():
+		return status.FromContextError(stream.Context().Err()).Err()
+	}
+
+	start := time.Now()
+	w := &chunkWriter{stream: stream}
+	if opts.GetPath() != "" {
+		err = s.decodePath(d, opts.GetPath(), w)
+	} else {
+		err = s.decodeStream(d, &recvReader{stream: stream, limit: s.maxInput}, w)
+	}
+	if err == nil {
+		err = w.flush()
+	}
+	if s.metrics != nil {
+		var audio time.Duration
+		if rate := d.Info().SamplingRate; rate > 0 {
+			audio = time.Duration(d.Stats().Samples) * time.Second / time.Duration(rate)
+		}
+		s.metrics.Observe(audio, w.total, time.Since(start), err)
+	}
+	return toStatus(err)
+}
+
+// decoder 按照请求的选项创建解码器
+func (s *server) decoder(opts *hcadpb.DecodeOptions) (*hca.Hca, error) {
+	d := hca.NewDecoder()
+	d.Limits = hca.UntrustedLimits // 客户端发送的文件不可信
+	switch k := opts.GetKeySelection().(type) {
+	case *hcadpb.DecodeOptions_KeyName:
+		key, ok := s.keys[k.KeyName]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "unknown key name %q", k.KeyName)
+		}
+		d.SetKey(key)
+	case *hcadpb.DecodeOptions_Key:
+		if !s.allowRawKey {
+			return nil, status.Error(codes.PermissionDenied, "raw keys are disabled, use key_name")
+		}
+		d.SetKey(k.Key)
+	case *hcadpb.DecodeOptions_Game:
+		if !s.allowRawKey {
+			return nil, status.Error(codes.PermissionDenied, "raw keys are disabled, use key_name")
+		}
+		games := hca.FindKeys(k.Game)
+		if len(games) != 1 {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown or ambiguous game %q", k.Game)
+		}
+		d.SetKey(games[0].Key)
+	}
+	if opts.GetSubkey() > 0xFFFF {
+		return nil, status.Errorf(codes.InvalidArgument, "subkey %d out of range", opts.GetSubkey())
+	}
+	d.Subkey = uint16(opts.GetSubkey())
+
+	switch opts.GetSampleFormat() {
+	case hcadpb.SampleFormat_SAMPLE_FORMAT_S16:
+		d.Mode = hca.Mode16Bit
+	case hcadpb.SampleFormat_SAMPLE_FORMAT_FLOAT32:
+		d.Mode = hca.ModeFloat
+	case hcadpb.SampleFormat_SAMPLE_FORMAT_U8:
+		d.Mode = hca.Mode8Bit
+	case hcadpb.SampleFormat_SAMPLE_FORMAT_S24:
+		d.Mode = hca.Mode24Bit
+	case hcadpb.SampleFormat_SAMPLE_FORMAT_S32:
+		d.Mode = hca.Mode32Bit
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown sample format %v", opts.GetSampleFormat())
+	}
+	d.Headerless = opts.GetFormat() == hcadpb.Format_FORMAT_RAW
+	d.Loop = int(opts.GetLoop())
+	if opts.GetVolume() != 0 {
+		d.Volume = opts.GetVolume()
+	}
+	return d, nil
+}
+
+// decodePath 解码 -root 目录中的文件
+func (s *server) decodePath(d *hca.Hca, path string, w *chunkWriter) error {
+	if s.root == nil {
+		return status.Error(codes.PermissionDenied, "path references are disabled (no -root)")
+	}
+	if !fs.ValidPath(path) {
+		return status.Errorf(codes.InvalidArgument, "invalid path %q", path)
+	}
+	f, err := s.root.Open(path)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "%s: %v", path, err)
+	}
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "%s is not a regular file", path)
+	}
+	if err := s.sendInfo(d, rs, w); err != nil {
+		return err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return d.DecodeWithWriter(rs, w)
+}
+
+// decodeStream 解码客户端发送的数据, 头部读取后立即开始输出
+func (s *server) decodeStream(d *hca.Hca, r io.Reader, w *chunkWriter) error {
+	var header bytes.Buffer // 读取头部时消耗的数据, 解码时重新放回数据流的开头
+	if err := s.sendInfo(d, io.TeeReader(r, &header), w); err != nil {
+		return err
+	}
+	return d.DecodeStream(io.MultiReader(&header, r), w)
+}
+
+// sendInfo 读取头部并返回 StreamInfo
+func (s *server) sendInfo(d *hca.Hca, r io.Reader, w *chunkWriter) error {
+	var header bytes.Buffer
+	info, err := d.Probe(io.TeeReader(r, &header))
+	if err != nil {
+		return err
+	}
+	size, err := d.PredictOutputSize(&header)
+	if err != nil {
+		return err
+	}
+	return w.stream.Send(&hcadpb.DecodeResponse{Payload: &hcadpb.DecodeResponse_Info{Info: &hcadpb.StreamInfo{
+		Channels:   int32(info.Channels),
+		SampleRate: int32(info.SamplingRate),
+		Samples:    info.Samples,
+		Size:       size,
+	}}})
+}
+
+// toStatus 将解码错误转换为 gRPC 状态
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, errInputTooLarge):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, hca.ErrWrongKey):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, hca.ErrInvalidHeader), errors.Is(err, hca.ErrInvalidOption),
+		errors.Is(err, hca.ErrTruncated), errors.Is(err, hca.ErrOutputTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, hca.ErrChecksumMismatch), errors.Is(err, hca.ErrInvalidBlockMagic),
+		errors.Is(err, hca.ErrInvalidBlockData), errors.Is(err, hca.ErrInvalidRVA):
+		return status.Error(codes.DataLoss, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
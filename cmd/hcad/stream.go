@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/WJQSERVER/hca/cmd/hcad/hcadpb"
+	"google.golang.org/grpc"
+)
+
+var errInputTooLarge = errors.New("input exceeds -max-input")
+
+// recvReader 将客户端发送的 data 消息作为 io.Reader 读取
+type recvReader struct {
+	stream grpc.BidiStreamingServer[hcadpb.DecodeRequest, hcadpb.DecodeResponse]
+	buf    []byte
+	read   int64
+	limit  int64
+}
+
+func (r *recvReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err // 客户端结束发送时为 io.EOF
+		}
+		if req.GetOptions() != nil {
+			return 0, errors.New("options may only be sent once")
+		}
+		r.buf = req.GetData()
+		r.read += int64(len(r.buf))
+		if r.read > r.limit {
+			return 0, errInputTooLarge
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// chunkWriter 将解码输出分块发送给客户端
+type chunkWriter struct {
+	stream grpc.BidiStreamingServer[hcadpb.DecodeRequest, hcadpb.DecodeResponse]
+	buf    []byte
+	total  int64 // 已写入的字节数
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	w.total += int64(len(p))
+	if len(w.buf) >= chunkSize {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush 发送缓冲中的数据
+func (w *chunkWriter) flush() error {
+	for len(w.buf) > 0 {
+		n := min(len(w.buf), chunkSize)
+		data := make([]byte, n) // Send 返回后消息可能仍在使用, 不能复用缓冲
+		copy(data, w.buf)
+		if err := w.stream.Send(&hcadpb.DecodeResponse{Payload: &hcadpb.DecodeResponse_Data{Data: data}}); err != nil {
+			return err
+		}
+		w.buf = w.buf[n:]
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
@@ -0,0 +1,50 @@
+package hca
+
+// crossfadeFrames 返回实际使用的交叉淡化长度: 不超过循环开始之前的样本帧数与循环区间的长度,
+// 没有展开循环时为 0
+func (h *Hca) crossfadeFrames() int64 {
+	if h.Loop == 0 || h.LoopCrossfade <= 0 {
+		return 0
+	}
+	start, end := h.loopRange()
+	return min(h.LoopCrossfade, int64(start)*samplesPerBlock, int64(end-start)*samplesPerBlock)
+}
+
+// crossfade 在每次从循环结束跳回循环开始之前, 将最后 LoopCrossfade 个样本帧与循环开始之前的样本帧交叉淡化,
+// 使跳转后的循环开始与淡化的结尾连续. 输出的长度不变. serial 是从输出位置 pos 开始的一个块 (修改 serial)
+func (h *Hca) crossfade(serial []float32, pos int64) {
+	c := h.crossfadeFrames()
+	if c == 0 {
+		return
+	}
+	channels := int64(h.outputChannels())
+	n := int64(len(serial)) / channels
+	start, end := h.loopRange()
+	loopStart := int64(start) * samplesPerBlock
+	loopLen := int64(end-start) * samplesPerBlock
+
+	// 第一次经过时记录循环开始之前的 c 个样本帧 (此时输出位置与文件中的位置相同)
+	if lo, hi := max(loopStart-c, pos), min(loopStart, pos+n); lo < hi {
+		if h.xfade == nil {
+			h.xfade = make([]float32, c*channels)
+		}
+		copy(h.xfade[(lo-loopStart+c)*channels:], serial[(lo-pos)*channels:(hi-pos)*channels])
+	}
+
+	first := int64(end)*samplesPerBlock - c // 第一个交叉淡化区间的开始
+	for i := int64(0); i < n; i++ {
+		rel := pos + i - first
+		if rel < 0 {
+			continue
+		}
+		k := rel % loopLen // 在交叉淡化区间内的位置
+		if rel/loopLen >= int64(h.Loop) || k >= c {
+			continue
+		}
+		w := (float32(k) + 0.5) / float32(c) // 循环开始之前的部分逐渐淡入
+		frame := serial[i*channels : (i+1)*channels]
+		for ch := range frame {
+			frame[ch] = frame[ch]*(1-w) + h.xfade[k*channels+int64(ch)]*w
+		}
+	}
+}
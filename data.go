@@ -2,6 +2,8 @@ package hca
 
 // Data
 
+var bitMask = [8]int{0xFFFFFF, 0x7FFFFF, 0x3FFFFF, 0x1FFFFF, 0x0FFFFF, 0x07FFFF, 0x03FFFF, 0x01FFFF}
+
 type clData struct {
 	data []byte
 	size int
@@ -9,36 +11,42 @@ type clData struct {
 }
 
 func (d *clData) Init(data []byte, size int) {
+	if size > len(data) {
+		size = len(data)
+	}
 	d.data = data
 	d.size = size*8 - 16
+	if d.size < 0 {
+		d.size = 0
+	}
 	d.bit = 0
 }
 
+// CheckBit return next bitSize bits without moving the cursor,
+// reads outside the block (or of invalid size) return zero
 func (d *clData) CheckBit(bitSize int) int {
-	v := 0
-	if (d.bit + bitSize) <= d.size {
-		mask := []int{0xFFFFFF, 0x7FFFFF, 0x3FFFFF, 0x1FFFFF, 0x0FFFFF, 0x07FFFF, 0x03FFFF, 0x01FFFF}
-		idx := d.bit >> 3
-		var data []byte
-		if len(d.data) < (idx + 3) {
-			data = d.data[idx:len(d.data)]
-
-			for len(data) < 3 {
-				data = append(data, 0)
-			}
-		} else {
-			data = d.data[idx : idx+3]
-		}
-
-		v = int(data[0])
-		v = (v << 8) | int(data[1])
-		v = (v << 8) | int(data[2])
-		v &= mask[d.bit&7]
-		v >>= uint(24 - (d.bit & 7) - bitSize)
+	if bitSize <= 0 || d.bit < 0 || bitSize > 24-(d.bit&7) {
+		return 0
+	}
+	if (d.bit + bitSize) > d.size {
+		return 0
 	}
+	idx := d.bit >> 3
+	v := int(d.byteAt(idx))
+	v = (v << 8) | int(d.byteAt(idx+1))
+	v = (v << 8) | int(d.byteAt(idx+2))
+	v &= bitMask[d.bit&7]
+	v >>= uint(24 - (d.bit & 7) - bitSize)
 	return v
 }
 
+func (d *clData) byteAt(i int) byte {
+	if i < len(d.data) {
+		return d.data[i]
+	}
+	return 0
+}
+
 func (d *clData) GetBit(bitSize int) int {
 	v := d.CheckBit(bitSize)
 	d.AddBit(bitSize)
@@ -5,157 +5,224 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"os"
-
-	"github.com/vazrupe/endibuf"
+	"time"
 )
 
-// DecodeFromFile is file decode, return decode success/failed
-// DecodeFromFile 是文件解码函数，返回解码成功/失败
+// NeoDecodeFromFile is file decode, return decode success/failed
+// NeoDecodeFromFile 与 DecodeFromFile 相同, 为兼容保留
+//
+// Deprecated: 使用 DecodeFromFile 或 DecodeFile
 func (h *Hca) NeoDecodeFromFile(src, dst string) bool {
-	f, err := os.Open(src) // 打开源 HCA 文件
-	if err != nil {        // 如果打开文件失败
-		return false // 返回 false
-	}
-	defer f.Close()                   // 确保文件关闭
-	r := endibuf.NewReader(f)         // 创建一个 endibuf.Reader 来读取文件
-	fileWriter, err := os.Create(dst) // 创建目标 WAV 文件
-	if err != nil {                   // 如果创建文件失败
-		return false // 返回 false
-	}
-
-	success := h.neoDecodeBuffer(r, fileWriter) // 调用 decodeBuffer 进行解码
-
-	fileWriter.Close() // 关闭目标文件
-	if !success {      // 如果解码失败
-		os.Remove(dst) // 删除不完整或错误的输出文件
-		return false   // 返回 false
-	}
-
-	return true // 解码成功返回 true
+	return h.DecodeFromFile(src, dst)
 }
 
+// Decoder is stream decode, return WAV stream
+// Decoder 返回解码 reader 得到的 WAV 数据流. 返回值是 *Stream, 使用完毕后应调用其 Close (例如 r.(io.Closer).Close());
+// 它同时实现 io.WriterTo, 用 io.Copy 写出时不经过后台 goroutine 与管道
 func (h *Hca) Decoder(reader io.Reader) (io.Reader, error) {
 	// 调用DecodeWithWriter, 并使用pipe连接
-	reader, ok := reader.(io.ReadSeeker)
+	rs, ok := reader.(io.ReadSeeker)
 	if !ok {
 		return nil, fmt.Errorf("reader is not a ReadSeeker")
 	}
-	pr, pw := io.Pipe()
-	go func() {
-		defer pw.Close()
-		if err := h.DecodeWithWriter(reader.(io.ReadSeeker), pw); err != nil {
-			fmt.Printf("decode error: %v\n", err)
-		}
-	}()
-	return pr, nil
+	return newStream(func(w io.Writer) error {
+		return h.DecodeWithWriter(rs, w)
+	}), nil
 }
 
+// DecodeWithWriter is decode from r and write to w
+// DecodeWithWriter 从 r 解码 HCA 数据并写入 w, 其他解码函数都基于它实现.
+// w 可以 Seek 时 (例如文件), 实际写出的数据与预计不一致 (丢弃块或截断) 时修正 WAV 头部
 func (h *Hca) DecodeWithWriter(r io.ReadSeeker, w io.Writer) error {
-	endibufReader := endibuf.NewReader(r)
-	//endibufWriter := endibuf.NewWriter(w)
-	//success := h.decodeBuffer(endibufReader, endibufWriter)
-	success := h.neoDecodeBuffer(endibufReader, w)
-	if !success {
-		return fmt.Errorf("decode failed")
-	}
-
-	return nil // 解码成功返回 nil 错误
+	return h.decodeBuffer(r, w, h.Format)
 }
 
-// decodeBuffer 从 endibuf.Reader 中解码 HCA 数据并写入 endibuf.Writer
-func (h *Hca) neoDecodeBuffer(r *endibuf.Reader, w io.Writer) bool {
-	saveEndian := r.Endian // 保存当前的读取字节序设置
+// DecodeStream is decode from reader which may not support seeking (e.g. stdin)
+// DecodeStream 从不一定支持 Seek 的 reader (例如标准输入的管道) 解码 HCA 数据并写入 w.
+// 不能 Seek 时顺序读取数据, 展开循环 (Loop > 0) 时需要在内存中保留全部数据块
+func (h *Hca) DecodeStream(r io.Reader, w io.Writer) error {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if _, err := rs.Seek(0, io.SeekCurrent); err == nil { // 可以 Seek (管道的 *os.File 会返回错误)
+			return h.DecodeWithWriter(rs, w)
+		}
+	}
+	limit := 0x10000 + keyCheckBlocks*0x10000 // 头部与检查密钥时读取的块
+	if h.Loop > 0 || h.TargetLoudness != 0 {
+		limit = -1 // 循环需要回到循环开始块, 响度归一化需要从头再解码一遍
+	}
+	return h.DecodeWithWriter(newSeqReader(r, limit), w)
+}
 
-	r.Endian = binary.BigEndian // 将读取字节序设置为大端序
+// decodeBuffer 从 r 中解码 HCA 数据并以 format 格式 (见 Hca.Format) 写入 w
+func (h *Hca) decodeBuffer(r io.ReadSeeker, w io.Writer, format string) (err error) {
+	if h.closed { // 解码器已关闭
+		return ErrClosed
+	}
 
 	// size check
 	// 大小检查
-	if h.Loop < 0 { // 检查循环次数是否有效
-		return false // 无效返回 false
-	}
-	switch h.Mode { // 检查写入模式是否有效
-	case ModeFloat, Mode8Bit, Mode16Bit, Mode24Bit, Mode32Bit:
-		break // 有效模式，继续
-	default:
-		return false // 无效模式返回 false
+	if err := h.checkOptions(); err != nil { // 检查循环次数与写入模式是否有效
+		return err
 	}
 
+	h.fileState = fileState{} // 重置上一次解码留下的文件状态与统计信息
+	start := time.Now()
+	bufSize := h.writeBufferSize(w)
+	w, counter := newOutputCounter(w) // 统计写出的字节数
+	defer func() { h.finishStats(start, counter.written(), err) }()
+	w, flush := bufferOutput(w, bufSize) // 合并每个块的写出, 返回之前 (在统计之前) 写出缓冲中的全部数据
+	defer func() {
+		if ferr := flush(); ferr != nil && (err == nil || h.recovered(err)) {
+			err = ferr
+		}
+	}()
+
 	// header read
 	// 读取头部
-	if !h.loadHeader(r) { // 读取 HCA 头部信息
-		return false // 读取失败返回 false
+	if err := h.loadHeader(r); err != nil { // 读取 HCA 头部信息
+		return err // 读取失败
+	}
+	if err := h.checkStream(); err != nil { // 检查数据块设置
+		return err
+	}
+	if err := h.checkChannelMap(); err != nil { // 检查通道映射
+		return err
+	}
+	if err := h.checkKey(r); err != nil { // 检查密钥是否能解密开头的数据块
+		return err
+	}
+	if h.TargetLoudness != 0 { // 先测量响度
+		if err := h.normalize(r); err != nil {
+			return err
+		}
+	}
+	if _, err := r.Seek(int64(h.dataOffset), io.SeekStart); err != nil { // 将读取位置移动到数据开始处
+		return err
 	}
-	r.Seek(int64(h.dataOffset), 0) // 将读取位置移动到数据开始处
-
-	// create temp file (write)
-	// 创建临时文件（用于写入，此行注释可能重复或指代 W 的初始化）
 
-	wavHeader := h.buildWaveHeader()           // 构建 WAV 头部信息
-	wavHeader.NeoWrite(w, binary.LittleEndian) // 将 WAV 头部写入 Writer
+	wavHeader, err := h.buildWaveHeader() // 构建 WAV 头部信息
+	if err != nil {
+		return err
+	}
+	sink, err := h.openSink(w, format) // 注册的输出格式代替 WAV 头部与 PCM 数据
+	if err != nil {
+		return err
+	}
+	if sink == nil && !h.Headerless {
+		if err := wavHeader.Write(w); err != nil { // 将 WAV 头部写入 Writer
+			return err
+		}
+	}
+	h.sink = sink
+	h.resampler = h.newResampler()
 
 	// adjust the relative volume
 	// 调整相对音量
-	h.rvaVolume *= h.Volume // 将 RVA 音量与用户指定的音量相乘
+	h.gain = h.rvaVolume * h.volume() // 将 RVA 音量与用户指定的音量相乘 (不修改 rvaVolume, 避免重复解码时累积)
+	if h.unityGain {                  // 增益交给调用方应用 (ExportBundle)
+		h.gain = 1
+	}
+	h.stats.Gain = h.gain
 
 	// decode
 	// 解码
-	if h.Loop == 0 { // 如果没有设置循环次数
-		if !h.neoDecodeFromBytesDecode(r, w, h.dataOffset, h.blockCount) { // 解码从数据开始到总块数
-			return false // 解码失败返回 false
+	decodeRange := func(address int64, count uint32) error {
+		return h.decodeBlocks(r, w, address, count)
+	}
+	if h.ReadAhead && !lowMemory { // 由一个后台 goroutine 按相同的顺序预读所有的块
+		ra := h.startReadAhead(r)
+		decodeRange = func(address int64, count uint32) error {
+			return h.decodeBlocksAhead(ra, w, address, count)
 		}
-	} else { // 如果设置了循环次数
-		loopBlockOffset := h.dataOffset + h.loopStart*h.blockSize       // 计算循环开始块的偏移量
-		loopBlockCount := h.loopEnd - h.loopStart                       // 计算循环块的数量
-		if !h.neoDecodeFromBytesDecode(r, w, h.dataOffset, h.loopEnd) { // 解码从数据开始到循环结束块
-			return false // 解码失败返回 false
+		defer ra.stop()
+	}
+	err = h.decodeLoop(decodeRange)
+	partial := h.partialError(err)
+	if partial != nil {
+		err = partial
+	}
+	if h.resampler != nil && (err == nil || h.recovered(err)) { // 写出重采样器中剩余的样本
+		if ferr := h.emit(h.resampler.flush(), w); ferr != nil {
+			return h.partialError(ferr)
 		}
-		for i := 1; i < h.Loop; i++ { // 循环指定次数
-			if !h.neoDecodeFromBytesDecode(r, w, loopBlockOffset, loopBlockCount) { // 解码循环部分的块
-				return false // 解码失败返回 false
-			}
+		if partial != nil {
+			partial.Samples = h.stats.Samples
 		}
-		if !h.neoDecodeFromBytesDecode(r, w, loopBlockOffset, h.blockCount-h.loopStart) { // 解码从循环开始块到总块数（这部分处理剩余的尾部数据）
-			return false // 解码失败返回 false
+	}
+	if sink != nil {
+		if cerr := sink.Close(); cerr != nil && (err == nil || h.recovered(err)) {
+			return cerr
 		}
+		return err
+	}
+	if err != nil && !h.recovered(err) {
+		return err
 	}
 
-	r.Endian = saveEndian // 恢复原始的读取字节序设置
-
-	return true // 解码成功返回 true
+	// 实际写出的数据与头部不一致时 (丢弃块或截断) 修正头部
+	if perr := h.patchWaveHeader(wavHeader, w); perr != nil {
+		return perr
+	}
+	return err
 }
 
-// decodeFromBytesDecode 从 endibuf.Reader 读取指定数量的块，解码并写入 endibuf.Writer
-func (h *Hca) neoDecodeFromBytesDecode(r *endibuf.Reader, w io.Writer, address, count uint32) bool {
-	r.Seek(int64(address), 0)            // 将读取位置移动到指定的地址
+// decodeBlocks 从 r 的 address 处读取 count 个块，解码并写入 w
+func (h *Hca) decodeBlocks(r io.ReadSeeker, w io.Writer, address int64, count uint32) error {
+	if _, err := r.Seek(address, io.SeekStart); err != nil { // 将读取位置移动到指定的地址
+		return h.blockError(address, err)
+	}
 	for l := uint32(0); l < count; l++ { // 循环指定数量的块
-		data, _ := r.ReadBytes(int(h.blockSize)) // 读取一个块的数据
-		if !h.decode(data) {                     // 解码当前块
-			return false // 解码失败返回 false
+		data, err := h.readBlock(r) // 读取一个块的数据
+		if err != nil {
+			return h.blockError(address, err) // 附带块索引与偏移量
 		}
-		saveBlock := h.decoder.waveSerialize(h.rvaVolume) // 将解码后的波形数据序列化
-		h.neoSave(saveBlock, w, binary.LittleEndian)      // 保存波形数据到 Writer
+		emit, err := h.decode(data, address) // 解码当前块
+		if err != nil {
+			return err // 解码失败
+		}
+		if emit { // 被丢弃的块不写出
+			if err := h.writeBlock(w, address); err != nil {
+				return err
+			}
+		}
+
+		address += int64(h.blockSize) // 更新地址到下一个块的开始处
+	}
+	return nil // 所有块解码成功
+}
+
+// writeBlock 写出刚解码的位于 address 的块, 已写出输出范围内的全部样本时返回 errRangeDone
+func (h *Hca) writeBlock(w io.Writer, address int64) error {
+	saveBlock := h.window(h.decoder.waveSerialize(h.gain, h.ChannelMap, h.clampEarly())) // 将解码后的波形数据序列化, 只保留输出范围内的部分
+	if h.resampler != nil {
+		saveBlock = h.resampler.process(saveBlock)
+	}
+	if err := h.emit(saveBlock, w); err != nil { // 保存波形数据到 Writer
+		return h.blockError(address, err) // 写入失败 (例如数据流已关闭), 附带块索引与偏移量
+	}
+	h.stats.Blocks++
+	h.progress()
+	if h.rangeDone() {
+		return errRangeDone
+	}
+	return nil
+}
 
-		address += h.blockSize // 更新地址到下一个块的开始处
+// emit 写出交错样本并计入统计
+func (h *Hca) emit(samples []float32, w io.Writer) error {
+	if err := h.save(samples, w); err != nil {
+		return err
 	}
-	return true // 所有块解码成功返回 true
+	h.stats.Samples += int64(len(samples)) / int64(h.outputChannels())
+	return nil
 }
 
-// save 将浮点样本数据转换为指定模式并写入 endibuf.Writer
-func (h *Hca) neoSave(base []float32, w io.Writer, endian binary.ByteOrder) {
-	switch h.Mode { // 根据指定的模式进行转换和写入
-	case ModeFloat: // 浮点模式
-		WriteData(base, w, endian) // 直接写入浮点数据
-	case Mode8Bit: // 8 位模式
-		WriteData(mode8BitConvert(base), w, endian) // 转换为 8 位整型并写入
-	case Mode16Bit: // 16 位模式
-		WriteData(mode16BitConvert(base), w, endian) // 转换为 16 位整型并写入
-	case Mode24Bit: // 24 位模式
-		WriteData(mode24BitConvert(base), w, endian) // 转换为 24 位字节切片并写入
-
-	case Mode32Bit: // 32 位模式
-		WriteData(mode32BitConvert(base), w, endian) // 转换为 32 位整型并写入
+// save 将浮点样本数据按写入模式转换为小端序并写入 w, 设置了输出格式时交给 Sink
+func (h *Hca) save(base []float32, w io.Writer) error {
+	if h.sink != nil { // 注册的输出格式
+		return h.sink.WriteSamples(base)
 	}
+	return h.writeSamples(base, w, binary.LittleEndian) // 直接编码到复用的缓冲中, 不经过反射
 }
 
 func WriteData(data interface{}, w io.Writer, endian binary.ByteOrder) (err error) {
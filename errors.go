@@ -0,0 +1,73 @@
+package hca
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by decode functions
+// 解码函数返回的错误
+var (
+	ErrInvalidOption     = errors.New("hca: invalid decode option")         // 无效的解码选项
+	ErrInvalidHeader     = errors.New("hca: invalid header")                // 无效的 HCA 头部
+	ErrChecksumMismatch  = errors.New("hca: checksum mismatch")             // 数据块校验和错误
+	ErrInvalidBlockMagic = errors.New("hca: invalid block magic")           // 数据块魔术数字错误
+	ErrEmpty             = errors.New("hca: no audio blocks")               // 文件只有头部没有数据块
+	ErrTruncated         = errors.New("hca: data truncated")                // 数据在预期结束前截断
+	ErrClosed            = errors.New("hca: decoder closed")                // 解码器或数据流已关闭
+	ErrInvalidRVA        = errors.New("hca: invalid rva volume")            // rva 块的音量无效或过大
+	ErrInvalidBlockData  = errors.New("hca: invalid block data")            // 数据块内容无法解码
+	ErrWrongKey          = errors.New("hca: wrong decryption key")          // 密钥无法解密数据块
+	ErrOutputTooLarge    = errors.New("hca: output exceeds WAV size limit") // 输出超过 RIFF 的 4GB 限制
+	ErrKeyNotFound       = errors.New("hca: no matching key")               // 候选密钥都无法解密数据块
+	ErrInvalidACB        = errors.New("hca: invalid ACB file")              // ACB 文件或其中的 @UTF 表格式错误
+)
+
+// Header errors, all of them match ErrInvalidHeader with errors.Is
+// 头部错误的具体原因, 都可以用 errors.Is 与 ErrInvalidHeader 匹配
+var (
+	ErrInvalidSignature  = fmt.Errorf("%w: not an HCA file", ErrInvalidHeader)         // 签名不是 HCA
+	ErrUnsupportedCipher = fmt.Errorf("%w: unsupported cipher type", ErrInvalidHeader) // ciph 块的加密类型不是 0, 1 或 56
+	ErrInvalidLoopRange  = fmt.Errorf("%w: invalid loop range", ErrInvalidHeader)      // loop 块的循环范围超出数据块
+	ErrLimitExceeded     = fmt.Errorf("%w: exceeds limit", ErrInvalidHeader)           // 头部数值或预计的输出大小超过 Limits
+)
+
+// BlockError is error of a single data block
+// BlockError 是单个数据块的错误, 记录出错块的位置. 读取头部之后的错误 (块的读取, 校验, 解码, 密钥检查与写出)
+// 都以 BlockError 返回, 可以用 errors.As 取出位置, 用 errors.Is 匹配 Cause
+type BlockError struct {
+	Index      int   // 块索引
+	FileOffset int64 // 块在文件中的字节偏移量
+	BlockCount int   // 文件的总块数
+	Cause      error // 原始错误
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("hca: block %d/%d at offset %d: %v", e.Index, e.BlockCount, e.FileOffset, e.Cause)
+}
+
+// Unwrap return the cause error
+// Unwrap 返回原始错误
+func (e *BlockError) Unwrap() error {
+	return e.Cause
+}
+
+// PartialError is error of a decode aborted after output started
+// PartialError 是开始写出数据之后中止的解码返回的错误, 记录中止之前已写出的数据量.
+// 可以用 errors.Is 与 errors.As 匹配原始错误 (例如 ErrTruncated 与 *BlockError).
+// 设置 KeepPartial 或 RecoverTruncated (截断时) 时输出的 WAV 头部已按写出的数据修正
+type PartialError struct {
+	Blocks  int   // 已解码并输出的块数
+	Samples int64 // 已写出的样本帧数 (按输出的采样率)
+	Err     error // 原始错误
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%v (decoded %d blocks, %d samples)", e.Err, e.Blocks, e.Samples)
+}
+
+// Unwrap return the cause error
+// Unwrap 返回原始错误
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// completionShells 是 completion 子命令支持的 shell
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// runCompletion 处理 "completion <shell>" 子命令, 输出补全脚本
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: %s completion %s", programName(), strings.Join(completionShells, "|"))
+	}
+	c := newCompleter()
+	switch args[0] {
+	case "bash":
+		c.bash(os.Stdout)
+	case "zsh":
+		c.zsh(os.Stdout)
+	case "fish":
+		c.fish(os.Stdout)
+	case "powershell", "pwsh":
+		c.powershell(os.Stdout)
+	default:
+		return fmt.Errorf("不支持的 shell %q (可用: %s)", args[0], strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+// programName 返回补全脚本中使用的命令名称
+func programName() string {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+}
+
+// completer 保存生成补全脚本所需的选项信息
+type completer struct {
+	name   string
+	flags  []*flag.Flag
+	values map[string][]string // 选项可以取的值
+	games  []hca.GameKey
+}
+
+func newCompleter() *completer {
+	c := &completer{
+		name: programName(),
+		values: map[string][]string{
+			"f":         formatNames(), // 包括注册的输出格式
+			"name-from": {"comment", "cue"},
+			"m":         {"0", "8", "16", "24", "32"},
+			"rate":      {"44100", "48000"},
+			"resample":  {"linear", "sinc"},
+			"bad-block": {"strict", "skip", "mute", "repeat", "decode"},
+		},
+		games: hca.KnownKeys,
+	}
+	flag.VisitAll(func(f *flag.Flag) { c.flags = append(c.flags, f) })
+	for _, g := range c.games {
+		c.values["game"] = append(c.values["game"], gameWord(g))
+	}
+	sort.Strings(c.values["game"])
+	return c
+}
+
+// gameWord 返回补全游戏名称时使用的单词: 有简称时使用第一个简称
+func gameWord(g hca.GameKey) string {
+	if len(g.Aliases) > 0 {
+		return g.Aliases[0]
+	}
+	return strings.ReplaceAll(strings.ToLower(g.Name), " ", "")
+}
+
+// isBool 判断选项是否为布尔选项 (不需要值)
+func isBool(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// kind 返回选项值的补全方式: "dir", "file", "values" 或 "" (任意值)
+func (c *completer) kind(f *flag.Flag) string {
+	switch f.Name {
+	case "save":
+		return "dir"
+	case "o", "report", "config":
+		return "file"
+	}
+	if c.values[f.Name] != nil {
+		return "values"
+	}
+	return ""
+}
+
+func (c *completer) bash(w io.Writer) {
+	fn := "_" + strings.ReplaceAll(c.name, "-", "_")
+	var names []string
+	fmt.Fprintf(w, "# bash completion for %s\n%s() {\n", c.name, fn)
+	fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "\tif [[ $COMP_CWORD -eq 2 && $prev == completion ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n\t\treturn\n\tfi\n", strings.Join(completionShells, " "))
+	fmt.Fprintf(w, "\tcase \"$prev\" in\n")
+	for _, f := range c.flags {
+		names = append(names, "-"+f.Name)
+		if isBool(f) {
+			continue
+		}
+		var reply string
+		switch c.kind(f) {
+		case "dir":
+			reply = `COMPREPLY=($(compgen -d -- "$cur"))`
+		case "file":
+			reply = `COMPREPLY=($(compgen -f -- "$cur"))`
+		case "values":
+			reply = fmt.Sprintf(`COMPREPLY=($(compgen -W "%s" -- "$cur"))`, strings.Join(c.values[f.Name], " "))
+		default:
+			reply = "COMPREPLY=()"
+		}
+		fmt.Fprintf(w, "\t-%s|--%s)\n\t\t%s\n\t\treturn\n\t\t;;\n", f.Name, f.Name, reply)
+	}
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tif [[ $cur == -* ]]; then\n\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n\t\treturn\n\tfi\n", strings.Join(names, " "))
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -f -X '!*.hca' -- \"$cur\") $(compgen -d -- \"$cur\"))\n")
+	fmt.Fprintf(w, "\tif [[ $COMP_CWORD -eq 1 ]]; then\n\t\tCOMPREPLY+=($(compgen -W \"%s\" -- \"$cur\"))\n\tfi\n", strings.Join(subcommandNames(), " "))
+	fmt.Fprintf(w, "}\ncomplete -o filenames -F %s %s\n", fn, c.name)
+}
+
+// zshQuote 转义 _arguments 说明中的特殊字符
+func zshQuote(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func (c *completer) zsh(w io.Writer) {
+	fmt.Fprintf(w, "#compdef %s\n\n_%s() {\n", c.name, c.name)
+	fmt.Fprintf(w, "\tif (( CURRENT == 3 )) && [[ $words[2] == completion ]]; then\n\t\t_values shell %s\n\t\treturn\n\tfi\n", strings.Join(completionShells, " "))
+	fmt.Fprintf(w, "\t_arguments \\\n")
+	for _, f := range c.flags {
+		spec := fmt.Sprintf("-%s[%s]", f.Name, zshQuote(f.Usage))
+		if !isBool(f) {
+			switch c.kind(f) {
+			case "dir":
+				spec += ":directory:_files -/"
+			case "file":
+				spec += ":file:_files"
+			case "values":
+				if f.Name == "game" {
+					var games []string
+					for _, g := range c.games {
+						games = append(games, fmt.Sprintf(`%s\:%s`, gameWord(g), zshQuote(strings.ReplaceAll(g.Name, " ", `\ `))))
+					}
+					spec += ":game:((" + strings.Join(games, " ") + "))"
+				} else {
+					spec += fmt.Sprintf(":%s:(%s)", f.Name, strings.Join(c.values[f.Name], " "))
+				}
+			default:
+				spec += ":value: "
+			}
+		}
+		fmt.Fprintf(w, "\t\t'%s' \\\n", spec)
+	}
+	fmt.Fprintf(w, "\t\t'*:HCA file:_files -g \"*.hca\"'\n}\n\n_%s \"$@\"\n", c.name)
+}
+
+// fishQuote 转义 fish 单引号字符串
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func (c *completer) fish(w io.Writer) {
+	var games []string
+	fmt.Fprintf(w, "# fish completion for %s\n", c.name)
+	for _, cmd := range subcommands {
+		fmt.Fprintf(w, "complete -c %s -n '__fish_use_subcommand' -a %s -d %s\n", c.name, cmd.name, fishQuote(cmd.usage))
+	}
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from completion' -x -a %s\n", c.name, fishQuote(strings.Join(completionShells, " ")))
+	for _, f := range c.flags {
+		line := fmt.Sprintf("complete -c %s -o %s -d %s", c.name, f.Name, fishQuote(f.Usage))
+		if !isBool(f) {
+			switch c.kind(f) {
+			case "dir":
+				line += " -x -a '(__fish_complete_directories)'"
+			case "file":
+				line += " -r -F"
+			case "values":
+				if f.Name == "game" { // 每个游戏单独一行, 以游戏全名作为说明
+					line += " -x"
+					for _, g := range c.games {
+						games = append(games, fmt.Sprintf("complete -c %s -o game -x -a %s -d %s", c.name, fishQuote(gameWord(g)), fishQuote(g.Name)))
+					}
+				} else {
+					line += " -x -a " + fishQuote(strings.Join(c.values[f.Name], " "))
+				}
+			default:
+				line += " -x"
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+	for _, line := range games {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "complete -c %s -k -a '(__fish_complete_suffix .hca)'\n", c.name)
+}
+
+// psQuote 返回 PowerShell 单引号字符串
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (c *completer) powershell(w io.Writer) {
+	var names []string
+	fmt.Fprintf(w, "# PowerShell completion for %s\n", c.name)
+	fmt.Fprintf(w, "Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {\n", psQuote(c.name))
+	fmt.Fprintf(w, "    param($wordToComplete, $commandAst, $cursorPosition)\n")
+	fmt.Fprintf(w, "    $elements = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })\n")
+	fmt.Fprintf(w, "    $prev = if ($wordToComplete) { $elements[-2] } else { $elements[-1] }\n")
+	fmt.Fprintf(w, "    $values = switch ($prev) {\n")
+	fmt.Fprintf(w, "        'completion' { %s }\n", psList(completionShells))
+	for _, f := range c.flags {
+		names = append(names, "-"+f.Name)
+		if !isBool(f) && c.kind(f) == "values" {
+			fmt.Fprintf(w, "        '-%s' { %s }\n", f.Name, psList(c.values[f.Name]))
+		}
+	}
+	fmt.Fprintf(w, "        default { $null }\n    }\n")
+	fmt.Fprintf(w, "    if ($null -eq $values) {\n")
+	fmt.Fprintf(w, "        if ($wordToComplete -notlike '-*') { return } # 其余的参数使用路径补全\n")
+	fmt.Fprintf(w, "        $values = %s\n    }\n", psList(names))
+	fmt.Fprintf(w, "    $values | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	fmt.Fprintf(w, "        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)\n    }\n}\n")
+}
+
+func psList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = psQuote(v)
+	}
+	return "@(" + strings.Join(quoted, ", ") + ")"
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configAliases 配置文件中可以使用的易读名称, 其余的键直接使用选项名称 (例如 "m", "progress")
+var configAliases = map[string]string{
+	"key":         "k",
+	"output_dir":  "save",
+	"format":      "f",
+	"parallel":    "p",
+	"mode":        "m",
+	"volume":      "v",
+	"loop":        "l",
+	"recursive":   "r",
+	"sample_rate": "rate",
+	"ogg_quality": "ogg-quality",
+	"flac_level":  "flac-level",
+}
+
+// defaultConfigPath 返回默认的配置文件路径 (Linux 下为 ~/.config/hca/config.toml)
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "hca", "config.toml")
+}
+
+// applyConfig 读取配置文件, 将其中的值设为命令行没有指定的选项的默认值.
+// 未指定 -config 时读取默认路径, 默认路径的文件不存在时忽略
+func applyConfig(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if path == "" {
+		return nil
+	}
+	values, err := readConfig(path)
+	if err != nil {
+		if !explicit && os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	set := make(map[string]bool) // 命令行中指定的选项优先
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, kv := range values {
+		name := kv[0]
+		if alias, ok := configAliases[name]; ok {
+			name = alias
+		}
+		if flag.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("%s: 未知的配置项 %q", path, kv[0])
+		}
+		if set[name] {
+			continue
+		}
+		if err := flag.Set(name, kv[1]); err != nil {
+			return fmt.Errorf("%s: 配置项 %q: %v", path, kv[0], err)
+		}
+	}
+	return nil
+}
+
+// readConfig 读取 TOML 格式配置文件中的键值对. 只支持顶层的
+// key = value, 值可以是字符串, 数字或布尔值, # 开始的内容为注释
+func readConfig(path string) ([][2]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values [][2]string
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: 需要 key = value", path, n)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		v, err := parseConfigValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		values = append(values, [2]string{key, v})
+	}
+	return values, s.Err()
+}
+
+// parseConfigValue 解析一个 TOML 值并去除行尾注释
+func parseConfigValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`): // 基本字符串, 支持转义
+		end := 1
+		for ; end < len(v); end++ {
+			if v[end] == '\\' {
+				end++
+			} else if v[end] == '"' {
+				break
+			}
+		}
+		if end >= len(v) {
+			return "", fmt.Errorf("字符串没有结束")
+		}
+		if rest := strings.TrimSpace(v[end+1:]); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("字符串后有多余的内容 %q", rest)
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"): // 字面量字符串, 不处理转义
+		end := strings.IndexByte(v[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("字符串没有结束")
+		}
+		return v[1 : end+1], nil
+	}
+	if i := strings.IndexByte(v, '#'); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	if v == "" {
+		return "", fmt.Errorf("缺少值")
+	}
+	return strings.ReplaceAll(v, "_", ""), nil // TOML 数字可以使用 _ 分隔
+}
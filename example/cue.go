@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// cues 是 -acb 文件中的 cue 名称, 未指定时为 nil
+var cues *hca.ACB
+
+// loadCues 读取 -acb 指定的 ACB 文件
+func loadCues(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	acb, err := hca.ReadACB(f)
+	if err != nil {
+		return fmt.Errorf("读取 ACB 文件失败: %w", err)
+	}
+	cues = acb
+	return nil
+}
+
+// cueName 返回输入文件对应的 cue 名称. 从 AWB 中提取的文件按波形 ID 命名 (例如 bgm_00012.hca),
+// 取文件名末尾的数字作为 ID; 没有 -acb, 文件名不以数字结尾或 ACB 中没有这个 ID 时返回 false
+func cueName(inputPath string) (string, bool) {
+	if cues == nil {
+		return "", false
+	}
+	base := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	stem := strings.TrimRight(base, "0123456789")
+	if stem == base {
+		return "", false
+	}
+	id, err := strconv.Atoi(base[len(stem):])
+	if err != nil {
+		return "", false
+	}
+	name, ok := cues.Cues[id]
+	return name, ok && name != ""
+}
+
+// applyCueTags 以 cue 名称与 ACB 的名称填写命令行没有指定的标题与专辑标签
+func applyCueTags(decoder *hca.Hca, inputPath string) {
+	if name, ok := cueName(inputPath); ok && *titleFlag == "" {
+		decoder.Tags.Title = name
+	}
+	if cues != nil && *albumFlag == "" {
+		decoder.Tags.Album = cues.Name
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// players 是 -play 按顺序查找的播放器, 均从标准输入读取 WAV
+var players = [][]string{
+	{"ffplay", "-nodisp", "-autoexit", "-loglevel", "error", "-"},
+	{"mpv", "--no-video", "--really-quiet", "-"},
+	{"aplay", "-q", "-"},
+}
+
+// execMode 判断是否将解码结果交给外部命令, 而不是写入文件
+func execMode() bool {
+	return *execFlag != "" || *playFlag
+}
+
+// findPlayer 返回第一个可用的播放器命令
+func findPlayer() ([]string, error) {
+	for _, p := range players {
+		if _, err := exec.LookPath(p[0]); err == nil {
+			return p, nil
+		}
+	}
+	names := make([]string, len(players))
+	for i, p := range players {
+		names[i] = p[0]
+	}
+	return nil, fmt.Errorf("没有找到播放器 (%s), 请使用 -exec 指定命令", strings.Join(names, ", "))
+}
+
+// shellQuote 为 shell 命令转义参数
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// command 创建处理 inputPath 解码结果的外部命令: -play 使用播放器,
+// -exec 通过 shell 执行, 命令中的 {input} 替换为输入文件路径
+func command(inputPath string) (*exec.Cmd, error) {
+	if *playFlag {
+		p, err := findPlayer()
+		if err != nil {
+			return nil, err
+		}
+		return exec.Command(p[0], p[1:]...), nil
+	}
+	line := strings.ReplaceAll(*execFlag, "{input}", shellQuote(inputPath))
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line), nil
+	}
+	return exec.Command("sh", "-c", line), nil
+}
+
+// runExec 将 inputPath 解码后的数据写入外部命令的标准输入, 并等待命令结束
+func runExec(decoder *hca.Hca, inputPath string) error {
+	cmd, err := command(inputPath)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("无法启动命令: %w", err)
+	}
+
+	w := bufio.NewWriter(stdin)
+	decodeErr := decoder.DecodeWithWriter(f, w)
+	if decodeErr == nil {
+		decodeErr = w.Flush()
+	}
+	stdin.Close() // 通知命令输入结束
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("命令执行失败: %w", err)
+	}
+	return decodeErr
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// outputFormat 输出文件的格式
+type outputFormat string
+
+const (
+	formatWAV outputFormat = "wav" // 带 WAV 头部的 PCM
+	formatRaw outputFormat = "raw" // 不带头部的 PCM (小端序, 按通道交错)
+)
+
+// formatExts 各格式的输出文件扩展名, 以及按扩展名推断格式时接受的扩展名
+var formatExts = map[string]outputFormat{
+	".wav": formatWAV,
+	".raw": formatRaw,
+	".pcm": formatRaw,
+}
+
+// parseFormat 解析 -f 选项的值, 接受内置格式与通过 hca.RegisterFormat 注册的格式
+func parseFormat(name string) (outputFormat, error) {
+	f := outputFormat(strings.ToLower(name))
+	switch {
+	case f == formatWAV, f == formatRaw, hca.IsFormat(string(f)):
+		return f, nil
+	}
+	return "", fmt.Errorf("未知的输出格式 %q (可用: %s)", name, strings.Join(formatNames(), ", "))
+}
+
+// formatNames 返回可用的输出格式名称
+func formatNames() []string {
+	return append([]string{string(formatWAV), string(formatRaw)}, hca.Formats()...)
+}
+
+// resolveFormat 确定输出格式: 优先使用 -f 选项, 否则按输出文件的扩展名推断, 都没有时输出 WAV
+func resolveFormat(flagValue, outputPath string) (outputFormat, error) {
+	if flagValue != "" {
+		return parseFormat(flagValue)
+	}
+	ext := strings.ToLower(filepath.Ext(outputPath))
+	if f, ok := formatExts[ext]; ok {
+		return f, nil
+	}
+	if ext != "" && hca.IsFormat(ext[1:]) { // 注册的格式以名称作为扩展名
+		return outputFormat(ext[1:]), nil
+	}
+	return formatWAV, nil
+}
+
+// ext 返回格式对应的输出文件扩展名
+func (f outputFormat) ext() string {
+	return "." + string(f)
+}
+
+// apply 按输出格式设置解码器
+func (f outputFormat) apply(decoder *hca.Hca) {
+	decoder.Headerless = f == formatRaw
+	decoder.Format = ""
+	if f != formatWAV && f != formatRaw { // 注册的格式由 Sink 写出
+		decoder.Format = string(f)
+	}
+}
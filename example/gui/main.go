@@ -0,0 +1,249 @@
+// Command gui is a drag-and-drop HCA converter built with Fyne.
+// gui 是使用 Fyne 编写的拖放式 HCA 转换器, 作为在图形界面中集成本库的参考示例:
+// 拖入文件后用 Probe 读取头部信息, 转换时用 Progress 回调显示每个文件的进度,
+// 多个文件由固定数量的 worker 并行转换. 输出文件写在输入文件旁边.
+//
+// 构建需要 cgo 与 OpenGL 开发库, 参见 https://docs.fyne.io/started/
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/WJQSERVER/hca"
+)
+
+// job 是列表中的一个文件
+type job struct {
+	path     string
+	info     string  // Probe 得到的头部信息, 或无法转换的原因
+	status   string  // 等待, 转换中, 完成或错误信息
+	progress float64 // 0 到 1
+	ok       bool    // 可以转换 (Probe 成功)
+}
+
+// converter 保存窗口的状态, jobs 只在 UI 线程中访问
+type converter struct {
+	win     fyne.Window
+	jobs    []*job
+	list    *widget.List
+	key     *widget.Entry
+	subkey  *widget.Entry
+	format  *widget.Select
+	start   *widget.Button
+	overall *widget.ProgressBar
+}
+
+func main() {
+	a := app.New()
+	w := a.NewWindow("HCA 转换器")
+	c := &converter{win: w}
+	w.SetContent(c.build())
+	w.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+		for _, u := range uris {
+			c.add(u.Path())
+		}
+	})
+	w.Resize(fyne.NewSize(640, 480))
+	w.ShowAndRun()
+}
+
+// build 创建窗口内容
+func (c *converter) build() fyne.CanvasObject {
+	c.key = widget.NewEntry()
+	c.key.SetPlaceHolder("密钥 (0x... 或游戏名, 留空使用默认密钥)")
+	c.subkey = widget.NewEntry()
+	c.subkey.SetPlaceHolder("子密钥")
+	c.format = widget.NewSelect(append([]string{"wav", "raw"}, hca.Formats()...), nil)
+	c.format.SetSelected("wav")
+
+	c.list = widget.NewList(
+		func() int { return len(c.jobs) },
+		func() fyne.CanvasObject {
+			return container.NewVBox(widget.NewLabel(""), widget.NewLabel(""), widget.NewProgressBar())
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			j := c.jobs[i]
+			box := o.(*fyne.Container)
+			box.Objects[0].(*widget.Label).SetText(filepath.Base(j.path) + "  " + j.status)
+			box.Objects[1].(*widget.Label).SetText(j.info)
+			box.Objects[2].(*widget.ProgressBar).SetValue(j.progress)
+		})
+
+	c.overall = widget.NewProgressBar()
+	c.start = widget.NewButton("转换", c.convert)
+	clearBtn := widget.NewButton("清空", func() {
+		c.jobs = nil
+		c.list.Refresh()
+	})
+
+	form := container.NewBorder(nil, nil, nil, c.format,
+		container.NewGridWithColumns(2, c.key, c.subkey))
+	bottom := container.NewBorder(nil, nil, nil, container.NewHBox(clearBtn, c.start), c.overall)
+	hint := widget.NewLabel("将 .hca 文件拖放到窗口中")
+	return container.NewBorder(container.NewVBox(form, hint), bottom, nil, nil, c.list)
+}
+
+// add 将拖入的文件加入列表, 文件夹中的 .hca 文件也会加入
+func (c *converter) add(path string) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".hca") {
+				c.add(p)
+			}
+			return nil
+		})
+		return
+	}
+	j := &job{path: path, status: "等待"}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".acb", ".awb":
+		j.info = "不支持 ACB/AWB 容器, 请先解包出 .hca 文件"
+		j.status = "跳过"
+	default:
+		if info, err := probe(path); err != nil {
+			j.info = err.Error()
+			j.status = "跳过"
+		} else {
+			j.info = fmt.Sprintf("%d ch, %d Hz, %s", info.Channels, info.SamplingRate, info.Duration().Round(time.Millisecond))
+			if info.Loop {
+				j.info += fmt.Sprintf(", 循环 %d-%d", info.LoopStart, info.LoopEnd)
+			}
+			if info.CipherType != 0 {
+				j.info += fmt.Sprintf(", 加密类型 %d", info.CipherType)
+			}
+			j.ok = true
+		}
+	}
+	c.jobs = append(c.jobs, j)
+	c.list.Refresh()
+}
+
+// probe 读取文件的头部信息
+func probe(path string) (hca.Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return hca.Info{}, err
+	}
+	defer f.Close()
+	return hca.NewDecoder().Probe(f)
+}
+
+// options 解析密钥与子密钥
+func (c *converter) options() (key uint64, subkey uint16, err error) {
+	if s := strings.TrimSpace(c.key.Text); s != "" {
+		if key, err = strconv.ParseUint(s, 0, 64); err != nil {
+			keys := hca.FindKeys(s)
+			if len(keys) != 1 {
+				return 0, 0, fmt.Errorf("无法识别的密钥或游戏名 %q", s)
+			}
+			key, err = keys[0].Key, nil
+		}
+	}
+	if s := strings.TrimSpace(c.subkey.Text); s != "" {
+		v, perr := strconv.ParseUint(s, 0, 16)
+		if perr != nil {
+			return 0, 0, fmt.Errorf("无效的子密钥 %q", s)
+		}
+		subkey = uint16(v)
+	}
+	return key, subkey, nil
+}
+
+// convert 并行转换列表中尚未完成的文件
+func (c *converter) convert() {
+	key, subkey, err := c.options()
+	if err != nil {
+		dialog.ShowError(err, c.win)
+		return
+	}
+	format := c.format.Selected
+
+	var pending []*job
+	for _, j := range c.jobs {
+		if j.ok && j.status != "完成" {
+			j.status, j.progress = "等待", 0
+			pending = append(pending, j)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	c.start.Disable()
+	c.overall.SetValue(0)
+	c.list.Refresh()
+
+	queue := make(chan *job)
+	var wg sync.WaitGroup
+	var done int
+	for range min(runtime.NumCPU(), len(pending)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				err := c.decode(j, key, subkey, format)
+				fyne.Do(func() {
+					if err != nil {
+						j.status = "错误: " + err.Error()
+					} else {
+						j.status, j.progress = "完成", 1
+					}
+					done++
+					c.overall.SetValue(float64(done) / float64(len(pending)))
+					c.list.Refresh()
+				})
+			}
+		}()
+	}
+	go func() {
+		for _, j := range pending {
+			queue <- j
+		}
+		close(queue)
+		wg.Wait()
+		fyne.Do(c.start.Enable)
+	}()
+}
+
+// decode 在 worker 中转换一个文件, 通过 Progress 回调更新进度
+func (c *converter) decode(j *job, key uint64, subkey uint16, format string) error {
+	d := hca.NewDecoder()
+	if key != 0 {
+		d.SetKey(key)
+	}
+	d.Subkey = subkey
+	switch format {
+	case "raw":
+		d.Headerless = true
+	case "wav":
+	default:
+		d.Format = format
+	}
+	fyne.Do(func() {
+		j.status = "转换中"
+		c.list.Refresh()
+	})
+	d.Progress = func(blocks, total int) {
+		if total == 0 || blocks%64 != 0 { // 限制刷新界面的频率
+			return
+		}
+		p := float64(blocks) / float64(total)
+		fyne.Do(func() {
+			j.progress = p
+			c.list.Refresh()
+		})
+	}
+	dst := strings.TrimSuffix(j.path, filepath.Ext(j.path)) + "." + format
+	return d.DecodeFile(j.path, dst)
+}
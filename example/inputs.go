@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// inputRels 记录在目录中找到的文件相对于该目录的路径, 用于在 -save 目录中保留目录结构
+var inputRels = make(map[string]string)
+
+// inputRel 返回输入文件在 -save 目录中的相对路径: 在目录中找到的文件保留子目录, 直接给出的文件只使用文件名
+func inputRel(p string) string {
+	if rel, ok := inputRels[p]; ok {
+		return rel
+	}
+	return filepath.Base(p)
+}
+
+// collectInputs 将命令行参数展开为要解码的文件列表
+// 参数可以是文件, 目录或 glob 模式 (支持 **); 模式用于筛选目录中找到的文件 (路径相对于该目录),
+// 没有给出目录时相对于当前目录. recursive 为 true 时递归遍历子目录
+func collectInputs(args []string, recursive bool) ([]string, error) {
+	var roots, patterns []string
+	for _, arg := range args {
+		if isPattern(arg) {
+			patterns = append(patterns, filepath.ToSlash(arg))
+		} else {
+			roots = append(roots, arg)
+		}
+	}
+	if len(roots) == 0 && len(patterns) > 0 {
+		roots = []string{"."} // 只有模式时在当前目录中查找
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(p, rel string) {
+		if !seen[p] {
+			seen[p] = true
+			files = append(files, p)
+			inputRels[p] = rel
+		}
+	}
+
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			log.Printf("错误: 无法访问 %s: %v", root, err)
+			continue
+		}
+		if !info.IsDir() { // 直接给出的文件
+			if strings.ToLower(filepath.Ext(root)) != ".hca" {
+				log.Printf("跳过: %s (非 .hca 文件)", root)
+				continue
+			}
+			add(root, filepath.Base(root))
+			continue
+		}
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				log.Printf("错误: 无法访问 %s: %v", p, err)
+				return nil // 跳过无法访问的部分, 继续遍历
+			}
+			if d.IsDir() {
+				if p != root && !recursive {
+					return filepath.SkipDir // 非递归模式只处理目录中的文件
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			if matchInput(filepath.ToSlash(rel), patterns) {
+				add(p, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// isPattern 判断参数是否为 glob 模式
+func isPattern(arg string) bool {
+	return strings.ContainsAny(arg, "*?[")
+}
+
+// matchInput 判断相对路径 rel 是否匹配任一模式, 没有模式时匹配所有 .hca 文件
+func matchInput(rel string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return strings.ToLower(path.Ext(rel)) == ".hca"
+	}
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") { // 不含目录的模式只匹配文件名
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+			continue
+		}
+		if matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments 按路径分段匹配模式, ** 匹配零个或多个目录
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// resolveGame 按游戏名称查找内置的密钥, 有多个同等匹配时返回错误并列出候选
+func resolveGame(name string) (hca.GameKey, error) {
+	keys := hca.FindKeys(name)
+	switch len(keys) {
+	case 0:
+		return hca.GameKey{}, fmt.Errorf("没有找到游戏 %q 的密钥, 使用 -list-games 查看已知的游戏", name)
+	case 1:
+		return keys[0], nil
+	}
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.Name
+	}
+	return hca.GameKey{}, fmt.Errorf("游戏名称 %q 不明确, 可能是: %s", name, strings.Join(names, ", "))
+}
+
+// listGames 输出内置密钥数据库中的游戏
+func listGames(w io.Writer) {
+	for _, k := range hca.KnownKeys {
+		fmt.Fprintf(w, "%-50s 0x%016X", k.Name, k.Key)
+		if len(k.Aliases) > 0 {
+			fmt.Fprintf(w, "  (%s)", strings.Join(k.Aliases, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// resolveKey 按 -game, -k, -c1/-c2 的优先顺序确定解密密钥
+func resolveKey() error {
+	if *subkeyFlag > 0xFFFF {
+		return fmt.Errorf("子密钥 %d 超出范围 (0~65535)", *subkeyFlag)
+	}
+	switch {
+	case *gameFlag != "": // 按游戏名称查找密钥
+		k, err := resolveGame(*gameFlag)
+		if err != nil {
+			return err
+		}
+		key = k.Key
+		log.Printf("使用 %s 的密钥 0x%016X", k.Name, k.Key)
+	case *keyFlag != "":
+		k, err := strconv.ParseUint(*keyFlag, 0, 64)
+		if err != nil {
+			return fmt.Errorf("无效的密钥 %q: %v", *keyFlag, err)
+		}
+		key = k
+	default:
+		key = uint64(uint32(*ciphKey2Flag))<<32 | uint64(uint32(*ciphKey1Flag))
+	}
+	return nil
+}
+
+// candidateKeys 是 -find-key 文件中的候选密钥
+var candidateKeys []uint64
+
+// readKeyList 读取密钥列表文件: 每行一个 64 位密钥 (十进制或 0x 开头的十六进制), # 之后为注释
+func readKeyList(path string) ([]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []uint64
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		k, err := strconv.ParseUint(line, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: 无效的密钥 %q", path, n, line)
+		}
+		keys = append(keys, k)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: 没有密钥", path)
+	}
+	return keys, nil
+}
+
+// findKey 在候选密钥中查找能够解密 inputPath 的密钥, 找到时设置到解码器
+func findKey(decoder *hca.Hca, inputPath string) (uint64, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	k, err := decoder.FindKey(f, candidateKeys)
+	if err != nil {
+		return 0, err
+	}
+	if k != 0 { // 0 表示文件没有使用密钥加密, 保留命令行指定的密钥
+		decoder.SetKey(k)
+	}
+	return k, nil
+}
@@ -1,136 +1,645 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings" // 用于ToLower
-	"sync"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/WJQSERVER/hca" // 保持原始库的导入
+	"github.com/WJQSERVER/hca"         // 保持原始库的导入
+	"github.com/WJQSERVER/hca/hcaflac" // 注册 flac 输出格式
+	"github.com/WJQSERVER/hca/hcaogg"  // 注册 ogg 输出格式
 )
 
 // global flags
 var (
 	saveDirFlag  *string
+	keyFlag      *string
+	subkeyFlag   *uint
 	ciphKey1Flag *uint // 使用 uint 因为 flag 包没有 uint32, 但解析十六进制时会处理
 	ciphKey2Flag *uint
 	modeFlag     *int
 	loopFlag     *int
 	volumeFlag   *float64
+	gainFlag     *float64
+	lufsFlag     *float64
+	analyzeFlag  *bool
+	limitFlag    *string
+	releaseFlag  *time.Duration
 	parallelFlag *int
+	recurseFlag  *bool
+	progressFlag *bool
+	formatFlag   *string
+	outputFlag   *string
+	nameFlag     *string
+	nameFromFlag *string
+	reportFlag   *string
+	failFastFlag *bool
+	configFlag   *string
+	startFlag    *time.Duration
+	durationFlag *time.Duration
+	loopsFlag    *int
+	execFlag     *string
+	findKeyFlag  *string
+	findLoopFlag *bool
+	verifyFlag   *bool
+	maxMemFlag   *string
+	titleFlag    *string
+	artistFlag   *string
+	albumFlag    *string
+	trackFlag    *string
+	acbFlag      *string
+	throttleFlag *string
+	playFlag     *bool
+	fadeFlag     *time.Duration
+	xfadeFlag    *time.Duration
+	gameFlag     *string
+	listFlag     *bool
+	rateFlag     *int
+	qualityFlag  *string
+	chmapFlag    *string
+	chgainFlag   *string
+	badBlockFlag *string
+	partialFlag  *bool
+	padFlag      *bool
+	chanParFlag  *int
+	aheadFlag    *bool
+	noCRCFlag    *bool
+	wbufFlag     *int
+	oggQFlag     *float64
+	flacLvlFlag  *int
+
+	bar      *progressBar        // 批量解码的进度显示, 未启用时为 nil
+	format   outputFormat        // 输出格式
+	key      uint64              // 64 位解密密钥, 由 -game, -k 或 -c1/-c2 确定
+	nameTmpl string              // 输出文件名模板, 由 -name 或 -name-from 确定
+	quality  hca.ResampleQuality // 重采样的质量, 由 -resample 确定
+	policy   hca.BlockPolicy     // 损坏块的处理策略, 由 -bad-block 确定
+	chmap    []int               // 输出通道的映射, 由 -channel-map 确定
+	chgain   []float64           // 各输出通道的增益, 由 -channel-gain 确定
+	limiter  *hca.Limiter        // 软限幅器, 由 -limit 确定
 )
 
 func init() {
 	// 使用空字符串作为默认值，表示与源文件同目录
-	saveDirFlag = flag.String("save", "", "保存WAV文件的目录 (默认为源文件所在目录)")
-	ciphKey1Flag = flag.Uint("c1", 0x01395C51, "解密密钥1 (十六进制, 例如 0x01395C51)")
-	ciphKey2Flag = flag.Uint("c2", 0x00000000, "解密密钥2 (十六进制, 例如 0x00000000)")
+	saveDirFlag = flag.String("save", "", "保存WAV文件的目录 (默认为源文件所在目录), 在目录中找到的文件保留子目录结构")
+	keyFlag = flag.String("k", "", "64 位解密密钥 (十进制或 0x 开头的十六进制), 覆盖 -c1/-c2")
+	subkeyFlag = flag.Uint("subkey", 0, "子密钥 (AWB 文件中的 subkey, 0 表示不使用)")
+	ciphKey1Flag = flag.Uint("c1", 0x01395C51, "解密密钥的低 32 位 (兼容旧版本, 推荐使用 -k)")
+	ciphKey2Flag = flag.Uint("c2", 0x00000000, "解密密钥的高 32 位 (兼容旧版本, 推荐使用 -k)")
 	modeFlag = flag.Int("m", 16, "解码输出位数 (0=浮点, 8, 16, 24, 32)")
 	loopFlag = flag.Int("l", 0, "循环次数 (0=使用文件内设置, >0=强制循环N次)")
 	volumeFlag = flag.Float64("v", 1.0, "音量缩放 (例如 0.5, 1.0, 1.5)")
+	gainFlag = flag.Float64("gain", 0, "以分贝表示的增益 (例如 -6), 与 -v 相乘")
+	lufsFlag = flag.Float64("loudness", 0, "将响度归一化到指定的 LUFS (例如 -16, EBU R128), 覆盖 -v 与 -gain, 0 表示不归一化")
+	limitFlag = flag.String("limit", "", "启用软限幅器, 值为输出峰值的上限 (dBFS, 例如 -1), 用于放大音量时避免削波")
+	releaseFlag = flag.Duration("limit-release", hca.DefaultLimiterRelease, "软限幅器的恢复时间")
+	analyzeFlag = flag.Bool("analyze", false, "解码后显示每个通道的峰值, RMS 与削波的样本数")
 	parallelFlag = flag.Int("p", runtime.NumCPU(), "并行解码的文件数量 (默认为CPU核心数)")
+	recurseFlag = flag.Bool("r", false, "递归处理目录中的子目录")
+	gameFlag = flag.String("game", "", "按游戏名称使用内置的密钥 (支持模糊匹配, 覆盖 -k 与 -c1/-c2)")
+	listFlag = flag.Bool("list-games", false, "列出内置密钥数据库中的游戏")
+	formatFlag = flag.String("f", "", "输出格式 ("+strings.Join(formatNames(), ", ")+"), 默认按 -o 的扩展名推断, 否则为 wav")
+	oggQFlag = flag.Float64("ogg-quality", hcaogg.DefaultQuality, "Ogg Vorbis 的编码质量 (-1 到 10, 越高码率越大)")
+	flacLvlFlag = flag.Int("flac-level", hcaflac.DefaultLevel, "FLAC 的压缩级别 (0 到 8, 越高文件越小, 编码越慢), 每样本位数随 -m (大于 16 或浮点时为 24 位)")
+	outputFlag = flag.String("o", "", "输出文件路径 (只能用于单个输入文件)")
+	startFlag = flag.Duration("start", 0, "从指定时间开始输出 (例如 1m30s, 展开循环后的时间)")
+	durationFlag = flag.Duration("duration", 0, "只输出指定的时长 (例如 20s, 0 表示到结尾)")
+	loopsFlag = flag.Int("loops", 0, "输出开头与 N 次循环, 之后接 -fade 指定的淡出 (覆盖 -l)")
+	fadeFlag = flag.Duration("fade", 0, "在输出末尾淡出的时长 (例如 5s)")
+	xfadeFlag = flag.Duration("crossfade", 0, "展开循环时在每次跳回循环开始前交叉淡化的时长 (例如 50ms)")
+	rateFlag = flag.Int("rate", 0, "输出的采样率 (例如 44100, 48000), 0 表示使用文件的采样率")
+	qualityFlag = flag.String("resample", "sinc", "重采样的质量 (linear, sinc)")
+	chanParFlag = flag.Int("channel-parallel", 0, "多声道文件每个块内并行处理的通道数 (用于 5.1/7.1 等环绕声文件), 0 表示不并行")
+	aheadFlag = flag.Bool("read-ahead", false, "在后台预读并解密下一个数据块 (用于机械硬盘, 网络文件系统等慢速介质)")
+	noCRCFlag = flag.Bool("skip-checksum", false, "不计算数据块的校验和, 用于批量解码可信的文件")
+	wbufFlag = flag.Int("write-buffer", 0, "输出缓冲的大小 (字节), 0 表示写出到文件时使用默认的 64 KiB, 负数表示不缓冲")
+	padFlag = flag.Bool("pad-truncated", false, "文件在数据块中间截断时 (例如下载中断) 补齐最后一个块并保留可用的数据, 视为成功")
+	partialFlag = flag.Bool("keep-partial", false, "解码中途失败时保留已解码的部分 (修正 WAV 头部), 仍然视为失败")
+	badBlockFlag = flag.String("bad-block", "strict", "损坏块的处理策略 (strict: 失败, skip: 丢弃, mute: 以静音替代, repeat: 重复上一个块, decode: 仍然解码)")
+	chgainFlag = flag.String("channel-gain", "", "各输出通道的增益 (dB), 逗号分隔, 按 -channel-map 之后的通道顺序 (例如 0,0,-6)")
+	chmapFlag = flag.String("channel-map", "", "输出通道的映射, 逗号分隔的文件通道序号 (从 0 开始, 例如 1,0 交换左右声道, 0,1,2,4,5 丢弃 5.1 的 LFE)")
+	titleFlag = flag.String("title", "", "标题标签 (WAV 的 INAM, FLAC/Ogg 的 TITLE)")
+	artistFlag = flag.String("artist", "", "艺术家标签 (WAV 的 IART, FLAC/Ogg 的 ARTIST)")
+	albumFlag = flag.String("album", "", "专辑标签 (WAV 的 IPRD, FLAC/Ogg 的 ALBUM)")
+	trackFlag = flag.String("track", "", "音轨号标签 (WAV 的 ITRK, FLAC/Ogg 的 TRACKNUMBER)")
+	acbFlag = flag.String("acb", "", "ACB 文件, 按输入文件名末尾的数字 (AWB 中的波形 ID) 查找 cue 名称, 作为没有指定时的标题标签 (ACB 的名称作为专辑), 也用于 -name-from cue")
+	maxMemFlag = flag.String("max-memory", "", "内存使用的软上限 (例如 512MB), 接近上限时更频繁地回收内存")
+	throttleFlag = flag.String("io-throttle", "", "限制所有文件写入磁盘的总速度 (每秒字节数, 例如 20MB)")
+	findKeyFlag = flag.String("find-key", "", "从密钥列表文件 (每行一个密钥) 中查找每个文件的密钥")
+	findLoopFlag = flag.Bool("find-loop", false, "分析音频内容, 显示推测的循环点 (用于没有 loop 块的文件), 不写出文件")
+	verifyFlag = flag.Bool("verify", false, "只检查每个数据块的校验和与魔术数字, 显示损坏或缺失的块, 不解码也不写出文件")
+	execFlag = flag.String("exec", "", "将解码的 WAV 写入命令的标准输入, 不写出文件 ({input} 替换为输入文件路径)")
+	playFlag = flag.Bool("play", false, "使用 ffplay, mpv 或 aplay 播放, 不写出文件 (依次播放)")
+	configFlag = flag.String("config", "", "配置文件路径 (默认为 "+defaultConfigPath()+", 命令行选项优先)")
+	failFastFlag = flag.Bool("fail-fast", false, "遇到第一个失败的文件后停止处理剩余的文件")
+	reportFlag = flag.String("report", "", "将每个文件的处理结果写入报告 (.json 或 .csv)")
+	nameFromFlag = flag.String("name-from", "", "使用文件中的元数据命名输出 (comment: 使用 comm 注释, 没有注释时使用原文件名; cue: 使用 -acb 中的 cue 名称)")
+	nameFlag = flag.String("name", "", "输出文件名模板, 可用变量: "+nameVars)
+	progressFlag = flag.Bool("progress", isTerminal(os.Stderr), "显示解码进度 (默认在终端中显示)")
 
 	// 自定义 Usage 函数
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "HCA 文件解码器 (基于 go-hca 库)\n\n")
-		fmt.Fprintf(os.Stderr, "用法: %s [选项] <hca文件1> [hca文件2] ...\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "用法: %s [选项] <hca文件|目录|模式> ...\n", filepath.Base(os.Args[0]))
+		printSubcommands(os.Stderr)
+		fmt.Fprintln(os.Stderr)
 		fmt.Fprintf(os.Stderr, "选项:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\n示例:\n")
 		fmt.Fprintf(os.Stderr, "  %s song.hca\n", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "  %s -save ./decoded_audio -m 0 -v 1.2 music1.hca sound_effect.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -r ./assets \"**/*.hca\"\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -o out.raw song.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -f flac -flac-level 8 song.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -o bgm.ogg -ogg-quality 6 bgm.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -start 1m30s -duration 20s bgm.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -loops 2 -fade 5s bgm.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -exec \"ffmpeg -i pipe:0 {input}.mp3\" *.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -name \"{dir}/{base}_{rate}Hz.wav\" *.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -k 0xCC55463930DBE1AB bgm.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -k 59751358413602 -subkey 1234 voice.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s -game \"uma musume\" voice.hca\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  cat a.hca | %s -m 16 - - | ffplay -\n", filepath.Base(os.Args[0]))
 	}
 }
 
 func main() {
 	log.SetFlags(0) // 不显示日期时间前缀
+	if len(os.Args) > 1 {
+		if cmd := lookupSubcommand(os.Args[1]); cmd != nil {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				log.Fatalf("错误: %v", err)
+			}
+			return
+		}
+	}
 	flag.Parse()
+	if err := applyConfig(*configFlag); err != nil {
+		log.Fatalf("错误: %v", err)
+	}
 
-	filesToProcess := flag.Args()
-	if len(filesToProcess) == 0 {
+	if *listFlag {
+		listGames(os.Stdout)
+		return
+	}
+	if err := resolveKey(); err != nil {
+		log.Fatalf("错误: %v", err)
+	}
+	if *maxMemFlag != "" {
+		limit, err := parseSize(*maxMemFlag)
+		if err != nil {
+			log.Fatalf("错误: -max-memory: %v", err)
+		}
+		debug.SetMemoryLimit(limit)
+	}
+	if *throttleFlag != "" {
+		rate, err := parseSize(*throttleFlag)
+		if err != nil {
+			log.Fatalf("错误: -io-throttle: %v", err)
+		}
+		throttle = &rateLimiter{rate: float64(rate)}
+	}
+	if q, ok := resampleQualities[*qualityFlag]; ok {
+		quality = q
+	} else {
+		log.Fatalf("错误: 未知的重采样质量 %q (可用: linear, sinc)", *qualityFlag)
+	}
+	if p, ok := blockPolicies[*badBlockFlag]; ok {
+		policy = p
+	} else {
+		log.Fatalf("错误: 未知的损坏块策略 %q (可用: strict, skip, mute, repeat, decode)", *badBlockFlag)
+	}
+	if *chmapFlag != "" {
+		m, err := parseChannelMap(*chmapFlag)
+		if err != nil {
+			log.Fatalf("错误: -channel-map: %v", err)
+		}
+		chmap = m
+	}
+	if *limitFlag != "" {
+		threshold, err := strconv.ParseFloat(*limitFlag, 64)
+		if err != nil {
+			log.Fatalf("错误: -limit: 无效的阈值 %q", *limitFlag)
+		}
+		limiter = &hca.Limiter{Threshold: threshold, Knee: 2, Release: *releaseFlag}
+	}
+	if *chgainFlag != "" {
+		for _, f := range strings.Split(*chgainFlag, ",") {
+			g, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+			if err != nil {
+				log.Fatalf("错误: -channel-gain: 无效的增益 %q", f)
+			}
+			chgain = append(chgain, g)
+		}
+	}
+	if *rateFlag < 0 {
+		log.Fatalf("错误: 无效的采样率 %d", *rateFlag)
+	}
+	if *findKeyFlag != "" {
+		keys, err := readKeyList(*findKeyFlag)
+		if err != nil {
+			log.Fatalf("错误: %v", err)
+		}
+		candidateKeys = keys
+	}
+	if flag.NArg() == 0 {
 		log.Println("错误: 请提供至少一个HCA文件进行解码。")
 		flag.Usage()
 		os.Exit(1)
 	}
+	var err error
+	if format, err = resolveFormat(*formatFlag, *outputFlag); err != nil {
+		log.Fatalf("错误: %v", err)
+	}
+	if !(*oggQFlag >= -1 && *oggQFlag <= 10) || *flacLvlFlag < 0 || *flacLvlFlag > 8 { // 在解码之前检查, 不必每个文件都失败一次
+		log.Fatalf("错误: -ogg-quality 的范围是 -1 到 10, -flac-level 的范围是 0 到 8")
+	}
+	if *acbFlag != "" {
+		if err := loadCues(*acbFlag); err != nil {
+			log.Fatalf("错误: %v", err)
+		}
+	}
+	if nameTmpl, err = nameTemplate(); err != nil {
+		log.Fatalf("错误: %v", err)
+	}
+	if flag.Arg(0) == "-" { // 管道模式: 从标准输入读取, 写入标准输出或指定文件
+		if err := runPipe(flag.Args()); err != nil {
+			log.Fatalf("解码失败: %v", err)
+		}
+		return
+	}
+	filesToProcess, err := collectInputs(flag.Args(), *recurseFlag) // 展开目录与模式
+	if err != nil {
+		log.Fatalf("错误: %v", err)
+	}
+	if len(filesToProcess) == 0 {
+		log.Println("没有找到要解码的HCA文件。")
+		os.Exit(1)
+	}
+	if *outputFlag != "" && len(filesToProcess) > 1 {
+		log.Fatalf("错误: -o 只能用于单个输入文件, 多个文件请使用 -save 指定目录")
+	}
 
 	numParallel := *parallelFlag
 	if numParallel <= 0 {
 		numParallel = 1 // 至少一个任务
 	}
+	if *playFlag {
+		numParallel = 1 // 依次播放
+	}
 	if numParallel > len(filesToProcess) { // 并行数不需要超过文件数
 		numParallel = len(filesToProcess)
 	}
 
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, numParallel) // 控制并发数量的信号量
-
+	if *progressFlag {
+		bar = newProgressBar(os.Stderr, len(filesToProcess))
+		log.SetOutput(bar) // 日志输出时保持进度行在最后一行
+	}
 	log.Printf("开始解码 %d 个文件，并行数: %d\n", len(filesToProcess), numParallel)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var done []fileResult // 处理结果, 不包括没有处理的文件
+	var jobs []hca.Job
+	var pending []fileResult // jobs 对应的结果, 解码结束后补充
+	failed := false          // 是否有文件处理失败
 	for _, hcaFilePath := range filesToProcess {
-		wg.Add(1)
-		semaphore <- struct{}{} // 获取一个处理许可
+		if *failFastFlag && failed {
+			break // 不再开始新的文件
+		}
+		job, res := prepareFile(hcaFilePath)
+		if job == nil { // 不写出文件的模式已经处理完毕, 或者准备失败
+			done = append(done, res)
+			failed = failed || res.Status != "ok"
+			continue
+		}
+		job.Done = func(r hca.Result) {
+			if bar != nil && r.Elapsed > 0 {
+				bar.finish(r.Job.Src)
+			}
+			if *failFastFlag && r.Err != nil && !ignoredError(r.Err) {
+				cancel() // 不再开始新的文件, 正在解码的文件中止
+			}
+		}
+		jobs = append(jobs, *job)
+		pending = append(pending, res)
+	}
+	for i, r := range hca.DecodeFiles(ctx, jobs, numParallel) {
+		if r.Elapsed == 0 && r.Err != nil && errors.Is(r.Err, context.Canceled) { // -fail-fast 之后没有开始的文件
+			continue
+		}
+		done = append(done, finishFile(r, pending[i]))
+	}
+
+	if bar != nil {
+		bar.close()
+		log.SetOutput(os.Stderr)
+	}
+	if *reportFlag != "" {
+		order := make(map[string]int, len(filesToProcess)) // 按输入顺序排列结果
+		for i, f := range filesToProcess {
+			order[f] = i
+		}
+		sort.SliceStable(done, func(i, j int) bool { return order[done[i].Input] < order[done[j].Input] })
+		if err := writeReport(*reportFlag, done); err != nil {
+			log.Printf("错误: 无法写入报告 '%s': %v", *reportFlag, err)
+		}
+	}
+	ok := 0
+	for _, res := range done {
+		if res.Status == "ok" {
+			ok++
+		}
+	}
+	log.Printf("所有解码任务完成: %d 个成功, %d 个失败, %d 个未处理。", ok, len(done)-ok, len(filesToProcess)-len(done))
+	if ok != len(filesToProcess) {
+		os.Exit(1) // 有文件失败或未处理
+	}
+}
 
-		go func(inputFile string) {
-			defer wg.Done()
-			defer func() { <-semaphore }() // 释放许可
+// resampleQualities 是 -resample 可以使用的值
+var resampleQualities = map[string]hca.ResampleQuality{
+	"linear": hca.ResampleLinear,
+	"sinc":   hca.ResampleSinc,
+}
 
-			processFile(inputFile)
-		}(hcaFilePath)
+// blockPolicies 是 -bad-block 可以使用的值
+var blockPolicies = map[string]hca.BlockPolicy{
+	"strict": hca.BlockStrict,
+	"skip":   hca.BlockSkip,
+	"mute":   hca.BlockMute,
+	"repeat": hca.BlockRepeat,
+	"decode": hca.BlockDecode,
+}
+
+// logLevels 显示 -analyze 统计的各通道电平, 有削波时提示降低音量
+func logLevels(s hca.Stats) {
+	for i, c := range s.Channels {
+		msg := fmt.Sprintf("  通道 %d: 峰值 %.2f dBFS, RMS %.2f dBFS", i, c.PeakDB(), c.RMSDB())
+		if c.Clipped > 0 {
+			msg += fmt.Sprintf(", %d 个样本被削波 (请降低 -v 或使用 -gain %.1f)", c.Clipped, -c.PeakDB())
+		}
+		log.Print(msg)
 	}
+}
 
-	wg.Wait() // 等待所有文件处理完毕
-	log.Println("所有解码任务完成。")
+// findLoops 显示 FindLoops 推测的循环点, 文件已有 loop 块时同时显示以便比较. 返回文件的头部信息
+func findLoops(decoder *hca.Hca, path string) (hca.Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return hca.Info{}, err
+	}
+	defer f.Close()
+	info, err := decoder.Probe(f)
+	if err != nil {
+		return info, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return info, err
+	}
+	loops, err := decoder.FindLoops(f, 3)
+	if err != nil {
+		return info, err
+	}
+	rate := time.Duration(info.SamplingRate)
+	if info.Loop {
+		log.Printf("%s: 文件的 loop 块: 块 %d-%d", path, info.LoopStart, info.LoopEnd)
+	}
+	if len(loops) == 0 {
+		log.Printf("%s: 没有找到重复的片段", path)
+	}
+	for i, l := range loops {
+		log.Printf("%s: 候选 %d: 样本帧 %d-%d (%v-%v), 相关系数 %.4f", path, i+1, l.Start, l.End,
+			time.Duration(l.Start)*time.Second/rate, time.Duration(l.End)*time.Second/rate, l.Score)
+	}
+	return info, nil
 }
 
-func processFile(hcaFilePath string) {
-	// 基本的文件有效性检查
-	if _, err := os.Stat(hcaFilePath); os.IsNotExist(err) {
-		log.Printf("错误: 文件不存在 %s", hcaFilePath)
-		return
+// verifyFile 检查文件的数据块, 有损坏或缺失的块时返回错误. 返回 Validate 读取的头部信息
+func verifyFile(decoder *hca.Hca, path string) (hca.Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return hca.Info{}, err
 	}
-	if strings.ToLower(filepath.Ext(hcaFilePath)) != ".hca" {
-		log.Printf("跳过: %s (非 .hca 文件)", hcaFilePath)
-		return
+	defer f.Close()
+	v, err := decoder.Validate(bufio.NewReader(f))
+	if err != nil {
+		return hca.Info{}, err
+	}
+	if v.OK() {
+		log.Printf("%s: %d 个块全部有效", path, v.Checked)
+		return v.Info, nil
+	}
+	if len(v.BadChecksum) > 0 {
+		log.Printf("%s: 校验和错误的块: %v", path, v.BadChecksum)
+	}
+	if len(v.BadMagic) > 0 {
+		log.Printf("%s: 魔术数字错误的块: %v", path, v.BadMagic)
 	}
+	if v.Truncated {
+		log.Printf("%s: 数据截断, 缺少 %d 个块 (共 %d 个)", path, v.Info.Blocks-v.Checked, v.Info.Blocks)
+	}
+	return v.Info, fmt.Errorf("%d 个块损坏, %d 个块缺失", len(v.BadChecksum)+len(v.BadMagic), v.Info.Blocks-v.Checked)
+}
 
-	// 创建和配置解码器实例
-	// 由于库的 Decoder 状态不是线程安全的（如果它内部有可变状态用于解码单个文件），
-	// 并且我们的并发模型是每个文件一个goroutine，所以每个goroutine都应有自己的Decoder实例。
+// parseChannelMap 解析逗号分隔的通道序号
+func parseChannelMap(s string) ([]int, error) {
+	var m []int
+	for _, f := range strings.Split(s, ",") {
+		c, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || c < 0 {
+			return nil, fmt.Errorf("无效的通道序号 %q", f)
+		}
+		m = append(m, c)
+	}
+	return m, nil
+}
+
+// newDecoder 按照命令行选项创建解码器
+func newDecoder() *hca.Hca {
 	decoder := hca.NewDecoder() // 使用库提供的构造函数
-	decoder.CiphKey1 = uint32(*ciphKey1Flag)
-	decoder.CiphKey2 = uint32(*ciphKey2Flag)
+	decoder.SetKey(key)
+	decoder.Subkey = uint16(*subkeyFlag)
 	decoder.Mode = *modeFlag
 	decoder.Loop = *loopFlag
 	decoder.Volume = float32(*volumeFlag)
+	decoder.GainDB = *gainFlag
+	decoder.TargetLoudness = *lufsFlag
+	decoder.Analyze = *analyzeFlag
+	decoder.Limiter = limiter
+	decoder.SampleRate = *rateFlag
+	decoder.Resample = quality
+	decoder.ChannelMap = chmap
+	decoder.ChannelGainDB = chgain
+	decoder.ChecksumPolicy, decoder.MagicPolicy = policy, policy
+	decoder.KeepPartial = *partialFlag
+	decoder.PadTruncated = *padFlag
+	decoder.ChannelParallelism = *chanParFlag
+	decoder.ReadAhead = *aheadFlag
+	decoder.SkipChecksum = *noCRCFlag
+	decoder.WriteBufferSize = *wbufFlag
+	decoder.Warn = func(err error) { log.Printf("警告: %v", err) }
+	decoder.Tags = hca.Tags{Title: *titleFlag, Artist: *artistFlag, Album: *albumFlag, Track: *trackFlag}
+	bits := "16"
+	if *modeFlag == 0 || *modeFlag > 16 { // FLAC 只支持整数样本, 浮点与 24/32 位输出 24 位
+		bits = "24"
+	}
+	decoder.FormatOptions = map[string]string{ // 各格式只读取自己的键
+		"quality": strconv.FormatFloat(*oggQFlag, 'g', -1, 64),
+		"level":   strconv.Itoa(*flacLvlFlag),
+		"bits":    bits,
+	}
+	format.apply(decoder)
+	return decoder
+}
+
+// prepareFile 为解码一个文件做准备: 查找密钥, 设置时间范围并确定输出路径, 返回交给 hca.DecodeFiles 的任务.
+// 不写出文件的模式 (-verify, -find-loops, -exec, -play) 在这里直接处理, 与准备失败时一样返回 nil 与处理结果
+func prepareFile(hcaFilePath string) (job *hca.Job, result fileResult) {
+	decoder := newDecoder()
+	applyCueTags(decoder, hcaFilePath)
+	result = fileResult{Input: hcaFilePath, Status: "failed", Key: keyString(key)}
+	if candidateKeys != nil { // 查找密钥
+		k, err := findKey(decoder, hcaFilePath)
+		if err != nil {
+			log.Printf("错误: 查找密钥失败: %v (文件: %s)", err, hcaFilePath)
+			result.Error = err.Error()
+			return
+		}
+		if k == 0 {
+			log.Printf("文件没有使用密钥加密: %s", hcaFilePath)
+		} else {
+			log.Printf("找到密钥: 0x%016X (文件: %s)", k, hcaFilePath)
+		}
+		result.Key = keyString(k)
+	}
+	if err := applyTimeRange(decoder, hcaFilePath); err != nil {
+		log.Printf("错误: %v (文件: %s)", err, hcaFilePath)
+		result.Error = err.Error()
+		return
+	}
+
+	if *verifyFlag { // 只检查文件是否损坏
+		info, err := verifyFile(decoder, hcaFilePath)
+		result.Duration = info.Duration().Seconds()
+		if err != nil {
+			log.Printf("检查失败: %s: %v", hcaFilePath, err)
+			result.Error = err.Error()
+			return
+		}
+		result.Status = "ok"
+		return
+	}
+
+	if *findLoopFlag { // 只分析循环点
+		info, err := findLoops(decoder, hcaFilePath)
+		result.Duration = info.Duration().Seconds()
+		if err != nil {
+			log.Printf("分析失败: %s: %v", hcaFilePath, err)
+			result.Error = err.Error()
+			return
+		}
+		result.Status = "ok"
+		return
+	}
+
+	if execMode() { // 交给外部命令处理
+		log.Printf("正在处理: %s", hcaFilePath)
+		err := runExec(decoder, hcaFilePath)
+		result.Duration = decoder.Info().Duration().Seconds()
+		if err != nil {
+			log.Printf("解码失败: %s: %v", hcaFilePath, err)
+			result.Error = err.Error()
+			return
+		}
+		result.Status = "ok"
+		return
+	}
 
 	// 准备输出文件名和路径
-	outputBaseName := hcaFilePath[:len(hcaFilePath)-len(filepath.Ext(hcaFilePath))] + ".wav"
+	outputBaseName := hcaFilePath[:len(hcaFilePath)-len(filepath.Ext(hcaFilePath))] + format.ext()
 	var outputFilePath string
 
-	if *saveDirFlag != "" { // 如果指定了输出目录
+	if *outputFlag != "" { // 指定了输出文件
+		outputFilePath = *outputFlag
+	} else if nameTmpl != "" { // 按模板命名
+		name, err := expandName(decoder, nameTmpl, hcaFilePath)
+		if err != nil {
+			log.Printf("错误: %v (文件: %s)", err, hcaFilePath)
+			result.Error = err.Error()
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			log.Printf("错误: 无法创建目录 '%s': %v (文件: %s)", filepath.Dir(name), err, hcaFilePath)
+			result.Error = err.Error()
+			return
+		}
+		outputFilePath = name
+	} else if *saveDirFlag != "" { // 如果指定了输出目录, 保留输入目录中的子目录结构
+		rel := inputRel(hcaFilePath)
+		outputFilePath = filepath.Join(*saveDirFlag, strings.TrimSuffix(rel, filepath.Ext(rel))+format.ext())
 		// 确保输出目录存在
-		if err := os.MkdirAll(*saveDirFlag, 0755); err != nil {
-			log.Printf("错误: 无法创建目录 '%s': %v (文件: %s)", *saveDirFlag, err, hcaFilePath)
+		if err := os.MkdirAll(filepath.Dir(outputFilePath), 0755); err != nil {
+			log.Printf("错误: 无法创建目录 '%s': %v (文件: %s)", filepath.Dir(outputFilePath), err, hcaFilePath)
+			result.Error = err.Error()
 			return
 		}
-		outputFilePath = filepath.Join(*saveDirFlag, filepath.Base(outputBaseName))
 	} else { // 否则，输出到源文件相同目录
 		outputFilePath = outputBaseName
 	}
 
-	// 执行解码
 	log.Printf("正在处理: %s -> %s", hcaFilePath, outputFilePath)
-	success := decoder.DecodeFromFile(hcaFilePath, outputFilePath) // 库函数返回 bool
+	if bar != nil {
+		decoder.Progress = func(blocks, total int) { bar.update(hcaFilePath, blocks, total) }
+	}
+	job = &hca.Job{Src: hcaFilePath, Dst: outputFilePath, Decoder: decoder}
+	if throttle != nil {
+		job.Create = createThrottled
+	}
+	result.Output = outputFilePath
+	return job, result
+}
+
+// ignoredError 判断解码错误是否不算作失败: -pad 时已保留截断之前可用的数据
+func ignoredError(err error) bool {
+	return *padFlag && errors.Is(err, hca.ErrTruncated)
+}
 
-	if success {
-		log.Printf("成功解码: %s", outputFilePath)
+// finishFile 根据 hca.DecodeFiles 返回的结果补充 prepareFile 的处理结果
+func finishFile(r hca.Result, result fileResult) fileResult {
+	result.Duration = r.Info.Duration().Seconds()
+	err := r.Err
+	if err != nil && ignoredError(err) {
+		log.Printf("警告: %s: %v", r.Job.Src, err)
+		err = nil
+	}
+
+	if err == nil {
+		log.Printf("成功解码: %s", r.Job.Dst)
+		st := r.Stats
+		log.Printf("  读取 %d 字节, 写出 %d 字节, 用时 %v (%.2f MB/s)", st.BytesRead, st.BytesWritten, st.Elapsed.Round(time.Millisecond), st.Throughput()/1e6)
+		logLevels(st)
+		if c := st.Concealed; len(c) > 0 {
+			log.Printf("  替代了 %d 个损坏块: %v", len(c), c)
+		}
+		result.Status = "ok"
 	} else {
-		// 库本身在 DecodeFromFile 失败时会删除目标文件，所以这里不需要额外删除
-		log.Printf("解码失败: %s (检查库的内部错误或文件是否损坏)", hcaFilePath)
-		// 由于库不返回具体错误，我们只能给出通用提示
+		// 库本身在解码失败时会删除目标文件 (-keep-partial 时保留)，所以这里不需要额外删除
+		log.Printf("解码失败: %s: %v", r.Job.Src, err)
+		result.Error = err.Error()
+		result.Output = ""
 	}
+	return result
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// nameVars 是 -name 模板中可用的变量
+const nameVars = "{dir} {base} {ext} {channels} {rate} {blocks} {seconds} {comment} {title} {cue}"
+
+// nameTemplate 返回输出文件名模板: -name 或 -name-from 生成的模板, 都没有时返回空字符串
+func nameTemplate() (string, error) {
+	switch *nameFromFlag {
+	case "":
+		return *nameFlag, nil
+	case "comment":
+		if *nameFlag != "" {
+			return "", fmt.Errorf("-name 与 -name-from 不能同时使用")
+		}
+		return "{dir}/{title}", nil
+	case "cue":
+		if cues == nil {
+			return "", fmt.Errorf("-name-from cue 需要用 -acb 指定 ACB 文件")
+		}
+		if *nameFlag != "" {
+			return "", fmt.Errorf("-name 与 -name-from 不能同时使用")
+		}
+		return "{dir}/{cue}", nil
+	}
+	return "", fmt.Errorf("未知的 -name-from 值 %q (可用: comment, cue)", *nameFromFlag)
+}
+
+// claimed 记录本次运行中已经使用的输出路径, 避免不同输入写入同一个文件
+var (
+	claimedMu sync.Mutex
+	claimed   = make(map[string]bool)
+)
+
+// expandName 按 -name 模板生成输出路径, 变量的值来自输入文件的头部.
+// 模板不以输出格式的扩展名结尾时添加扩展名
+func expandName(decoder *hca.Hca, tmpl, inputPath string) (string, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	info, err := decoder.Probe(f)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("读取头部失败: %w", err)
+	}
+
+	dir := filepath.Dir(inputPath)
+	if *saveDirFlag != "" { // 保留输入目录中的子目录结构
+		dir = filepath.Join(*saveDirFlag, filepath.Dir(inputRel(inputPath)))
+	}
+	base := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	title := strings.TrimSpace(sanitizeName(info.Comment)) // 有注释时使用注释, 否则使用输入文件名
+	if strings.Trim(title, ".") == "" {
+		title = base
+	}
+	cue, ok := cueName(inputPath) // 有 cue 名称时使用 cue 名称, 否则使用输入文件名
+	if cue = strings.TrimSpace(sanitizeName(cue)); !ok || strings.Trim(cue, ".") == "" {
+		cue = base
+	}
+	r := strings.NewReplacer(
+		"{dir}", dir,
+		"{base}", base,
+		"{ext}", string(format),
+		"{channels}", strconv.Itoa(info.Channels),
+		"{rate}", strconv.Itoa(info.SamplingRate),
+		"{blocks}", strconv.Itoa(info.Blocks),
+		"{seconds}", strconv.FormatFloat(info.Duration().Seconds(), 'f', 3, 64),
+		"{comment}", sanitizeName(info.Comment),
+		"{title}", title,
+		"{cue}", cue,
+	)
+	name := r.Replace(tmpl)
+	if _, ok := formatExts[strings.ToLower(filepath.Ext(name))]; !ok {
+		name += format.ext()
+	}
+	return claimName(name), nil
+}
+
+// claimName 返回本次运行中未使用过的路径, 重复时在扩展名前添加 _2, _3 ...
+func claimName(name string) string {
+	claimedMu.Lock()
+	defer claimedMu.Unlock()
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 2; claimed[filepath.Clean(name)]; i++ {
+		name = fmt.Sprintf("%s_%d%s", stem, i, ext)
+	}
+	claimed[filepath.Clean(name)] = true
+	return name
+}
+
+// sanitizeName 替换文件名中不能使用的字符
+func sanitizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, s)
+}
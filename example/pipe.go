@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// runPipe 处理管道模式: args[0] 为 "-" 时从标准输入读取 HCA,
+// args[1] (或 -o) 为输出文件, 省略或为 "-" 时写入标准输出
+func runPipe(args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("管道模式只接受一个输入与一个输出")
+	}
+	if hasTimeRange() { // 换算样本数需要先读取头部中的采样率
+		return fmt.Errorf("管道模式不支持 -start, -duration, -loops 与 -fade")
+	}
+	out := "-"
+	if len(args) == 2 {
+		out = args[1]
+	} else if *outputFlag != "" {
+		out = *outputFlag
+	}
+	if out != "-" && *formatFlag == "" { // 按输出文件的扩展名推断格式
+		f, err := resolveFormat("", out)
+		if err != nil {
+			return err
+		}
+		format = f
+	}
+	decoder := newDecoder()
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		err = decoder.DecodeStream(bufio.NewReader(os.Stdin), f)
+		f.Close()
+		if err != nil {
+			os.Remove(out)
+		}
+		return err
+	}
+
+	decoder.UnknownSize = true // 标准输出无法回写头部
+	w := bufio.NewWriter(os.Stdout)
+	if err := decoder.DecodeStream(bufio.NewReader(os.Stdin), w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressBar 在终端的一行中显示批量解码的总进度与正在解码的各文件进度,
+// 同时作为 log 的输出, 打印日志前清除进度行, 打印后重新绘制
+type progressBar struct {
+	mu     sync.Mutex
+	out    io.Writer
+	total  int                // 文件总数
+	done   int                // 已完成的文件数
+	active map[string]float64 // 正在解码的文件及其进度 (0~1)
+	start  time.Time
+	drawn  time.Time // 上次绘制的时间, 用于限制刷新频率
+	width  int       // 上次绘制的进度行长度
+}
+
+func newProgressBar(out io.Writer, total int) *progressBar {
+	return &progressBar{out: out, total: total, active: make(map[string]float64), start: time.Now()}
+}
+
+// isTerminal 判断 f 是否为终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// begin 开始解码文件
+func (p *progressBar) begin(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active[name] = 0
+	p.draw(true)
+}
+
+// update 更新文件的解码进度
+func (p *progressBar) update(name string, blocks, total int) {
+	if total <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active[name] = float64(blocks) / float64(total)
+	p.draw(false)
+}
+
+// finish 完成解码文件 (无论成功或失败)
+func (p *progressBar) finish(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.active, name)
+	p.done++
+	p.draw(true)
+}
+
+// Write 实现 io.Writer, 供 log 输出使用
+func (p *progressBar) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	n, err := p.out.Write(b)
+	p.draw(true)
+	return n, err
+}
+
+// close 清除进度行
+func (p *progressBar) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+}
+
+func (p *progressBar) clear() {
+	if p.width > 0 {
+		fmt.Fprintf(p.out, "\r%s\r", strings.Repeat(" ", p.width))
+		p.width = 0
+	}
+}
+
+// draw 绘制进度行, force 为 false 时最多每 100ms 刷新一次
+func (p *progressBar) draw(force bool) {
+	now := time.Now()
+	if !force && now.Sub(p.drawn) < 100*time.Millisecond {
+		return
+	}
+	p.drawn = now
+
+	fraction := float64(p.done) // 总进度 = 已完成的文件 + 正在解码的文件的进度
+	names := make([]string, 0, len(p.active))
+	for name, f := range p.active {
+		fraction += f
+		names = append(names, name)
+	}
+	fraction /= float64(p.total)
+	sort.Strings(names)
+
+	const barWidth = 20
+	filled := int(fraction * barWidth)
+	line := fmt.Sprintf("[%s%s] %3.0f%% %d/%d 文件", strings.Repeat("#", filled), strings.Repeat(".", barWidth-filled), fraction*100, p.done, p.total)
+	if fraction > 0 && fraction < 1 {
+		elapsed := now.Sub(p.start)
+		eta := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+		line += fmt.Sprintf(" 剩余 %s", eta.Round(time.Second))
+	}
+	for _, name := range names {
+		line += fmt.Sprintf(" | %s %3.0f%%", filepath.Base(name), p.active[name]*100)
+	}
+
+	p.clear()
+	fmt.Fprint(p.out, line)
+	p.width = len(line)
+}
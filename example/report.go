@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// fileResult 是单个输入文件的处理结果
+type fileResult struct {
+	Input    string  `json:"input"`
+	Status   string  `json:"status"` // "ok" 或 "failed"
+	Key      string  `json:"key"`    // 使用的 64 位密钥 (十六进制)
+	Duration float64 `json:"duration"`
+	Output   string  `json:"output,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// writeReport 将处理结果写入 path, 扩展名为 .csv 时写出 CSV, 否则写出 JSON
+func writeReport(path string, list []fileResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = writeCSV(f, list)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(list)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeCSV(f *os.File, list []fileResult) error {
+	w := csv.NewWriter(f)
+	w.Write([]string{"input", "status", "key", "duration", "output", "error"})
+	for _, r := range list {
+		w.Write([]string{r.Input, r.Status, r.Key, strconv.FormatFloat(r.Duration, 'f', 3, 64), r.Output, r.Error})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// keyString 返回报告中使用的密钥表示
+func keyString(k uint64) string {
+	return fmt.Sprintf("0x%016X", k)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// subcommand 是以第一个参数选择的子命令, 不解码文件
+type subcommand struct {
+	name  string
+	args  string // 用法中的参数说明
+	usage string
+	run   func(args []string) error
+}
+
+// subcommands 是所有的子命令. 第一个参数是子命令名称时, 其余的参数交给子命令处理, 否则按选项与输入文件解析.
+// 在 init 中赋值, 补全脚本也会列出子命令
+var subcommands []subcommand
+
+func init() {
+	subcommands = []subcommand{
+		{"completion", "bash|zsh|fish|powershell", "生成 shell 补全脚本", runCompletion},
+	}
+}
+
+// lookupSubcommand 返回名称为 name 的子命令, 没有时返回 nil
+func lookupSubcommand(name string) *subcommand {
+	for i := range subcommands {
+		if subcommands[i].name == name {
+			return &subcommands[i]
+		}
+	}
+	return nil
+}
+
+// subcommandNames 返回所有子命令的名称
+func subcommandNames() []string {
+	names := make([]string, len(subcommands))
+	for i, c := range subcommands {
+		names[i] = c.name
+	}
+	return names
+}
+
+// printSubcommands 输出各子命令的用法
+func printSubcommands(w io.Writer) {
+	for _, c := range subcommands {
+		fmt.Fprintf(w, "      %s %s %s\t%s\n", programName(), c.name, c.args, c.usage)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseSize 解析带单位的字节数, 例如 "256MB", "10M", "512k", "1G" 或 "4096"
+func parseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I") // 接受 MB, MiB 与 M
+	mul := int64(1)
+	if n := len(v); n > 0 {
+		switch v[n-1] {
+		case 'K':
+			mul = 1 << 10
+		case 'M':
+			mul = 1 << 20
+		case 'G':
+			mul = 1 << 30
+		}
+		if mul > 1 {
+			v = v[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("无效的大小 %q", s)
+	}
+	return int64(f * float64(mul)), nil
+}
+
+// rateLimiter 限制所有解码任务写入磁盘的总速度
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate float64   // 每秒字节数
+	next time.Time // 下一次写入可以开始的时间
+}
+
+// wait 等待到可以写入 n 个字节
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	start := l.next
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+	time.Sleep(time.Until(start))
+}
+
+// throttle 是 -io-throttle 设置的写入限速, 未设置时为 nil
+var throttle *rateLimiter
+
+// throttledFile 按限速写入文件, 支持 Seek 以便解码结束后修正 WAV 头部
+type throttledFile struct {
+	*os.File
+	limiter *rateLimiter
+}
+
+func (f throttledFile) Write(p []byte) (int, error) {
+	const chunk = 64 << 10 // 分段等待, 使多个任务的写入交替进行
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), chunk)
+		f.limiter.wait(n)
+		m, err := f.File.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+var _ io.WriteSeeker = throttledFile{}
+
+// createThrottled 创建按 -io-throttle 的速度写入的输出文件, 供 hca.Job.Create 使用
+func createThrottled(name string) (io.WriteCloser, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return throttledFile{f, throttle}, nil
+}
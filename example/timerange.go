@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// hasTimeRange 判断是否指定了 -start, -duration, -loops, -fade 或 -crossfade
+func hasTimeRange() bool {
+	return *startFlag > 0 || *durationFlag > 0 || *loopsFlag > 0 || *fadeFlag > 0 || *xfadeFlag > 0
+}
+
+// applyTimeRange 按 -start 与 -duration 设置解码器的输出范围, 样本数按文件的采样率换算
+func applyTimeRange(decoder *hca.Hca, inputPath string) error {
+	if *startFlag < 0 || *durationFlag < 0 || *loopsFlag < 0 || *fadeFlag < 0 || *xfadeFlag < 0 {
+		return fmt.Errorf("-start, -duration, -loops, -fade 与 -crossfade 不能为负数")
+	}
+	if !hasTimeRange() {
+		return nil
+	}
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	info, err := decoder.Probe(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("读取头部失败: %w", err)
+	}
+	decoder.StartSample = durationSamples(*startFlag, info.SamplingRate)
+	decoder.SampleCount = durationSamples(*durationFlag, info.SamplingRate)
+	decoder.FadeOut = durationSamples(*fadeFlag, info.SamplingRate)
+	decoder.LoopCrossfade = durationSamples(*xfadeFlag, info.SamplingRate)
+	if *loopsFlag > 0 && *durationFlag == 0 { // 开头 + N 次循环 + 淡出
+		decoder.Loop = *loopsFlag
+		decoder.SampleCount = max(loopsEnd(info, *loopsFlag)+decoder.FadeOut-decoder.StartSample, 1)
+	}
+	return nil
+}
+
+// loopsEnd 返回展开 n 次循环时第 n 次循环结束的样本帧位置, 没有 loop 块时整个文件作为循环区间
+func loopsEnd(info hca.Info, n int) int64 {
+	start, end := info.LoopStart, info.LoopEnd
+	if !info.Loop {
+		start, end = 0, info.Blocks
+	}
+	blocks := int64(end) + int64(n-1)*int64(end-start)
+	return blocks * info.Samples / int64(max(info.Blocks, 1))
+}
+
+// durationSamples 将时长换算为样本帧数 (向下取整)
+func durationSamples(d time.Duration, rate int) int64 {
+	sec, frac := int64(d/time.Second), int64(d%time.Second) // 分开计算整秒与不足一秒的部分, 避免溢出
+	return sec*int64(rate) + frac*int64(rate)/int64(time.Second)
+}
@@ -0,0 +1,37 @@
+package hca
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// ffmpegFormats 是各写入模式的 PCM 数据对应的 ffmpeg 输入格式
+var ffmpegFormats = map[int]string{
+	ModeFloat: "f32le",
+	Mode8Bit:  "u8", // 8 位 PCM 与 WAV 一致, 为无符号数
+	Mode16Bit: "s16le",
+	Mode24Bit: "s24le",
+	Mode32Bit: "s32le",
+}
+
+// FFmpegArgs return ffmpeg input arguments for headerless output of mode
+// FFmpegArgs 返回以 mode 解码且不带头部 (Headerless) 的输出作为 ffmpeg 输入时需要的参数,
+// 例如 [-f s16le -ar 44100 -ac 2]. mode 无效时返回 nil
+func (i Info) FFmpegArgs(mode int) []string {
+	f, ok := ffmpegFormats[mode]
+	if !ok {
+		return nil
+	}
+	return []string{"-f", f, "-ar", strconv.Itoa(i.SamplingRate), "-ac", strconv.Itoa(i.Channels)}
+}
+
+// FFmpegCommand return ffmpeg command reading headerless output of mode from stdin
+// FFmpegCommand 返回从标准输入读取 (pipe:0) 不带头部的输出的 ffmpeg 命令, args 追加在输入参数之后
+// (输出选项与输出文件). 调用方设置 Stdin 后启动命令; mode 无效时返回 nil
+func (i Info) FFmpegCommand(mode int, args ...string) *exec.Cmd {
+	in := i.FFmpegArgs(mode)
+	if in == nil {
+		return nil
+	}
+	return exec.Command("ffmpeg", append(append(in, "-i", "pipe:0"), args...)...)
+}
@@ -0,0 +1,91 @@
+package hca
+
+import "io"
+
+// DecodeAllFloat32 decode r into interleaved float samples
+// DecodeAllFloat32 将 r 完整解码为交错排列的浮点样本 (范围 -1 到 1), 返回样本, 通道数与采样率.
+// buf 的容量足够时写入 buf 并返回其前面的部分, 否则分配一个恰好容纳全部样本的切片.
+// 使用 h 的循环, 截取范围, 音量, 通道映射, 重采样与效果设置, 忽略 Mode, Headerless 与 Format.
+// 损坏的块按容错策略处理, 数据截断且 RecoverTruncated 时返回已解码的部分
+func (h *Hca) DecodeAllFloat32(r io.ReadSeeker, buf []float32) (samples []float32, channels, sampleRate int, err error) {
+	d := *h // 使用副本解码, 不修改 h 的输出设置
+	d.Format, d.Headerless, d.Mode = "", true, ModeFloat
+	s := &floatSink{}
+	d.capture = func(_ io.Writer, info Info, _ SinkOptions) (Sink, error) {
+		channels, sampleRate = info.Channels, info.SamplingRate
+		need := int(d.finalFrames()) * channels
+		if cap(buf) >= need {
+			s.samples = buf[:0]
+		} else {
+			s.samples = make([]float32, 0, need)
+		}
+		return s, nil
+	}
+	err = d.DecodeWithWriter(r, io.Discard)
+	h.fileState = d.fileState // 保留头部信息与统计, 供 Info 与 Stats 使用
+	if err != nil && !d.recovered(err) {
+		return nil, 0, 0, err
+	}
+	return s.samples, channels, sampleRate, err
+}
+
+// DecodeAllPlanar decode r into one float slice per channel
+// DecodeAllPlanar 与 DecodeAllFloat32 相同, 但按通道分开返回样本 (planes[c] 是第 c 个输出通道),
+// 解码时直接写入各通道的切片, 不需要之后再拆分交错的数据
+func (h *Hca) DecodeAllPlanar(r io.ReadSeeker) (planes [][]float32, sampleRate int, err error) {
+	d := *h // 使用副本解码, 不修改 h 的输出设置
+	d.Format, d.Headerless, d.Mode = "", true, ModeFloat
+	s := &planarSink{}
+	d.capture = func(_ io.Writer, info Info, _ SinkOptions) (Sink, error) {
+		sampleRate = info.SamplingRate
+		frames := int(d.finalFrames())
+		s.planes = make([][]float32, info.Channels)
+		for c := range s.planes {
+			s.planes[c] = make([]float32, 0, frames)
+		}
+		return s, nil
+	}
+	err = d.DecodeWithWriter(r, io.Discard)
+	h.fileState = d.fileState // 保留头部信息与统计, 供 Info 与 Stats 使用
+	if err != nil && !d.recovered(err) {
+		return nil, 0, err
+	}
+	return s.planes, sampleRate, err
+}
+
+// floatSink 将样本追加到切片中
+type floatSink struct {
+	samples []float32
+}
+
+// WriteSamples 追加 samples
+func (s *floatSink) WriteSamples(samples []float32) error {
+	s.samples = append(s.samples, samples...)
+	return nil
+}
+
+// Close 不做任何事
+func (s *floatSink) Close() error {
+	return nil
+}
+
+// planarSink 将交错样本拆分到各通道的切片中
+type planarSink struct {
+	planes [][]float32
+}
+
+// WriteSamples 拆分 samples
+func (s *planarSink) WriteSamples(samples []float32) error {
+	channels := len(s.planes)
+	for i := 0; i+channels <= len(samples); i += channels {
+		for c := range s.planes {
+			s.planes[c] = append(s.planes[c], samples[i+c])
+		}
+	}
+	return nil
+}
+
+// Close 不做任何事
+func (s *planarSink) Close() error {
+	return nil
+}
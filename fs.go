@@ -0,0 +1,69 @@
+package hca
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// openFS 打开 fsys 中的文件, 文件不支持 Seek 时 (例如 zip 中的文件) 将其读取到内存中
+func openFS(fsys fs.FS, name string) (io.ReadSeeker, func() error, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, f.Close, nil
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bytes.NewReader(data), func() error { return nil }, nil
+}
+
+// DecodeFSWithWriter decode file name of fsys into w
+// DecodeFSWithWriter 解码 fsys (例如 embed.FS, zip.Reader) 中的文件 name 并写入 w
+func (h *Hca) DecodeFSWithWriter(fsys fs.FS, name string, w io.Writer) error {
+	r, closeFile, err := openFS(fsys, name)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+	return h.DecodeWithWriter(r, w)
+}
+
+// DecodeFileFS decode file src of fsys into file dst
+// DecodeFileFS 解码 fsys 中的文件 src 并写入磁盘上的文件 dst, 按扩展名选择输出格式并在失败时删除 dst (与 DecodeFile 一致)
+func (h *Hca) DecodeFileFS(fsys fs.FS, src, dst string) error {
+	r, closeFile, err := openFS(fsys, src)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	err = h.decodeBuffer(r, f, h.formatFor(dst))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil && !h.recovered(err) { // 解码失败 (恢复模式下的截断除外)
+		os.Remove(dst) // 删除不完整或错误的输出文件
+	}
+	return err
+}
+
+// ProbeFS read only header of file name of fsys
+// ProbeFS 只读取 fsys 中的文件 name 的头部并返回文件信息
+func (h *Hca) ProbeFS(fsys fs.FS, name string) (Info, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return Info{}, err
+	}
+	defer f.Close()
+	return h.Probe(f)
+}
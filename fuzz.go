@@ -0,0 +1,57 @@
+package hca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// FuzzHeader is fuzzing entry point of header parsing and decoding
+// FuzzHeader 是头部解析与完整解码的模糊测试入口 (go-fuzz 与 libFuzzer 的形式): 把 data 当作 HCA 文件,
+// 读取头部信息并预测输出大小, 头部有效时以 UntrustedLimits 与宽松模式解码全部数据块.
+// 头部有效时返回 1 (值得保留在语料中), 否则返回 0. 任何输入都不应该 panic
+func FuzzHeader(data []byte) int {
+	h := NewDecoder()
+	h.Limits = UntrustedLimits
+	if _, err := h.Probe(bytes.NewReader(data)); err != nil {
+		return 0
+	}
+	if _, err := h.PredictOutputSize(bytes.NewReader(data)); err != nil {
+		return 0
+	}
+	h.Strictness = StrictnessPermissive
+	h.DecodeWithWriter(bytes.NewReader(data), io.Discard) // 只检查是否 panic, 损坏的数据返回错误是正常的
+	return 1
+}
+
+// fuzzBlockHeader 是 FuzzBlock 使用的头部: 双声道, 44100 Hz, 块大小 256 字节 (与 testdata/stereo.hca 相同)
+var fuzzBlockHeader = []byte{
+	0x48, 0x43, 0x41, 0x00, 0x02, 0x00, 0x00, 0x30, 0x66, 0x6d, 0x74, 0x00,
+	0x02, 0x00, 0xac, 0x44, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00,
+	0x63, 0x6f, 0x6d, 0x70, 0x01, 0x00, 0x01, 0x0f, 0x01, 0x00, 0x80, 0x68,
+	0x18, 0x00, 0x00, 0x00, 0x70, 0x61, 0x64, 0x00, 0x00, 0x00, 0x5d, 0x7e,
+}
+
+// FuzzBlock is fuzzing entry point of block decoding
+// FuzzBlock 是数据块解码的模糊测试入口: 把 data 按 256 字节分为数据块 (最后一块以零补齐), 使用固定的头部逐块解码.
+// 每个块的校验和都被修正, 魔术数字错误时仍然解码, 使任意内容都能到达通道解码器.
+// 有块解码成功时返回 1, 否则返回 0. 任何输入都不应该 panic
+func FuzzBlock(data []byte) int {
+	h := NewDecoder()
+	h.MagicPolicy = BlockDecode
+	b, err := h.NewBlockDecoder(bytes.NewReader(fuzzBlockHeader))
+	if err != nil {
+		panic(err) // 固定的头部总是有效
+	}
+	size := int(b.d.blockSize)
+	result := 0
+	for len(data) > 0 {
+		block := make([]byte, size)
+		data = data[copy(block, data):]
+		binary.BigEndian.PutUint16(block[size-2:], checkSum(block[:size-2], 0))
+		if _, err := b.DecodeBlock(block); err == nil {
+			result = 1
+		}
+	}
+	return result
+}
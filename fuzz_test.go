@@ -0,0 +1,52 @@
+package hca_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// seedFiles 返回 testdata 中的 HCA 文件
+func seedFiles(f *testing.F) map[string][]byte {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.hca"))
+	if err != nil || len(paths) == 0 {
+		f.Fatalf("no seed files: %v", err)
+	}
+	files := make(map[string][]byte, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			f.Fatal(err)
+		}
+		files[filepath.Base(p)] = data
+	}
+	return files
+}
+
+func FuzzHeader(f *testing.F) {
+	for name, data := range seedFiles(f) {
+		if hca.FuzzHeader(data) != 1 {
+			f.Errorf("seed %s: header rejected", name)
+		}
+		f.Add(data)
+		f.Add(data[:len(data)/2]) // 截断的文件
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hca.FuzzHeader(data)
+	})
+}
+
+func FuzzBlock(f *testing.F) {
+	data := seedFiles(f)["stereo.hca"]
+	blocks := data[0x30:] // 与 FuzzBlock 的头部相同, 数据块从 0x30 开始
+	if hca.FuzzBlock(blocks) != 1 {
+		f.Error("seed blocks: no block decoded")
+	}
+	f.Add(blocks)
+	f.Add(blocks[:0x100])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hca.FuzzBlock(data)
+	})
+}
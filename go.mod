@@ -1,5 +1,3 @@
 module github.com/WJQSERVER/hca
 
 go 1.24.4
-
-require github.com/vazrupe/endibuf v0.0.0-20160813153856-31abb2524e1c
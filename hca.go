@@ -1,7 +1,11 @@
 package hca
 
 import (
-	"github.com/vazrupe/endibuf"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"time"
 )
 
 // Hca is Hca File Structor
@@ -9,12 +13,149 @@ import (
 type Hca struct {
 	CiphKey1 uint32 // 密码密钥 1
 	CiphKey2 uint32 // 密码密钥 2
+	Subkey   uint16 // 子密钥 (AWB 文件中的 subkey), 非 0 时与 64 位密钥组合后使用
 
 	Mode int // 写入模式（例如 16 位）
-	Loop int // 循环次数
+	// Loop 循环次数: 0 表示不展开循环; N > 0 时输出从开头到循环结束块, 再重复循环区间 N-1 次,
+	// 最后输出从循环开始块到文件末尾. 文件没有 loop 块时整个文件作为循环区间.
+	Loop int
+	// LoopCrossfade 展开循环时每次跳回循环开始之前交叉淡化的样本帧数, 0 表示直接跳转.
+	// 用循环开始之前的数据淡入, 输出的长度不变; 不超过循环开始之前的样本帧数 (整个文件作为循环区间时不起作用)
+	LoopCrossfade int64
 
 	Volume float32 // 音量
+	GainDB float64 // 以分贝表示的增益, 与 Volume 相乘 (例如 -6 约为 Volume 0.5), 可以使用 AnalyzeReplayGain 的 TrackGain
 
+	// TargetLoudness 响度归一化的目标 (LUFS, 例如 -16), 0 表示不归一化. 设置后解码前先完整解码一遍
+	// 测量积分响度 (EBU R128), 按测量结果确定增益并忽略 Volume 与 GainDB. 不能 Seek 的输入会保留全部数据
+	TargetLoudness float64
+
+	Limiter *Limiter // 可选的软限幅器, 在应用音量之后工作, nil 表示超出范围的样本直接削波
+
+	// Processors 按顺序作用于每个块的效果处理, 在应用音量, 通道映射与循环交叉淡化之后,
+	// 限幅与削波之前, 以文件的采样率处理 (重采样之前)
+	Processors []Processor
+
+	ChecksumPolicy   BlockPolicy // 校验和错误块的处理策略
+	SkipChecksum     bool        // 不计算数据块的校验和 (可信的输入), 损坏的块只能通过魔术数字与块内容发现; StrictnessStrict 时无效
+	MagicPolicy      BlockPolicy // 块魔术数字 (0xFFFF) 错误或块内容无法解码时的处理策略
+	RecoverTruncated bool        // 数据截断时保留已解码的部分并修正 WAV 头部
+	PadTruncated     bool        // 数据在块的中间截断时以零补齐这个块并尽量解码 (例如下载中断的文件), 之后的块视为缺失; 包含 RecoverTruncated 的作用
+	KeepPartial      bool        // 解码中途因任何错误 (截断, 读取或写入失败, 损坏块) 中止时都保留已写出的部分并修正 WAV 头部, 仍然返回 *PartialError
+	RejectEmpty      bool        // 文件没有数据块时返回 ErrEmpty, 默认输出空的 WAV
+	Strictness       Strictness  // 解析的严格程度, 非默认值时覆盖上面的各项容错设置
+	Limits           Limits      // 头部数值与输出大小的上限, 零值不限制; 解码不可信的文件时可以使用 UntrustedLimits
+	RVALimit         float32     // rva 块音量的上限, 0 使用 DefaultRVALimit, 负数表示不限制
+	Compat           CompatFlags // 兼容旧版本输出的开关, 默认 (0) 使用规范的输出
+	UnknownSize      bool        // WAV 头部的 RIFF 与 data 大小写为 0xFFFFFFFF (大小未知), 用于无法回写头部的流式输出
+	Headerless       bool        // 不写出 WAV 头部, 只输出 PCM 数据 (raw)
+	Format           string      // 使用 RegisterFormat 注册的输出格式, 为空时输出 WAV (Headerless 时为 raw), 设置后忽略 Mode 与 Headerless
+
+	// FormatOptions 传给输出格式的选项, 例如 hcaogg 的 "quality" 与 hcaflac 的 "level", 各格式忽略不认识的键
+	FormatOptions map[string]string
+
+	// StartSample 与 SampleCount 截取输出的一段: 从展开循环后的第 StartSample 个样本帧开始,
+	// 写出 SampleCount 个样本帧 (0 表示写出到结尾). WAV 头部按截取后的长度写出
+	StartSample int64
+	SampleCount int64
+	// MaxDuration 输出时长的上限 (按文件的采样率换算为样本帧), 与 SampleCount 同时设置时取较短的一个, 0 表示不限制.
+	// 用于生成大文件的预览, 到达上限后停止解码, WAV 头部按实际输出的长度写出
+	MaxDuration time.Duration
+	FadeOut     int64 // 在输出的最后 FadeOut 个样本帧内线性淡出到静音, 0 表示不淡出
+
+	// SampleRate 输出的采样率, 0 表示使用文件的采样率. 与文件不同时按 Resample 的质量重采样,
+	// StartSample, SampleCount, FadeOut 与 WAV 头部以外的样本位置仍然按文件的采样率计算
+	SampleRate int
+	Resample   ResampleQuality
+
+	// ChannelMap 输出通道的映射: 第 i 个输出通道取文件的第 ChannelMap[i] 个通道,
+	// 可以交换 (例如 {1, 0}), 重排, 丢弃或复制通道. 为空时按文件的通道顺序输出
+	ChannelMap []int
+	// ChannelGainDB 每个输出通道 (通道映射之后) 的增益 (dB), 与整体的音量相乘; 比输出通道少时其余通道不变
+	ChannelGainDB []float64
+
+	Tags Tags // 写入 WAV LIST/INFO 块或输出格式注释 (SinkOptions.Tags) 的标签, 全部为空时不写出
+
+	Warn     func(err error)         // 可选的警告回调, 以宽松策略处理损坏块时调用
+	Progress func(blocks, total int) // 可选的进度回调, 每写出一个块后以已写出的块数与预计输出的总块数调用
+	Observer DecodeObserver          // 可选, 每次解码结束时以统计信息调用 (选项无效时除外)
+
+	// Logger 可选的结构化日志, 记录解析的头部块, 使用的密码类型, 损坏块的处理与解码结果 (Debug 与 Warn 级别),
+	// 以及每个解码的块 (LevelTrace 级别). 为 nil 时不记录
+	Logger *slog.Logger
+
+	Analyze bool // 统计每个输出通道的峰值, RMS 与削波的样本数, 结果在 Stats().Channels 中
+
+	// SpectrumTap 可选的频谱回调, 每解码一个块后以该块各通道的幅度谱调用 (包括输出范围之外与循环重复的块,
+	// 不包括丢弃或以静音替代的损坏块). 频谱直接取自解码过程中的 MDCT 系数, 不需要额外的 FFT
+	SpectrumTap func(s *Spectrum)
+
+	// ChannelParallelism 大于 1 时, 多通道文件在每个块内最多使用这么多个 goroutine 并行完成各通道的逆 MDCT 与加窗
+	// (比特流的解析仍然是串行的), 用于加快 5.1/7.1 等环绕声文件的解码. 0 与 1 时不使用额外的 goroutine,
+	// hca_lowmem 与 TinyGo 构建中忽略. 每个块启动 goroutine 时有一次很小的内存分配
+	ChannelParallelism int
+
+	// ReadAhead 为 true 时由后台 goroutine 预先读取, 校验并解密下一个数据块, 与当前块的逆 MDCT 和写出同时进行 (双缓冲),
+	// 用于在机械硬盘, 网络文件系统等慢速介质上隐藏读取的延迟. hca_lowmem 与 TinyGo 构建中忽略
+	ReadAhead bool
+
+	// WriteBufferSize is size of output buffer. There is no Flush: buffered data always reaches the destination
+	// before DecodeWithWriter, DecodeFile and the other decode functions return, also when decoding fails or ctx is cancelled.
+	// A flush error is returned when decoding succeeded (or was recovered), otherwise the decode error is returned.
+	// WriteBufferSize 输出缓冲的大小 (字节), 将每个块的写出合并为较大的写入, 减少机械硬盘与网络文件系统上的系统调用.
+	// 0 时写出到 *os.File (DecodeFile, DecodeFileFS 等) 使用 DefaultWriteBufferSize, 写出到其他 Writer 时不缓冲
+	// (调用方可能已经缓冲, 例如 bufio.Writer 与 HTTP 响应), 负数表示总是不缓冲. 没有单独的 Flush:
+	// DecodeWithWriter, DecodeFile 等解码函数返回之前 (包括解码失败与 ctx 被取消时) 总是写出缓冲中的全部数据.
+	// 解码成功 (或按 RecoverTruncated, KeepPartial 恢复) 时返回写出缓冲的错误, 解码失败时返回解码的错误.
+	// 回写 WAV 头部或 Sink 调用 Seek 之前也会先写出. hca_lowmem 与 TinyGo 构建中默认不缓冲
+	WriteBufferSize int
+
+	closed bool // 是否已调用 Close
+
+	unityGain bool // 不应用 rva 音量与 Volume, 由 ExportBundle 写入描述文件
+
+	capture SinkFactory // 库内部使用的 Sink (AnalyzeReplayGain), 优先于 Format
+
+	fileState // 当前文件的头部信息与解码状态
+}
+
+// Close release decode state, the Hca can not be used after Close
+// Close 释放最近一次解码留下的表与解码器状态, 之后的解码返回 ErrClosed
+func (h *Hca) Close() error {
+	h.fileState = fileState{} // 丢弃 ATH 表, 密码表与通道解码器
+	h.closed = true
+	return nil
+}
+
+// Reset clear per-file state, keeping the configuration
+// Reset 丢弃最近一次解码的头部信息 (注释, 循环点, rva 音量等), 统计与解码器的表, 保留密钥与输出设置.
+// 每次解码开始时都会重新初始化这些状态, Reset 用于在处理下一个文件之前释放内存, 或避免 Info 与 Stats 返回上一个文件的结果
+func (h *Hca) Reset() {
+	h.fileState = fileState{cipher: NewCipher()}
+}
+
+// Clone return a copy carrying only the configuration
+// Clone 返回只带有配置 (密钥, 写入模式, 循环, 音量, 容错策略, 回调等) 的独立副本, 可以在另一个 goroutine 中解码.
+// ChannelMap, ChannelGainDB, FormatOptions, Processors 与 Limiter 会被复制, 但 Processors 中的各个 Processor 与回调函数仍然是共享的,
+// 有状态时需要由调用方保证可以并发使用
+func (h *Hca) Clone() *Hca {
+	c := *h
+	c.closed = false
+	c.fileState = fileState{cipher: NewCipher()}
+	c.ChannelMap = slices.Clone(h.ChannelMap)
+	c.ChannelGainDB = slices.Clone(h.ChannelGainDB)
+	c.Processors = slices.Clone(h.Processors)
+	c.FormatOptions = maps.Clone(h.FormatOptions)
+	if h.Limiter != nil {
+		l := *h.Limiter
+		c.Limiter = &l
+	}
+	return &c
+}
+
+// fileState 保存单个文件的头部信息与解码状态, 每次解码开始时重新初始化,
+// 保证同一个 Hca 重复解码的结果一致
+type fileState struct {
 	version    uint32 // 版本
 	dataOffset uint32 // 数据偏移量
 
@@ -58,7 +199,25 @@ type Hca struct {
 
 	decoder *channelDecoder // 通道解码器（假设 channelDecoder 已定义）
 
-	saver func(f float32, w *endibuf.Writer) // 保存函数，用于将浮点样本写入 endibuf.Writer
+	gain     float32 // 实际应用的音量 (rvaVolume * Volume)
+	normGain float32 // TargetLoudness 时按测量的响度确定的增益
+	position int64   // 已解码的输出样本帧数 (包括输出范围之前的部分)
+
+	stats Stats // 最近一次解码的统计信息
+
+	sink      Sink       // Format 对应的写出器, 为 nil 时按 Mode 写出 PCM
+	resampler *resampler // 输出采样率与文件不同时的重采样器
+
+	blockBuf   []byte // 复用的数据块缓冲
+	shortBlock bool   // 最近读取的块是以零补齐的截断块 (PadTruncated)
+	maskBuf    []byte // 复用的解密结果缓冲
+	outBuf     []byte // 复用的输出样本缓冲
+
+	levels   []channelLevel // Analyze 时每个输出通道的电平统计
+	limiter  *limiterState  // Limiter 的状态
+	xfade    []float32      // LoopCrossfade 时循环开始之前的样本帧
+	balance  []float32      // ChannelGainDB 换算的线性增益
+	spectrum Spectrum       // SpectrumTap 时复用的频谱
 }
 
 // Modes is writting mode num
@@ -71,13 +230,123 @@ const (
 	Mode32Bit = 32 // 32 位模式
 )
 
+// DefaultRVALimit is default upper limit of rva volume
+// DefaultRVALimit 是 rva 块音量的默认上限 (约 +12 dB)
+const DefaultRVALimit = 4
+
+// Tags is metadata written to LIST/INFO chunk
+// Tags 是写入 WAV LIST/INFO 块的元数据, 注册的输出格式通过 SinkOptions 获得, 例如写入 Vorbis comment
+type Tags struct {
+	Title  string // INAM, TITLE
+	Artist string // IART, ARTIST
+	Album  string // IPRD, ALBUM
+	Track  string // ITRK, TRACKNUMBER
+}
+
+// VorbisComments return non-empty tags as Vorbis comments
+// VorbisComments 以 Vorbis comment 的形式 ("TITLE=...") 返回不为空的标签, 供 FLAC 与 Ogg 等输出格式使用
+func (t Tags) VorbisComments() []string {
+	var comments []string
+	for _, tag := range [...]struct{ field, value string }{
+		{"TITLE", t.Title}, {"ARTIST", t.Artist}, {"ALBUM", t.Album}, {"TRACKNUMBER", t.Track},
+	} {
+		if tag.value != "" {
+			comments = append(comments, tag.field+"="+tag.value)
+		}
+	}
+	return comments
+}
+
+// CompatFlags is switches of legacy output quirks
+// CompatFlags 是重现旧版本输出差异的开关, 可以按位组合
+type CompatFlags uint
+
+// CompatFlags values
+// CompatFlags 的取值
+const (
+	CompatSmplBytes   CompatFlags = 1 << iota // smpl 块的循环位置使用数据字节偏移量, 而不是样本帧数
+	CompatPlainFormat                         // 超过 16 位或 2 个通道时仍然使用普通的 fmt 块, 不使用 WAVE_FORMAT_EXTENSIBLE
+)
+
+// BlockPolicy is bad block handling policy
+// BlockPolicy 是损坏块的处理策略
+type BlockPolicy int
+
+// BlockPolicy values
+// BlockPolicy 的取值
+const (
+	BlockStrict BlockPolicy = iota // 严格模式, 遇到损坏块立即失败
+	BlockSkip                      // 丢弃损坏块, 输出会相应变短
+	BlockMute                      // 用一个块长度的静音替代损坏块
+	// BlockDecode 通过 Warn 报告错误后仍然解码损坏块, 保留可能有杂音的内容而不是整块丢弃;
+	// 块内容无法解码时以静音替代. 适合只有个别块损坏的文件
+	BlockDecode
+	// BlockRepeat 重复输出上一个块代替损坏块 (第一个块以静音代替), 与 BlockMute 一样保持输出的长度与循环位置,
+	// 短暂的损坏比插入静音更不明显
+	BlockRepeat
+)
+
+// SetKey set 64-bit key to CiphKey1 and CiphKey2
+// SetKey 将 64 位密钥拆分为 CiphKey1 (低 32 位) 与 CiphKey2 (高 32 位)
+func (h *Hca) SetKey(key uint64) {
+	h.CiphKey1, h.CiphKey2 = uint32(key), uint32(key>>32)
+}
+
+// volume 返回用户指定的线性增益 (Volume 与 GainDB)
+func (h *Hca) volume() float32 {
+	if h.TargetLoudness != 0 && h.normGain != 0 { // 响度归一化的增益
+		return h.normGain
+	}
+	if h.GainDB == 0 {
+		return h.Volume
+	}
+	return h.Volume * float32(math.Pow(10, h.GainDB/20))
+}
+
+// cipherKeys 返回用于初始化密码的密钥, 有子密钥时按 CRI 的方式组合
+func (h *Hca) cipherKeys() (key1, key2 uint32) {
+	key := uint64(h.CiphKey2)<<32 | uint64(h.CiphKey1)
+	if h.Subkey != 0 {
+		key *= uint64(h.Subkey)<<16 | (uint64(^h.Subkey) + 2)
+	}
+	return uint32(key), uint32(key >> 32)
+}
+
 // NewDecoder is create hca with default option
 // NewDecoder 使用默认选项创建 HCA 解码器
 func NewDecoder() *Hca {
 	return &Hca{CiphKey1: 0x30DBE1AB, // 默认密码密钥 1
-		CiphKey2: 0xCC554639,  // 默认密码密钥 2
-		Mode:     16,          // 默认模式为 16 位
-		Loop:     0,           // 默认循环次数为 0
-		Volume:   1.0,         // 默认音量为 1.0
-		cipher:   NewCipher()} // 创建新的密码对象
+		CiphKey2:  0xCC554639,                     // 默认密码密钥 2
+		Mode:      16,                             // 默认模式为 16 位
+		Loop:      0,                              // 默认循环次数为 0
+		Volume:    1.0,                            // 默认音量为 1.0
+		fileState: fileState{cipher: NewCipher()}} // 创建新的密码对象
+}
+
+// WithChannelParallelism set ChannelParallelism and return h
+// WithChannelParallelism 设置 ChannelParallelism 并返回 h, 例如 hca.NewDecoder().WithChannelParallelism(runtime.NumCPU())
+func (h *Hca) WithChannelParallelism(n int) *Hca {
+	h.ChannelParallelism = n
+	return h
+}
+
+// WithReadAhead set ReadAhead and return h
+// WithReadAhead 设置 ReadAhead 并返回 h
+func (h *Hca) WithReadAhead(on bool) *Hca {
+	h.ReadAhead = on
+	return h
+}
+
+// WithSkipChecksum set SkipChecksum and return h
+// WithSkipChecksum 设置 SkipChecksum 并返回 h, 用于批量解码可信的文件
+func (h *Hca) WithSkipChecksum(skip bool) *Hca {
+	h.SkipChecksum = skip
+	return h
+}
+
+// WithWriteBufferSize set WriteBufferSize and return h
+// WithWriteBufferSize 设置 WriteBufferSize 并返回 h
+func (h *Hca) WithWriteBufferSize(n int) *Hca {
+	h.WriteBufferSize = n
+	return h
 }
@@ -2,37 +2,25 @@ package hca
 
 import (
 	"bytes"           // 导入 bytes 包，用于处理字节切片
+	"context"         // 导入 context 包，用于文件解码
 	"encoding/binary" // 导入 encoding/binary 包，用于处理字节序
+	"errors"          // 导入 errors 包，用于错误判断
+	"fmt"             // 导入 fmt 包，用于包装错误信息
 	"io"              // 导入 io 包，用于输入输出操作
-	"os"              // 导入 os 包，用于操作系统相关操作
-
-	"github.com/vazrupe/endibuf" // 导入 endibuf 库
+	"log/slog"        // 导入 log/slog 包，用于结构化日志
+	"math"            // 导入 math 包，用于整数范围检查与样本舍入
 )
 
 // DecodeFromFile is file decode, return decode success/failed
 // DecodeFromFile 是文件解码函数，返回解码成功/失败
 func (h *Hca) DecodeFromFile(src, dst string) bool {
-	f, err := os.Open(src) // 打开源 HCA 文件
-	if err != nil {        // 如果打开文件失败
-		return false // 返回 false
-	}
-	defer f.Close()           // 确保文件关闭
-	r := endibuf.NewReader(f) // 创建一个 endibuf.Reader 来读取文件
-	f2, err := os.Create(dst) // 创建目标 WAV 文件
-	if err != nil {           // 如果创建文件失败
-		return false // 返回 false
-	}
-	w := endibuf.NewWriter(f2) // 创建一个 endibuf.Writer 来写入文件
-
-	success := h.decodeBuffer(r, w) // 调用 decodeBuffer 进行解码
-
-	f2.Close()    // 关闭目标文件
-	if !success { // 如果解码失败
-		os.Remove(dst) // 删除不完整或错误的输出文件
-		return false   // 返回 false
-	}
+	return h.DecodeFile(src, dst) == nil
+}
 
-	return true // 解码成功返回 true
+// DecodeFile is file decode, return decode error
+// DecodeFile 是文件解码函数，返回解码错误
+func (h *Hca) DecodeFile(src, dst string) error {
+	return h.decodeFileContext(context.Background(), src, dst, h.formatFor(dst), nil) // 失败时删除不完整或错误的输出文件
 }
 
 // DecodeFromBytes is []byte data decode
@@ -49,94 +37,326 @@ func (h *Hca) DecodeFromBytes(data []byte) (decoded []byte, ok bool) {
 		return decodedData, false // 长度不足返回 false
 	}
 
-	// create read buffer
-	// 创建读取缓冲区
-	base := bytes.NewReader(data)                    // 创建一个 bytes.Reader 来从字节切片读取
-	buf := io.NewSectionReader(base, 0, base.Size()) // 创建一个 io.SectionReader，以便像文件一样读取
-	r := endibuf.NewReader(buf)                      // 创建一个 endibuf.Reader
-
-	// create temp file (write)
-	// 创建临时文件（用于写入）
-	tempfile, _ := os.CreateTemp("", "hca_wav_temp_") // 创建一个临时文件
-	defer os.Remove(tempfile.Name())                  // 确保临时文件被删除
-	w := endibuf.NewWriter(tempfile)                  // 创建一个 endibuf.Writer
-	w.Endian = binary.LittleEndian                    // 设置写入字节序为小端序
-
-	if !h.decodeBuffer(r, w) { // 调用 decodeBuffer 进行解码
+	w := &memWriter{}                                         // 在内存中写出, 可以 Seek 以修正 WAV 头部
+	err := h.decodeBuffer(bytes.NewReader(data), w, h.Format) // 调用 decodeBuffer 进行解码
+	if err != nil && !h.recovered(err) {                      // 解码失败 (恢复模式下的截断除外)
 		return decodedData, false // 解码失败返回 false
 	}
+	decodedData = w.buf
 
-	tempfile.Seek(0, 0)                   // 将临时文件指针移到开头
-	decodedData, _ = io.ReadAll(tempfile) // 读取临时文件的所有内容
+	return decodedData, err == nil // 返回解码后的数据和成功标志 (截断恢复的数据返回 false)
+}
 
-	return decodedData, true // 返回解码后的数据和成功标志
+// memWriter 是写入内存的 io.WriteSeeker
+type memWriter struct {
+	buf []byte
+	pos int
 }
 
-// decodeBuffer 从 endibuf.Reader 中解码 HCA 数据并写入 endibuf.Writer
-func (h *Hca) decodeBuffer(r *endibuf.Reader, w *endibuf.Writer) bool {
-	saveEndian := r.Endian // 保存当前的读取字节序设置
+func (m *memWriter) Write(b []byte) (int, error) {
+	if end := m.pos + len(b); end > len(m.buf) {
+		m.buf = append(m.buf, make([]byte, end-len(m.buf))...)
+	}
+	m.pos += copy(m.buf[m.pos:], b)
+	return len(b), nil
+}
 
-	r.Endian = binary.BigEndian // 将读取字节序设置为大端序
+func (m *memWriter) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(m.pos)
+	case io.SeekEnd:
+		offset += int64(len(m.buf))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	m.pos = int(offset) // 超过结尾时之后的写入以零填充中间的部分
+	return offset, nil
+}
 
-	// size check
-	// 大小检查
+// checkOptions 检查用户设置的解码选项
+func (h *Hca) checkOptions() error {
 	if h.Loop < 0 { // 检查循环次数是否有效
-		return false // 无效返回 false
+		return fmt.Errorf("%w: loop %d", ErrInvalidOption, h.Loop)
+	}
+	if h.StartSample < 0 || h.SampleCount < 0 || h.MaxDuration < 0 || h.FadeOut < 0 || h.LoopCrossfade < 0 { // 检查输出范围与淡出长度是否有效
+		return fmt.Errorf("%w: sample range %d+%d max %v fade %d crossfade %d", ErrInvalidOption, h.StartSample, h.SampleCount, h.MaxDuration, h.FadeOut, h.LoopCrossfade)
+	}
+	if math.IsNaN(h.GainDB) || math.IsInf(h.GainDB, 0) { // 检查增益是否有效
+		return fmt.Errorf("%w: gain %v dB", ErrInvalidOption, h.GainDB)
+	}
+	if h.TargetLoudness != 0 && !(h.TargetLoudness < 0 && h.TargetLoudness > -70) { // 检查目标响度
+		return fmt.Errorf("%w: target loudness %v LUFS", ErrInvalidOption, h.TargetLoudness)
+	}
+	if h.Limiter != nil {
+		if err := h.Limiter.check(); err != nil {
+			return err
+		}
+	}
+	if h.SampleRate < 0 || h.Resample < ResampleLinear || h.Resample > ResampleSinc { // 检查输出采样率与重采样质量
+		return fmt.Errorf("%w: sample rate %d quality %d", ErrInvalidOption, h.SampleRate, h.Resample)
+	}
+	if h.Format != "" && !IsFormat(h.Format) { // 检查输出格式是否已注册
+		return fmt.Errorf("%w: format %q is not registered", ErrInvalidOption, h.Format)
 	}
 	switch h.Mode { // 检查写入模式是否有效
 	case ModeFloat, Mode8Bit, Mode16Bit, Mode24Bit, Mode32Bit:
-		break // 有效模式，继续
+		return nil // 有效模式
 	default:
-		return false // 无效模式返回 false
+		return fmt.Errorf("%w: mode %d", ErrInvalidOption, h.Mode)
+	}
+}
+
+// checkChannelMap 检查 ChannelMap 与 ChannelGainDB 是否适用于当前文件
+func (h *Hca) checkChannelMap() error {
+	if h.ChannelMap == nil {
+		return h.checkChannelGain()
+	}
+	if len(h.ChannelMap) == 0 || len(h.ChannelMap) > 16 {
+		return fmt.Errorf("%w: channel map has %d channels", ErrInvalidOption, len(h.ChannelMap))
+	}
+	for _, c := range h.ChannelMap {
+		if c < 0 || c >= int(h.channelCount) {
+			return fmt.Errorf("%w: channel map index %d, file has %d channels", ErrInvalidOption, c, h.channelCount)
+		}
+	}
+	return h.checkChannelGain()
+}
+
+// checkChannelGain 检查 ChannelGainDB 是否适用于当前文件
+func (h *Hca) checkChannelGain() error {
+	if len(h.ChannelGainDB) > int(h.outputChannels()) {
+		return fmt.Errorf("%w: %d channel gains, output has %d channels", ErrInvalidOption, len(h.ChannelGainDB), h.outputChannels())
+	}
+	for _, g := range h.ChannelGainDB {
+		if math.IsNaN(g) || math.IsInf(g, 0) {
+			return fmt.Errorf("%w: channel gain %v dB", ErrInvalidOption, g)
+		}
+	}
+	return nil
+}
+
+// outputChannels 返回输出的通道数
+func (h *Hca) outputChannels() uint32 {
+	if h.ChannelMap != nil {
+		return uint32(len(h.ChannelMap))
 	}
+	return h.channelCount
+}
 
-	// header read
-	// 读取头部
-	if !h.loadHeader(r) { // 读取 HCA 头部信息
-		return false // 读取失败返回 false
+// checkStream 检查头部数值是否超过 Limits 与描述的数据块是否可以解码, 没有数据块时按照 RejectEmpty 输出空 WAV 或返回 ErrEmpty
+func (h *Hca) checkStream() error {
+	if err := h.checkLimits(); err != nil { // 检查头部数值是否超过上限
+		return err
+	}
+	if h.blockCount == 0 { // 只有头部的文件
+		if h.RejectEmpty {
+			return ErrEmpty
+		}
+		return nil // 输出只有头部的空 WAV
+	}
+	if h.blockSize == 0 { // 块大小为 0 (可变块大小) 时无法定位数据块
+		return fmt.Errorf("%w: zero block size", ErrInvalidHeader)
 	}
-	r.Seek(int64(h.dataOffset), 0) // 将读取位置移动到数据开始处
+	return nil
+}
 
-	// create temp file (write)
-	// 创建临时文件（用于写入，此行注释可能重复或指代 W 的初始化）
-	w.Endian = binary.LittleEndian // 设置写入字节序为小端序
+// keyCheckBlocks 是检查密钥时读取的开头数据块数量
+const keyCheckBlocks = 8
 
-	wavHeader := h.buildWaveHeader() // 构建 WAV 头部信息
-	wavHeader.Write(w)               // 将 WAV 头部写入 Writer
+// keyCheck 是用当前密钥解密开头数据块的结果. 只有校验和正确的块能够说明密钥是否正确
+// (校验和在加密后的数据上计算, 这样的块在正确的密钥下总能解码), 都不能判断时结果不确定
+type keyCheck struct {
+	passed int   // 解密后能够解码的块数
+	failed int   // 解密后无法解码 (魔术数字或量化参数无效) 的块数
+	first  int64 // 第一个无法解码的块的地址
+}
 
-	// adjust the relative volume
-	// 调整相对音量
-	h.rvaVolume *= h.Volume // 将 RVA 音量与用户指定的音量相乘
+// wrong 判断密钥是否错误: 能够判断的块都无法解码
+func (c keyCheck) wrong() bool {
+	return c.failed > 0 && c.passed == 0
+}
 
-	// decode
-	// 解码
-	if h.Loop == 0 { // 如果没有设置循环次数
-		if !h.decodeFromBytesDecode(r, w, h.dataOffset, h.blockCount) { // 解码从数据开始到总块数
-			return false // 解码失败返回 false
+// verified 判断密钥是否正确: 能够判断的块都能解码
+func (c keyCheck) verified() bool {
+	return c.passed > 0 && c.failed == 0
+}
+
+// checkKey 检查使用密钥加密 (ciph 类型 56) 的文件: 开头能够判断的数据块解密后都无法解码时,
+// 说明密钥不正确, 返回原因为 ErrWrongKey 的 BlockError. 只有部分块无法解码时交给解码过程按损坏块处理,
+// 没有能够判断的块时不报告错误
+func (h *Hca) checkKey(r io.ReadSeeker) error {
+	if h.ciphType != 56 || h.blockCount == 0 {
+		return nil // 没有使用密钥加密
+	}
+	c, err := h.checkKeyBlocks(r)
+	if err != nil {
+		return err
+	}
+	if c.wrong() {
+		return h.blockError(c.first, ErrWrongKey) // 附带无法解密的块的位置
+	}
+	return nil
+}
+
+// checkKeyBlocks 用当前的密钥解密开头的 keyCheckBlocks 个数据块, 统计能够与不能解码的块
+func (h *Hca) checkKeyBlocks(r io.ReadSeeker) (keyCheck, error) {
+	var c keyCheck
+	if _, err := r.Seek(int64(h.dataOffset), io.SeekStart); err != nil {
+		return c, err
+	}
+	decoder := h.newChannelDecoder() // 使用独立的解码器, 不影响正式解码的状态
+	for i := uint32(0); i < h.blockCount && i < keyCheckBlocks; i++ {
+		address := h.blockAddress(i)
+		data, err := h.readBlock(r)
+		if err != nil {
+			break // 截断等错误留给解码过程处理
 		}
-	} else { // 如果设置了循环次数
-		loopBlockOffset := h.dataOffset + h.loopStart*h.blockSize    // 计算循环开始块的偏移量
-		loopBlockCount := h.loopEnd - h.loopStart                    // 计算循环块的数量
-		if !h.decodeFromBytesDecode(r, w, h.dataOffset, h.loopEnd) { // 解码从数据开始到循环结束块
-			return false // 解码失败返回 false
+		if checkSum(data, 0) != 0 {
+			continue // 损坏的块不能说明密钥是否正确
 		}
-		for i := 1; i < h.Loop; i++ { // 循环指定次数
-			if !h.decodeFromBytesDecode(r, w, loopBlockOffset, loopBlockCount) { // 解码循环部分的块
-				return false // 解码失败返回 false
-			}
+		d := &clData{}
+		d.Init(h.mask(data), int(h.blockSize))
+		if d.GetBit(16) == 0xFFFF && decoder.decode(d, h.ath.GetTable()) {
+			c.passed++
+			continue
 		}
-		if !h.decodeFromBytesDecode(r, w, loopBlockOffset, h.blockCount-h.loopStart) { // 解码从循环开始块到总块数（这部分处理剩余的尾部数据）
-			return false // 解码失败返回 false
+		if c.failed == 0 {
+			c.first = address
 		}
+		c.failed++
 	}
+	return c, nil
+}
+
+// decodeLoop 按照循环设置依次解码各段数据块, decodeRange 负责解码从 address 开始的 count 个块.
+// decodeRange 返回 errRangeDone 时 (已写出输出范围内的全部样本) 停止解码
+func (h *Hca) decodeLoop(decodeRange func(address int64, count uint32) error) error {
+	err := h.decodeSegments(decodeRange)
+	if err == errRangeDone {
+		err = nil
+	}
+	h.log(slog.LevelDebug, "hca: decode finished", "blocks", h.stats.Blocks, "bad_blocks", h.stats.BadBlocks,
+		"concealed", len(h.stats.Concealed), "error", err)
+	return err
+}
 
-	r.Endian = saveEndian // 恢复原始的读取字节序设置
+// decodeSegments 依次解码开头, 重复的循环区间与结尾
+func (h *Hca) decodeSegments(decodeRange func(address int64, count uint32) error) error {
+	if h.Loop == 0 { // 如果没有设置循环次数
+		return decodeRange(int64(h.dataOffset), h.blockCount) // 解码从数据开始到总块数
+	}
+	// 如果设置了循环次数
+	loopStart, loopEnd := h.loopRange()                               // 获取循环区间
+	loopBlockOffset := h.blockAddress(loopStart)                      // 计算循环开始块的偏移量
+	loopBlockCount := loopEnd - loopStart                             // 计算循环块的数量
+	if err := decodeRange(int64(h.dataOffset), loopEnd); err != nil { // 解码从数据开始到循环结束块
+		return err
+	}
+	for i := 1; i < h.Loop; i++ { // 循环指定次数
+		if err := decodeRange(loopBlockOffset, loopBlockCount); err != nil { // 解码循环部分的块
+			return err
+		}
+	}
+	return decodeRange(loopBlockOffset, h.blockCount-loopStart) // 解码从循环开始块到总块数（这部分处理剩余的尾部数据）
+}
 
-	return true // 解码成功返回 true
+// loopRange 返回循环区间的开始与结束块索引, 没有 loop 块时整个文件作为循环区间
+func (h *Hca) loopRange() (start, end uint32) {
+	if h.loopFlg {
+		return h.loopStart, h.loopEnd
+	}
+	return 0, h.blockCount
 }
 
-// buildWaveHeader 构建 WAV 头部信息
-func (h *Hca) buildWaveHeader() *stWaveHeader {
+// blockAddress 返回第 index 个数据块在文件中的偏移量 (使用 int64 计算, 避免大文件溢出)
+func (h *Hca) blockAddress(index uint32) int64 {
+	return int64(h.dataOffset) + int64(index)*int64(h.blockSize)
+}
+
+// outputBlocks 返回按照循环设置实际输出的块数 (使用 uint64 计算, 避免大量循环时溢出)
+func (h *Hca) outputBlocks() uint64 {
+	if h.Loop == 0 {
+		return uint64(h.blockCount)
+	}
+	loopStart, loopEnd := h.loopRange()
+	return uint64(h.blockCount) + uint64(loopEnd-loopStart)*uint64(h.Loop) // 总块数 + 循环部分的块数 * 循环次数
+}
+
+// blockBytes 返回 blocks 个数据块解码后的 WAV 数据字节数
+func blockBytes(blocks uint64, samplingSize uint16) uint64 {
+	return blocks * samplesPerBlock * uint64(samplingSize)
+}
+
+// recovered 判断错误是否为恢复模式下已处理的截断或 KeepPartial 时中途失败的解码 (头部解析完成后才会写出数据)
+func (h *Hca) recovered(err error) bool {
+	if h.decoder == nil {
+		return false
+	}
+	var partial *PartialError
+	if h.KeepPartial && errors.As(err, &partial) {
+		return true
+	}
+	return h.recoverTruncated() && errors.Is(err, ErrTruncated)
+}
+
+// partialError 将开始写出数据之后的错误包装为附带已写出数据量的 PartialError, err 为 nil 时返回 nil
+func (h *Hca) partialError(err error) *PartialError {
+	if err == nil {
+		return nil
+	}
+	return &PartialError{Blocks: h.stats.Blocks, Samples: h.stats.Samples, Err: err}
+}
+
+// patchWaveHeader 在输出可 Seek 时按实际写出的数据量修正 WAV 头部的大小字段
+func (h *Hca) patchWaveHeader(wavHeader *stWaveHeader, w io.Writer) error {
+	written := uint64(h.stats.Samples) * uint64(wavHeader.Riff.fmtSamplingSize) // 实际写出的数据大小
+	if written == uint64(wavHeader.Data.dataSize) || h.UnknownSize || h.Headerless {
+		return nil // 大小一致, 无需修正
+	}
+	ws, ok := w.(io.WriteSeeker)
+	if !ok {
+		return nil // 输出不可 Seek, 无法修正
+	}
+	wavHeader.Riff.riffSize -= wavHeader.Data.dataSize - uint32(written) // 按差值调整 Riff 块大小 (written 不超过头部中的大小)
+	wavHeader.Data.dataSize = uint32(written)
+	end, err := ws.Seek(0, io.SeekCurrent) // 记录当前写入位置
+	if err != nil {
+		return err
+	}
+	if _, err := ws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := wavHeader.Write(ws); err != nil { // 重新写入头部
+		return err
+	}
+	_, err = ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// smplOffset 返回第 block 个块开头在 smpl 块中的位置: 默认为样本帧数 (WAV 规范),
+// CompatSmplBytes 时为旧版本使用的数据字节偏移量. 位置相对于输出的开头 (StartSample)
+func (h *Hca) smplOffset(block uint32, samplingSize uint16) uint32 {
+	frames := uint64(block)*samplesPerBlock - uint64(h.StartSample)
+	if rate := h.outputRate(); rate != h.samplingRate { // 换算为输出采样率的位置
+		frames = (frames*uint64(rate) + uint64(h.samplingRate)/2) / uint64(h.samplingRate)
+	}
+	if h.Compat&CompatSmplBytes != 0 {
+		return uint32(frames * uint64(samplingSize))
+	}
+	return uint32(frames)
+}
+
+// smplInRange 判断循环区间是否完整地位于输出范围内, 否则不写出 smpl 块
+func (h *Hca) smplInRange() bool {
+	start := uint64(h.loopStart) * samplesPerBlock
+	end := uint64(h.loopEnd) * samplesPerBlock
+	return start >= uint64(h.StartSample) && end-uint64(h.StartSample) <= h.outputFrames()
+}
+
+// buildWaveHeader 构建 WAV 头部信息, 输出超过 RIFF 的 4GB 限制时返回 ErrOutputTooLarge
+func (h *Hca) buildWaveHeader() (*stWaveHeader, error) {
 	wavHeader := newWaveHeader() // 创建新的 WAV 头部结构体
 
 	riff := wavHeader.Riff // 获取 Riff 块
@@ -144,140 +364,264 @@ func (h *Hca) buildWaveHeader() *stWaveHeader {
 	note := wavHeader.Note // 获取 Note 块
 	data := wavHeader.Data // 获取 Data 块
 
-	if h.Mode > 0 { // 如果模式大于 0 (非浮点模式)
-		riff.fmtType = 1                  // 设置 fmt 类型为 1 (PCM)
-		riff.fmtBitCount = uint16(h.Mode) // 设置每样本位数
+	// 设置格式, 通道数量与采样率, 并计算每样本字节数 (block align) 与每秒字节数;
+	// 超过 16 位或 2 个通道时使用 WAVE_FORMAT_EXTENSIBLE
+	extensible := h.Compat&CompatPlainFormat == 0 // 兼容模式下总是使用普通的 fmt 块
+	if h.Mode > 0 {                               // 如果模式大于 0 (非浮点模式)
+		riff.setFormat(waveFormatPCM, uint16(h.Mode), uint16(h.outputChannels()), h.outputRate(), extensible) // PCM, 每样本位数为写入模式
 	} else { // 如果是浮点模式
-		riff.fmtType = 3      // 设置 fmt 类型为 3 (IEEE Float)
-		riff.fmtBitCount = 32 // 设置每样本位数为 32
+		riff.setFormat(waveFormatFloat, 32, uint16(h.outputChannels()), h.outputRate(), extensible) // IEEE Float, 每样本位数为 32
 	}
-	riff.fmtChannelCount = uint16(h.channelCount)                               // 设置通道数量
-	riff.fmtSamplingRate = h.samplingRate                                       // 设置采样率
-	riff.fmtSamplingSize = riff.fmtBitCount / 8 * riff.fmtChannelCount          // 计算每样本字节数
-	riff.fmtSamplesPerSec = riff.fmtSamplingRate * uint32(riff.fmtSamplingSize) // 计算每秒字节数
 
 	if h.loopFlg { // 如果有循环标志
 		smpl.samplePeriod = uint32(1 / float64(riff.fmtSamplingRate) * 1000000000) // 计算样本周期
-		smpl.loopStart = h.loopStart * 0x80 * 8 * uint32(riff.fmtSamplingSize)     // 计算循环开始的字节偏移量
-		smpl.loopEnd = h.loopEnd * 0x80 * 8 * uint32(riff.fmtSamplingSize)         // 计算循环结束的字节偏移量
+		smpl.loopStart = h.smplOffset(h.loopStart, riff.fmtSamplingSize)           // 计算循环开始的样本位置
+		smpl.loopEnd = h.smplOffset(h.loopEnd, riff.fmtSamplingSize)               // 计算循环结束的样本位置
 		if h.loopR01 == 0x80 {                                                     // 如果 loopR01 是 0x80 (无限循环)
 			smpl.loopPlayCount = 0 // 设置循环播放次数为 0 (无限)
 		} else {
 			smpl.loopPlayCount = h.loopR01 // 否则设置循环播放次数
 		}
-	} else if h.Loop != 0 { // 如果没有循环标志但用户指定了循环次数
-		smpl.loopStart = 0                                                    // 设置循环开始为 0
-		smpl.loopEnd = h.blockCount * 0x80 * 8 * uint32(riff.fmtSamplingSize) // 设置循环结束为总样本数的字节偏移量
-		h.loopStart = 0                                                       // 将 HCA 结构体中的循环开始和结束更新为总范围
-		h.loopEnd = h.blockCount
+	} else if h.Loop != 0 { // 如果没有循环标志但用户指定了循环次数, 整个文件作为循环区间
+		smpl.loopStart = 0                                              // 设置循环开始为 0
+		smpl.loopEnd = h.smplOffset(h.blockCount, riff.fmtSamplingSize) // 设置循环结束为总样本数
 	}
 	if h.commLen > 0 { // 如果有注释
 		wavHeader.NoteOk = true // 标记 Note 块存在
 
-		note.noteSize = 4 + h.commLen + 1 // 计算 Note 块的大小 (4字节长度 + 注释长度 + 1字节结束符)
-		note.comm = h.commComment         // 设置注释内容
-		if (note.noteSize & 3) != 0 {     // 如果 Note 块大小不是 4 的倍数
-			note.noteSize += 4 - (note.noteSize & 3) // 填充到 4 的倍数
-		}
+		note.setComment(h.commComment) // 设置注释内容并按实际写出的注释计算 Note 块的大小 (填充到 4 的倍数)
 	}
-	data.dataSize = h.blockCount*0x80*8*uint32(riff.fmtSamplingSize) + (smpl.loopEnd-smpl.loopStart)*uint32(h.Loop) // 计算数据块大小 (总样本数 + 循环部分的样本数 * (循环次数-1))
-	riff.riffSize = 0x1C + 8 + data.dataSize                                                                        // 计算 Riff 块大小 (固定部分 + 数据块大小)
-	if h.loopFlg && h.Loop == 0 {                                                                                   // 如果有循环标志且用户没有指定循环次数 (使用 HCA 原生的循环)
+	dataSize := h.finalFrames() * uint64(riff.fmtSamplingSize) // 计算数据块大小 (按实际输出的样本帧数计算)
+	riffSize := 4 + 8 + uint64(riff.fmtSize) + 8 + dataSize    // 计算 Riff 块大小 (WAVE + fmt 块 + 数据块)
+	if h.loopFlg && h.Loop == 0 && h.smplInRange() {           // 如果有循环标志且用户没有指定循环次数 (使用 HCA 原生的循环)
 		// smpl Size
-		riff.riffSize += 17 * 4 // 添加 Smpl 块的大小
+		riffSize += 17 * 4      // 添加 Smpl 块的大小
 		wavHeader.SmplOk = true // 标记 Smpl 块存在
 	}
 	if h.commLen > 0 { // 如果有注释
-		riff.riffSize += 8 + note.noteSize // 添加 Note 块的大小
+		riffSize += 8 + uint64(note.noteSize) // 添加 Note 块的大小
+	}
+	list := wavHeader.List // 标签
+	list.add("INAM", h.Tags.Title)
+	list.add("IART", h.Tags.Artist)
+	list.add("IPRD", h.Tags.Album)
+	list.add("ITRK", h.Tags.Track)
+	if len(list.items) > 0 {
+		wavHeader.ListOk = true
+		riffSize += 8 + uint64(list.listSize) // 添加 LIST 块的大小
 	}
+	if h.UnknownSize || h.Headerless { // 流式输出: 大小未知, 播放器读取到数据结束为止 (不写出头部时无需检查大小)
+		dataSize, riffSize = math.MaxUint32, math.MaxUint32
+	} else if riffSize > math.MaxUint32 { // RIFF 的大小字段只有 32 位
+		return nil, fmt.Errorf("%w: %d bytes", ErrOutputTooLarge, riffSize+8)
+	}
+	data.dataSize = uint32(dataSize)
+	riff.riffSize = uint32(riffSize)
 
-	return wavHeader // 返回构建好的 WAV 头部结构体
+	return wavHeader, nil // 返回构建好的 WAV 头部结构体
 }
 
-// decodeFromBytesDecode 从 endibuf.Reader 读取指定数量的块，解码并写入 endibuf.Writer
-func (h *Hca) decodeFromBytesDecode(r *endibuf.Reader, w *endibuf.Writer, address, count uint32) bool {
-	r.Seek(int64(address), 0)            // 将读取位置移动到指定的地址
-	for l := uint32(0); l < count; l++ { // 循环指定数量的块
-		data, _ := r.ReadBytes(int(h.blockSize)) // 读取一个块的数据
-		if !h.decode(data) {                     // 解码当前块
-			return false // 解码失败返回 false
+// readBlock 读取一个完整的数据块, 数据不足一个块时返回 ErrTruncated
+func (h *Hca) readBlock(r io.Reader) ([]byte, error) {
+	if cap(h.blockBuf) < int(h.blockSize) { // 复用缓冲, 返回的数据在读取下一个块时被覆盖
+		h.blockBuf = make([]byte, h.blockSize)
+	}
+	data := h.blockBuf[:h.blockSize]
+	n, short, err := h.fillBlock(r, data)
+	h.stats.BytesRead += int64(n)
+	h.shortBlock = short
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// fillBlock 读取一个完整的数据块到 data, 返回读取的字节数与是否以零补齐, 数据不足一个块时返回 ErrTruncated.
+// 不修改解码状态, 可以在 ReadAhead 的后台 goroutine 中调用
+func (h *Hca) fillBlock(r io.Reader, data []byte) (n int, short bool, err error) {
+	n, err = io.ReadFull(r, data)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF && h.padTruncated() { // 以零补齐, 由 decode 按损坏块解码
+			clear(data[n:])
+			return n, true, nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return n, false, ErrTruncated
 		}
-		saveBlock := h.decoder.waveSerialize(h.rvaVolume) // 将解码后的波形数据序列化
-		h.save(saveBlock, w)                              // 保存波形数据到 Writer
+		return n, false, err
+	}
+	return n, false, nil
+}
 
-		address += h.blockSize // 更新地址到下一个块的开始处
+// blockError 将错误包装为带有块索引与文件偏移量的 BlockError
+func (h *Hca) blockError(address int64, err error) error {
+	return &BlockError{
+		Index:      int((address - int64(h.dataOffset)) / int64(h.blockSize)),
+		FileOffset: address,
+		BlockCount: int(h.blockCount),
+		Cause:      err,
 	}
-	return true // 所有块解码成功返回 true
 }
 
-// decode 解码一个 HCA 数据块
-func (h *Hca) decode(data []byte) bool {
+// decode 解码位于 address 的 HCA 数据块, emit 表示该块是否需要写出
+func (h *Hca) decode(data []byte, address int64) (emit bool, err error) {
 	// block data
 	// 块数据
 	if len(data) < int(h.blockSize) { // 检查数据长度是否与块大小匹配
-		return false // 不匹配返回 false
+		return false, h.blockError(address, ErrTruncated) // 不匹配返回失败
+	}
+	return h.decodeMasked(h.blockSum(data), h.mask(data), address)
+}
+
+// blockSum 返回数据块的校验和, 完好的块为 0. 跳过校验和 (SkipChecksum) 时不计算, 总是返回 0
+func (h *Hca) blockSum(data []byte) uint16 {
+	if h.skipChecksum() {
+		return 0
+	}
+	return checkSum(data, 0)
+}
+
+// mask 解密数据块到复用的缓冲中, 返回的数据在解密下一个块时被覆盖
+func (h *Hca) mask(data []byte) []byte {
+	if cap(h.maskBuf) < len(data) {
+		h.maskBuf = make([]byte, len(data))
 	}
-	if checkSum(data, 0) != 0 { // 检查校验和
-		return false // 校验和错误返回 false
+	return h.cipher.maskTo(h.maskBuf, data) // 使用密码对数据进行掩码操作（解密）
+}
+
+// decodeMasked 解码位于 address 的数据块, sum 为原始数据的校验和, mask 为解密后的数据
+func (h *Hca) decodeMasked(sum uint16, mask []byte, address int64) (emit bool, err error) {
+	damaged := false // 已经按 BlockDecode 报告过错误, 之后的错误以静音替代
+	if sum != 0 {    // 检查校验和
+		h.stats.BadBlocks++
+		switch {
+		case h.shortBlock: // 补齐的部分使校验和错误, 仍然解码已有的数据
+			h.warn(h.blockError(address, ErrTruncated))
+		case h.checksumPolicy() != BlockDecode:
+			return h.badBlock(h.checksumPolicy(), address, ErrChecksumMismatch) // 根据策略处理损坏块
+		default:
+			h.warn(h.blockError(address, ErrChecksumMismatch))
+		}
+		damaged = true
 	}
-	mask := h.cipher.Mask(data)    // 使用密码对数据进行掩码操作（解密）
 	d := &clData{}                 // 创建 clData 对象（假设 clData 是一个比特读取器结构体）
 	d.Init(mask, int(h.blockSize)) // 初始化 clData，使用解密后的数据
-	magic := d.GetBit(16)          // 读取块的魔术数字 (通常应该是 0xFFFF)
-	if magic == 0xFFFF {           // 如果魔术数字正确
-		h.decoder.decode(d, h.ath.GetTable()) // 调用通道解码器进行解码
+	magic := d.GetBit(16)          // 读取块的魔术数字 (应该是 0xFFFF)
+	if magic != 0xFFFF {           // 魔术数字错误
+		h.stats.BadMagic++
+		switch {
+		case damaged:
+			return h.badBlock(BlockMute, address, ErrInvalidBlockMagic)
+		case h.magicPolicy() == BlockDecode:
+			h.warn(h.blockError(address, ErrInvalidBlockMagic))
+			damaged = true
+		default:
+			return h.badBlock(h.magicPolicy(), address, ErrInvalidBlockMagic) // 根据策略处理损坏块
+		}
+	}
+	if !h.decoder.decode(d, h.ath.GetTable()) { // 调用通道解码器进行解码
+		h.stats.BadData++
+		policy := h.magicPolicy() // 块内容无效, 与魔术数字错误使用相同的策略
+		if damaged || policy == BlockDecode {
+			policy = BlockMute
+		}
+		return h.badBlock(policy, address, ErrInvalidBlockData)
+	}
+	h.tapSpectrum(address)
+	if h.logEnabled(LevelTrace) {
+		h.log(LevelTrace, "hca: block decoded", "block", (address-int64(h.dataOffset))/int64(h.blockSize), "offset", address)
+	}
+	return true, nil // 解码成功
+}
+
+// badBlock 按照策略处理位于 address 的损坏块, 宽松策略下通过 Warn 回调报告错误, 替代的块记录在统计中
+func (h *Hca) badBlock(policy BlockPolicy, address int64, cause error) (emit bool, _ error) {
+	err := h.blockError(address, cause)
+	switch policy {
+	case BlockSkip:
+		h.warn(err)
+		return false, nil // 丢弃该块
+	case BlockMute:
+		h.warn(err)
+		h.decoder.mute() // 以静音替代该块
+	case BlockRepeat:
+		h.warn(err) // 通道解码器的输出仍然是上一个块, 直接再输出一次
+	default:
+		return false, err // 严格模式下返回错误
+	}
+	index := int((address - int64(h.dataOffset)) / int64(h.blockSize))
+	h.stats.Concealed = append(h.stats.Concealed, index)
+	h.log(slog.LevelDebug, "hca: block concealed", "block", index, "repeat", policy == BlockRepeat)
+	return true, nil
+}
+
+// progress 调用用户设置的进度回调
+func (h *Hca) progress() {
+	if h.Progress != nil {
+		h.Progress(h.stats.Blocks, int(h.outputBlocks()))
 	}
-	return true // 解码成功返回 true (即使 magic 不为 0xFFFF，只要 checkSum 通过也返回 true，这可能需要根据实际 HCA 规范确认行为)
+}
+
+// warn 调用用户设置的警告回调, 并记录到 Logger
+func (h *Hca) warn(err error) {
+	h.log(slog.LevelWarn, "hca: damaged block", "error", err)
+	if h.Warn != nil {
+		h.Warn(err)
+	}
+}
+
+// checkSumTable 是校验和 (CRC-16, 多项式 0x8005) 的查找表
+var checkSumTable = [256]uint16{
+	0x0000, 0x8005, 0x800F, 0x000A, 0x801B, 0x001E, 0x0014, 0x8011, 0x8033, 0x0036, 0x003C, 0x8039, 0x0028, 0x802D, 0x8027, 0x0022,
+	0x8063, 0x0066, 0x006C, 0x8069, 0x0078, 0x807D, 0x8077, 0x0072, 0x0050, 0x8055, 0x805F, 0x005A, 0x804B, 0x004E, 0x0044, 0x8041,
+	0x80C3, 0x00C6, 0x00CC, 0x80C9, 0x00D8, 0x80DD, 0x80D7, 0x00D2, 0x00F0, 0x80F5, 0x80FF, 0x00FA, 0x80EB, 0x00EE, 0x00E4, 0x80E1,
+	0x00A0, 0x80A5, 0x80AF, 0x00AA, 0x80BB, 0x00BE, 0x00B4, 0x80B1, 0x8093, 0x0096, 0x009C, 0x8099, 0x0088, 0x808D, 0x8087, 0x0082,
+	0x8183, 0x0186, 0x018C, 0x8189, 0x0198, 0x819D, 0x8197, 0x0192, 0x01B0, 0x81B5, 0x81BF, 0x01BA, 0x81AB, 0x01AE, 0x01A4, 0x81A1,
+	0x01E0, 0x81E5, 0x81EF, 0x01EA, 0x81FB, 0x01FE, 0x01F4, 0x81F1, 0x81D3, 0x01D6, 0x01DC, 0x81D9, 0x01C8, 0x81CD, 0x81C7, 0x01C2,
+	0x0140, 0x8145, 0x814F, 0x014A, 0x815B, 0x015E, 0x0154, 0x8151, 0x8173, 0x0176, 0x017C, 0x8179, 0x0168, 0x816D, 0x8167, 0x0162,
+	0x8123, 0x0126, 0x012C, 0x8129, 0x0138, 0x813D, 0x8137, 0x0132, 0x0110, 0x8115, 0x811F, 0x011A, 0x810B, 0x010E, 0x0104, 0x8101,
+	0x8303, 0x0306, 0x030C, 0x8309, 0x0318, 0x831D, 0x8317, 0x0312, 0x0330, 0x8335, 0x833F, 0x033A, 0x832B, 0x032E, 0x0324, 0x8321,
+	0x0360, 0x8365, 0x836F, 0x036A, 0x837B, 0x037E, 0x0374, 0x8371, 0x8353, 0x0356, 0x035C, 0x8359, 0x0348, 0x834D, 0x8347, 0x0342,
+	0x03C0, 0x83C5, 0x83CF, 0x03CA, 0x83DB, 0x03DE, 0x03D4, 0x83D1, 0x83F3, 0x03F6, 0x03FC, 0x83F9, 0x03E8, 0x83ED, 0x83E7, 0x03E2,
+	0x83A3, 0x03A6, 0x03AC, 0x83A9, 0x03B8, 0x83BD, 0x83B7, 0x03B2, 0x0390, 0x8395, 0x839F, 0x039A, 0x838B, 0x038E, 0x0384, 0x8381,
+	0x0280, 0x8285, 0x828F, 0x028A, 0x829B, 0x029E, 0x0294, 0x8291, 0x82B3, 0x02B6, 0x02BC, 0x82B9, 0x02A8, 0x82AD, 0x82A7, 0x02A2,
+	0x82E3, 0x02E6, 0x02EC, 0x82E9, 0x02F8, 0x82FD, 0x82F7, 0x02F2, 0x02D0, 0x82D5, 0x82DF, 0x02DA, 0x82CB, 0x02CE, 0x02C4, 0x82C1,
+	0x8243, 0x0246, 0x024C, 0x8249, 0x0258, 0x825D, 0x8257, 0x0252, 0x0270, 0x8275, 0x827F, 0x027A, 0x826B, 0x026E, 0x0264, 0x8261,
+	0x0220, 0x8225, 0x822F, 0x022A, 0x823B, 0x023E, 0x0234, 0x8231, 0x8213, 0x0216, 0x021C, 0x8219, 0x0208, 0x820D, 0x8207, 0x0202,
 }
 
 // checkSum 计算给定数据的校验和
 func checkSum(data []byte, sum uint16) uint16 {
-	res := sum     // 初始化校验和结果
-	v := []uint16{ // 校验和查找表
-		0x0000, 0x8005, 0x800F, 0x000A, 0x801B, 0x001E, 0x0014, 0x8011, 0x8033, 0x0036, 0x003C, 0x8039, 0x0028, 0x802D, 0x8027, 0x0022,
-		0x8063, 0x0066, 0x006C, 0x8069, 0x0078, 0x807D, 0x8077, 0x0072, 0x0050, 0x8055, 0x805F, 0x005A, 0x804B, 0x004E, 0x0044, 0x8041,
-		0x80C3, 0x00C6, 0x00CC, 0x80C9, 0x00D8, 0x80DD, 0x80D7, 0x00D2, 0x00F0, 0x80F5, 0x80FF, 0x00FA, 0x80EB, 0x00EE, 0x00E4, 0x80E1,
-		0x00A0, 0x80A5, 0x80AF, 0x00AA, 0x80BB, 0x00BE, 0x00B4, 0x80B1, 0x8093, 0x0096, 0x009C, 0x8099, 0x0088, 0x808D, 0x8087, 0x0082,
-		0x8183, 0x0186, 0x018C, 0x8189, 0x0198, 0x819D, 0x8197, 0x0192, 0x01B0, 0x81B5, 0x81BF, 0x01BA, 0x81AB, 0x01AE, 0x01A4, 0x81A1,
-		0x01E0, 0x81E5, 0x81EF, 0x01EA, 0x81FB, 0x01FE, 0x01F4, 0x81F1, 0x81D3, 0x01D6, 0x01DC, 0x81D9, 0x01C8, 0x81CD, 0x81C7, 0x01C2,
-		0x0140, 0x8145, 0x814F, 0x014A, 0x815B, 0x015E, 0x0154, 0x8151, 0x8173, 0x0176, 0x017C, 0x8179, 0x0168, 0x816D, 0x8167, 0x0162,
-		0x8123, 0x0126, 0x012C, 0x8129, 0x0138, 0x813D, 0x8137, 0x0132, 0x0110, 0x8115, 0x811F, 0x011A, 0x810B, 0x010E, 0x0104, 0x8101,
-		0x8303, 0x0306, 0x030C, 0x8309, 0x0318, 0x831D, 0x8317, 0x0312, 0x0330, 0x8335, 0x833F, 0x033A, 0x832B, 0x032E, 0x0324, 0x8321,
-		0x0360, 0x8365, 0x836F, 0x036A, 0x837B, 0x037E, 0x0374, 0x8371, 0x8353, 0x0356, 0x035C, 0x8359, 0x0348, 0x834D, 0x8347, 0x0342,
-		0x03C0, 0x83C5, 0x83CF, 0x03CA, 0x83DB, 0x03DE, 0x03D4, 0x83D1, 0x83F3, 0x03F6, 0x03FC, 0x83F9, 0x03E8, 0x83ED, 0x83E7, 0x03E2,
-		0x83A3, 0x03A6, 0x03AC, 0x83A9, 0x03B8, 0x83BD, 0x83B7, 0x03B2, 0x0390, 0x8395, 0x839F, 0x039A, 0x838B, 0x038E, 0x0384, 0x8381,
-		0x0280, 0x8285, 0x828F, 0x028A, 0x829B, 0x029E, 0x0294, 0x8291, 0x82B3, 0x02B6, 0x02BC, 0x82B9, 0x02A8, 0x82AD, 0x82A7, 0x02A2,
-		0x82E3, 0x02E6, 0x02EC, 0x82E9, 0x02F8, 0x82FD, 0x82F7, 0x02F2, 0x02D0, 0x82D5, 0x82DF, 0x02DA, 0x82CB, 0x02CE, 0x02C4, 0x82C1,
-		0x8243, 0x0246, 0x024C, 0x8249, 0x0258, 0x825D, 0x8257, 0x0252, 0x0270, 0x8275, 0x827F, 0x027A, 0x826B, 0x026E, 0x0264, 0x8261,
-		0x0220, 0x8225, 0x822F, 0x022A, 0x823B, 0x023E, 0x0234, 0x8231, 0x8213, 0x0216, 0x021C, 0x8219, 0x0208, 0x820D, 0x8207, 0x0202,
-	}
+	res := sum                       // 初始化校验和结果
 	for i := 0; i < len(data); i++ { // 遍历数据字节
-		res = (res << 8) ^ v[byte(res>>8)^data[i]] // 计算校验和
+		res = (res << 8) ^ checkSumTable[byte(res>>8)^data[i]] // 计算校验和
 	}
 	return res // 返回计算出的校验和
 }
 
-// save 将浮点样本数据转换为指定模式并写入 endibuf.Writer
-func (h *Hca) save(base []float32, w *endibuf.Writer) {
-	switch h.Mode { // 根据指定的模式进行转换和写入
-	case ModeFloat: // 浮点模式
-		w.WriteData(base) // 直接写入浮点数据
-	case Mode8Bit: // 8 位模式
-		w.WriteData(mode8BitConvert(base)) // 转换为 8 位整型并写入
-	case Mode16Bit: // 16 位模式
-		w.WriteData(mode16BitConvert(base)) // 转换为 16 位整型并写入
-	case Mode24Bit: // 24 位模式
-		w.WriteData(mode24BitConvert(base)) // 转换为 24 位字节切片并写入
-	case Mode32Bit: // 32 位模式
-		w.WriteData(mode32BitConvert(base)) // 转换为 32 位整型并写入
+// 整数模式的样本转换约定: 浮点样本先限制在 [-1, 1] 内 (NaN 视为 0),
+// 再乘以 2^(n-1)-1 并四舍五入 (远离 0), 因此正负满幅对称, 1.0 与 -1.0 分别映射为最大值与其相反数.
+// 8 位 WAV 为无符号格式, 结果再加上 128; 24 位按小端序写出 3 个字节.
+
+// scaleSample 按照转换约定将浮点样本缩放到 [-max, max] 的整数
+func scaleSample(f float32, max float64) int64 {
+	v := float64(f) // 使用 float64 计算, 避免 32 位缩放时的舍入溢出
+	switch {
+	case v != v: // NaN
+		return 0
+	case v > 1:
+		v = 1
+	case v < -1:
+		v = -1
 	}
+	return int64(math.Round(v * max))
 }
 
-// mode8BitConvert 将 float32 切片转换为 8 位整型切片
-func mode8BitConvert(base []float32) []int8 {
-	res := make([]int8, len(base)) // 创建新的 int8 切片
-	for i := range res {           // 遍历浮点切片
-		res[i] = int8(int(base[i]*0x7F) + 0x80) // 转换为 8 位整型，并偏移 0x80 (使其范围为 0 到 255)
+// mode8BitConvert 将 float32 切片转换为 8 位无符号整型切片
+func mode8BitConvert(base []float32) []uint8 {
+	res := make([]uint8, len(base)) // 创建新的 uint8 切片
+	for i := range res {            // 遍历浮点切片
+		res[i] = uint8(scaleSample(base[i], 0x7F) + 0x80) // 转换为 8 位整型，并偏移 0x80 (使其范围为 1 到 255)
 	}
 	return res // 返回转换后的切片
 }
@@ -286,7 +630,7 @@ func mode8BitConvert(base []float32) []int8 {
 func mode16BitConvert(base []float32) []int16 {
 	res := make([]int16, len(base)) // 创建新的 int16 切片
 	for i := range res {            // 遍历浮点切片
-		res[i] = int16(base[i] * 0x7FFF) // 转换为 16 位整型
+		res[i] = int16(scaleSample(base[i], 0x7FFF)) // 转换为 16 位整型
 	}
 	return res // 返回转换后的切片
 }
@@ -296,11 +640,11 @@ func mode24BitConvert(base []float32) []byte {
 	res := make([]byte, len(base)*3) // 创建新的字节切片，大小为 float32 切片长度的 3 倍
 
 	for i := range base { // 遍历浮点切片
-		v := int32(base[i] * 0x7FFFFF) // 转换为 24 位有符号整数 (0x7FFFFF 是 2^23 - 1)
-		// 将 24 位整数拆分为 3 个字节（大端序）
-		res[i*3] = byte((v & 0xFF0000) >> 16)
-		res[i*3+1] = byte((v & 0xFF00) >> 8)
-		res[i*3+2] = byte((v & 0xFF))
+		v := scaleSample(base[i], 0x7FFFFF) // 转换为 24 位有符号整数 (0x7FFFFF 是 2^23 - 1)
+		// 将 24 位整数拆分为 3 个字节（小端序, 与 WAV 的其他字段一致）
+		res[i*3] = byte(v)
+		res[i*3+1] = byte(v >> 8)
+		res[i*3+2] = byte(v >> 16)
 	}
 	return res // 返回转换后的字节切片
 }
@@ -309,7 +653,7 @@ func mode24BitConvert(base []float32) []byte {
 func mode32BitConvert(base []float32) []int32 {
 	res := make([]int32, len(base)) // 创建新的 int32 切片
 	for i := range res {            // 遍历浮点切片
-		res[i] = int32(base[i] * 0x7FFFFFFF) // 转换为 32 位整型
+		res[i] = int32(scaleSample(base[i], 0x7FFFFFFF)) // 转换为 32 位整型
 	}
 	return res // 返回转换后的切片
 }
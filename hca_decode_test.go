@@ -0,0 +1,829 @@
+package hca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// goldenSamples 是各转换函数共用的输入样本
+var goldenSamples = []float32{0, 1, -1, 0.5, -0.5, 2, -2, float32(math.NaN()), 1e-9}
+
+func TestMode8BitConvert(t *testing.T) {
+	want := []uint8{128, 255, 1, 192, 64, 255, 1, 128, 128}
+	if got := mode8BitConvert(goldenSamples); !bytes.Equal(got, want) {
+		t.Errorf("mode8BitConvert = %v, want %v", got, want)
+	}
+}
+
+func TestMode16BitConvert(t *testing.T) {
+	want := []int16{0, 32767, -32767, 16384, -16384, 32767, -32767, 0, 0}
+	got := mode16BitConvert(goldenSamples)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mode16BitConvert(%v) = %d, want %d", goldenSamples[i], got[i], want[i])
+		}
+	}
+}
+
+func TestMode24BitConvert(t *testing.T) {
+	want := []byte{
+		0x00, 0x00, 0x00,
+		0xFF, 0xFF, 0x7F,
+		0x01, 0x00, 0x80,
+		0x00, 0x00, 0x40,
+		0x00, 0x00, 0xC0,
+		0xFF, 0xFF, 0x7F,
+		0x01, 0x00, 0x80,
+		0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00,
+	}
+	if got := mode24BitConvert(goldenSamples); !bytes.Equal(got, want) {
+		t.Errorf("mode24BitConvert = % X, want % X", got, want)
+	}
+}
+
+func TestMode32BitConvert(t *testing.T) {
+	want := []int32{0, 2147483647, -2147483647, 1073741824, -1073741824, 2147483647, -2147483647, 0, 2}
+	got := mode32BitConvert(goldenSamples)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mode32BitConvert(%v) = %d, want %d", goldenSamples[i], got[i], want[i])
+		}
+	}
+}
+
+// TestWave24Bit 检查 24 位输出的 fmt 块 (普通与 WAVE_FORMAT_EXTENSIBLE) 的字段与数据块的大小,
+// 以及每个样本按 3 字节小端序写出, 与 16 位输出的高位相同 (舍入误差以内)
+func TestWave24Bit(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, ok := NewDecoder().DecodeFromBytes(data) // 默认 16 位
+	if !ok {
+		t.Fatal("16-bit decode failed")
+	}
+	_, offset16, _ := waveChunk(want, "data")
+	pcm16 := want[offset16:]
+
+	for _, tc := range []struct {
+		compat CompatFlags
+		typ    uint16
+		size   uint32
+	}{
+		{0, waveFormatExtensible, 40},
+		{CompatPlainFormat, waveFormatPCM, 16},
+	} {
+		h := NewDecoder()
+		h.Mode, h.Compat = Mode24Bit, tc.compat
+		wav, ok := h.DecodeFromBytes(data)
+		if !ok {
+			t.Fatal("24-bit decode failed")
+		}
+		size, at, ok := waveChunk(wav, "fmt ")
+		le := binary.LittleEndian
+		if !ok || size != tc.size || le.Uint16(wav[at:]) != tc.typ || le.Uint16(wav[at+2:]) != 2 ||
+			le.Uint32(wav[at+4:]) != 44100 || le.Uint32(wav[at+8:]) != 44100*6 || le.Uint16(wav[at+12:]) != 6 || le.Uint16(wav[at+14:]) != 24 {
+			t.Errorf("compat %d: fmt chunk % X", tc.compat, wav[at:at+int(size)])
+		}
+		if tc.typ == waveFormatExtensible && le.Uint16(wav[at+18:]) != 24 {
+			t.Errorf("compat %d: valid bits %d", tc.compat, le.Uint16(wav[at+18:]))
+		}
+		size, at, ok = waveChunk(wav, "data")
+		if !ok || int(size) != len(pcm16)/2*3 || at+int(size) != len(wav) {
+			t.Fatalf("compat %d: data chunk %d bytes, want %d", tc.compat, size, len(pcm16)/2*3)
+		}
+		for i := 0; i < len(pcm16)/2; i++ {
+			p := wav[at+3*i:]
+			v24 := int32(uint32(p[0])<<8|uint32(p[1])<<16|uint32(p[2])<<24) >> 8 // 符号扩展
+			v16 := int32(int16(le.Uint16(pcm16[2*i:])))
+			if d := v24>>8 - v16; d < -1 || d > 1 {
+				t.Fatalf("compat %d: sample %d is %#x, 16-bit output %#x", tc.compat, i, v24, v16)
+			}
+		}
+	}
+}
+
+// TestEncodeSamples 检查写出路径的编码与各转换函数按小端序写出的结果一致
+func TestEncodeSamples(t *testing.T) {
+	converted := map[int]any{
+		ModeFloat: goldenSamples,
+		Mode8Bit:  mode8BitConvert(goldenSamples),
+		Mode16Bit: mode16BitConvert(goldenSamples),
+		Mode24Bit: mode24BitConvert(goldenSamples),
+		Mode32Bit: mode32BitConvert(goldenSamples),
+	}
+	for mode, data := range converted {
+		var want bytes.Buffer
+		if err := binary.Write(&want, binary.LittleEndian, data); err != nil {
+			t.Fatal(err)
+		}
+		h := &Hca{Mode: mode}
+		if got := h.encodeSamples(goldenSamples, binary.LittleEndian); !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("mode %d: encodeSamples = % X, want % X", mode, got, want.Bytes())
+		}
+	}
+}
+
+// TestSIMDMatchesGeneric 检查向量化的 IMDCT 与加窗和纯 Go 实现的结果一致
+func TestSIMDMatchesGeneric(t *testing.T) {
+	if !useSIMD {
+		t.Skip("no SIMD implementation on this platform")
+	}
+	defer func(v bool) { useSIMD = v }(useSIMD)
+
+	rng := rand.New(rand.NewSource(1))
+	simd, generic := newChannel(), newChannel()
+	for n := 0; n < 64; n++ {
+		for i := range simd.block {
+			simd.block[i] = rng.Float32()*2 - 1
+		}
+		copy(generic.block, simd.block)
+
+		useSIMD = true
+		calcBlock(simd.block)
+		simd.buildWaveBytes(n & 7)
+		useSIMD = false
+		calcBlock(generic.block)
+		generic.buildWaveBytes(n & 7)
+
+		check := func(name string, got, want []float32) {
+			for i := range want {
+				if d := math.Abs(float64(got[i] - want[i])); d > 1e-5*(1+math.Abs(float64(want[i]))) {
+					t.Fatalf("round %d: %s[%d] = %v, want %v", n, name, i, got[i], want[i])
+				}
+			}
+		}
+		check("block", simd.block, generic.block)
+		check("wave", simd.wave[n&7][:], generic.wave[n&7][:])
+		check("wavTmp", simd.wavTmp, generic.wavTmp)
+	}
+}
+
+// TestReadAhead 检查 ReadAhead 的输出与逐块读取一致 (包括展开循环, 截取范围与截断的文件)
+func TestReadAhead(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.hca"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no test files: %v", err)
+	}
+	options := map[string]func(h *Hca){
+		"plain": func(h *Hca) {},
+		"loop":  func(h *Hca) { h.Loop = 3 },
+		"range": func(h *Hca) { h.StartSample, h.SampleCount = 100, 1500 },
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string][]byte{"": data, " truncated": data[:len(data)-0x20]}
+		for suffix, data := range files {
+			for name, set := range options {
+				decode := func(ahead bool) ([]byte, error) {
+					h := NewDecoder().WithSkipChecksum(true).WithReadAhead(ahead)
+					h.PadTruncated = true
+					set(h)
+					var out bytes.Buffer
+					err := h.DecodeStream(bytes.NewReader(data), &out)
+					return out.Bytes(), err
+				}
+				want, wantErr := decode(false)
+				got, gotErr := decode(true)
+				if (gotErr == nil) != (wantErr == nil) || !bytes.Equal(got, want) {
+					t.Errorf("%s%s %s: read-ahead output differs (err %v, want %v)", filepath.Base(path), suffix, name, gotErr, wantErr)
+				}
+			}
+		}
+	}
+}
+
+// TestSincRange 检查 sinc 重采样的输出在满幅的输入 (方波与放大后削波的解码结果) 下仍然在 -1 到 1 之内
+func TestSincRange(t *testing.T) {
+	inRange := func(samples []float32) bool {
+		for _, f := range samples {
+			if f < -1 || f > 1 {
+				return false
+			}
+		}
+		return true
+	}
+	square := make([]float32, 2*4096)
+	for i := range square {
+		square[i] = float32(1 - 2*(i/64%2)) // 32 帧一个半周期的满幅方波
+	}
+	r := newResampler(2, 44100, 48000, ResampleSinc)
+	out := slices.Clone(r.process(square))
+	out = append(out, r.flush()...)
+	if !inRange(out) || !slices.Contains(out, 1) {
+		t.Error("square wave: resampled samples outside -1 to 1 or not clipped")
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewDecoder()
+	h.Mode, h.SampleRate, h.Resample, h.GainDB = ModeFloat, 48000, ResampleSinc, 24
+	var wav bytes.Buffer
+	if err := h.DecodeWithWriter(bytes.NewReader(data), &wav); err != nil {
+		t.Fatal(err)
+	}
+	size, offset, ok := waveChunk(wav.Bytes(), "data")
+	if !ok {
+		t.Fatal("no data chunk")
+	}
+	samples := make([]float32, size/4)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(wav.Bytes()[offset+4*i:]))
+	}
+	if !inRange(samples) {
+		t.Error("decoded: resampled samples outside -1 to 1")
+	}
+}
+
+// TestReaderSeek 检查 Reader 从任意样本帧 (包括块的边界, 块中间与向前 Seek) 读取的数据与完整解码的结果相同
+func TestReaderSeek(t *testing.T) {
+	for _, name := range []string{"stereo.hca", "mono_loop.hca"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewDecoder()
+		h.Headerless = true
+		var want bytes.Buffer
+		if err := h.DecodeWithWriter(bytes.NewReader(data), &want); err != nil {
+			t.Fatal(err)
+		}
+		pr, err := h.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs := int64(pr.FrameSize())
+		frames := int64(want.Len()) / fs
+		if pr.Length() != int64(want.Len()) {
+			t.Errorf("%s: Length %d, want %d", name, pr.Length(), want.Len())
+		}
+		for _, n := range []int64{frames / 2, 0, 1, samplesPerBlock - 1, samplesPerBlock, samplesPerBlock + 1, 3*samplesPerBlock + 517, frames - 1, 5} {
+			if pos, err := pr.SeekSample(n); err != nil || pos != n*fs {
+				t.Fatalf("%s: SeekSample(%d) = %d, %v", name, n, pos, err)
+			}
+			got := make([]byte, min(2*samplesPerBlock*fs, int64(want.Len())-n*fs))
+			if _, err := io.ReadFull(pr, got); err != nil {
+				t.Fatalf("%s: sample %d: %v", name, n, err)
+			}
+			if !bytes.Equal(got, want.Bytes()[n*fs:n*fs+int64(len(got))]) {
+				t.Errorf("%s: data from sample %d differs from full decode", name, n)
+			}
+		}
+	}
+}
+
+// TestBlockDecoder 检查逐块解码的输出与 DecodeWithWriter 的浮点输出相同, Reset 并解码前一个块之后可以从任意块继续
+func TestBlockDecoder(t *testing.T) {
+	for _, name := range []string{"stereo.hca", "mono_loop.hca"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewDecoder()
+		h.Mode, h.Headerless = ModeFloat, true
+		var pcm bytes.Buffer
+		if err := h.DecodeWithWriter(bytes.NewReader(data), &pcm); err != nil {
+			t.Fatal(err)
+		}
+		want := make([]float32, pcm.Len()/4)
+		for i := range want {
+			want[i] = math.Float32frombits(binary.LittleEndian.Uint32(pcm.Bytes()[4*i:]))
+		}
+
+		b, err := NewDecoder().NewBlockDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := b.Info()
+		size := samplesPerBlock * b.Channels()
+		block := func(i int) []byte {
+			at := b.DataOffset() + int64(i*info.BlockSize)
+			return data[at : at+int64(info.BlockSize)]
+		}
+		for i := range info.Blocks {
+			got, err := b.DecodeBlock(block(i))
+			if err != nil {
+				t.Fatalf("%s: block %d: %v", name, i, err)
+			}
+			if !slices.Equal(got, want[i*size:(i+1)*size]) {
+				t.Errorf("%s: block %d differs from DecodeWithWriter", name, i)
+			}
+		}
+		if b.Stats().Blocks != info.Blocks {
+			t.Errorf("%s: %d blocks in Stats, want %d", name, b.Stats().Blocks, info.Blocks)
+		}
+
+		i := info.Blocks / 2
+		b.Reset(i - 1)
+		if _, err := b.DecodeBlock(block(i - 1)); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := b.DecodeBlock(block(i)); err != nil || !slices.Equal(got, want[i*size:(i+1)*size]) {
+			t.Errorf("%s: block %d after Reset differs from DecodeWithWriter (%v)", name, i, err)
+		}
+	}
+}
+
+// TestForcedLoopSize 检查展开循环 (包括没有 loop 块, 整个文件作为循环区间的文件) 时
+// PredictOutputSize, WAV 头部的 RIFF 与 data 大小都与实际的输出一致
+func TestForcedLoopSize(t *testing.T) {
+	for _, name := range []string{"stereo.hca", "mono_loop.hca"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, loop := range []int{0, 1, 3} {
+			for _, mode := range []int{Mode16Bit, Mode24Bit, ModeFloat} {
+				h := NewDecoder()
+				h.Loop, h.Mode = loop, mode
+				predicted, err := h.PredictOutputSize(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var out bytes.Buffer
+				if err := h.DecodeWithWriter(bytes.NewReader(data), &out); err != nil {
+					t.Fatal(err)
+				}
+				wav := out.Bytes()
+				size, offset, ok := waveChunk(wav, "data")
+				if predicted != int64(len(wav)) || int(binary.LittleEndian.Uint32(wav[4:])) != len(wav)-8 ||
+					!ok || offset+int(size) > len(wav) || int64(size) != h.Stats().Samples*int64(binary.LittleEndian.Uint16(wav[32:])) {
+					t.Errorf("%s loop %d mode %d: predicted %d, RIFF %d, data %d at %d, output %d bytes",
+						name, loop, mode, predicted, binary.LittleEndian.Uint32(wav[4:]), size, offset, len(wav))
+				}
+			}
+		}
+	}
+}
+
+// TestForcedLoop 检查展开循环的输出: 开头到循环结束块, 重复 Loop-1 次循环区间, 最后是循环开始块到文件末尾;
+// 没有 loop 块的文件以整个文件作为循环区间. 每段除第一个块以外 (重叠状态来自跳转之前的块) 与不展开循环的输出相同
+func TestForcedLoop(t *testing.T) {
+	for _, name := range []string{"stereo.hca", "mono_loop.hca"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewDecoder()
+		h.Headerless = true
+		once, ok := h.DecodeFromBytes(data)
+		if !ok {
+			t.Fatalf("%s: decode failed", name)
+		}
+		info := h.Info()
+		start, end := info.LoopStart, info.LoopEnd
+		if !info.Loop {
+			start, end = 0, info.Blocks
+		}
+		blockBytes := len(once) / info.Blocks
+		for _, loop := range []int{1, 3} {
+			h.Loop = loop
+			out, ok := h.DecodeFromBytes(data)
+			if !ok {
+				t.Fatalf("%s loop %d: decode failed", name, loop)
+			}
+			segments := [][2]int{{0, end}} // 每段的开始与结束块
+			for range loop - 1 {
+				segments = append(segments, [2]int{start, end})
+			}
+			segments = append(segments, [2]int{start, info.Blocks})
+			blocks := 0
+			for _, seg := range segments {
+				blocks += seg[1] - seg[0]
+			}
+			if len(out) != blocks*blockBytes {
+				t.Errorf("%s loop %d: %d bytes, want %d blocks", name, loop, len(out), blocks)
+				continue
+			}
+			pos := 0
+			for _, seg := range segments {
+				n := (seg[1] - seg[0]) * blockBytes
+				if n > blockBytes && !bytes.Equal(out[pos+blockBytes:pos+n], once[(seg[0]+1)*blockBytes:seg[1]*blockBytes]) {
+					t.Errorf("%s loop %d: blocks %d-%d at output block %d differ", name, loop, seg[0], seg[1], pos/blockBytes)
+				}
+				pos += n
+			}
+		}
+	}
+}
+
+// decodeAllocs 返回用 h 解码 data 一次的平均内存分配次数
+func decodeAllocs(t *testing.T, h *Hca, data []byte) float64 {
+	return testing.AllocsPerRun(5, func() {
+		if err := h.DecodeWithWriter(bytes.NewReader(data), io.Discard); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// TestSteadyStateAllocs 检查解码的内存分配次数与块数无关: 第一个块之后不再分配内存
+func TestSteadyStateAllocs(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := map[string]func(h *Hca){
+		"default":   func(h *Hca) {},
+		"float":     func(h *Hca) { h.Mode = ModeFloat },
+		"24bit":     func(h *Hca) { h.Mode = 24 },
+		"resample":  func(h *Hca) { h.SampleRate = 22050 },
+		"map":       func(h *Hca) { h.ChannelMap = []int{1, 0} },
+		"gain":      func(h *Hca) { h.ChannelGainDB = []float64{-3, 3} },
+		"analyze":   func(h *Hca) { h.Analyze = true },
+		"limiter":   func(h *Hca) { h.Limiter = &Limiter{} },
+		"spectrum":  func(h *Hca) { h.SpectrumTap = func(*Spectrum) {} },
+		"readahead": func(h *Hca) { h.ReadAhead = true },
+	}
+	for name, set := range options {
+		h := NewDecoder()
+		set(h)
+		h.Loop = 2
+		short := decodeAllocs(t, h, data)
+		h.Loop = 20 // 多解码约 10 倍的块
+		long := decodeAllocs(t, h, data)
+		if long > short {
+			t.Errorf("%s: %v allocations with Loop 20, %v with Loop 2", name, long, short)
+		}
+	}
+}
+
+// BenchmarkDecode 解码展开 100 次循环的文件, allocs/op 是每个文件的分配次数, 不随块数增长
+func BenchmarkDecode(b *testing.B) {
+	data, err := os.ReadFile(filepath.Join("testdata", "mono_loop.hca"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	h := NewDecoder()
+	h.Loop = 100
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := h.DecodeWithWriter(bytes.NewReader(data), io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestSkipChecksum 检查 SkipChecksum 时校验和错误的块按完好的块解码, StrictnessStrict 时仍然校验
+func TestSkipChecksum(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewDecoder()
+	var want bytes.Buffer
+	if err := h.DecodeWithWriter(bytes.NewReader(data), &want); err != nil {
+		t.Fatal(err)
+	}
+	bad := bytes.Clone(data)
+	bad[h.blockAddress(1)-1] ^= 0xFF // 破坏第一个块末尾的校验和, 块的内容不变
+
+	for _, ahead := range []bool{false, true} {
+		h := NewDecoder().WithSkipChecksum(true).WithReadAhead(ahead)
+		var got bytes.Buffer
+		if err := h.DecodeWithWriter(bytes.NewReader(bad), &got); err != nil {
+			t.Fatalf("read-ahead %v: %v", ahead, err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) || h.Stats().BadBlocks != 0 {
+			t.Errorf("read-ahead %v: output differs or %d bad blocks", ahead, h.Stats().BadBlocks)
+		}
+	}
+
+	h = NewDecoder().WithSkipChecksum(true)
+	h.Strictness = StrictnessStrict
+	if err := h.DecodeWithWriter(bytes.NewReader(bad), io.Discard); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("strict: got %v, want ErrChecksumMismatch", err)
+	}
+}
+
+// callWriter 保存写出的数据并统计 Write 的调用次数
+type callWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (c *callWriter) Write(b []byte) (int, error) {
+	c.writes++
+	return c.Buffer.Write(b)
+}
+
+// failWriter 的每次写入都返回 err
+type failWriter struct{ err error }
+
+func (f failWriter) Write([]byte) (int, error) { return 0, f.err }
+
+// TestWriteBuffer 检查输出缓冲合并每个块的写出, 且不改变写出的数据 (包括截断时回写的头部),
+// 返回之前写出缓冲: 写出失败时返回写入的错误, 解码失败时已解码的块仍然写出
+func TestWriteBuffer(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got callWriter
+	h := NewDecoder()
+	h.Loop = 3
+	if err := h.DecodeWithWriter(bytes.NewReader(data), &want); err != nil { // 其他 Writer 默认不缓冲
+		t.Fatal(err)
+	}
+	if err := h.WithWriteBufferSize(1<<20).DecodeWithWriter(bytes.NewReader(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) || got.writes != 1 {
+		t.Errorf("buffered output differs or took %d writes (%d unbuffered)", got.writes, want.writes)
+	}
+
+	errWrite := errors.New("write failed")
+	if err := h.DecodeWithWriter(bytes.NewReader(data), failWriter{errWrite}); !errors.Is(err, errWrite) { // 只有最后写出缓冲时写入
+		t.Errorf("failing writer: got %v, want the write error", err)
+	}
+	var partial callWriter
+	h.Loop = 0
+	if err := h.DecodeWithWriter(bytes.NewReader(data[:len(data)-0x100]), &partial); !errors.Is(err, ErrTruncated) || partial.Len() == 0 {
+		t.Errorf("truncated: got %v and %d bytes, want ErrTruncated after writing the buffered blocks", err, partial.Len())
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "truncated.hca")
+	if err := os.WriteFile(src, data[:len(data)-0x100], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var first []byte
+	for _, size := range []int{-1, 0, 16, 1 << 20} {
+		h := NewDecoder().WithWriteBufferSize(size)
+		h.RecoverTruncated = true
+		dst := filepath.Join(dir, "out.wav")
+		if err := h.DecodeFile(src, dst); !errors.Is(err, ErrTruncated) {
+			t.Fatalf("size %d: got %v, want ErrTruncated", size, err)
+		}
+		out, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = out
+		} else if !bytes.Equal(out, first) {
+			t.Errorf("size %d: output differs from unbuffered", size)
+		}
+		if size := binary.LittleEndian.Uint32(out[4:]); int(size) != len(out)-8 {
+			t.Errorf("RIFF size %d, file has %d bytes", size, len(out))
+		}
+	}
+}
+
+// TestBlockPolicy 检查各个损坏块处理策略的输出与统计: 修改 stereo.hca 第 1 个块中的一个字节使校验和错误
+func TestBlockPolicy(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode := func(data []byte, policy BlockPolicy) ([]byte, *Hca, int, error) {
+		h := NewDecoder()
+		h.Headerless = true
+		h.ChecksumPolicy = policy
+		warnings := 0
+		h.Warn = func(error) { warnings++ }
+		var out bytes.Buffer
+		err := h.DecodeWithWriter(bytes.NewReader(data), &out)
+		return out.Bytes(), h, warnings, err
+	}
+	want, h, _, err := decode(data, BlockStrict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const blockBytes = samplesPerBlock * 2 * 2 // 立体声 16 位
+	bad := bytes.Clone(data)
+	bad[h.blockAddress(1)+0x40] ^= 0xFF
+
+	tests := []struct {
+		policy    BlockPolicy
+		size      int   // 输出的块数
+		concealed []int // 代替的块
+		check     func(t *testing.T, out []byte)
+	}{
+		{BlockSkip, 3, nil, nil},
+		{BlockMute, 4, []int{1}, func(t *testing.T, out []byte) {
+			if !bytes.Equal(out[blockBytes:2*blockBytes], make([]byte, blockBytes)) {
+				t.Error("block 1 is not silent")
+			}
+		}},
+		{BlockRepeat, 4, []int{1}, func(t *testing.T, out []byte) {
+			if !bytes.Equal(out[blockBytes:2*blockBytes], out[:blockBytes]) {
+				t.Error("block 1 does not repeat block 0")
+			}
+		}},
+		{BlockDecode, 4, nil, nil},
+	}
+	for _, tt := range tests {
+		out, h, warnings, err := decode(bad, tt.policy)
+		if err != nil {
+			t.Errorf("policy %d: %v", tt.policy, err)
+			continue
+		}
+		stats := h.Stats()
+		if len(out) != tt.size*blockBytes || stats.Blocks != tt.size {
+			t.Errorf("policy %d: %d bytes, %d blocks, want %d blocks", tt.policy, len(out), stats.Blocks, tt.size)
+			continue
+		}
+		if stats.BadBlocks != 1 || warnings != 1 || !slices.Equal(stats.Concealed, tt.concealed) {
+			t.Errorf("policy %d: %d bad blocks, %d warnings, concealed %v, want 1, 1, %v", tt.policy, stats.BadBlocks, warnings, stats.Concealed, tt.concealed)
+		}
+		if !bytes.Equal(out[:blockBytes], want[:blockBytes]) {
+			t.Errorf("policy %d: block 0 differs", tt.policy)
+		}
+		if tt.check != nil {
+			tt.check(t, out)
+		}
+	}
+
+	_, h, _, err = decode(bad, BlockStrict)
+	var be *BlockError
+	if !errors.As(err, &be) || be.Index != 1 || !errors.Is(err, ErrChecksumMismatch) || h.Stats().BadBlocks != 1 {
+		t.Errorf("strict: got %v, %d bad blocks, want checksum mismatch at block 1", err, h.Stats().BadBlocks)
+	}
+}
+
+// replaceComp 把 stereo.hca 的 comp 块换成 chunk, 以 pad 块补齐原来的头部大小并修正头部校验和
+func replaceComp(data, chunk []byte) []byte {
+	header := append(bytes.Clone(data[:0x18]), chunk...)
+	header = append(header, "pad\x00"...)
+	header = append(header, make([]byte, 0x2E-len(header))...)
+	header = binary.BigEndian.AppendUint16(header, checkSum(header, 0))
+	return append(header, data[0x30:]...)
+}
+
+// TestInvalidHeader 检查频段数错误的 comp 与 dec 块返回 ErrInvalidHeader 而不是在解码时 panic,
+// 与 comp 块参数相同的 dec 块解码结果不变
+func TestInvalidHeader(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, ok := NewDecoder().DecodeFromBytes(data)
+	if !ok {
+		t.Fatal("stereo.hca: decode failed")
+	}
+	dec, ok := NewDecoder().DecodeFromBytes(replaceComp(data, []byte("dec\x00\x01\x00\x01\x0f\x7f\x67\x01\x01")))
+	if !ok || !bytes.Equal(dec, want) {
+		t.Error("dec chunk: output differs from comp chunk")
+	}
+
+	for name, chunk := range map[string]string{
+		"comp bands":     "comp\x01\x00\x01\x0f\x01\x00\x80\x68\x20\x00\x00\x00", // 128 < 0x68+0x20
+		"comp total":     "comp\x01\x00\x01\x0f\x01\x00\x81\x68\x18\x00\x00\x00", // 超过 128 个频段
+		"dec total":      "dec\x00\x01\x00\x01\x0f\x80\x67\x01\x00",              // 129 个频段
+		"dec base bands": "dec\x00\x01\x00\x01\x0f\x7f\x80\x01\x01",              // 基本频段 129 > 128, compR07 下溢
+	} {
+		bad := replaceComp(data, []byte(chunk))
+		if _, err := NewDecoder().Probe(bytes.NewReader(bad)); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: got %v, want ErrInvalidHeader", name, err)
+		}
+		if err := NewDecoder().DecodeWithWriter(bytes.NewReader(bad), io.Discard); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: decode got %v, want ErrInvalidHeader", name, err)
+		}
+	}
+}
+
+// TestRVA 检查 rva 块的音量无效 (NaN, 无穷大, 非正数) 时使用 1, 过大时限制到 RVALimit 并发出警告,
+// 严格模式下返回 ErrInvalidRVA; 不限制音量时浮点输出仍然在 -1 到 1 之内
+func TestRVA(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "mono_loop.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int(binary.BigEndian.Uint16(data[6:]))
+	withRVA := func(v float32) []byte {
+		b := bytes.Clone(data)
+		binary.BigEndian.PutUint32(b[0x3C:], math.Float32bits(v)) // rva 块的音量
+		binary.BigEndian.PutUint16(b[size-2:], checkSum(b[:size-2], 0))
+		return b
+	}
+	inRange := func(wav []byte) bool {
+		n, at, ok := waveChunk(wav, "data")
+		for i := 0; ok && i < int(n)/4; i++ {
+			if f := math.Float32frombits(binary.LittleEndian.Uint32(wav[at+4*i:])); !(f >= -1 && f <= 1) {
+				return false
+			}
+		}
+		return ok
+	}
+
+	for _, tc := range []struct {
+		volume, limit, gain float32
+		warn                bool
+	}{
+		{0.8, 0, 0.8, false},
+		{1e30, 0, DefaultRVALimit, true},
+		{1000, 2, 2, true},
+		{float32(math.NaN()), 0, 1, true},
+		{float32(math.Inf(1)), 0, 1, true},
+		{-3, 0, 1, true},
+		{1e30, -1, 1e30, false}, // 不限制
+	} {
+		h := NewDecoder()
+		h.Mode, h.RVALimit = ModeFloat, tc.limit
+		var warned error
+		h.Warn = func(err error) { warned = err }
+		wav, ok := h.DecodeFromBytes(withRVA(tc.volume))
+		if !ok {
+			t.Errorf("rva %v limit %v: decode failed", tc.volume, tc.limit)
+			continue
+		}
+		if gain := h.Stats().Gain; gain != tc.gain || errors.Is(warned, ErrInvalidRVA) != tc.warn {
+			t.Errorf("rva %v limit %v: gain %v, warning %v, want %v", tc.volume, tc.limit, gain, warned, tc.gain)
+		}
+		if !inRange(wav) {
+			t.Errorf("rva %v limit %v: samples outside -1 to 1", tc.volume, tc.limit)
+		}
+	}
+
+	h := NewDecoder()
+	h.Strictness = StrictnessStrict
+	if err := h.DecodeWithWriter(bytes.NewReader(withRVA(1e30)), io.Discard); !errors.Is(err, ErrInvalidRVA) {
+		t.Errorf("strict: got %v, want ErrInvalidRVA", err)
+	}
+}
+
+// waveChunk 返回 WAV 数据中 id 块的大小与内容的偏移量, 没有该块时 ok 为 false
+func waveChunk(wav []byte, id string) (size uint32, offset int, ok bool) {
+	for p := 12; p+8 <= len(wav); p += 8 + int(size+size&1) {
+		size = binary.LittleEndian.Uint32(wav[p+4:])
+		if string(wav[p:p+4]) == id {
+			return size, p + 8, true
+		}
+	}
+	return 0, 0, false
+}
+
+// TestRecoverTruncated 检查 RecoverTruncated 时保留截断前的块, 返回 ErrTruncated 并修正 WAV 头部的大小
+func TestRecoverTruncated(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewDecoder()
+	if err := h.loadHeader(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	truncated := data[:h.blockAddress(3)+0x10] // 3 个完整的块与第 4 个块的开头
+
+	h.RecoverTruncated = true
+	w := &memWriter{}
+	if err := h.DecodeWithWriter(bytes.NewReader(truncated), w); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want ErrTruncated", err)
+	}
+	out := w.buf
+	if size := binary.LittleEndian.Uint32(out[4:]); int(size) != len(out)-8 {
+		t.Errorf("RIFF size %d, file has %d bytes", size, len(out))
+	}
+	size, offset, ok := waveChunk(out, "data")
+	if !ok || size != 3*samplesPerBlock*2*2 || offset+int(size) != len(out) {
+		t.Errorf("data chunk %d bytes at %d (found %v), file has %d bytes", size, offset, ok, len(out))
+	}
+	if h.Stats().Blocks != 3 {
+		t.Errorf("%d blocks decoded, want 3", h.Stats().Blocks)
+	}
+
+	h.RecoverTruncated = false
+	if _, ok := h.DecodeFromBytes(truncated); ok {
+		t.Error("truncated file decoded without RecoverTruncated")
+	}
+}
+
+// TestEmpty 检查只有头部的文件默认输出没有样本的 WAV, RejectEmpty 时返回 ErrEmpty
+func TestEmpty(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int(binary.BigEndian.Uint16(data[6:]))
+	header := bytes.Clone(data[:size])
+	binary.BigEndian.PutUint32(header[0x10:], 0) // fmt 块的块数
+	binary.BigEndian.PutUint16(header[size-2:], checkSum(header[:size-2], 0))
+
+	var out bytes.Buffer
+	if err := NewDecoder().DecodeWithWriter(bytes.NewReader(header), &out); err != nil {
+		t.Fatal(err)
+	}
+	if n, offset, ok := waveChunk(out.Bytes(), "data"); !ok || n != 0 || offset != out.Len() {
+		t.Errorf("data chunk %d bytes at %d (found %v), file has %d bytes", n, offset, ok, out.Len())
+	}
+
+	h := NewDecoder()
+	h.RejectEmpty = true
+	if err := h.DecodeWithWriter(bytes.NewReader(header), io.Discard); !errors.Is(err, ErrEmpty) {
+		t.Errorf("got %v, want ErrEmpty", err)
+	}
+}
@@ -1,9 +1,13 @@
 package hca
 
 import (
+	"bytes"           // 导入 bytes 包，用于在内存中解析头部
 	"encoding/binary" // 导入 encoding/binary 包，用于处理字节序
-
-	"github.com/vazrupe/endibuf" // 导入 endibuf 库
+	"errors"          // 导入 errors 包，用于错误判断
+	"fmt"             // 导入 fmt 包，用于包装错误信息
+	"io"              // 导入 io 包，用于读取完整的头部
+	"log/slog"        // 导入 log/slog 包，用于结构化日志
+	"math"            // 导入 math 包，用于检查 rva 音量
 )
 
 const (
@@ -18,130 +22,127 @@ const (
 	sigCIPH = 0x63697068 // ciph 签名
 	sigRVA  = 0x72766100 // rva 签名
 	sigCOMM = 0x636F6D6D // comm 签名
+	sigPAD  = 0x70616400 // pad 签名
 )
 
-// loadHeader 从 endibuf.Reader 中读取 HCA 头部信息
-func (h *Hca) loadHeader(r *endibuf.Reader) bool {
-	endianSave := r.Endian      // 保存当前的字节序设置
-	r.Endian = binary.BigEndian // 将字节序设置为大端序
-
-	var sig uint32 // 用于存储读取的块签名
+// loadHeader 从 r 中读取 HCA 头部信息
+// 头部在数据偏移量之前结束时返回 ErrTruncated, 头部内容无效时返回 ErrInvalidHeader
+func (h *Hca) loadHeader(r io.Reader) error {
+	header, err := readHeader(r) // 读取完整的头部 (到数据偏移量为止)
+	h.stats.BytesRead += int64(len(header))
+	if err != nil && !(h.Strictness == StrictnessPermissive && len(header) > 0) {
+		return err // 宽松模式下尽量使用已读取的部分头部
+	}
+	hr := bytes.NewReader(header) // 在内存中解析头部 (大端序)
 
-	// HCA 块
-	r.ReadData(&sig)           // 读取 HCA 块签名
-	if sig&sigMask == sigHCA { // 检查签名是否匹配 HCA
-		if !h.hcaHeaderRead(r) { // 读取 HCA 头部详细信息
-			return false // 读取失败返回 false
+	// next 读取下一个块签名, 到达头部末尾时返回 0 (表示后面没有块)
+	next := func() uint32 {
+		var sig uint32
+		if binary.Read(hr, binary.BigEndian, &sig) != nil {
+			return 0
 		}
+		return sig
+	}
 
-		r.ReadData(&sig) // 读取下一个块签名
-	} else {
-		return false // HCA 签名不匹配返回 false
+	// HCA 块
+	if err := h.hcaHeaderRead(hr); err != nil { // 读取 HCA 头部详细信息
+		return err
 	}
 
 	// fmt 块
-	if sig&sigMask == sigFMT { // 检查签名是否匹配 fmt
-		if !h.fmtHeaderRead(r) { // 读取 fmt 头部详细信息
-			return false // 读取失败返回 false
-		}
-		r.ReadData(&sig) // 读取下一个块签名
-	} else {
-		return false // fmt 签名不匹配返回 false
+	if next()&sigMask != sigFMT { // 检查签名是否匹配 fmt
+		return fmt.Errorf("%w: missing fmt chunk", ErrInvalidHeader)
 	}
-
-	if sig&sigMask == sigCOMP { // 检查签名是否匹配 comp
-		// comp 块
-		if !h.compHeaderRead(r) { // 读取 comp 头部详细信息
-			return false // 读取失败返回 false
-		}
-		r.ReadData(&sig) // 读取下一个块签名
-	} else if sig&sigMask == sigDEC { // 检查签名是否匹配 dec
-		// dec 块
-		if !h.decHeaderRead(r) { // 读取 dec 头部详细信息
-			return false // 读取失败返回 false
-		}
-		r.ReadData(&sig) // 读取下一个块签名
-	} else {
-		return false // comp 或 dec 签名不匹配返回 false
+	if err := h.fmtHeaderRead(hr); err != nil { // 读取 fmt 头部详细信息
+		return err
 	}
+	h.log(slog.LevelDebug, "hca: header chunk", "chunk", "fmt", "version", h.version,
+		"channels", h.channelCount, "rate", h.samplingRate, "blocks", h.blockCount)
 
-	// vbr 块
-	if sig&sigMask == sigVBR { // 检查签名是否匹配 vbr
-		if !h.vbrHeaderRead(r) { // 读取 vbr 头部详细信息
-			return false // 读取失败返回 false
-		}
-		r.ReadData(&sig) // 读取下一个块签名
-	} else {
-		h.vbrR01 = 0 // 如果没有 vbr 块，设置默认值
-		h.vbrR02 = 0
+	switch next() & sigMask {
+	case sigCOMP: // comp 块
+		err = h.compHeaderRead(hr)
+	case sigDEC: // dec 块
+		err = h.decHeaderRead(hr)
+	default:
+		err = fmt.Errorf("%w: missing comp or dec chunk", ErrInvalidHeader)
 	}
-
-	// ath 块
-	if sig&sigMask == sigATH { // 检查签名是否匹配 ath
-		if !h.athHeaderRead(r) { // 读取 ath 头部详细信息
-			return false // 读取失败返回 false
-		}
-		r.ReadData(&sig) // 读取下一个块签名
-	} else {
-		if h.version < 0x200 { // 如果没有 ath 块，根据版本设置默认类型
-			h.athType = 1
-		} else {
-			h.athType = 0
-		}
+	if err != nil {
+		return err
 	}
+	h.log(slog.LevelDebug, "hca: header chunk", "chunk", "comp", "block_size", h.blockSize)
 
-	// loop 块
-	if sig&sigMask == sigLOOP { // 检查签名是否匹配 loop
-		if !h.loopHeaderRead(r) { // 读取 loop 头部详细信息
-			return false // 读取失败返回 false
-		}
-		r.ReadData(&sig) // 读取下一个块签名
+	// 可选块的默认值
+	h.vbrR01 = 0 // 没有 vbr 块
+	h.vbrR02 = 0
+	if h.version < 0x200 { // 没有 ath 块时根据版本设置默认类型
+		h.athType = 1
 	} else {
-		h.loopStart = 0 // 如果没有 loop 块，设置默认值
-		h.loopEnd = 0
-		h.loopR01 = 0
-		h.loopR02 = 0x400
-		h.loopFlg = false
+		h.athType = 0
 	}
+	h.loopStart = 0 // 没有 loop 块
+	h.loopEnd = 0
+	h.loopR01 = 0
+	h.loopR02 = 0x400
+	h.loopFlg = false
+	h.ciphType = 0  // 没有 ciph 块时无密码
+	h.rvaVolume = 1 // 没有 rva 块时音量为 1
+	h.commLen = 0   // 没有 comm 块
+	h.commComment = ""
 
-	// ciph 块
-	if sig&sigMask == sigCIPH { // 检查签名是否匹配 ciph
-		if !h.ciphHeaderRead(r) { // 读取 ciph 头部详细信息
-			return false // 读取失败返回 false
+	// 可选块按固定顺序出现, 不存在的块保持默认值
+	optional := []struct {
+		sig  uint32
+		name string
+		read func(r *bytes.Reader) error
+	}{
+		{sigVBR, "vbr", h.vbrHeaderRead},
+		{sigATH, "ath", h.athHeaderRead},
+		{sigLOOP, "loop", h.loopHeaderRead},
+		{sigCIPH, "ciph", h.ciphHeaderRead},
+		{sigRVA, "rva", h.rvaHeaderRead},
+		{sigCOMM, "comm", h.commHeaderRead},
+	}
+	sig := next()
+	for _, chunk := range optional {
+		if sig&sigMask != chunk.sig {
+			continue // 块不存在
 		}
-		r.ReadData(&sig) // 读取下一个块签名
-	} else {
-		h.ciphType = 0 // 如果没有 ciph 块，设置默认类型为 0 (无密码)
+		if err := chunk.read(hr); err != nil {
+			if h.Strictness == StrictnessPermissive && errors.Is(err, ErrTruncated) {
+				sig = 0 // 宽松模式下把被截断的可选块当作不存在
+				break
+			}
+			return err
+		}
+		h.log(slog.LevelDebug, "hca: header chunk", "chunk", chunk.name)
+		sig = next() // 读取下一个块签名
 	}
 
-	// rva 块
-	if sig&sigMask == sigRVA { // 检查签名是否匹配 rva
-		if !h.rvaHeaderRead(r) { // 读取 rva 头部详细信息
-			return false // 读取失败返回 false
+	// 严格模式下检查未知的块与头部校验和
+	if h.Strictness == StrictnessStrict {
+		if sig != 0 && hr.Size()-int64(hr.Len())-4 < int64(h.dataOffset)-2 && sig&sigMask != sigPAD { // 头部校验和之前还有无法识别的块
+			return fmt.Errorf("%w: unknown chunk %q", ErrInvalidHeader, binary.BigEndian.AppendUint32(nil, sig&sigMask))
+		}
+		if checkSum(header, 0) != 0 { // 头部末尾的校验和使整体校验结果为 0
+			return fmt.Errorf("%w: header checksum mismatch", ErrInvalidHeader)
 		}
-		r.ReadData(&sig) // 读取下一个块签名
-	} else {
-		h.rvaVolume = 1 // 如果没有 rva 块，设置默认音量为 1
 	}
 
-	// comm 块
-	if sig&sigMask == sigCOMM { // 检查签名是否匹配 comm
-		if !h.commHeaderRead(r) { // 读取 comm 头部详细信息
-			return false // 读取失败返回 false
-		}
-	} else {
-		h.commLen = 0 // 如果没有 comm 块，设置默认值
-		h.commComment = ""
+	if err := h.checkRVA(); err != nil { // 检查 rva 音量
+		return err
 	}
 
 	// 初始化
 	if !h.ath.Init(int(h.athType), h.samplingRate) { // 初始化 ATH
-		return false // 初始化失败返回 false
+		return fmt.Errorf("%w: ath type %d", ErrInvalidHeader, h.athType)
 	}
-	h.cipher = NewCipher()                                       // 创建新的密码对象
-	if !h.cipher.Init(int(h.ciphType), h.CiphKey1, h.CiphKey2) { // 初始化密码
-		return false // 初始化失败返回 false
+	h.cipher = NewCipher()                           // 创建新的密码对象
+	key1, key2 := h.cipherKeys()                     // 组合子密钥
+	if !h.cipher.Init(int(h.ciphType), key1, key2) { // 初始化密码
+		return fmt.Errorf("%w %d", ErrUnsupportedCipher, h.ciphType)
 	}
+	h.log(slog.LevelDebug, "hca: cipher", "type", h.ciphType)
 
 	// 数值检查（为了避免头部修改错误引起的错误）
 	if h.compR03 == 0 {
@@ -150,48 +151,139 @@ func (h *Hca) loadHeader(r *endibuf.Reader) bool {
 
 	// 解码准备
 	if !(h.compR01 == 1 && h.compR02 == 15) { // 检查 compR01 和 compR02 的特定值
-		return false // 不匹配返回 false
+		return fmt.Errorf("%w: unsupported comp parameters %d/%d", ErrInvalidHeader, h.compR01, h.compR02)
 	}
-	h.compR09 = ceil2(h.compR05-(h.compR06+h.compR07), h.compR08)                                                              // 计算 compR09
-	h.decoder = newChannelDecoder(h.channelCount, h.compR03, h.compR04, h.compR05, h.compR06, h.compR07, h.compR08, h.compR09) // 创建新的通道解码器
+	if h.compR05 > 0x80 || h.compR06 > h.compR05 || h.compR07 > h.compR05-h.compR06 { // 频段数不能超过每个子块的 128 个频段
+		return fmt.Errorf("%w: band counts %d/%d/%d", ErrInvalidHeader, h.compR05, h.compR06, h.compR07)
+	}
+	h.compR09 = ceil2(h.compR05-(h.compR06+h.compR07), h.compR08) // 计算 compR09
+	h.decoder = h.newChannelDecoder()                             // 创建新的通道解码器
+	if h.SpectrumTap != nil {
+		h.decoder.spectrum = make([][8][SpectrumBins]float32, h.channelCount)
+	}
+	if h.ChannelParallelism > 1 && h.channelCount > 1 && !lowMemory {
+		h.decoder.setParallel(min(h.ChannelParallelism, int(h.channelCount)))
+	}
+
+	return nil // 头部读取成功
+}
 
-	r.Endian = endianSave // 恢复原始的字节序设置
-	return true           // 头部读取成功返回 true
+// newChannelDecoder 按照头部参数创建通道解码器
+func (h *Hca) newChannelDecoder() *channelDecoder {
+	return newChannelDecoder(h.channelCount, h.compR03, h.compR04, h.compR05, h.compR06, h.compR07, h.compR08, h.compR09)
+}
+
+// readHeader 从 r 的当前位置读取到数据偏移量为止的完整头部
+// 头部不完整时返回已读取的部分与 ErrTruncated
+func readHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, 8) // 签名, 版本与数据偏移量
+	if n, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return header[:n], fmt.Errorf("%w: header", ErrTruncated)
+		}
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(header)&sigMask != sigHCA { // 检查签名是否匹配 HCA
+		return nil, ErrInvalidSignature
+	}
+	dataOffset := int(binary.BigEndian.Uint16(header[6:]))
+	if dataOffset < len(header) { // 数据偏移量不能落在头部之内
+		return nil, fmt.Errorf("%w: data offset %d", ErrInvalidHeader, dataOffset)
+	}
+	header = append(header, make([]byte, dataOffset-len(header))...)
+	if n, err := io.ReadFull(r, header[8:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return header[:8+n], fmt.Errorf("%w: header ends at %d of %d bytes", ErrTruncated, 8+n, dataOffset)
+		}
+		return nil, err
+	}
+	return header, nil
+}
+
+// checkRVA 检查 rva 块的音量: NaN, Inf 与非正数视为 1, 超过上限时限制到上限,
+// 宽松与默认模式下通过 Warn 回调报告, 严格模式下返回错误
+func (h *Hca) checkRVA() error {
+	limit := float64(h.RVALimit)
+	if limit == 0 {
+		limit = DefaultRVALimit
+	}
+	v := float64(h.rvaVolume)
+	fixed := v
+	switch {
+	case math.IsNaN(v) || math.IsInf(v, 0) || v <= 0:
+		fixed = 1
+	case limit > 0 && v > limit:
+		fixed = limit
+	default:
+		return nil // 有效值
+	}
+	err := fmt.Errorf("%w: %v", ErrInvalidRVA, h.rvaVolume)
+	if h.Strictness == StrictnessStrict {
+		return fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	h.warn(err)
+	h.rvaVolume = float32(fixed)
+	return nil
+}
+
+// chunkError 将读取块时的错误转换为 ErrTruncated (块在头部结束前没有读完)
+func chunkError(name string, err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %s chunk", ErrTruncated, name)
+	}
+	return err
 }
 
 // hcaHeaderRead 读取 HCA 块的详细信息
-func (h *Hca) hcaHeaderRead(r *endibuf.Reader) bool {
-	version, _ := r.ReadUint16()    // 读取版本
-	dataOffset, _ := r.ReadUint16() // 读取数据偏移量
-	h.version = uint32(version)
-	h.dataOffset = uint32(dataOffset)
-	return true // 读取成功返回 true
+func (h *Hca) hcaHeaderRead(r *bytes.Reader) error {
+	var chunk struct {
+		Sig        uint32
+		Version    uint16 // 版本
+		DataOffset uint16 // 数据偏移量
+	}
+	if err := binary.Read(r, binary.BigEndian, &chunk); err != nil {
+		return chunkError("HCA", err)
+	}
+	h.version = uint32(chunk.Version)
+	h.dataOffset = uint32(chunk.DataOffset)
+	return nil // 读取成功
 }
 
 // fmtHeaderRead 读取 fmt 块的详细信息
-func (h *Hca) fmtHeaderRead(r *endibuf.Reader) bool {
-	ui, _ := r.ReadUint32()                  // 读取一个 uint32 字段
-	h.channelCount = (ui & 0xFF000000) >> 24 // 提取通道数量
-	h.samplingRate = ui & 0x00FFFFFF         // 提取采样率
-	h.blockCount, _ = r.ReadUint32()         // 读取块总数
-	fmtR01, _ := r.ReadUint16()              // 读取 fmtR01
-	fmtR02, _ := r.ReadUint16()              // 读取 fmtR02
-	h.fmtR01 = uint32(fmtR01)
-	h.fmtR02 = uint32(fmtR02)
+func (h *Hca) fmtHeaderRead(r *bytes.Reader) error {
+	var chunk struct {
+		ChannelsAndRate uint32 // 高 8 位为通道数量, 低 24 位为采样率
+		BlockCount      uint32 // 块总数
+		R01, R02        uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &chunk); err != nil {
+		return chunkError("fmt", err)
+	}
+	h.channelCount = (chunk.ChannelsAndRate & 0xFF000000) >> 24 // 提取通道数量
+	h.samplingRate = chunk.ChannelsAndRate & 0x00FFFFFF         // 提取采样率
+	h.blockCount = chunk.BlockCount
+	h.fmtR01 = uint32(chunk.R01)
+	h.fmtR02 = uint32(chunk.R02)
 	if !(h.channelCount >= 1 && h.channelCount <= 16) { // 检查通道数量的有效范围
-		return false // 无效返回 false
+		return fmt.Errorf("%w: channel count %d", ErrInvalidHeader, h.channelCount)
 	}
 	if !(h.samplingRate >= 1 && h.samplingRate <= 0x7FFFFF) { // 检查采样率的有效范围
-		return false // 无效返回 false
+		return fmt.Errorf("%w: sampling rate %d", ErrInvalidHeader, h.samplingRate)
 	}
-	return true // 读取成功返回 true
+	return nil // 读取成功
 }
 
 // compHeaderRead 读取 comp 块的详细信息
-func (h *Hca) compHeaderRead(r *endibuf.Reader) bool {
-	blockSize, _ := r.ReadUint16() // 读取块大小
-	h.blockSize = uint32(blockSize)
-	datas, _ := r.ReadBytes(10) // 读取接下来的 10 个字节
+func (h *Hca) compHeaderRead(r *bytes.Reader) error {
+	var chunk struct {
+		BlockSize uint16   // 块大小
+		Datas     [10]byte // R01 ~ R08 与保留字节
+	}
+	if err := binary.Read(r, binary.BigEndian, &chunk); err != nil {
+		return chunkError("comp", err)
+	}
+	datas := chunk.Datas
+	h.blockSize = uint32(chunk.BlockSize)
 	h.compR01 = uint32(datas[0])
 	h.compR02 = uint32(datas[1])
 	h.compR03 = uint32(datas[2])
@@ -201,19 +293,25 @@ func (h *Hca) compHeaderRead(r *endibuf.Reader) bool {
 	h.compR07 = uint32(datas[6])
 	h.compR08 = uint32(datas[7])
 	if !((h.blockSize >= 8 && h.blockSize <= 0xFFFF) || (h.blockSize == 0)) { // 检查块大小的有效范围
-		return false // 无效返回 false
+		return fmt.Errorf("%w: block size %d", ErrInvalidHeader, h.blockSize)
 	}
 	if !(h.compR01 >= 0 && h.compR01 <= h.compR02 && h.compR02 <= 0x1F) { // 检查 compR01 和 compR02 的有效范围
-		return false // 无效返回 false
+		return fmt.Errorf("%w: comp parameters %d/%d", ErrInvalidHeader, h.compR01, h.compR02)
 	}
-	return true // 读取成功返回 true
+	return nil // 读取成功
 }
 
 // decHeaderRead 读取 dec 块的详细信息
-func (h *Hca) decHeaderRead(r *endibuf.Reader) bool {
-	blockSize, _ := r.ReadUint16() // 读取块大小
-	h.blockSize = uint32(blockSize)
-	datas, _ := r.ReadBytes(6) // 读取接下来的 6 个字节
+func (h *Hca) decHeaderRead(r *bytes.Reader) error {
+	var chunk struct {
+		BlockSize uint16  // 块大小
+		Datas     [6]byte // dec 块参数
+	}
+	if err := binary.Read(r, binary.BigEndian, &chunk); err != nil {
+		return chunkError("dec", err)
+	}
+	datas := chunk.Datas
+	h.blockSize = uint32(chunk.BlockSize)
 	h.compR01 = uint32(datas[0])
 	h.compR02 = uint32(datas[1])
 	h.compR03 = uint32(datas[4] & 0xF) // 提取 compR03
@@ -224,72 +322,116 @@ func (h *Hca) decHeaderRead(r *endibuf.Reader) bool {
 	} else {
 		h.compR06 = uint32(datas[2]) + 1
 	}
+	if h.compR06 > h.compR05 { // 基本频段数超过总频段数时 compR07 会下溢
+		return fmt.Errorf("%w: dec band counts %d/%d", ErrInvalidHeader, h.compR05, h.compR06)
+	}
 	h.compR07 = h.compR05 - h.compR06                                       // 计算 compR07
 	h.compR08 = 0                                                           // compR08 在 dec 块中为 0
 	if !((h.blockSize >= 8 && h.blockSize <= 0xFFFF) || h.blockSize == 0) { // 检查块大小的有效范围
-		return false // 无效返回 false
+		return fmt.Errorf("%w: block size %d", ErrInvalidHeader, h.blockSize)
 	}
 	if !(h.compR01 >= 0 && h.compR01 <= h.compR02 && h.compR02 <= 0x1F) { // 检查 compR01 和 compR02 的有效范围
-		return false // 无效返回 false
+		return fmt.Errorf("%w: dec parameters %d/%d", ErrInvalidHeader, h.compR01, h.compR02)
 	}
 	if h.compR03 == 0 { // 如果 compR03 为 0，设置为 1
 		h.compR03 = 1
 	}
-	return true // 读取成功返回 true
+	return nil // 读取成功
 }
 
 // vbrHeaderRead 读取 vbr 块的详细信息
-func (h *Hca) vbrHeaderRead(r *endibuf.Reader) bool {
-	tmp, _ := r.ReadUint16() // 读取 vbrR01
-	h.vbrR01 = uint32(tmp)
-	tmp, _ = r.ReadUint16() // 读取 vbrR02
-	h.vbrR02 = uint32(tmp)
-	return true // 读取成功返回 true
+func (h *Hca) vbrHeaderRead(r *bytes.Reader) error {
+	var chunk struct{ R01, R02 uint16 }
+	if err := binary.Read(r, binary.BigEndian, &chunk); err != nil {
+		return chunkError("vbr", err)
+	}
+	h.vbrR01 = uint32(chunk.R01)
+	h.vbrR02 = uint32(chunk.R02)
+	return nil // 读取成功
 }
 
 // athHeaderRead 读取 ath 块的详细信息
-func (h *Hca) athHeaderRead(r *endibuf.Reader) bool {
-	tmp, _ := r.ReadUint16() // 读取 athType
-	h.athType = uint32(tmp)
-	return true // 读取成功返回 true
+func (h *Hca) athHeaderRead(r *bytes.Reader) error {
+	var athType uint16
+	if err := binary.Read(r, binary.BigEndian, &athType); err != nil {
+		return chunkError("ath", err)
+	}
+	h.athType = uint32(athType)
+	return nil // 读取成功
 }
 
 // loopHeaderRead 读取 loop 块的详细信息
-func (h *Hca) loopHeaderRead(r *endibuf.Reader) bool {
-	h.loopStart, _ = r.ReadUint32() // 读取循环开始块索引
-	h.loopEnd, _ = r.ReadUint32()   // 读取循环结束块索引
-	tmp, _ := r.ReadUint16()        // 读取 loopR01
-	h.loopR01 = uint32(tmp)
-	tmp, _ = r.ReadUint16() // 读取 loopR02
-	h.loopR02 = uint32(tmp)
-	if !(h.loopStart >= 0 && h.loopStart <= h.loopEnd && h.loopEnd < h.blockCount) { // 检查循环范围的有效性
-		return false // 无效返回 false
-	}
-	return true // 读取成功返回 true
+func (h *Hca) loopHeaderRead(r *bytes.Reader) error {
+	var chunk struct {
+		Start, End uint32 // 循环开始与结束块索引
+		R01, R02   uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &chunk); err != nil {
+		return chunkError("loop", err)
+	}
+	if !(chunk.Start <= chunk.End && chunk.End < h.blockCount) { // 检查循环范围的有效性
+		return fmt.Errorf("%w: loop %d-%d of %d blocks", ErrInvalidLoopRange, chunk.Start, chunk.End, h.blockCount)
+	}
+	h.loopStart = chunk.Start
+	h.loopEnd = chunk.End
+	h.loopR01 = uint32(chunk.R01)
+	h.loopR02 = uint32(chunk.R02)
+	h.loopFlg = true // 文件带有循环区间
+	return nil       // 读取成功
 }
 
 // ciphHeaderRead 读取 ciph 块的详细信息
-func (h *Hca) ciphHeaderRead(r *endibuf.Reader) bool {
-	tmp, _ := r.ReadUint16() // 读取 ciphType
-	h.ciphType = uint32(tmp)
-	if !(h.ciphType == 0 || h.ciphType == 1 || h.ciphType == 0x38) { // 检查 ciphType 的有效值
-		return false // 无效返回 false
+func (h *Hca) ciphHeaderRead(r *bytes.Reader) error {
+	var ciphType uint16
+	if err := binary.Read(r, binary.BigEndian, &ciphType); err != nil {
+		return chunkError("ciph", err)
+	}
+	if !(ciphType == 0 || ciphType == 1 || ciphType == 0x38) { // 检查 ciphType 的有效值
+		return fmt.Errorf("%w %d", ErrUnsupportedCipher, ciphType)
 	}
-	return true // 读取成功返回 true
+	h.ciphType = uint32(ciphType)
+	return nil // 读取成功
 }
 
 // rvaHeaderRead 读取 rva 块的详细信息
-func (h *Hca) rvaHeaderRead(r *endibuf.Reader) bool {
-	h.rvaVolume, _ = r.ReadFloat32() // 读取 rvaVolume
-	return true                      // 读取成功返回 true
+func (h *Hca) rvaHeaderRead(r *bytes.Reader) error {
+	var volume float32
+	if err := binary.Read(r, binary.BigEndian, &volume); err != nil {
+		return chunkError("rva", err)
+	}
+	h.rvaVolume = volume
+	return nil // 读取成功
 }
 
 // commHeaderRead 读取 comm 块的详细信息
-func (h *Hca) commHeaderRead(r *endibuf.Reader) bool {
-	tmp, _ := r.ReadByte() // 读取注释长度
-	h.commLen = uint32(tmp)
-	h.commComment, _ = r.ReadCString() // 读取注释字符串（假设 ReadCString 存在并能读取 C 风格字符串）
-	return true                        // 读取成功返回 true
+// 注释只在已读取的头部 (数据偏移量之前) 中查找, 长度超过 commLen 的部分被截去
+func (h *Hca) commHeaderRead(r *bytes.Reader) error {
+	var commLen uint8 // 注释长度
+	if err := binary.Read(r, binary.BigEndian, &commLen); err != nil {
+		return chunkError("comm", err)
+	}
+	var comment []byte
+	for { // 读取以 0 结尾的注释字符串
+		var c [1]byte
+		if _, err := io.ReadFull(r, c[:]); err != nil {
+			return chunkError("comm", err)
+		}
+		if c[0] == 0 {
+			break
+		}
+		comment = append(comment, c[0])
+	}
+	if len(comment) != int(commLen) { // 注释的实际长度与长度字段不一致
+		if h.Strictness == StrictnessStrict {
+			return fmt.Errorf("%w: comment length %d, want %d", ErrInvalidHeader, len(comment), commLen)
+		}
+		if len(comment) > int(commLen) {
+			comment = comment[:commLen]
+		}
+	}
+	h.commLen = uint32(len(comment))
+	h.commComment = string(comment)
+	return nil // 读取成功
 }
 
 // ceil2 计算 ceil(a / b)，当 b > 0 时
@@ -0,0 +1,135 @@
+package hcaflac
+
+// bitWriter 按高位在前的顺序写出比特
+type bitWriter struct {
+	buf  []byte
+	acc  uint64 // 尚未写出的比特
+	nacc uint   // acc 中的比特数, 小于 8
+}
+
+// reset 清空已写出的数据, 保留缓冲
+func (w *bitWriter) reset() {
+	w.buf, w.acc, w.nacc = w.buf[:0], 0, 0
+}
+
+// writeBits 写出 v 的低 n 位, n 不超过 56
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	w.acc = w.acc<<n | v&(1<<n-1)
+	w.nacc += n
+	for w.nacc >= 8 {
+		w.nacc -= 8
+		w.buf = append(w.buf, byte(w.acc>>w.nacc))
+	}
+	w.acc &= 1<<w.nacc - 1
+}
+
+// writeSigned 以 n 位补码写出 v
+func (w *bitWriter) writeSigned(v int64, n uint) {
+	w.writeBits(uint64(v), n)
+}
+
+// writeUnary 写出 q 个 0 与一个 1
+func (w *bitWriter) writeUnary(q uint32) {
+	for q >= 32 {
+		w.writeBits(0, 32)
+		q -= 32
+	}
+	w.writeBits(1, uint(q)+1)
+}
+
+// writeRice 以参数 k 的 Rice 编码写出 v
+func (w *bitWriter) writeRice(v int32, k uint) {
+	u := uint32(v<<1) ^ uint32(v>>31)
+	if q := u >> k; q+1+uint32(k) <= 56 {
+		w.writeBits(1<<k|uint64(u)&(1<<k-1), uint(q)+1+k)
+		return
+	}
+	w.writeUnary(u >> k)
+	w.writeBits(uint64(u), k)
+}
+
+// writeUTF8 以 FLAC 帧头部使用的扩展 UTF-8 形式写出 v (最多 36 位)
+func (w *bitWriter) writeUTF8(v uint64) {
+	if v < 0x80 {
+		w.writeBits(v, 8)
+		return
+	}
+	n := uint(2) // 字节数
+	for v >= 1<<(5*n+1) && n < 7 {
+		n++
+	}
+	if n == 7 {
+		w.writeBits(0xFE, 8)
+	} else {
+		w.writeBits(0xFF<<(8-n)|v>>(6*(n-1)), 8)
+	}
+	for i := int(n) - 2; i >= 0; i-- {
+		w.writeBits(0x80|v>>(6*uint(i))&0x3F, 8)
+	}
+}
+
+// append 写出 o 中的全部比特
+func (w *bitWriter) append(o *bitWriter) {
+	if w.nacc == 0 {
+		w.buf = append(w.buf, o.buf...)
+	} else {
+		for _, b := range o.buf {
+			w.writeBits(uint64(b), 8)
+		}
+	}
+	w.writeBits(o.acc, o.nacc)
+}
+
+// bits 返回已写出的比特数
+func (w *bitWriter) bits() int {
+	return len(w.buf)*8 + int(w.nacc)
+}
+
+// align 以 0 补齐到字节边界
+func (w *bitWriter) align() {
+	if w.nacc > 0 {
+		w.writeBits(0, 8-w.nacc)
+	}
+}
+
+var (
+	crc8Table  [256]uint8
+	crc16Table [256]uint16
+)
+
+func init() {
+	for i := range 256 {
+		c8, c16 := uint8(i), uint16(i)<<8
+		for range 8 {
+			if c8&0x80 != 0 {
+				c8 = c8<<1 ^ 0x07
+			} else {
+				c8 <<= 1
+			}
+			if c16&0x8000 != 0 {
+				c16 = c16<<1 ^ 0x8005
+			} else {
+				c16 <<= 1
+			}
+		}
+		crc8Table[i], crc16Table[i] = c8, c16
+	}
+}
+
+// crc8 计算帧头部的 CRC-8 (多项式 0x07)
+func crc8(b []byte) uint8 {
+	var c uint8
+	for _, v := range b {
+		c = crc8Table[c^v]
+	}
+	return c
+}
+
+// crc16 计算整个帧的 CRC-16 (多项式 0x8005)
+func crc16(b []byte) uint16 {
+	var c uint16
+	for _, v := range b {
+		c = c<<8 ^ crc16Table[byte(c>>8)^v]
+	}
+	return c
+}
@@ -0,0 +1,361 @@
+package hcaflac
+
+import (
+	"math"
+	"math/bits"
+)
+
+// params 是一个压缩级别的编码参数
+type params struct {
+	stereo   bool // 立体声时尝试 left/side, right/side 与 mid/side
+	lpcOrder int  // LPC 预测的最高阶数, 0 时只使用固定预测
+	maxPart  int  // Rice 分区阶数的上限
+}
+
+// levels 是压缩级别 0 到 8 的参数, 级别越高压缩率越高, 编码越慢
+var levels = [9]params{
+	{false, 0, 3},
+	{true, 0, 3},
+	{true, 0, 4},
+	{false, 6, 4},
+	{true, 8, 4},
+	{true, 8, 5},
+	{true, 8, 6},
+	{true, 12, 6},
+	{true, 16, 6},
+}
+
+// qlpPrecision 量化 LPC 系数的位数
+const qlpPrecision = 15
+
+// 子帧类型 (带前后的填充位与 wasted bits 标志)
+const (
+	subframeConstant = 0x00
+	subframeVerbatim = 0x02
+	subframeFixed    = 0x10 // | order<<1
+	subframeLPC      = 0x40 // | (order-1)<<1
+)
+
+// encoder 编码子帧, 保存复用的缓冲
+type encoder struct {
+	p params
+
+	res    []int32   // 候选的残差
+	best   []int32   // 目前最好的残差
+	sums   []uint64  // 各 Rice 分区的 zigzag 值之和
+	window []float64 // LPC 分析窗
+	data   []float64 // 加窗后的样本
+}
+
+// subframe 是选定的子帧编码方式
+type subframe struct {
+	kind  int     // 子帧类型
+	order int     // 预测阶数
+	qlp   []int32 // LPC 量化系数
+	shift int     // LPC 系数的移位
+	part  int     // Rice 分区阶数
+	cost  int     // 预计的比特数
+}
+
+// encodeSubframe 选择 x (每样本 bps 位) 最小的子帧编码并写入 w
+func (e *encoder) encodeSubframe(w *bitWriter, x []int32, bps uint) {
+	n := len(x)
+	constant := true
+	for _, v := range x[1:] {
+		if v != x[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		w.writeBits(subframeConstant, 8)
+		w.writeSigned(int64(x[0]), bps)
+		return
+	}
+
+	e.res = grow(e.res, n)
+	e.best = grow(e.best, n)
+	best := subframe{kind: subframeVerbatim, cost: 8 + n*int(bps)}
+	for order := 0; order <= 4 && order < n; order++ { // 固定预测
+		if !fixedResidual(e.res[:n-order], x, order) {
+			continue
+		}
+		part, cost := e.riceCost(e.res[:n-order], n, order)
+		cost += 8 + order*int(bps)
+		if cost < best.cost {
+			best = subframe{kind: subframeFixed, order: order, part: part, cost: cost}
+			e.res, e.best = e.best, e.res
+		}
+	}
+	if e.p.lpcOrder > 0 && n > e.p.lpcOrder*2 {
+		if qlp, shift, ok := e.lpc(x); ok {
+			order := len(qlp)
+			if lpcResidual(e.res[:n-order], x, qlp, shift) {
+				part, cost := e.riceCost(e.res[:n-order], n, order)
+				cost += 8 + order*int(bps) + 4 + 5 + order*qlpPrecision
+				if cost < best.cost {
+					best = subframe{kind: subframeLPC, order: order, qlp: qlp, shift: shift, part: part, cost: cost}
+					e.res, e.best = e.best, e.res
+				}
+			}
+		}
+	}
+
+	switch best.kind {
+	case subframeVerbatim:
+		w.writeBits(subframeVerbatim, 8)
+		for _, v := range x {
+			w.writeSigned(int64(v), bps)
+		}
+		return
+	case subframeFixed:
+		w.writeBits(subframeFixed|uint64(best.order)<<1, 8)
+	case subframeLPC:
+		w.writeBits(subframeLPC|uint64(best.order-1)<<1, 8)
+	}
+	for _, v := range x[:best.order] { // 预热样本
+		w.writeSigned(int64(v), bps)
+	}
+	if best.kind == subframeLPC {
+		w.writeBits(qlpPrecision-1, 4)
+		w.writeSigned(int64(best.shift), 5)
+		for _, c := range best.qlp {
+			w.writeSigned(int64(c), qlpPrecision)
+		}
+	}
+	e.writeResidual(w, e.best[:n-best.order], n, best.order, best.part)
+}
+
+// grow 返回长度至少为 n 的切片
+func grow(s []int32, n int) []int32 {
+	if cap(s) < n {
+		return make([]int32, n)
+	}
+	return s[:n]
+}
+
+// fixedResidual 计算 order 阶固定预测的残差, 残差超出 32 位时返回 false
+func fixedResidual(res, x []int32, order int) bool {
+	for i := order; i < len(x); i++ {
+		var r int64
+		switch order {
+		case 0:
+			r = int64(x[i])
+		case 1:
+			r = int64(x[i]) - int64(x[i-1])
+		case 2:
+			r = int64(x[i]) - 2*int64(x[i-1]) + int64(x[i-2])
+		case 3:
+			r = int64(x[i]) - 3*int64(x[i-1]) + 3*int64(x[i-2]) - int64(x[i-3])
+		case 4:
+			r = int64(x[i]) - 4*int64(x[i-1]) + 6*int64(x[i-2]) - 4*int64(x[i-3]) + int64(x[i-4])
+		}
+		if r != int64(int32(r)) {
+			return false
+		}
+		res[i-order] = int32(r)
+	}
+	return true
+}
+
+// lpcResidual 计算量化 LPC 系数 qlp 预测的残差, 残差超出 32 位时返回 false
+func lpcResidual(res, x, qlp []int32, shift int) bool {
+	order := len(qlp)
+	for i := order; i < len(x); i++ {
+		var sum int64
+		for j, c := range qlp {
+			sum += int64(c) * int64(x[i-1-j])
+		}
+		r := int64(x[i]) - sum>>shift
+		if r != int64(int32(r)) {
+			return false
+		}
+		res[i-order] = int32(r)
+	}
+	return true
+}
+
+// lpc 计算 x 的 LPC 预测系数, 按估计的比特数选择阶数并量化
+func (e *encoder) lpc(x []int32) (qlp []int32, shift int, ok bool) {
+	n := len(x)
+	maxOrder := e.p.lpcOrder
+	if len(e.window) != n { // Tukey (0.5) 窗
+		e.window = make([]float64, n)
+		e.data = make([]float64, n)
+		taper := n / 4
+		for i := range e.window {
+			e.window[i] = 1
+		}
+		for i := range taper {
+			e.window[i] = 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(taper))
+			e.window[n-1-i] = e.window[i]
+		}
+	}
+	data := e.data
+	for i, v := range x {
+		data[i] = float64(v) * e.window[i]
+	}
+	var r [33]float64 // 自相关
+	for lag := 0; lag <= maxOrder; lag++ {
+		var s float64
+		for i := lag; i < n; i++ {
+			s += data[i] * data[i-lag]
+		}
+		r[lag] = s
+	}
+	if r[0] == 0 {
+		return nil, 0, false
+	}
+
+	// Levinson-Durbin 递推, 保留估计比特数最少的阶数的系数
+	var a, next, coef [33]float64
+	bestOrder, bestBits := 0, math.Inf(1)
+	errv := r[0]
+	for m := 1; m <= maxOrder; m++ {
+		k := r[m]
+		for j := 1; j < m; j++ {
+			k -= a[j] * r[m-j]
+		}
+		k /= errv
+		for j := 1; j < m; j++ {
+			next[j] = a[j] - k*a[m-j]
+		}
+		next[m] = k
+		a = next
+		errv *= 1 - k*k
+		if errv <= 0 {
+			break
+		}
+		est := float64(n-m)*max(0, 0.5*math.Log2(errv/float64(n))) + float64(m*qlpPrecision)
+		if est < bestBits {
+			bestOrder, bestBits = m, est
+			copy(coef[:], a[:])
+		}
+	}
+	if bestOrder == 0 {
+		return nil, 0, false
+	}
+
+	// 量化系数, 误差累积到下一个系数
+	cmax := 0.0
+	for _, c := range coef[1 : bestOrder+1] {
+		cmax = max(cmax, math.Abs(c))
+	}
+	if cmax == 0 {
+		return nil, 0, false
+	}
+	_, exp := math.Frexp(cmax)
+	shift = qlpPrecision - 1 - exp
+	if shift < 0 {
+		return nil, 0, false
+	}
+	shift = min(shift, 15)
+	qmax := float64(int32(1)<<(qlpPrecision-1) - 1)
+	qlp = make([]int32, bestOrder)
+	var carry float64
+	for i := range qlp {
+		carry += coef[i+1] * float64(int(1)<<shift)
+		q := math.Max(-qmax-1, math.Min(qmax, math.Round(carry)))
+		qlp[i] = int32(q)
+		carry -= q
+	}
+	return qlp, shift, true
+}
+
+// partOrder 返回 n 个样本, order 阶预测时可以使用的最高 Rice 分区阶数
+func (e *encoder) partOrder(n, order int) int {
+	p := e.p.maxPart
+	for p > 0 && (n%(1<<p) != 0 || n>>p <= order) {
+		p--
+	}
+	return p
+}
+
+// riceCost 选择残差的 Rice 分区阶数, 返回分区阶数与估计的比特数
+func (e *encoder) riceCost(res []int32, n, order int) (part, cost int) {
+	maxPart := e.partOrder(n, order)
+	parts := 1 << maxPart
+	if cap(e.sums) < parts {
+		e.sums = make([]uint64, parts)
+	}
+	sums := e.sums[:parts]
+	size := n >> maxPart
+	i := 0
+	for j := range sums {
+		end := (j+1)*size - order
+		var s uint64
+		for ; i < end; i++ {
+			v := res[i]
+			s += uint64(uint32(v<<1) ^ uint32(v>>31))
+		}
+		sums[j] = s
+	}
+	cost = math.MaxInt
+	for p := maxPart; p >= 0; p-- { // 从最细的分区开始, 逐级合并相邻的分区
+		size := n >> p
+		c := 6
+		for j := range 1 << p {
+			count := size
+			if j == 0 {
+				count -= order
+			}
+			_, bits := riceParam(sums[j], count)
+			c += bits
+		}
+		if c < cost {
+			part, cost = p, c
+		}
+		for j := range 1 << p >> 1 {
+			sums[j] = sums[2*j] + sums[2*j+1]
+		}
+	}
+	return part, cost
+}
+
+// riceParam 按 count 个 zigzag 值之和 sum 选择 Rice 参数, 返回参数与估计的比特数 (包括参数本身)
+func riceParam(sum uint64, count int) (k uint, cost int) {
+	if count == 0 {
+		return 0, 5
+	}
+	mean := sum / uint64(count)
+	if mean > 0 {
+		k = uint(bits.Len64(mean) - 1)
+	}
+	k = min(k, 30)
+	return k, 5 + count*(int(k)+1) + int(sum>>k)
+}
+
+// writeResidual 以分区阶数 part 写出残差
+func (e *encoder) writeResidual(w *bitWriter, res []int32, n, order, part int) {
+	size := n >> part
+	params := make([]uint, 1<<part)
+	wide := false // 参数超过 14 时使用 5 位的参数
+	i := 0
+	for j := range params {
+		end := (j+1)*size - order
+		var s uint64
+		for _, v := range res[i:end] {
+			s += uint64(uint32(v<<1) ^ uint32(v>>31))
+		}
+		params[j], _ = riceParam(s, end-i)
+		wide = wide || params[j] > 14
+		i = end
+	}
+	paramBits := uint(4)
+	if wide {
+		w.writeBits(1, 2)
+		paramBits = 5
+	} else {
+		w.writeBits(0, 2)
+	}
+	w.writeBits(uint64(part), 4)
+	i = 0
+	for j, k := range params {
+		end := (j+1)*size - order
+		w.writeBits(uint64(k), paramBits)
+		for _, v := range res[i:end] {
+			w.writeRice(v, k)
+		}
+		i = end
+	}
+}
@@ -0,0 +1,325 @@
+// Package hcaflac registers the "flac" output format
+// hcaflac 注册 "flac" 输出格式, 将解码结果无损压缩为 FLAC. 只需要导入:
+//
+//	import _ "github.com/WJQSERVER/hca/hcaflac"
+//
+// 之后设置 Hca.Format = "flac" 或 DecodeFile 到扩展名为 .flac 的文件即可.
+// Hca.FormatOptions 中的 "level" 是压缩级别 (0 到 8, 默认 5), "bits" 是每样本位数 (16 或 24, 默认 16).
+// Hca.Tags 写入 VORBIS_COMMENT 块 (TITLE, ARTIST, ALBUM 与 TRACKNUMBER).
+// 输出可以 Seek 时 (例如文件) 解码结束后回写 STREAMINFO 中的总样本数与 MD5, 否则这两项为 0 (未知)
+package hcaflac
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// Format is the registered format name
+// Format 是注册的输出格式名称
+const Format = "flac"
+
+// DefaultLevel is the default compression level
+// DefaultLevel 是默认的压缩级别
+const DefaultLevel = 5
+
+// blockSize 每帧的样本帧数
+const blockSize = 4096
+
+// vendor 写入 VORBIS_COMMENT 块的编码器名称
+const vendor = "github.com/WJQSERVER/hca"
+
+func init() {
+	hca.RegisterFormat(Format, newSink)
+}
+
+// sink 将交错的浮点样本编码为 FLAC 帧
+type sink struct {
+	w        io.Writer
+	channels int
+	rate     int
+	bps      uint
+	scale    float64 // 满幅样本的整数值
+
+	enc    encoder
+	buf    [][]int32 // 当前帧各通道的样本
+	n      int       // 当前帧的样本帧数
+	mid    []int32
+	side   []int32
+	frame  bitWriter   // 正在编码的帧
+	subs   []bitWriter // 各通道的子帧, 立体声时依次是 left, right, mid, side
+	pcm    []byte      // 计算 MD5 的小端序样本
+	md5    hash.Hash
+	number uint64 // 下一帧的序号
+
+	total              uint64 // 已编码的样本帧数
+	minFrame, maxFrame int    // 帧的最小与最大字节数
+	start              int64  // 输出开始的位置, 输出不能 Seek 时为 -1
+}
+
+// newSink 是 hca.SinkFactory, 写出 fLaC 标记与元数据块
+func newSink(w io.Writer, info hca.Info, opts hca.SinkOptions) (hca.Sink, error) {
+	level, bps := DefaultLevel, uint(16)
+	if v, ok := opts.Options["level"]; ok {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 0 || l >= len(levels) {
+			return nil, fmt.Errorf("%w: flac level %q (0-8)", hca.ErrInvalidOption, v)
+		}
+		level = l
+	}
+	if v, ok := opts.Options["bits"]; ok {
+		switch v {
+		case "16":
+			bps = 16
+		case "24":
+			bps = 24
+		default:
+			return nil, fmt.Errorf("%w: flac bits %q (16 or 24)", hca.ErrInvalidOption, v)
+		}
+	}
+	if info.Channels < 1 || info.Channels > 8 {
+		return nil, fmt.Errorf("%w: flac supports 1 to 8 channels, got %d", hca.ErrInvalidOption, info.Channels)
+	}
+	if info.SamplingRate < 1 || info.SamplingRate >= 1<<20 {
+		return nil, fmt.Errorf("%w: flac sampling rate %d", hca.ErrInvalidOption, info.SamplingRate)
+	}
+	s := &sink{
+		w:        w,
+		channels: info.Channels,
+		rate:     info.SamplingRate,
+		bps:      bps,
+		scale:    float64(int64(1)<<(bps-1) - 1),
+		enc:      encoder{p: levels[level]},
+		buf:      make([][]int32, info.Channels),
+		subs:     make([]bitWriter, max(info.Channels, 4)),
+		md5:      md5.New(),
+		minFrame: math.MaxInt,
+		start:    -1,
+	}
+	for i := range s.buf {
+		s.buf[i] = make([]int32, blockSize)
+	}
+	if seeker, ok := w.(io.Seeker); ok {
+		if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			s.start = pos
+		}
+	}
+
+	b := []byte("fLaC")
+	b = appendBlockHeader(b, 0, false, 34)
+	b = s.appendStreamInfo(b)
+	comments := opts.Tags.VorbisComments()
+	comment := appendString(nil, vendor)
+	comment = binary.LittleEndian.AppendUint32(comment, uint32(len(comments)))
+	for _, c := range comments {
+		comment = appendString(comment, c)
+	}
+	if len(comment) >= 1<<24 { // 元数据块的大小只有 24 位
+		return nil, fmt.Errorf("%w: flac tags too long", hca.ErrInvalidOption)
+	}
+	b = appendBlockHeader(b, 4, true, len(comment))
+	b = append(b, comment...)
+	_, err := w.Write(b)
+	return s, err
+}
+
+// appendBlockHeader 添加元数据块的头部
+func appendBlockHeader(b []byte, kind byte, last bool, size int) []byte {
+	if last {
+		kind |= 0x80
+	}
+	return append(b, kind, byte(size>>16), byte(size>>8), byte(size))
+}
+
+// appendString 添加 Vorbis comment 中以小端序长度开始的字符串
+func appendString(b []byte, s string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// appendStreamInfo 添加 STREAMINFO 块的内容, 总样本数与 MD5 在结束之前为 0
+func (s *sink) appendStreamInfo(b []byte) []byte {
+	var w bitWriter
+	w.writeBits(blockSize, 16)
+	w.writeBits(blockSize, 16)
+	if s.number > 0 {
+		w.writeBits(uint64(s.minFrame), 24)
+		w.writeBits(uint64(s.maxFrame), 24)
+	} else {
+		w.writeBits(0, 48)
+	}
+	w.writeBits(uint64(s.rate), 20)
+	w.writeBits(uint64(s.channels-1), 3)
+	w.writeBits(uint64(s.bps-1), 5)
+	w.writeBits(s.total, 36)
+	b = append(b, w.buf...)
+	if s.number > 0 {
+		return s.md5.Sum(b)
+	}
+	return append(b, make([]byte, md5.Size)...)
+}
+
+// WriteSamples 实现 hca.Sink, 每满一帧编码并写出
+func (s *sink) WriteSamples(samples []float32) error {
+	if len(samples)%s.channels != 0 {
+		return errChannels
+	}
+	for i := 0; i < len(samples); i += s.channels {
+		for c, f := range samples[i : i+s.channels] {
+			v := float64(f)
+			switch {
+			case v != v: // NaN
+				v = 0
+			case v > 1:
+				v = 1
+			case v < -1:
+				v = -1
+			}
+			s.buf[c][s.n] = int32(math.Round(v * s.scale))
+		}
+		s.n++
+		if s.n == blockSize {
+			if err := s.writeFrame(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close 实现 hca.Sink, 写出最后不满的一帧, 输出可以 Seek 时回写 STREAMINFO
+func (s *sink) Close() error {
+	if s.n > 0 {
+		if err := s.writeFrame(); err != nil {
+			return err
+		}
+	}
+	if s.start < 0 {
+		return nil
+	}
+	seeker := s.w.(io.Seeker)
+	end, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := seeker.Seek(s.start+8, io.SeekStart); err != nil { // fLaC 与块头部之后
+		return err
+	}
+	if _, err := s.w.Write(s.appendStreamInfo(nil)); err != nil {
+		return err
+	}
+	_, err = seeker.Seek(end, io.SeekStart)
+	return err
+}
+
+// errChannels 表示样本数不是通道数的整数倍
+var errChannels = errors.New("hcaflac: samples are not a multiple of channels")
+
+// writeFrame 编码并写出当前的 s.n 个样本帧
+func (s *sink) writeFrame() error {
+	n := s.n
+	s.n = 0
+	s.updateMD5(n)
+
+	f := &s.frame
+	f.reset()
+	f.writeBits(0x3FFE, 14) // 同步码
+	f.writeBits(0, 2)       // 保留位, 固定块大小
+	sizeCode := uint64(7)   // 16 位的块大小 - 1
+	switch {
+	case n == blockSize:
+		sizeCode = 12
+	case n <= 256:
+		sizeCode = 6
+	}
+	f.writeBits(sizeCode, 4)
+	f.writeBits(0, 4) // 采样率取自 STREAMINFO
+
+	// 选择通道的编码方式, 立体声时比较四种组合的大小
+	channelCode := uint64(s.channels - 1)
+	var chosen []*bitWriter
+	if s.channels == 2 && s.enc.p.stereo {
+		l, r := s.buf[0][:n], s.buf[1][:n]
+		s.mid, s.side = grow(s.mid, n), grow(s.side, n)
+		for i := range n {
+			s.mid[i] = (l[i] + r[i]) >> 1
+			s.side[i] = l[i] - r[i]
+		}
+		for i, x := range [][]int32{l, r, s.mid, s.side} {
+			bps := s.bps
+			if i == 3 {
+				bps++
+			}
+			s.subs[i].reset()
+			s.enc.encodeSubframe(&s.subs[i], x, bps)
+		}
+		L, R, M, S := &s.subs[0], &s.subs[1], &s.subs[2], &s.subs[3]
+		chosen = []*bitWriter{L, R}
+		best := L.bits() + R.bits()
+		if b := L.bits() + S.bits(); b < best {
+			channelCode, chosen, best = 8, []*bitWriter{L, S}, b
+		}
+		if b := S.bits() + R.bits(); b < best {
+			channelCode, chosen, best = 9, []*bitWriter{S, R}, b
+		}
+		if b := M.bits() + S.bits(); b < best {
+			channelCode, chosen = 10, []*bitWriter{M, S}
+		}
+	} else {
+		for c := range s.channels {
+			s.subs[c].reset()
+			s.enc.encodeSubframe(&s.subs[c], s.buf[c][:n], s.bps)
+			chosen = append(chosen, &s.subs[c])
+		}
+	}
+	f.writeBits(channelCode, 4)
+	if s.bps == 24 {
+		f.writeBits(6, 3)
+	} else {
+		f.writeBits(4, 3)
+	}
+	f.writeBits(0, 1)
+	f.writeUTF8(s.number)
+	switch sizeCode {
+	case 6:
+		f.writeBits(uint64(n-1), 8)
+	case 7:
+		f.writeBits(uint64(n-1), 16)
+	}
+	f.writeBits(uint64(crc8(f.buf)), 8)
+	for _, sub := range chosen {
+		f.append(sub)
+	}
+	f.align()
+	f.writeBits(uint64(crc16(f.buf)), 16)
+
+	s.number++
+	s.total += uint64(n)
+	s.minFrame = min(s.minFrame, len(f.buf))
+	s.maxFrame = max(s.maxFrame, len(f.buf))
+	_, err := s.w.Write(f.buf)
+	return err
+}
+
+// updateMD5 将 n 个样本帧以小端序交错排列加入 MD5
+func (s *sink) updateMD5(n int) {
+	size := int(s.bps / 8)
+	s.pcm = s.pcm[:0]
+	for i := range n {
+		for c := range s.channels {
+			v := s.buf[c][i]
+			s.pcm = append(s.pcm, byte(v), byte(v>>8))
+			if size == 3 {
+				s.pcm = append(s.pcm, byte(v>>16))
+			}
+		}
+	}
+	s.md5.Write(s.pcm)
+}
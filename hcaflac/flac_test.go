@@ -0,0 +1,308 @@
+package hcaflac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// bitReader 读取 FLAC 比特流, 用于检查编码结果
+type bitReader struct {
+	b   []byte
+	pos int // 比特位置
+}
+
+func (r *bitReader) read(n uint) uint64 {
+	var v uint64
+	for range n {
+		v = v<<1 | uint64(r.b[r.pos>>3]>>(7-r.pos&7)&1)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) signed(n uint) int64 {
+	v := r.read(n)
+	return int64(v<<(64-n)) >> (64 - n)
+}
+
+func (r *bitReader) utf8() uint64 {
+	v := r.read(8)
+	n := 0
+	for v&(0x80>>n) != 0 {
+		n++
+	}
+	if n == 0 {
+		return v
+	}
+	v &= 0x7F >> n
+	for range n - 1 {
+		v = v<<6 | r.read(8)&0x3F
+	}
+	return v
+}
+
+// decodeFLAC 解码 encodeFLAC 的输出, 检查帧的 CRC 与 STREAMINFO, 返回交错排列的样本
+func decodeFLAC(t *testing.T, data []byte) (info []byte, samples []int32) {
+	t.Helper()
+	if string(data[:4]) != "fLaC" || data[4] != 0 {
+		t.Fatal("missing fLaC marker or STREAMINFO")
+	}
+	info = data[8:42]
+	si := &bitReader{b: info}
+	si.read(16 + 16 + 24 + 24)
+	si.read(20)
+	channels := int(si.read(3)) + 1
+	bps := uint(si.read(5)) + 1
+	pos := 4
+	for { // 跳过元数据块
+		last := data[pos]&0x80 != 0
+		pos += 4 + int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		if last {
+			break
+		}
+	}
+	for number := uint64(0); pos < len(data); number++ {
+		r := &bitReader{b: data, pos: pos * 8}
+		if r.read(14) != 0x3FFE || r.read(2) != 0 {
+			t.Fatalf("frame %d: bad sync", number)
+		}
+		sizeCode := r.read(4)
+		r.read(4)
+		assign := r.read(4)
+		r.read(4)
+		if n := r.utf8(); n != number {
+			t.Fatalf("frame number %d, want %d", n, number)
+		}
+		n := blockSize
+		switch sizeCode {
+		case 6:
+			n = int(r.read(8)) + 1
+		case 7:
+			n = int(r.read(16)) + 1
+		}
+		if crc := crc8(data[pos : r.pos/8]); uint8(r.read(8)) != crc {
+			t.Fatalf("frame %d: header CRC mismatch", number)
+		}
+		ch := make([][]int64, channels)
+		for c := range ch {
+			sbps := bps
+			if assign == 8 && c == 1 || assign == 9 && c == 0 || assign == 10 && c == 1 {
+				sbps++ // side 通道
+			}
+			ch[c] = decodeSubframe(t, r, n, sbps)
+		}
+		for i := range n {
+			switch assign {
+			case 8:
+				ch[1][i] = ch[0][i] - ch[1][i]
+			case 9:
+				ch[0][i] += ch[1][i]
+			case 10:
+				mid := ch[0][i]<<1 | ch[1][i]&1
+				ch[0][i], ch[1][i] = (mid+ch[1][i])>>1, (mid-ch[1][i])>>1
+			}
+			for c := range ch {
+				samples = append(samples, int32(ch[c][i]))
+			}
+		}
+		r.pos = (r.pos + 7) &^ 7
+		end := r.pos / 8
+		if crc := crc16(data[pos:end]); uint16(r.read(16)) != crc {
+			t.Fatalf("frame %d: CRC mismatch", number)
+		}
+		pos = end + 2
+	}
+	return info, samples
+}
+
+// decodeSubframe 解码一个子帧
+func decodeSubframe(t *testing.T, r *bitReader, n int, bps uint) []int64 {
+	t.Helper()
+	header := r.read(8) >> 1
+	x := make([]int64, n)
+	switch {
+	case header == 0:
+		v := r.signed(bps)
+		for i := range x {
+			x[i] = v
+		}
+		return x
+	case header == 1:
+		for i := range x {
+			x[i] = r.signed(bps)
+		}
+		return x
+	}
+	var order int
+	var qlp []int64
+	var shift int64
+	if header&0x20 != 0 {
+		order = int(header&0x1F) + 1
+	} else {
+		order = int(header & 7)
+	}
+	for i := range order {
+		x[i] = r.signed(bps)
+	}
+	if header&0x20 != 0 {
+		precision := uint(r.read(4)) + 1
+		shift = r.signed(5)
+		for range order {
+			qlp = append(qlp, r.signed(precision))
+		}
+	} else {
+		qlp = [][]int64{{}, {1}, {2, -1}, {3, -3, 1}, {4, -6, 4, -1}}[order]
+	}
+	method := r.read(2)
+	paramBits := uint(4 + method)
+	part := r.read(4)
+	res := x[order:][:0]
+	for j := range 1 << part {
+		count := n >> part
+		if j == 0 {
+			count -= order
+		}
+		k := uint(r.read(paramBits))
+		for range count {
+			q := uint64(0)
+			for r.read(1) == 0 {
+				q++
+			}
+			u := q<<k | r.read(k)
+			res = append(res, int64(u>>1)^-int64(u&1))
+		}
+	}
+	for i := order; i < n; i++ {
+		var sum int64
+		for j, c := range qlp {
+			sum += c * x[i-1-j]
+		}
+		x[i] += sum >> shift
+	}
+	return x
+}
+
+// encodeFLAC 以 opts 编码交错排列的样本
+func encodeFLAC(t *testing.T, samples []float32, channels int, opts map[string]string) []byte {
+	t.Helper()
+	out, err := os.Create(filepath.Join(t.TempDir(), "out.flac")) // 可以 Seek, 结束时回写 STREAMINFO
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	s, err := newSink(out, hca.Info{Channels: channels, SamplingRate: 44100}, hca.SinkOptions{Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(samples) > 0 { // 分多次写入, 与解码器每次写出一个块相同
+		n := min(len(samples), 1024*channels)
+		if err := s.WriteSamples(samples[:n]); err != nil {
+			t.Fatal(err)
+		}
+		samples = samples[n:]
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestLossless 检查各压缩级别与位数的输出都能还原出量化后的样本, 包括静音, 削波与最后不满的一帧
+func TestLossless(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const frames = blockSize*3 + 100
+	for _, channels := range []int{1, 2, 3} {
+		samples := make([]float32, frames*channels)
+		for i := range frames {
+			for c := range channels {
+				v := 0.4*math.Sin(2*math.Pi*float64(i*(c+1))*440/44100) + 0.02*rng.NormFloat64()
+				switch {
+				case i >= blockSize && i < blockSize*2: // 静音的帧
+					v = 0
+				case i >= blockSize*2+100 && i < blockSize*2+300: // 削波
+					v = 2
+				}
+				samples[i*channels+c] = float32(v)
+			}
+		}
+		for _, bits := range []string{"16", "24"} {
+			scale, size := 32767.0, 2
+			if bits == "24" {
+				scale, size = 8388607, 3
+			}
+			var want []int32
+			var pcm []byte
+			for _, f := range samples {
+				v := int32(math.Round(math.Max(-1, math.Min(1, float64(f))) * scale))
+				want = append(want, v)
+				pcm = binary.LittleEndian.AppendUint32(pcm, uint32(v))[:len(pcm)+size]
+			}
+			sum := md5.Sum(pcm)
+			for level := range levels {
+				data := encodeFLAC(t, samples, channels, map[string]string{"level": strconv.Itoa(level), "bits": bits})
+				info, got := decodeFLAC(t, data)
+				if !slices.Equal(got, want) {
+					t.Fatalf("channels %d, bits %s, level %d: decoded samples differ", channels, bits, level)
+				}
+				if total := binary.BigEndian.Uint64(info[10:18]) & (1<<36 - 1); total != frames || !bytes.Equal(info[18:], sum[:]) {
+					t.Errorf("channels %d, bits %s, level %d: STREAMINFO has %d samples, md5 %x", channels, bits, level, total, info[18:])
+				}
+			}
+		}
+	}
+}
+
+// TestOptions 检查无效的选项
+func TestOptions(t *testing.T) {
+	for _, opts := range []map[string]string{{"level": "9"}, {"level": "x"}, {"bits": "8"}} {
+		if _, err := newSink(&bytes.Buffer{}, hca.Info{Channels: 2, SamplingRate: 44100}, hca.SinkOptions{Options: opts}); !errors.Is(err, hca.ErrInvalidOption) {
+			t.Errorf("%v: got %v, want ErrInvalidOption", opts, err)
+		}
+	}
+}
+
+// TestTags 检查 Tags 写入 VORBIS_COMMENT 块
+func TestTags(t *testing.T) {
+	var out bytes.Buffer
+	tags := hca.Tags{Title: "Title", Album: "专辑", Track: "3"}
+	s, err := newSink(&out, hca.Info{Channels: 1, SamplingRate: 44100}, hca.SinkOptions{Tags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+	block := data[42:] // STREAMINFO 之后的块
+	if block[0] != 0x84 {
+		t.Fatalf("block type %x, want last VORBIS_COMMENT", block[0])
+	}
+	comment := block[4 : 4+int(block[1])<<16|int(block[2])<<8|int(block[3])]
+	next := func(n uint32) []byte {
+		b := comment[:n]
+		comment = comment[n:]
+		return b
+	}
+	fields := []string{string(next(binary.LittleEndian.Uint32(next(4))))}
+	for range binary.LittleEndian.Uint32(next(4)) {
+		fields = append(fields, string(next(binary.LittleEndian.Uint32(next(4)))))
+	}
+	if want := []string{vendor, "TITLE=Title", "ALBUM=专辑", "TRACKNUMBER=3"}; !slices.Equal(fields, want) || len(comment) != 0 {
+		t.Errorf("comments %q, want %q", fields, want)
+	}
+}
@@ -0,0 +1,264 @@
+// Package hcahttp serves HCA files decoded on the fly over HTTP.
+// hcahttp 通过 HTTP 提供实时解码的 HCA 文件, 用于内部的素材预览页面.
+package hcahttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WJQSERVER/hca"
+	"github.com/WJQSERVER/hca/hcaflac"
+	"github.com/WJQSERVER/hca/hcaogg"
+)
+
+// Options is handler options
+// Options 是 Handler 的选项
+type Options struct {
+	Key    uint64 // 默认的 64 位密钥, 0 使用 hca.NewDecoder 的默认密钥
+	Subkey uint16 // 默认的子密钥
+
+	// KeyQuery 允许请求通过查询参数选择密钥: key (十进制或 0x 十六进制), subkey 与 game (hca.FindKeys 的游戏名称)
+	KeyQuery bool
+
+	// Configure 可选, 为每个请求创建的解码器设置输出选项 (写入模式, 音量, 循环等)
+	Configure func(d *hca.Hca)
+
+	// Observe 可选, 每个解码请求结束后调用, 用于统计 (例如 hcametrics.Collector)
+	Observe func(r Result)
+}
+
+// Result is the outcome of a decode request
+// Result 是一次解码请求的结果
+type Result struct {
+	Path    string
+	Audio   time.Duration // 返回的数据对应的音频时长 (Range 请求按比例计算)
+	Bytes   int64         // 返回的字节数
+	Elapsed time.Duration
+	Err     error // 读取头部或解码失败时的错误
+}
+
+// Handler return a handler serving decoded files of fsys
+// Handler 返回一个 http.Handler, 将请求路径对应的 fsys 中的 HCA 文件解码后返回.
+// 查询参数 format 选择输出格式: wav (默认), raw (不带头部的 PCM), ogg, flac 或其他注册的输出格式.
+// wav 与 raw 输出通过 hca.Reader 按块定位, 支持 Range 请求, Content-Length 是 PCM 数据与 WAV 头部的大小;
+// Configure 设置了 hca.Reader 不支持的选项 (循环, 截取, 重采样, 淡出, 处理器等) 时按顺序解码输出,
+// Content-Length 由 PredictOutputSize 计算, 不支持 Range. ogg 与 flac 等编码格式的大小未知, 同样按顺序输出
+func Handler(fsys fs.FS, opts Options) http.Handler {
+	return &handler{fsys: fsys, opts: opts}
+}
+
+type handler struct {
+	fsys fs.FS
+	opts Options
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if !fs.ValidPath(name) || name == "." {
+		http.NotFound(w, r)
+		return
+	}
+
+	d, contentType, err := h.decoder(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stat, err := fs.Stat(h.fsys, name)
+	if err != nil || stat.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	f, closeFile, err := openSeeker(h.fsys, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer closeFile()
+	w.Header().Set("Content-Type", contentType)
+	if seekable(d) {
+		h.serveSeekable(w, r, name, stat.ModTime(), d, f)
+	} else {
+		h.serveStream(w, r, name, d, f)
+	}
+}
+
+// serveSeekable 以 hca.Reader 提供 WAV 或 raw 输出, Range 请求从目标位置所在的块开始解码
+func (h *handler) serveSeekable(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, d *hca.Hca, f io.ReadSeeker) {
+	start := time.Now()
+	pcm, err := d.NewReader(f)
+	var header []byte
+	if err == nil && !d.Headerless {
+		header, err = pcm.WaveHeader()
+	}
+	if err != nil {
+		h.fail(w, name, start, err)
+		return
+	}
+	src := newOutput(header, pcm)
+	http.ServeContent(w, r, name, modTime, src)
+	if src.size > 0 {
+		h.observe(Result{
+			Path:    name,
+			Audio:   time.Duration(float64(pcm.Info().Duration()) * float64(src.sent) / float64(src.size)),
+			Bytes:   src.sent,
+			Elapsed: time.Since(start),
+			Err:     src.err,
+		})
+	}
+}
+
+// serveStream 按顺序解码输出, 不支持 Range. 大小可以预测时 (wav 与 raw) 设置 Content-Length
+func (h *handler) serveStream(w http.ResponseWriter, r *http.Request, name string, d *hca.Hca, f io.ReadSeeker) {
+	start := time.Now()
+	info, err := d.Probe(f) // 先检查头部, 错误可以作为 HTTP 状态返回
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err == nil && d.Format == "" {
+		var size int64
+		if size, err = d.PredictOutputSize(f); err == nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+			_, err = f.Seek(0, io.SeekStart)
+		}
+	}
+	if err != nil {
+		h.fail(w, name, start, err)
+		return
+	}
+	w.Header().Set("Accept-Ranges", "none")
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	out := &countWriter{w: w}
+	err = d.DecodeWithWriter(f, out)
+	if err != nil && out.n == 0 { // 还没有发送响应
+		h.fail(w, name, start, err)
+		return
+	}
+	h.observe(Result{Path: name, Audio: info.Duration(), Bytes: out.n, Elapsed: time.Since(start), Err: err})
+}
+
+// fail 返回读取头部或解码的错误
+func (h *handler) fail(w http.ResponseWriter, name string, start time.Time, err error) {
+	h.observe(Result{Path: name, Elapsed: time.Since(start), Err: err})
+	w.Header().Del("Content-Length")
+	http.Error(w, err.Error(), statusOf(err))
+}
+
+// seekable 判断解码器的设置能否由 hca.Reader 输出: Reader 不展开循环也不截取, 不应用下面这些选项
+func seekable(d *hca.Hca) bool {
+	return d.Format == "" && !d.UnknownSize &&
+		d.Loop == 0 && d.LoopCrossfade == 0 && d.StartSample == 0 && d.SampleCount == 0 && d.MaxDuration == 0 &&
+		d.FadeOut == 0 && d.SampleRate == 0 && d.TargetLoudness == 0 && d.Limiter == nil && len(d.Processors) == 0
+}
+
+// observe 调用 Options.Observe
+func (h *handler) observe(r Result) {
+	if h.opts.Observe != nil {
+		h.opts.Observe(r)
+	}
+}
+
+// decoder 按照选项与查询参数创建解码器, 返回输出的 Content-Type
+func (h *handler) decoder(r *http.Request) (*hca.Hca, string, error) {
+	d := hca.NewDecoder()
+	if h.opts.Key != 0 {
+		d.SetKey(h.opts.Key)
+	}
+	d.Subkey = h.opts.Subkey
+	if h.opts.Configure != nil {
+		h.opts.Configure(d)
+	}
+
+	q := r.URL.Query()
+	if h.opts.KeyQuery {
+		if game := q.Get("game"); game != "" {
+			keys := hca.FindKeys(game)
+			if len(keys) != 1 {
+				return nil, "", fmt.Errorf("unknown or ambiguous game %q", game)
+			}
+			d.SetKey(keys[0].Key)
+		}
+		if s := q.Get("key"); s != "" {
+			key, err := strconv.ParseUint(s, 0, 64)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid key %q", s)
+			}
+			d.SetKey(key)
+		}
+		if s := q.Get("subkey"); s != "" {
+			sub, err := strconv.ParseUint(s, 0, 16)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid subkey %q", s)
+			}
+			d.Subkey = uint16(sub)
+		}
+	}
+
+	format := strings.ToLower(q.Get("format"))
+	switch {
+	case format == "" || format == "wav":
+		d.Format, d.Headerless = "", false
+		return d, "audio/wav", nil
+	case format == "raw":
+		d.Format, d.Headerless = "", true
+		return d, "application/octet-stream", nil
+	case hca.IsFormat(format):
+		d.Format = format
+		if t, ok := contentTypes[format]; ok {
+			return d, t, nil
+		}
+		return d, "application/octet-stream", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// contentTypes 是注册的输出格式对应的 Content-Type
+var contentTypes = map[string]string{
+	hcaogg.Format:  "audio/ogg",
+	hcaflac.Format: "audio/flac",
+}
+
+// statusOf 返回解码错误对应的 HTTP 状态码
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, hca.ErrInvalidHeader):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, hca.ErrOutputTooLarge), errors.Is(err, hca.ErrInvalidOption):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// openSeeker 打开 fsys 中的文件, 文件不支持 Seek 时 (例如 zip 中的文件) 读取到内存中
+func openSeeker(fsys fs.FS, name string) (io.ReadSeeker, func() error, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, f.Close, nil
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return strings.NewReader(string(data)), func() error { return nil }, nil
+}
@@ -0,0 +1,166 @@
+package hcahttp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// testFS 返回包含 testdata 中的 stereo.hca 与 mono_loop.hca 的文件系统, 以及 stereo.hca 的内容
+func testFS(t *testing.T) (fstest.MapFS, []byte) {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"stereo.hca", "mono_loop.hca"} {
+		data, err := os.ReadFile("../testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys["a/"+name] = &fstest.MapFile{Data: data}
+	}
+	return fsys, fsys["a/stereo.hca"].Data
+}
+
+// get 发送请求并返回响应
+func get(h http.Handler, target, rangeHeader string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if rangeHeader != "" {
+		r.Header.Set("Range", rangeHeader)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// decode 返回以 configure 设置的解码器直接解码的结果
+func decode(t *testing.T, data []byte, configure func(d *hca.Hca)) []byte {
+	t.Helper()
+	d := hca.NewDecoder()
+	configure(d)
+	var out bytes.Buffer
+	if err := d.DecodeWithWriter(bytes.NewReader(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// TestContentLength 检查 wav 与 raw 输出的 Content-Length 与内容和直接解码的结果相同 (包括带循环区间的 smpl 块)
+func TestContentLength(t *testing.T) {
+	fsys, _ := testFS(t)
+	h := Handler(fsys, Options{})
+	for _, tc := range []struct {
+		query       string
+		contentType string
+		headerless  bool
+	}{
+		{"stereo.hca", "audio/wav", false},
+		{"stereo.hca?format=raw", "application/octet-stream", true},
+		{"mono_loop.hca", "audio/wav", false},
+	} {
+		name, _, _ := strings.Cut(tc.query, "?")
+		want := decode(t, fsys["a/"+name].Data, func(d *hca.Hca) { d.Headerless = tc.headerless })
+		w := get(h, "/a/"+tc.query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status %d", tc.query, w.Code)
+		}
+		if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(want)) {
+			t.Errorf("%q: Content-Length %s, want %d", tc.query, got, len(want))
+		}
+		if got := w.Header().Get("Content-Type"); got != tc.contentType {
+			t.Errorf("%q: Content-Type %s", tc.query, got)
+		}
+		if w.Header().Get("Accept-Ranges") != "bytes" {
+			t.Errorf("%q: Accept-Ranges %q", tc.query, w.Header().Get("Accept-Ranges"))
+		}
+		if !bytes.Equal(w.Body.Bytes(), want) {
+			t.Errorf("%q: body differs from decoded output", tc.query)
+		}
+	}
+}
+
+// TestRange 检查 Range 请求返回 206 与对应的部分, 包括在头部之内, 从样本帧中间开始与到结尾的范围
+func TestRange(t *testing.T) {
+	fsys, data := testFS(t)
+	want := decode(t, data, func(d *hca.Hca) {})
+	size := len(want)
+	h := Handler(fsys, Options{})
+	for _, r := range [][2]int{{0, 9}, {20, 100}, {size / 2, size/2 + 4097}, {size/3 + 1, size/3 + 2}, {size - 10, size - 1}} {
+		w := get(h, "/a/stereo.hca", fmt.Sprintf("bytes=%d-%d", r[0], r[1]))
+		if w.Code != http.StatusPartialContent {
+			t.Fatalf("%v: status %d", r, w.Code)
+		}
+		if got, cr := w.Header().Get("Content-Range"), fmt.Sprintf("bytes %d-%d/%d", r[0], r[1], size); got != cr {
+			t.Errorf("%v: Content-Range %q, want %q", r, got, cr)
+		}
+		if got := w.Header().Get("Content-Length"); got != strconv.Itoa(r[1]-r[0]+1) {
+			t.Errorf("%v: Content-Length %s", r, got)
+		}
+		if !bytes.Equal(w.Body.Bytes(), want[r[0]:r[1]+1]) {
+			t.Errorf("%v: body differs from decoded output", r)
+		}
+	}
+}
+
+// TestRangeNotSatisfiable 检查超出大小的 Range 返回 416
+func TestRangeNotSatisfiable(t *testing.T) {
+	fsys, data := testFS(t)
+	size := len(decode(t, data, func(d *hca.Hca) {}))
+	w := get(Handler(fsys, Options{}), "/a/stereo.hca", fmt.Sprintf("bytes=%d-", size))
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("status %d", w.Code)
+	}
+	if got, want := w.Header().Get("Content-Range"), fmt.Sprintf("bytes */%d", size); got != want {
+		t.Errorf("Content-Range %q, want %q", got, want)
+	}
+}
+
+// TestStream 检查 hca.Reader 不支持的设置与编码格式按顺序输出, 忽略 Range
+func TestStream(t *testing.T) {
+	fsys, data := testFS(t)
+	loop := func(d *hca.Hca) { d.Loop = 1 }
+	h := Handler(fsys, Options{Configure: loop})
+	want := decode(t, data, loop)
+	w := get(h, "/a/stereo.hca", "bytes=0-9")
+	if w.Code != http.StatusOK || w.Header().Get("Accept-Ranges") != "none" {
+		t.Fatalf("loop: status %d, Accept-Ranges %q", w.Code, w.Header().Get("Accept-Ranges"))
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(want)) {
+		t.Errorf("loop: Content-Length %s, want %d", got, len(want))
+	}
+	if !bytes.Equal(w.Body.Bytes(), want) {
+		t.Error("loop: body differs from decoded output")
+	}
+
+	for format, contentType := range map[string]string{"ogg": "audio/ogg", "flac": "audio/flac"} {
+		w := get(Handler(fsys, Options{}), "/a/stereo.hca?format="+format, "")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentType || w.Body.Len() == 0 {
+			t.Errorf("%s: status %d, Content-Type %q, %d bytes", format, w.Code, w.Header().Get("Content-Type"), w.Body.Len())
+		}
+	}
+}
+
+// TestErrors 检查错误请求的状态码
+func TestErrors(t *testing.T) {
+	fsys, _ := testFS(t)
+	fsys["bad.hca"] = &fstest.MapFile{Data: []byte("not a hca file")}
+	h := Handler(fsys, Options{})
+	for target, status := range map[string]int{
+		"/missing.hca":              http.StatusNotFound,
+		"/a":                        http.StatusNotFound,
+		"/a/stereo.hca?format=mp3":  http.StatusBadRequest,
+		"/bad.hca":                  http.StatusUnsupportedMediaType,
+		"/bad.hca?format=ogg":       http.StatusUnsupportedMediaType,
+		"/a/stereo.hca?format=wav0": http.StatusBadRequest,
+	} {
+		if w := get(h, target, ""); w.Code != status {
+			t.Errorf("%s: status %d, want %d", target, w.Code, status)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package hcahttp
+
+import (
+	"errors"
+	"io"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// output 是 WAV 头部与 hca.Reader 的 PCM 数据拼接成的 io.ReadSeeker, 作为 http.ServeContent 的内容.
+// Seek 只记录位置, 读取时由 hca.Reader 从目标位置所在的块开始解码, 不需要从头解码
+type output struct {
+	header []byte // WAV 头部, raw 输出时为空
+	pcm    *hca.Reader
+	size   int64
+
+	pos  int64 // 读取位置
+	seek bool  // pcm 的读取位置需要按 pos 重新设置
+
+	sent int64 // 已读取的字节数
+	err  error // 解码的错误 (不包括 io.EOF)
+}
+
+// newOutput 创建从头开始读取的 output
+func newOutput(header []byte, pcm *hca.Reader) *output {
+	return &output{header: header, pcm: pcm, size: int64(len(header)) + pcm.Length()}
+}
+
+func (o *output) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+	if o.pos < int64(len(o.header)) {
+		n := copy(p, o.header[o.pos:])
+		o.pos += int64(n)
+		o.sent += int64(n)
+		return n, nil
+	}
+	if o.seek {
+		off := o.pos - int64(len(o.header))
+		aligned, err := o.pcm.Seek(off, io.SeekStart) // 按样本帧对齐
+		if err != nil {
+			return 0, o.fail(err)
+		}
+		if _, err := io.CopyN(io.Discard, o.pcm, off-aligned); err != nil { // Range 从样本帧中间开始, 丢弃不到一帧的数据
+			return 0, o.fail(unexpected(err))
+		}
+		o.seek = false
+	}
+	n, err := o.pcm.Read(p)
+	o.pos += int64(n)
+	o.sent += int64(n)
+	if err == io.EOF && o.pos < o.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, o.fail(err)
+}
+
+// fail 记录解码的错误
+func (o *output) fail(err error) error {
+	if err != nil && err != io.EOF && o.err == nil {
+		o.err = err
+	}
+	return err
+}
+
+func (o *output) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.pos
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, errors.New("hcahttp: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("hcahttp: negative position")
+	}
+	if offset != o.pos {
+		o.pos, o.seek = offset, true
+	}
+	return offset, nil
+}
+
+// unexpected 将数据结束之前的 io.EOF 转换为 io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// countWriter 统计写入 w 的字节数, 流式输出时判断是否已经开始发送响应
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
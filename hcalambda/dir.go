@@ -0,0 +1,64 @@
+package hcalambda
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Dir is Source and Destination backed by a local directory
+// Dir 是以本地目录实现的 Source 与 Destination, 引用是目录中的相对路径 (使用 /),
+// 不能访问目录之外的文件. 用于本地测试或挂载了共享存储的函数
+type Dir string
+
+// Open open ref for reading
+// Open 打开引用对应的文件
+func (d Dir) Open(_ context.Context, ref string) (io.ReadSeekCloser, error) {
+	root, err := os.OpenRoot(string(d))
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.Open(ref)
+}
+
+// Create create ref, the file appears only after a successful Close
+// Create 创建引用对应的文件, 先写入同目录下的临时文件, Close 时重命名, Abort 时删除
+func (d Dir) Create(_ context.Context, ref string) (io.WriteCloser, error) {
+	name := filepath.FromSlash(ref)
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("hcalambda: invalid output %q", ref)
+	}
+	name = filepath.Join(string(d), name)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return nil, err
+	}
+	f.Chmod(0o644) // CreateTemp 创建的文件只有所有者可以读写
+	return &dirFile{File: f, name: name}, nil
+}
+
+// dirFile 是 Dir.Create 返回的临时文件
+type dirFile struct {
+	*os.File
+	name string // 最终的文件名
+}
+
+func (f *dirFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	return os.Rename(f.File.Name(), f.name)
+}
+
+// Abort 丢弃不完整的输出
+func (f *dirFile) Abort() error {
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
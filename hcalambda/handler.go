@@ -0,0 +1,283 @@
+// Package hcalambda is a serverless decode handler for cloud asset pipelines.
+// hcalambda 提供一个无服务器 (例如 AWS Lambda, Cloud Functions) 使用的解码处理函数:
+// 请求给出输入与输出对象的引用, 通过 Source 与 Destination 读写 (对象存储, 本地目录等),
+// 按照大小限制与超时解码, 返回可以直接序列化为 JSON 的结果.
+package hcalambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// ErrLimit is returned when input or output exceeds the handler limits
+// ErrLimit 表示输入或输出超过了 Handler 的大小限制
+var ErrLimit = errors.New("hcalambda: size limit exceeded")
+
+// Source open input object
+// Source 按引用打开输入对象
+type Source interface {
+	Open(ctx context.Context, ref string) (io.ReadSeekCloser, error)
+}
+
+// Destination create output object
+// Destination 按引用创建输出对象. 解码失败时如果返回的 io.WriteCloser 实现了 Abort() error,
+// 调用 Abort 代替 Close, 用于丢弃不完整的对象
+type Destination interface {
+	Create(ctx context.Context, ref string) (io.WriteCloser, error)
+}
+
+// Request is a decode request, usually unmarshaled from the event payload
+// Request 是一个解码请求, 通常由事件的 JSON 解析得到
+type Request struct {
+	Input  string `json:"input"`            // 输入对象的引用
+	Output string `json:"output"`           // 输出对象的引用
+	Key    string `json:"key,omitempty"`    // 64 位密钥 (十进制或 0x 十六进制) 或 hca.FindKeys 的游戏名称, 为空使用默认密钥
+	Subkey uint16 `json:"subkey,omitempty"` // 子密钥
+	Format string `json:"format,omitempty"` // 输出格式: wav (默认), raw 或 hca.RegisterFormat 注册的格式
+	Sample string `json:"sample,omitempty"` // 样本格式: s16 (默认), f32, u8, s24, s32
+	Loop   int    `json:"loop,omitempty"`   // 循环次数, 参见 hca.Hca.Loop
+}
+
+// Result is the outcome of a request
+// Result 是一次请求的结果, 失败时 Error 与 ErrorType 记录原因
+type Result struct {
+	Input        string  `json:"input"`
+	Output       string  `json:"output"`
+	Channels     int     `json:"channels,omitempty"`
+	SamplingRate int     `json:"sample_rate,omitempty"`
+	Duration     float64 `json:"duration,omitempty"` // 输出的音频时长 (秒)
+	Bytes        int64   `json:"bytes"`              // 写出的字节数
+	Elapsed      float64 `json:"elapsed"`            // 处理用时 (秒)
+	Warnings     int     `json:"warnings,omitempty"` // 按宽松策略处理的损坏块数
+	Error        string  `json:"error,omitempty"`
+	ErrorType    string  `json:"error_type,omitempty"` // 错误类型标签, 与 hcametrics.ErrorType 一致
+}
+
+// Handler decode requests
+// Handler 处理解码请求, 可以并发使用
+type Handler struct {
+	Source      Source
+	Destination Destination
+
+	MaxInputSize  int64         // 输入对象的最大字节数, 0 表示不限制
+	MaxOutputSize int64         // 输出的最大字节数, 0 表示不限制
+	Timeout       time.Duration // 单个请求的超时, 0 表示只使用 ctx 的期限
+
+	// Configure 可选, 在应用请求的选项之前设置解码器 (例如容错策略, 音量)
+	Configure func(d *hca.Hca)
+}
+
+// Handle decode req, the returned error is non-nil when Result.Error is set
+// Handle 处理一个请求, 总是返回结果; 失败时同时返回错误, 便于运行时记录失败的调用
+func (h *Handler) Handle(ctx context.Context, req Request) (*Result, error) {
+	start := time.Now()
+	res := &Result{Input: req.Input, Output: req.Output}
+	err := h.handle(ctx, req, res)
+	res.Elapsed = time.Since(start).Seconds()
+	if err != nil {
+		res.Error = err.Error()
+		res.ErrorType = ErrorType(err)
+		return res, err
+	}
+	return res, nil
+}
+
+func (h *Handler) handle(ctx context.Context, req Request, res *Result) error {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	if req.Input == "" || req.Output == "" {
+		return fmt.Errorf("%w: input and output are required", hca.ErrInvalidOption)
+	}
+	d, err := h.decoder(req)
+	if err != nil {
+		return err
+	}
+
+	in, err := h.Source.Open(ctx, req.Input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	size, err := in.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if h.MaxInputSize > 0 && size > h.MaxInputSize {
+		return fmt.Errorf("%w: input is %d bytes, limit %d", ErrLimit, size, h.MaxInputSize)
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	info, err := d.Probe(in)
+	if err != nil {
+		return err
+	}
+	res.Channels, res.SamplingRate = info.Channels, info.SamplingRate
+	if h.MaxOutputSize > 0 && d.Format == "" { // 注册的格式无法预先计算大小, 只在写出时检查
+		if _, err := in.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		n, err := d.PredictOutputSize(in)
+		if err != nil {
+			return err
+		}
+		if n > h.MaxOutputSize {
+			return fmt.Errorf("%w: output would be %d bytes, limit %d", ErrLimit, n, h.MaxOutputSize)
+		}
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	out, err := h.Destination.Create(ctx, req.Output)
+	if err != nil {
+		return err
+	}
+	w := &limitWriter{ctx: ctx, w: out, limit: h.MaxOutputSize}
+	err = d.DecodeWithWriter(&ctxReader{ctx: ctx, r: in}, w)
+	res.Bytes = w.n
+	if err != nil {
+		if a, ok := out.(interface{ Abort() error }); ok {
+			a.Abort()
+		} else {
+			out.Close()
+		}
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	stats := d.Stats()
+	res.Duration = float64(stats.Samples) / float64(info.SamplingRate)
+	res.Warnings = stats.BadBlocks + stats.BadMagic + stats.BadData
+	return nil
+}
+
+// decoder 按请求创建解码器
+func (h *Handler) decoder(req Request) (*hca.Hca, error) {
+	d := hca.NewDecoder()
+	if h.Configure != nil {
+		h.Configure(d)
+	}
+	if s := strings.TrimSpace(req.Key); s != "" {
+		key, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			keys := hca.FindKeys(s)
+			if len(keys) != 1 {
+				return nil, fmt.Errorf("%w: unknown or ambiguous key %q", hca.ErrInvalidOption, s)
+			}
+			key = keys[0].Key
+		}
+		d.SetKey(key)
+	}
+	d.Subkey = req.Subkey
+	d.Loop = req.Loop
+
+	switch req.Sample {
+	case "", "s16":
+		d.Mode = hca.Mode16Bit
+	case "f32":
+		d.Mode = hca.ModeFloat
+	case "u8":
+		d.Mode = hca.Mode8Bit
+	case "s24":
+		d.Mode = hca.Mode24Bit
+	case "s32":
+		d.Mode = hca.Mode32Bit
+	default:
+		return nil, fmt.Errorf("%w: sample format %q", hca.ErrInvalidOption, req.Sample)
+	}
+	d.Headerless, d.Format = false, ""
+	switch format := strings.ToLower(req.Format); format {
+	case "", "wav":
+	case "raw":
+		d.Headerless = true
+	default:
+		if !hca.IsFormat(format) {
+			return nil, fmt.Errorf("%w: format %q", hca.ErrInvalidOption, req.Format)
+		}
+		d.Format = format
+	}
+	return d, nil
+}
+
+// limitWriter 统计写出的字节数, 超过限制或 ctx 结束时返回错误以中止解码
+type limitWriter struct {
+	ctx   context.Context
+	w     io.Writer
+	n     int64
+	limit int64
+}
+
+func (l *limitWriter) Write(b []byte) (int, error) {
+	if err := l.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if l.limit > 0 && l.n+int64(len(b)) > l.limit {
+		return 0, fmt.Errorf("%w: output exceeds %d bytes", ErrLimit, l.limit)
+	}
+	n, err := l.w.Write(b)
+	l.n += int64(n)
+	return n, err
+}
+
+// ctxReader 在 ctx 结束后停止读取
+type ctxReader struct {
+	ctx context.Context
+	r   io.ReadSeeker
+}
+
+func (c *ctxReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+func (c *ctxReader) Seek(offset int64, whence int) (int64, error) {
+	return c.r.Seek(offset, whence)
+}
+
+// ErrorType return the error type label of err
+// ErrorType 返回 err 的错误类型标签, 与 hcametrics.ErrorType 相同, 超过大小限制时为 "too_large"
+func ErrorType(err error) string {
+	if errors.Is(err, ErrLimit) {
+		return "too_large"
+	}
+	for _, e := range errorTypes {
+		if errors.Is(err, e.err) {
+			return e.name
+		}
+	}
+	return "other"
+}
+
+// errorTypes 是错误类型标签对应的错误
+var errorTypes = []struct {
+	err  error
+	name string
+}{
+	{hca.ErrInvalidHeader, "invalid_header"},
+	{hca.ErrEmpty, "empty"},
+	{hca.ErrWrongKey, "wrong_key"},
+	{hca.ErrKeyNotFound, "wrong_key"},
+	{hca.ErrChecksumMismatch, "checksum"},
+	{hca.ErrInvalidBlockMagic, "block_magic"},
+	{hca.ErrInvalidBlockData, "block_data"},
+	{hca.ErrInvalidRVA, "invalid_rva"},
+	{hca.ErrTruncated, "truncated"},
+	{hca.ErrInvalidOption, "invalid_option"},
+	{hca.ErrOutputTooLarge, "too_large"},
+	{context.Canceled, "canceled"},
+	{context.DeadlineExceeded, "deadline"},
+}
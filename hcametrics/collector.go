@@ -0,0 +1,132 @@
+// Package hcametrics exports Prometheus metrics for services decoding HCA files.
+// hcametrics 为长期运行的解码服务 (hcahttp, hcad) 提供 Prometheus 指标.
+package hcametrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/WJQSERVER/hca"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects decode metrics
+// Collector 统计解码次数, 按错误类型的失败次数, 输入与输出字节数, 校验失败的块数, 解码耗时与实时倍率, 实现 prometheus.Collector
+type Collector struct {
+	decodes  *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	bytes    prometheus.Counter
+	input    prometheus.Counter
+	checksum prometheus.Counter
+	latency  prometheus.Histogram
+	realtime prometheus.Histogram
+}
+
+// New create a collector, namespace is the metric name prefix (e.g. "hcad")
+// New 创建 Collector, namespace 是指标名称的前缀 (例如 "hcad")
+func New(namespace string) *Collector {
+	return &Collector{
+		decodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "decodes_total", Help: "Number of decodes by result.",
+		}, []string{"result"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "decode_failures_total", Help: "Number of failed decodes by error type.",
+		}, []string{"error"}),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "output_bytes_total", Help: "Bytes of decoded output.",
+		}),
+		input: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "input_bytes_total", Help: "Bytes of HCA input read by decodes.",
+		}),
+		checksum: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "checksum_failures_total", Help: "Blocks that failed the checksum.",
+		}),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "decode_duration_seconds", Help: "Wall time of a decode.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14), // 5ms ~ 40s
+		}),
+		realtime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "decode_realtime_factor", Help: "Decoded audio time divided by wall time.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12), // 1x ~ 2048x
+		}),
+	}
+}
+
+// Observe record one decode
+// Observe 记录一次解码: audio 是解码出的音频时长, bytes 是输出的字节数, elapsed 是耗时, err 为 nil 表示成功
+func (c *Collector) Observe(audio time.Duration, bytes int64, elapsed time.Duration, err error) {
+	if err != nil {
+		c.decodes.WithLabelValues("failure").Inc()
+		c.failures.WithLabelValues(ErrorType(err)).Inc()
+	} else {
+		c.decodes.WithLabelValues("success").Inc()
+	}
+	c.bytes.Add(float64(bytes))
+	c.latency.Observe(elapsed.Seconds())
+	if elapsed > 0 && audio > 0 {
+		c.realtime.Observe(audio.Seconds() / elapsed.Seconds())
+	}
+}
+
+// ObserveDecode implements hca.DecodeObserver
+// ObserveDecode 实现 hca.DecodeObserver, 设置为解码器的 Observer 后每次解码自动记录, 同时统计输入字节数与校验失败的块数
+func (c *Collector) ObserveDecode(info hca.Info, s hca.Stats, err error) {
+	c.input.Add(float64(s.BytesRead))
+	c.checksum.Add(float64(s.BadBlocks))
+	c.Observe(s.Duration, s.BytesWritten, s.Elapsed, err)
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.decodes.Describe(ch)
+	c.failures.Describe(ch)
+	c.bytes.Describe(ch)
+	c.input.Describe(ch)
+	c.checksum.Describe(ch)
+	c.latency.Describe(ch)
+	c.realtime.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.decodes.Collect(ch)
+	c.failures.Collect(ch)
+	c.bytes.Collect(ch)
+	c.input.Collect(ch)
+	c.checksum.Collect(ch)
+	c.latency.Collect(ch)
+	c.realtime.Collect(ch)
+}
+
+// errorTypes 是错误类型标签对应的错误
+var errorTypes = []struct {
+	err  error
+	name string
+}{
+	{hca.ErrLimitExceeded, "limit"}, // 同时匹配 ErrInvalidHeader, 需要先检查
+	{hca.ErrInvalidHeader, "invalid_header"},
+	{hca.ErrEmpty, "empty"},
+	{hca.ErrWrongKey, "wrong_key"},
+	{hca.ErrKeyNotFound, "wrong_key"},
+	{hca.ErrChecksumMismatch, "checksum"},
+	{hca.ErrInvalidBlockMagic, "block_magic"},
+	{hca.ErrInvalidBlockData, "block_data"},
+	{hca.ErrInvalidRVA, "invalid_rva"},
+	{hca.ErrTruncated, "truncated"},
+	{hca.ErrInvalidOption, "invalid_option"},
+	{hca.ErrOutputTooLarge, "too_large"},
+	{context.Canceled, "canceled"},
+	{context.DeadlineExceeded, "deadline"},
+}
+
+// ErrorType return the error type label of err
+// ErrorType 返回 err 的错误类型标签, 不是库定义的错误时返回 "other"
+func ErrorType(err error) string {
+	for _, e := range errorTypes {
+		if errors.Is(err, e.err) {
+			return e.name
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,414 @@
+package hcaogg
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// 块的大小. 编码器只使用长块, 短块的大小只写入标识头部
+const (
+	shortBlockBits = 8
+	longBlockBits  = 11
+	blockSize      = 1 << longBlockBits
+	spectrumSize   = blockSize / 2 // 每块的频谱系数个数, 也是相邻两块的间隔
+	partitionSize  = 32            // 残差分区的大小
+)
+
+// floor1 的设置
+const (
+	floorRange      = 128 // Y 值的范围
+	floorMultiplier = 2   // Y 值乘以 2 后查表, 每级约 1.09 dB
+	floorRangeBits  = 10  // X 值的位数, X 的范围是 0 到 1024
+	floorClassDim   = 4   // 每个分区的点数
+	floorPartitions = (len(floorX) - 2) / floorClassDim
+)
+
+// floorX 是 floor1 各点的位置 (频谱系数的序号), 前两个固定为 0 与 1024.
+// 其余由粗到细排列, 使后面的点可以由前面的点较准确地预测
+var floorX = [...]int{
+	0, 1024,
+	256, 64, 512, 16, 128, 384, 768,
+	4, 32, 96, 192, 320, 448, 640, 896,
+	8, 24, 48, 80, 112, 160, 224, 288,
+	12, 352, 576, 704, 832,
+}
+
+// 各点在解码时使用的相邻点与按位置排序的顺序, 以及计算目标值时覆盖的频谱范围
+var (
+	floorLow, floorHigh [len(floorX)]int
+	floorOrder          [len(floorX)]int
+	floorFrom, floorTo  [len(floorX)]int
+)
+
+// minDB 是 floor 的下限, 约为 16 位样本的量化噪声在频谱中的大小, 更弱的成分被舍去
+const minDB = -110
+
+// dbStep 是 inverseDB 每级的分贝数
+var dbStep = -20 * math.Log10(inverseDB[0]) / 255
+
+// inverseDB 是 Vorbis 规范中的 floor1_inverse_dB_table, 从 -139.5 dB 到 0 dB 等比排列
+var inverseDB = func() (t [256]float64) {
+	const first = 1.0649863e-07
+	for i := range t {
+		t[i] = float64(float32(first * math.Pow(1/first, float64(i)/255)))
+	}
+	return t
+}()
+
+func init() {
+	for i := range floorX {
+		floorOrder[i] = i
+		if i < 2 {
+			continue
+		}
+		for j := 1; j < i; j++ { // 与解码器的 low_neighbor/high_neighbor 相同, 跳过第 0 个点
+			if floorX[j] < floorX[i] && floorX[j] > floorX[floorLow[i]] {
+				floorLow[i] = j
+			}
+			if floorX[j] > floorX[i] && (floorHigh[i] == 0 || floorX[j] < floorX[floorHigh[i]]) {
+				floorHigh[i] = j
+			}
+		}
+	}
+	for i := 1; i < len(floorOrder); i++ { // 按位置插入排序
+		for j := i; j > 0 && floorX[floorOrder[j]] < floorX[floorOrder[j-1]]; j-- {
+			floorOrder[j], floorOrder[j-1] = floorOrder[j-1], floorOrder[j]
+		}
+	}
+	for j, i := range floorOrder {
+		if j > 0 {
+			floorFrom[i] = (floorX[floorOrder[j-1]] + floorX[i]) / 2
+		}
+		floorTo[i] = spectrumSize
+		if j+1 < len(floorOrder) {
+			floorTo[i] = (floorX[i]+floorX[floorOrder[j+1]])/2 + 1
+		}
+	}
+}
+
+// tuning 是由质量决定的编码参数
+type tuning struct {
+	snr    float64 // floor 低于附近频谱峰值的分贝数, 越大量化噪声越小
+	depth  float64 // floor 最多低于整个块峰值的分贝数, 更弱的成分被舍去
+	cutoff int     // 从该频谱系数开始不编码 (低通)
+}
+
+// newTuning 返回质量 quality (-1 到 10) 在采样率 rate 下的参数
+func newTuning(quality float64, rate int) tuning {
+	t := tuning{
+		snr:    4 + 2.5*quality,
+		depth:  70 + 4*quality,
+		cutoff: spectrumSize,
+	}
+	if hz := 14000 + 1500*(quality+1); hz < float64(rate)/2 {
+		t.cutoff = int(hz / (float64(rate) / 2) * spectrumSize)
+	}
+	return t
+}
+
+// encoder 编码音频数据包, 保存复用的缓冲
+type encoder struct {
+	t      tuning
+	mdct   *mdct
+	coef   []float64 // 当前通道的频谱
+	curve  []uint8   // 当前通道 floor 曲线在 inverseDB 中的序号
+	res    [][]int   // 各通道的残差
+	class  [][]uint8 // 各通道各分区的类别
+	active []bool    // 通道是否有非零的残差
+
+	y      [len(floorX)]int // floor 各点写出的值
+	finalY [len(floorX)]int // 解码器还原的各点 Y 值
+	step2  [len(floorX)]bool
+	vec    [floorClassDim]int
+}
+
+// newEncoder 创建 channels 个通道的编码器
+func newEncoder(channels int, t tuning) *encoder {
+	e := &encoder{
+		t:      t,
+		mdct:   newMDCT(blockSize),
+		coef:   make([]float64, spectrumSize),
+		curve:  make([]uint8, spectrumSize),
+		res:    make([][]int, channels),
+		class:  make([][]uint8, channels),
+		active: make([]bool, channels),
+	}
+	for c := range e.res {
+		e.res[c] = make([]int, spectrumSize)
+		e.class[c] = make([]uint8, spectrumSize/partitionSize)
+	}
+	return e
+}
+
+// encodePacket 将各通道的 blockSize 个样本编码为一个音频数据包写入 w
+func (e *encoder) encodePacket(w *bitWriter, pcm [][]float64) {
+	w.writeBits(0, 1) // 音频数据包, 只有一个模式, 模式序号占 0 位
+	w.writeBits(1, 1) // 前后都是长块
+	w.writeBits(1, 1)
+	for c, x := range pcm {
+		e.mdct.forward(x, e.coef)
+		e.floor()
+		e.active[c] = e.residue(e.res[c], e.class[c])
+		if !e.active[c] {
+			w.writeBits(0, 1) // 没有 floor, 解码结果为静音
+			continue
+		}
+		w.writeBits(1, 1)
+		w.writeBits(uint64(e.y[0]), 7)
+		w.writeBits(uint64(e.y[1]), 7)
+		for _, y := range e.y[2:] {
+			books[bookFloor].write(w, y)
+		}
+	}
+	e.writeResidue(w)
+}
+
+// floor 按当前通道的频谱选择 floor 各点的值, 并按解码器的方式画出 floor 曲线
+func (e *encoder) floor() {
+	var level [len(floorX)]float64
+	peak := 0.0
+	for i := range floorX {
+		m := 0.0
+		for _, v := range e.coef[floorFrom[i]:floorTo[i]] {
+			m = max(m, math.Abs(v))
+		}
+		level[i] = m
+		peak = max(peak, m)
+	}
+	peakDB := 20 * math.Log10(peak)
+	target := func(i int) int {
+		db := max(20*math.Log10(level[i])-e.t.snr, peakDB-e.t.depth, minDB)
+		y := int(math.Round((db/dbStep + 255) / floorMultiplier))
+		return min(max(y, 0), floorRange-1)
+	}
+
+	e.y[0], e.y[1] = target(0), target(1)
+	e.finalY[0], e.finalY[1] = e.y[0], e.y[1]
+	e.step2[0], e.step2[1] = true, true
+	for i := 2; i < len(floorX); i++ {
+		low, high := floorLow[i], floorHigh[i]
+		predicted := renderPoint(floorX[low], e.finalY[low], floorX[high], e.finalY[high], floorX[i])
+		y := target(i)
+		if d := y - predicted; d >= -1 && d <= 1 { // 与预测值相差不到 1 级时不写出差值
+			e.y[i], e.finalY[i], e.step2[i] = 0, predicted, false
+			continue
+		}
+		e.y[i] = floorValue(y, predicted)
+		e.finalY[i] = y
+		e.step2[i], e.step2[low], e.step2[high] = true, true, true
+	}
+
+	lx, ly := 0, e.finalY[0]*floorMultiplier
+	for _, i := range floorOrder[1:] {
+		if e.step2[i] {
+			hx, hy := floorX[i], e.finalY[i]*floorMultiplier
+			renderLine(lx, ly, hx, hy, e.curve)
+			lx, ly = hx, hy
+		}
+	}
+}
+
+// floorValue 返回在预测值 predicted 下表示 y 时写出的值 (解码器 floor1 step 2 的逆运算)
+func floorValue(y, predicted int) int {
+	highRoom, lowRoom := floorRange-predicted, predicted
+	room := 2 * min(highRoom, lowRoom)
+	switch d := y - predicted; {
+	case d > 0 && 2*d < room:
+		return 2 * d
+	case d > 0:
+		return d + lowRoom
+	case 2*-d-1 < room:
+		return 2*-d - 1
+	default:
+		return -d + highRoom - 1
+	}
+}
+
+// renderPoint 返回 (x0, y0) 到 (x1, y1) 的直线在 x 处的整数值
+func renderPoint(x0, y0, x1, y1, x int) int {
+	dy := y1 - y0
+	off := abs(dy) * (x - x0) / (x1 - x0)
+	if dy < 0 {
+		return y0 - off
+	}
+	return y0 + off
+}
+
+// renderLine 与解码器相同地画出 [x0, x1) 范围内的直线
+func renderLine(x0, y0, x1, y1 int, v []uint8) {
+	dy, adx := y1-y0, x1-x0
+	base := dy / adx
+	sy := base + 1
+	if dy < 0 {
+		sy = base - 1
+	}
+	ady := abs(dy) - abs(base)*adx
+	y, err := y0, 0
+	v[x0] = uint8(y)
+	for x := x0 + 1; x < x1; x++ {
+		err += ady
+		if err >= adx {
+			err -= adx
+			y += sy
+		} else {
+			y += base
+		}
+		v[x] = uint8(y)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// residue 将当前通道的频谱除以 floor 曲线并量化, 选择各分区的类别, 全部为 0 时返回 false
+func (e *encoder) residue(res []int, class []uint8) bool {
+	nonzero := false
+	for p := range class {
+		peak := 0
+		for k := p * partitionSize; k < (p+1)*partitionSize; k++ {
+			v := 0
+			if k < e.t.cutoff {
+				v = int(math.Round(e.coef[k] / inverseDB[e.curve[k]]))
+				v = min(max(v, -maxResidue), maxResidue)
+			}
+			res[k] = v
+			peak = max(peak, abs(v))
+		}
+		c := 0
+		for residueClasses[c].limit < peak {
+			c++
+		}
+		class[p] = uint8(c)
+		nonzero = nonzero || c > 0
+	}
+	return nonzero
+}
+
+// writeResidue 按解码器的顺序写出各通道的残差: 每一轮依次写出各分区,
+// 第 0 轮先写出分区的类别. 大的值拆成几位, 第 i 轮写出第 i 位
+func (e *encoder) writeResidue(w *bitWriter) {
+	for pass := range residueClasses[len(residueClasses)-1].books {
+		for p := range spectrumSize / partitionSize {
+			if pass == 0 {
+				for c, res := range e.class {
+					if e.active[c] {
+						books[bookClass].write(w, int(res[p]))
+					}
+				}
+			}
+			for c, res := range e.res {
+				if !e.active[c] {
+					continue
+				}
+				rc := residueClasses[e.class[c][p]]
+				if pass >= len(rc.books) {
+					continue
+				}
+				book := books[rc.books[pass]]
+				part := res[p*partitionSize : (p+1)*partitionSize]
+				for i := 0; i < len(part); i += book.dim {
+					vec := e.vec[:book.dim]
+					for j := range vec {
+						vec[j] = digit(part[i+j], rc.books[:pass+1])
+					}
+					book.writeVector(w, vec)
+				}
+			}
+		}
+	}
+}
+
+// digit 返回 v 依次用 passes 中各码本表示时, 最后一个码本写出的值
+func digit(v int, passes []int) int {
+	d := 0
+	for _, b := range passes {
+		step := books[b].delta
+		if v < 0 {
+			d = -((-v + step/2) / step) * step
+		} else {
+			d = (v + step/2) / step * step
+		}
+		v -= d
+	}
+	return d
+}
+
+// mdct 计算加窗的 MDCT, 通过 n/4 点的复数 FFT 计算长度 n/2 的 DCT-IV
+type mdct struct {
+	n       int
+	window  []float64 // Vorbis 窗口
+	rotate  []complex128
+	post    []complex128
+	twiddle []complex128
+	rev     []int
+	u       []float64
+	z       []complex128
+}
+
+// newMDCT 创建长度为 n 的 MDCT
+func newMDCT(n int) *mdct {
+	m := n / 2
+	h := m / 2
+	t := &mdct{
+		n:       n,
+		window:  make([]float64, n),
+		rotate:  make([]complex128, h),
+		post:    make([]complex128, h),
+		twiddle: make([]complex128, h/2),
+		rev:     make([]int, h),
+		u:       make([]float64, m),
+		z:       make([]complex128, h),
+	}
+	for i := range t.window {
+		s := math.Sin((float64(i) + 0.5) / float64(n) * math.Pi)
+		t.window[i] = math.Sin(math.Pi / 2 * s * s)
+	}
+	// 解码器的 IMDCT 不做缩放, 系数乘以 4/n 使加窗重叠相加后还原输入
+	scale := 4 / float64(n)
+	for i := range h {
+		t.rotate[i] = cmplx.Exp(complex(0, -math.Pi*(4*float64(i)+1)/(4*float64(m))))
+		t.post[i] = cmplx.Exp(complex(0, -math.Pi*float64(i)/float64(m))) * complex(scale, 0)
+	}
+	for i := range t.twiddle {
+		t.twiddle[i] = cmplx.Exp(complex(0, -2*math.Pi*float64(i)/float64(h)))
+	}
+	shift := 65 - bits.Len(uint(h))
+	for i := range t.rev {
+		t.rev[i] = int(bits.Reverse64(uint64(i)) >> shift)
+	}
+	return t
+}
+
+// forward 计算 n 个样本 x 加窗后的 n/2 个 MDCT 系数
+func (t *mdct) forward(x, out []float64) {
+	m, q := t.n/2, t.n/4
+	u, w := t.u, t.window
+	for i := range q { // 折叠为 DCT-IV 的输入
+		u[i] = -x[3*q-1-i]*w[3*q-1-i] - x[3*q+i]*w[3*q+i]
+		u[q+i] = x[i]*w[i] - x[2*q-1-i]*w[2*q-1-i]
+	}
+	z := t.z
+	for i := range q {
+		z[t.rev[i]] = complex(u[2*i], u[m-1-2*i]) * t.rotate[i]
+	}
+	for size := 2; size <= len(z); size <<= 1 {
+		half, step := size/2, len(z)/size
+		for s := 0; s < len(z); s += size {
+			for j := range half {
+				v := t.twiddle[j*step] * z[s+j+half]
+				z[s+j+half] = z[s+j] - v
+				z[s+j] += v
+			}
+		}
+	}
+	for k, v := range z {
+		v *= t.post[k]
+		out[2*k] = real(v)
+		out[m-1-2*k] = -imag(v)
+	}
+}
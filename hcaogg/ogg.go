@@ -0,0 +1,146 @@
+// Package hcaogg registers the "ogg" output format
+// hcaogg 注册 "ogg" 输出格式, 将解码结果编码为 Ogg Vorbis. 只需要导入:
+//
+//	import _ "github.com/WJQSERVER/hca/hcaogg"
+//
+// 之后设置 Hca.Format = "ogg" 或 DecodeFile 到扩展名为 .ogg 的文件即可.
+// Hca.FormatOptions 中的 "quality" 是编码质量 (-1 到 10, 可以是小数, 默认 3), 越高码率越大.
+// Hca.Tags 写入注释头部 (TITLE, ARTIST, ALBUM 与 TRACKNUMBER).
+// 编码器只使用长块且不耦合通道, 输出可以被任何 Vorbis 解码器播放, 但同码率下音质不如 libvorbis
+package hcaogg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// Format is the registered format name
+// Format 是注册的输出格式名称
+const Format = "ogg"
+
+// DefaultQuality is the default encoding quality
+// DefaultQuality 是默认的编码质量
+const DefaultQuality = 3
+
+// vendor 写入注释头部的编码器名称
+const vendor = "github.com/WJQSERVER/hca"
+
+func init() {
+	hca.RegisterFormat(Format, newSink)
+}
+
+// sink 将交错的浮点样本编码为 Vorbis 数据包并写出 Ogg 页
+type sink struct {
+	pages    pageWriter
+	channels int
+	enc      *encoder
+	packet   bitWriter
+	pcm      [][]float64 // 各通道当前块的样本, 前一半与上一块重叠
+	n        int         // pcm 中已有的样本帧数
+	block    int64       // 下一块的序号
+	total    int64       // 已写入的样本帧数
+}
+
+// newSink 是 hca.SinkFactory, 写出三个头部数据包
+func newSink(w io.Writer, info hca.Info, opts hca.SinkOptions) (hca.Sink, error) {
+	quality := float64(DefaultQuality)
+	if v, ok := opts.Options["quality"]; ok {
+		q, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(q >= -1 && q <= 10) {
+			return nil, fmt.Errorf("%w: ogg quality %q (-1 to 10)", hca.ErrInvalidOption, v)
+		}
+		quality = q
+	}
+	if info.Channels < 1 || info.Channels > 255 {
+		return nil, fmt.Errorf("%w: ogg supports 1 to 255 channels, got %d", hca.ErrInvalidOption, info.Channels)
+	}
+	if info.SamplingRate < 1 {
+		return nil, fmt.Errorf("%w: ogg sampling rate %d", hca.ErrInvalidOption, info.SamplingRate)
+	}
+	s := &sink{
+		pages:    pageWriter{w: w, granule: -1},
+		channels: info.Channels,
+		enc:      newEncoder(info.Channels, newTuning(quality, info.SamplingRate)),
+		pcm:      make([][]float64, info.Channels),
+		n:        spectrumSize, // 第一块的前一半在开始之前, 为 0
+	}
+	for c := range s.pcm {
+		s.pcm[c] = make([]float64, blockSize)
+	}
+	// 标识头部单独在第一页, 音频数据从新的一页开始
+	if err := s.pages.packet(identificationHeader(info.Channels, info.SamplingRate), 0); err != nil {
+		return nil, err
+	}
+	if err := s.pages.flush(0); err != nil {
+		return nil, err
+	}
+	if err := s.pages.packet(commentHeader(opts.Tags.VorbisComments()), 0); err != nil {
+		return nil, err
+	}
+	if err := s.pages.packet(setupHeader(), 0); err != nil {
+		return nil, err
+	}
+	return s, s.pages.flush(0)
+}
+
+// errChannels 表示样本数不是通道数的整数倍
+var errChannels = errors.New("hcaogg: samples are not a multiple of channels")
+
+// WriteSamples 实现 hca.Sink, 每满一块编码一个数据包
+func (s *sink) WriteSamples(samples []float32) error {
+	if len(samples)%s.channels != 0 {
+		return errChannels
+	}
+	for i := 0; i < len(samples); i += s.channels {
+		for c, f := range samples[i : i+s.channels] {
+			v := float64(f)
+			switch {
+			case v != v: // NaN
+				v = 0
+			case v > 1:
+				v = 1
+			case v < -1:
+				v = -1
+			}
+			s.pcm[c][s.n] = v
+		}
+		s.n++
+		s.total++
+		if s.n == blockSize {
+			if err := s.writeBlock(s.block * spectrumSize); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close 实现 hca.Sink, 以静音补齐并编码剩余的块, 最后一页的位置是总样本数, 解码器据此去掉补齐的部分
+func (s *sink) Close() error {
+	last := (s.total + spectrumSize - 1) / spectrumSize // 第 k 块解码出 [(k-1)*spectrumSize, k*spectrumSize) 的样本
+	for s.block <= last {
+		for _, x := range s.pcm {
+			clear(x[s.n:])
+		}
+		if err := s.writeBlock(min(s.block*spectrumSize, s.total)); err != nil {
+			return err
+		}
+	}
+	return s.pages.flush(flagLast)
+}
+
+// writeBlock 编码当前块, 结束位置为 granule, 之后将后一半移到开始
+func (s *sink) writeBlock(granule int64) error {
+	s.packet.reset()
+	s.enc.encodePacket(&s.packet, s.pcm)
+	for _, x := range s.pcm {
+		copy(x, x[spectrumSize:])
+	}
+	s.n = spectrumSize
+	s.block++
+	return s.pages.packet(s.packet.bytes(), granule)
+}
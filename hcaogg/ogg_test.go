@@ -0,0 +1,273 @@
+package hcaogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/WJQSERVER/hca"
+)
+
+// readPages 检查 Ogg 页的结构与 CRC, 返回拼接后的数据包与各页的 granule
+func readPages(t *testing.T, data []byte) (packets [][]byte, granules []int64) {
+	t.Helper()
+	var packet []byte
+	for seq := uint32(0); len(data) > 0; seq++ {
+		if len(data) < 27 || string(data[:4]) != "OggS" || data[4] != 0 {
+			t.Fatalf("page %d: bad header", seq)
+		}
+		flags := data[5]
+		if got := binary.LittleEndian.Uint32(data[14:]); got != serial {
+			t.Fatalf("page %d: serial %x", seq, got)
+		}
+		if got := binary.LittleEndian.Uint32(data[18:]); got != seq {
+			t.Fatalf("page %d: sequence number %d", seq, got)
+		}
+		segs := data[27 : 27+int(data[26])]
+		size := 27 + len(segs)
+		for _, s := range segs {
+			size += int(s)
+		}
+		page := bytes.Clone(data[:size])
+		crc := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if crc32(page) != crc {
+			t.Fatalf("page %d: CRC mismatch", seq)
+		}
+		if (flags&flagFirst != 0) != (seq == 0) || (flags&flagLast != 0) != (size == len(data)) {
+			t.Fatalf("page %d: flags %x", seq, flags)
+		}
+		if (flags&flagContinued != 0) != (len(packet) > 0) {
+			t.Fatalf("page %d: continued flag %x with %d pending bytes", seq, flags, len(packet))
+		}
+		body := page[27+len(segs):]
+		for _, s := range segs {
+			packet = append(packet, body[:s]...)
+			body = body[s:]
+			if s < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+		granules = append(granules, int64(binary.LittleEndian.Uint64(data[6:])))
+		data = data[size:]
+	}
+	if len(packet) > 0 {
+		t.Fatal("last packet is not finished")
+	}
+	return packets, granules
+}
+
+// encode 以 opts 编码交错排列的样本
+func encode(t *testing.T, samples []float32, channels int, opts map[string]string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	s, err := newSink(&out, hca.Info{Channels: channels, SamplingRate: 44100}, hca.SinkOptions{Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(samples) > 0 {
+		n := min(len(samples), 1000*channels)
+		if err := s.WriteSamples(samples[:n]); err != nil {
+			t.Fatal(err)
+		}
+		samples = samples[n:]
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// TestStream 检查头部, 分页与各页的位置: 标识头部单独在第一页, 音频从新的一页开始, 最后一页的位置是样本帧数
+func TestStream(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, frames := range []int{0, 1, spectrumSize, 100000} {
+		samples := make([]float32, frames*2)
+		for i := range samples {
+			samples[i] = float32(0.3*math.Sin(float64(i)*0.05) + 0.1*rng.NormFloat64())
+		}
+		data := encode(t, samples, 2, nil)
+		packets, granules := readPages(t, data)
+		if n := 3 + (frames+spectrumSize-1)/spectrumSize + 1; len(packets) != n {
+			t.Fatalf("%d frames: %d packets, want %d", frames, len(packets), n)
+		}
+		id := packets[0]
+		if string(id[:7]) != "\x01vorbis" || id[11] != 2 || binary.LittleEndian.Uint32(id[12:]) != 44100 || id[28] != 0xB8 || id[29] != 1 {
+			t.Fatalf("bad identification header % x", id)
+		}
+		if string(packets[1][:7]) != "\x03vorbis" || !bytes.Contains(packets[1], []byte(vendor)) || string(packets[2][:7]) != "\x05vorbis" {
+			t.Fatal("bad comment or setup header")
+		}
+		if granules[0] != 0 || granules[1] != 0 || len(granules) < 3 {
+			t.Fatalf("header pages: granules %v", granules)
+		}
+		for i := 3; i < len(granules); i++ {
+			if granules[i] != -1 && granules[i] < granules[i-1] {
+				t.Fatalf("granules %v are not increasing", granules)
+			}
+		}
+		if last := granules[len(granules)-1]; last != int64(frames) {
+			t.Errorf("%d frames: last granule %d", frames, last)
+		}
+		for _, p := range packets[3:] {
+			if p[0]&1 != 0 {
+				t.Fatal("audio packet starts with a header bit")
+			}
+		}
+		if !bytes.Equal(data, encode(t, samples, 2, nil)) {
+			t.Error("output is not deterministic")
+		}
+	}
+}
+
+// TestMDCT 检查 MDCT 与直接按定义计算的结果相同, 并且按解码器的方式 IMDCT, 加窗, 重叠相加后还原输入
+func TestMDCT(t *testing.T) {
+	const n = 64
+	m := newMDCT(n)
+	rng := rand.New(rand.NewSource(1))
+	x := make([]float64, n*3)
+	for i := range x {
+		x[i] = rng.Float64()*2 - 1
+	}
+	out := make([]float64, n*2)
+	coef := make([]float64, n/2)
+	for start := 0; start+n <= len(x); start += n / 2 {
+		m.forward(x[start:start+n], coef)
+		for k, c := range coef {
+			want := 0.0
+			for i := range n {
+				want += x[start+i] * m.window[i] * math.Cos(2*math.Pi/n*(float64(i)+0.5+n/4)*(float64(k)+0.5))
+			}
+			if want *= 4.0 / n; math.Abs(c-want) > 1e-9 {
+				t.Fatalf("block at %d, coefficient %d: got %g, want %g", start, k, c, want)
+			}
+		}
+		for i := range n { // 解码器的 IMDCT 没有缩放
+			y := 0.0
+			for k, c := range coef {
+				y += c * math.Cos(2*math.Pi/n*(float64(i)+0.5+n/4)*(float64(k)+0.5))
+			}
+			if start+i < len(out) {
+				out[start+i] += y * m.window[i]
+			}
+		}
+	}
+	for i := n / 2; i < len(out)-n/2; i++ { // 第一块的前一半与最后一块的后一半没有重叠
+		if math.Abs(out[i]-x[i]) > 1e-9 {
+			t.Fatalf("sample %d: got %g, want %g", i, out[i], x[i])
+		}
+	}
+}
+
+// TestFloorValue 检查 floorValue 写出的值按解码器 floor1 的规则还原出原来的 Y 值
+func TestFloorValue(t *testing.T) {
+	for predicted := range floorRange {
+		for y := range floorRange {
+			if y == predicted {
+				continue
+			}
+			val := floorValue(y, predicted)
+			if val <= 0 || val >= floorRange {
+				t.Fatalf("predicted %d, y %d: value %d out of range", predicted, y, val)
+			}
+			highRoom, lowRoom := floorRange-predicted, predicted
+			room := 2 * min(highRoom, lowRoom)
+			var got int
+			switch {
+			case val >= room && highRoom > lowRoom:
+				got = val - lowRoom + predicted
+			case val >= room:
+				got = predicted - val + highRoom - 1
+			case val%2 == 1:
+				got = predicted - (val+1)/2
+			default:
+				got = predicted + val/2
+			}
+			if got != y {
+				t.Fatalf("predicted %d, y %d: value %d decodes to %d", predicted, y, val, got)
+			}
+		}
+	}
+}
+
+// TestCodebooks 检查码本是完整的前缀码, 残差的各位在码本的范围内且相加等于原值
+func TestCodebooks(t *testing.T) {
+	for i, c := range books {
+		kraft := 0.0
+		var codes []string // 按读出顺序排列的码字
+		for e, l := range c.lengths {
+			kraft += math.Ldexp(1, -int(l))
+			code := make([]byte, l)
+			for j := range code {
+				code[j] = '0' + byte(c.codes[e]>>j&1)
+			}
+			codes = append(codes, string(code))
+		}
+		if kraft != 1 {
+			t.Errorf("book %d: Kraft sum %g", i, kraft)
+		}
+		slices.Sort(codes) // 一个码字是其他码字的前缀时, 也是排序后下一个码字的前缀
+		for j := 1; j < len(codes); j++ {
+			if strings.HasPrefix(codes[j], codes[j-1]) {
+				t.Fatalf("book %d: code %s is a prefix of %s", i, codes[j-1], codes[j])
+			}
+		}
+	}
+	for _, rc := range residueClasses {
+		for v := -rc.limit; v <= rc.limit; v++ {
+			sum := 0
+			for pass, b := range rc.books {
+				d := digit(v, rc.books[:pass+1])
+				if c := books[b]; d < c.min || d > c.min+(c.values-1)*c.delta || (d-c.min)%c.delta != 0 {
+					t.Fatalf("limit %d, value %d: pass %d writes %d", rc.limit, v, pass, d)
+				}
+				sum += d
+			}
+			if sum != v {
+				t.Fatalf("limit %d, value %d: passes add up to %d", rc.limit, v, sum)
+			}
+		}
+	}
+}
+
+// TestOptions 检查无效的选项
+func TestOptions(t *testing.T) {
+	for _, opts := range []map[string]string{{"quality": "11"}, {"quality": "-2"}, {"quality": "x"}, {"quality": "NaN"}} {
+		if _, err := newSink(&bytes.Buffer{}, hca.Info{Channels: 2, SamplingRate: 44100}, hca.SinkOptions{Options: opts}); !errors.Is(err, hca.ErrInvalidOption) {
+			t.Errorf("%v: got %v, want ErrInvalidOption", opts, err)
+		}
+	}
+}
+
+// TestTags 检查 Tags 写入注释头部
+func TestTags(t *testing.T) {
+	var out bytes.Buffer
+	tags := hca.Tags{Title: "Title", Artist: "歌手"}
+	s, err := newSink(&out, hca.Info{Channels: 1, SamplingRate: 44100}, hca.SinkOptions{Tags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	packets, _ := readPages(t, out.Bytes())
+	comment := packets[1][7:]
+	next := func(n uint32) []byte {
+		b := comment[:n]
+		comment = comment[n:]
+		return b
+	}
+	fields := []string{string(next(binary.LittleEndian.Uint32(next(4))))}
+	for range binary.LittleEndian.Uint32(next(4)) {
+		fields = append(fields, string(next(binary.LittleEndian.Uint32(next(4)))))
+	}
+	if want := []string{vendor, "TITLE=Title", "ARTIST=歌手"}; !slices.Equal(fields, want) || !bytes.Equal(comment, []byte{1}) {
+		t.Errorf("comments %q, want %q, framing %v", fields, want, comment)
+	}
+}
@@ -0,0 +1,115 @@
+package hcaogg
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// pageSize 数据超过该字节数时开始新的一页
+const pageSize = 4096
+
+// serial 逻辑流的序列号, 固定使同样的输入得到同样的输出
+const serial = 0x48434131
+
+// Ogg 页头部的标志
+const (
+	flagContinued = 0x01 // 以上一页未结束的数据包开始
+	flagFirst     = 0x02 // 流的第一页
+	flagLast      = 0x04 // 流的最后一页
+)
+
+// pageWriter 将数据包分装为 Ogg 页
+type pageWriter struct {
+	w       io.Writer
+	seq     uint32 // 下一页的序号
+	granule int64  // 当前页最后一个结束的数据包的位置, -1 表示没有数据包在当前页结束
+	cont    bool   // 当前页以上一页未结束的数据包开始
+	segs    []byte // 当前页的分段长度
+	data    []byte // 当前页的数据
+	page    []byte
+}
+
+// packet 添加一个结束位置为 granule 的数据包, 当前页已满时先写出当前页
+func (p *pageWriter) packet(b []byte, granule int64) error {
+	if len(p.data) >= pageSize {
+		if err := p.flush(0); err != nil {
+			return err
+		}
+	}
+	for {
+		avail := 255 - len(p.segs)
+		if len(b)/255+1 <= avail {
+			for range len(b) / 255 {
+				p.segs = append(p.segs, 255)
+			}
+			p.segs = append(p.segs, byte(len(b)%255))
+			p.data = append(p.data, b...)
+			p.granule = granule
+			return nil
+		}
+		// 分段表已满, 数据包延续到下一页
+		for range avail {
+			p.segs = append(p.segs, 255)
+		}
+		p.data = append(p.data, b[:avail*255]...)
+		b = b[avail*255:]
+		if err := p.flush(0); err != nil {
+			return err
+		}
+		p.cont = true
+	}
+}
+
+// flush 写出当前页, flags 是额外的头部标志
+func (p *pageWriter) flush(flags byte) error {
+	if p.cont {
+		flags |= flagContinued
+	}
+	if p.seq == 0 {
+		flags |= flagFirst
+	}
+	b := append(p.page[:0], "OggS"...)
+	b = append(b, 0, flags)
+	b = binary.LittleEndian.AppendUint64(b, uint64(p.granule))
+	b = binary.LittleEndian.AppendUint32(b, serial)
+	b = binary.LittleEndian.AppendUint32(b, p.seq)
+	b = binary.LittleEndian.AppendUint32(b, 0) // CRC, 计算后填入
+	b = append(b, byte(len(p.segs)))
+	b = append(b, p.segs...)
+	b = append(b, p.data...)
+	binary.LittleEndian.PutUint32(b[22:], crc32(b))
+	p.page = b
+
+	p.seq++
+	p.granule = -1
+	p.cont = false
+	p.segs = p.segs[:0]
+	p.data = p.data[:0]
+	_, err := p.w.Write(b)
+	return err
+}
+
+var crcTable [256]uint32
+
+func init() {
+	for i := range crcTable {
+		c := uint32(i) << 24
+		for range 8 {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		crcTable[i] = c
+	}
+}
+
+// crc32 计算 Ogg 页的 CRC (多项式 0x04C11DB7, 不反转, 初值与结果异或均为 0)
+func crc32(b []byte) uint32 {
+	var c uint32
+	for _, v := range b {
+		c = c<<8 ^ crcTable[byte(c>>24)^v]
+	}
+	return c
+}
@@ -0,0 +1,334 @@
+package hcaogg
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// bitWriter 按 Vorbis 的顺序 (每字节低位在前) 写出比特
+type bitWriter struct {
+	buf  []byte
+	acc  uint64 // 尚未写出的比特
+	nacc uint   // acc 中的比特数, 小于 8
+}
+
+// reset 清空已写出的数据, 保留缓冲
+func (w *bitWriter) reset() {
+	w.buf, w.acc, w.nacc = w.buf[:0], 0, 0
+}
+
+// writeBits 写出 v 的低 n 位, n 不超过 32
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	w.acc |= v & (1<<n - 1) << w.nacc
+	w.nacc += n
+	for w.nacc >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nacc -= 8
+	}
+}
+
+// bytes 以 0 补齐最后一个字节并返回全部数据
+func (w *bitWriter) bytes() []byte {
+	if w.nacc > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nacc = 0, 0
+	}
+	return w.buf
+}
+
+// codebook 是 Vorbis 码本, 码长按假定的分布生成, 码字总是完整的前缀码
+type codebook struct {
+	dim     int
+	lengths []uint8
+	codes   []uint32 // 按位反转的码字, 可以直接低位在前写出
+
+	// lookup type 1 的向量, 每维取 values 个值 min, min+delta, ...; values 为 0 时只用于标量
+	values, min, delta int
+}
+
+// newScalarBook 创建标量码本, weights 是各项的相对概率
+func newScalarBook(weights []float64) *codebook {
+	c := &codebook{dim: 1}
+	c.build(weights)
+	return c
+}
+
+// newVectorBook 创建 dim 维的向量码本, 每维的值 min+i*delta (i 小于 values) 的概率随 |i*delta+min|/delta 按 decay 衰减
+func newVectorBook(dim, values, min, delta int, decay float64) *codebook {
+	c := &codebook{dim: dim, values: values, min: min, delta: delta}
+	entries := 1
+	for range dim {
+		entries *= values
+	}
+	weights := make([]float64, entries)
+	for e := range weights {
+		w := 1.0
+		for i, v := 0, e; i < dim; i, v = i+1, v/values {
+			w *= math.Exp(-math.Abs(float64(v%values+min/delta)) / decay)
+		}
+		weights[e] = w
+	}
+	c.build(weights)
+	return c
+}
+
+// build 按 weights 生成 Huffman 码长, 再按 Vorbis 规定的方式分配码字
+func (c *codebook) build(weights []float64) {
+	n := len(weights)
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	// 每项至少有 1/65536 的概率, 使码长不超过 Vorbis 的上限 32
+	type node struct {
+		w      float64
+		leaves []int
+	}
+	nodes := make([]node, n)
+	for i, w := range weights {
+		nodes[i] = node{w/total + 1.0/65536, []int{i}}
+	}
+	c.lengths = make([]uint8, n)
+	for len(nodes) > 1 {
+		a, b := 0, 1 // 权重最小的两个节点
+		if nodes[b].w < nodes[a].w {
+			a, b = b, a
+		}
+		for i := 2; i < len(nodes); i++ {
+			switch {
+			case nodes[i].w < nodes[a].w:
+				a, b = i, a
+			case nodes[i].w < nodes[b].w:
+				b = i
+			}
+		}
+		for _, l := range nodes[a].leaves {
+			c.lengths[l]++
+		}
+		for _, l := range nodes[b].leaves {
+			c.lengths[l]++
+		}
+		nodes[a] = node{nodes[a].w + nodes[b].w, append(nodes[a].leaves, nodes[b].leaves...)}
+		nodes = append(nodes[:b], nodes[b+1:]...)
+	}
+
+	// 依次为每项分配该长度下最小的可用码字 (与 libvorbis 的 _make_words 相同)
+	var marker [33]uint32
+	c.codes = make([]uint32, n)
+	for i, l := range c.lengths {
+		entry := marker[l]
+		c.codes[i] = bits.Reverse32(entry) >> (32 - l)
+		for j := l; j > 0; j-- {
+			if marker[j]&1 != 0 {
+				if j == 1 {
+					marker[1]++
+				} else {
+					marker[j] = marker[j-1] << 1
+				}
+				break
+			}
+			marker[j]++
+		}
+		for j := l + 1; j < 33; j++ {
+			if marker[j]>>1 != entry {
+				break
+			}
+			entry = marker[j]
+			marker[j] = marker[j-1] << 1
+		}
+	}
+}
+
+// write 写出第 entry 项的码字
+func (c *codebook) write(w *bitWriter, entry int) {
+	w.writeBits(uint64(c.codes[entry]), uint(c.lengths[entry]))
+}
+
+// writeVector 写出各维为 v 的向量, v 的值必须在码本的范围内
+func (c *codebook) writeVector(w *bitWriter, v []int) {
+	entry := 0
+	for i := len(v) - 1; i >= 0; i-- {
+		entry = entry*c.values + (v[i]-c.min)/c.delta
+	}
+	c.write(w, entry)
+}
+
+// writeHeader 写出码本在 setup 头部中的定义
+func (c *codebook) writeHeader(w *bitWriter) {
+	w.writeBits(0x564342, 24) // "BCV"
+	w.writeBits(uint64(c.dim), 16)
+	w.writeBits(uint64(len(c.lengths)), 24)
+	w.writeBits(0, 1) // 不按码长排序
+	w.writeBits(0, 1) // 每项都有码字
+	for _, l := range c.lengths {
+		w.writeBits(uint64(l-1), 5)
+	}
+	if c.values == 0 {
+		w.writeBits(0, 4)
+		return
+	}
+	valueBits := uint(bits.Len(uint(c.values - 1)))
+	w.writeBits(1, 4)
+	w.writeBits(uint64(packFloat(c.min)), 32)
+	w.writeBits(uint64(packFloat(c.delta)), 32)
+	w.writeBits(uint64(valueBits-1), 4)
+	w.writeBits(0, 1) // 各维独立, 不累加
+	for i := range c.values {
+		w.writeBits(uint64(i), valueBits)
+	}
+}
+
+// packFloat 以 Vorbis 的浮点格式表示整数 v
+func packFloat(v int) uint32 {
+	var sign uint32
+	if v < 0 {
+		sign, v = 1<<31, -v
+	}
+	return sign | 788<<21 | uint32(v) // 尾数 * 2^(788-788)
+}
+
+// 码本的序号
+const (
+	bookClass  = iota // 残差分区的类别
+	bookFloor         // floor1 的 Y 值
+	bookUnit          // 4 维, -1 到 1
+	bookSmall         // 2 维, -2 到 2
+	bookFine          // 2 维, -4 到 4
+	bookWide          // 2 维, -8 到 8
+	bookMid           // 2 维, -136 到 136, 步长 17
+	bookCoarse        // 2 维, -2312 到 2312, 步长 289
+)
+
+// books 是所有流共用的码本
+var books = func() []*codebook {
+	class := make([]float64, len(residueClasses))
+	for i := range class {
+		class[i] = math.Exp(-float64(i) / 2)
+	}
+	floor := make([]float64, floorRange)
+	for i := range floor {
+		floor[i] = math.Exp(-float64(i) / 6)
+	}
+	return []*codebook{
+		bookClass:  newScalarBook(class),
+		bookFloor:  newScalarBook(floor),
+		bookUnit:   newVectorBook(4, 3, -1, 1, 0.8),
+		bookSmall:  newVectorBook(2, 5, -2, 1, 1),
+		bookFine:   newVectorBook(2, 9, -4, 1, 1.5),
+		bookWide:   newVectorBook(2, 17, -8, 1, 3),
+		bookMid:    newVectorBook(2, 17, -136, 17, 1),
+		bookCoarse: newVectorBook(2, 17, -2312, 289, 0.7),
+	}
+}()
+
+// residueClass 是残差分区的一个类别, 分区内的值都不超过 limit 时可以使用
+type residueClass struct {
+	limit int
+	books []int // 依次叠加的码本, 第 i 个码本在第 i 轮写出
+}
+
+// residueClasses 按 limit 从小到大排列, 大的值拆成以 289, 17, 1 为单位的几位 (每位 -8 到 8)
+var residueClasses = []residueClass{
+	{0, nil},
+	{1, []int{bookUnit}},
+	{2, []int{bookSmall}},
+	{4, []int{bookFine}},
+	{8, []int{bookWide}},
+	{8*17 + 8, []int{bookMid, bookWide}},
+	{maxResidue, []int{bookCoarse, bookMid, bookWide}},
+}
+
+// maxResidue 残差的最大绝对值
+const maxResidue = 8*289 + 8*17 + 8
+
+// headerPacket 返回以 kind 与 "vorbis" 开始的头部数据包
+func headerPacket(kind byte) []byte {
+	return append([]byte{kind}, "vorbis"...)
+}
+
+// identificationHeader 返回标识头部
+func identificationHeader(channels, rate int) []byte {
+	b := headerPacket(1)
+	b = binary.LittleEndian.AppendUint32(b, 0) // 版本
+	b = append(b, byte(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = append(b, make([]byte, 12)...) // 最大, 标称与最小码率, 未指定
+	b = append(b, longBlockBits<<4|shortBlockBits)
+	return append(b, 1) // 结束标志
+}
+
+// commentHeader 返回包含 comments 的注释头部
+func commentHeader(comments []string) []byte {
+	b := headerPacket(3)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(vendor)))
+	b = append(b, vendor...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(comments)))
+	for _, c := range comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(c)))
+		b = append(b, c...)
+	}
+	return append(b, 1)
+}
+
+// setupHeader 返回 setup 头部: 码本, 一个 floor1, 一个 type 1 的残差, 一个不耦合通道的 mapping 与只使用长块的模式
+func setupHeader() []byte {
+	w := &bitWriter{buf: headerPacket(5)}
+	w.writeBits(uint64(len(books)-1), 8)
+	for _, c := range books {
+		c.writeHeader(w)
+	}
+	w.writeBits(0, 6) // 一个时域变换, 类型 0
+	w.writeBits(0, 16)
+
+	w.writeBits(0, 6) // 一个 floor
+	w.writeBits(1, 16)
+	w.writeBits(uint64(floorPartitions), 5)
+	for range floorPartitions {
+		w.writeBits(0, 4) // 都使用类别 0
+	}
+	w.writeBits(floorClassDim-1, 3)
+	w.writeBits(0, 2) // 没有子类别
+	w.writeBits(bookFloor+1, 8)
+	w.writeBits(floorMultiplier-1, 2)
+	w.writeBits(floorRangeBits, 4)
+	for _, x := range floorX[2:] {
+		w.writeBits(uint64(x), floorRangeBits)
+	}
+
+	w.writeBits(0, 6) // 一个残差
+	w.writeBits(1, 16)
+	w.writeBits(0, 24)
+	w.writeBits(spectrumSize, 24)
+	w.writeBits(partitionSize-1, 24)
+	w.writeBits(uint64(len(residueClasses)-1), 6)
+	w.writeBits(bookClass, 8)
+	for _, c := range residueClasses {
+		cascade := uint64(1)<<len(c.books) - 1
+		w.writeBits(cascade&7, 3)
+		w.writeBits(0, 1) // 不超过 3 轮, 没有高位
+	}
+	for _, c := range residueClasses {
+		for _, b := range c.books {
+			w.writeBits(uint64(b), 8)
+		}
+	}
+
+	w.writeBits(0, 6)  // 一个 mapping
+	w.writeBits(0, 16) // 类型 0
+	w.writeBits(0, 1)  // 一个 submap
+	w.writeBits(0, 1)  // 不耦合通道
+	w.writeBits(0, 2)
+	w.writeBits(0, 8) // submap 0: 时域变换, floor 与残差
+	w.writeBits(0, 8)
+	w.writeBits(0, 8)
+
+	w.writeBits(0, 6) // 一个模式
+	w.writeBits(1, 1) // 长块
+	w.writeBits(0, 16)
+	w.writeBits(0, 16)
+	w.writeBits(0, 8)
+	w.writeBits(1, 1) // 结束标志
+	return w.bytes()
+}
@@ -0,0 +1,16 @@
+module github.com/WJQSERVER/hca/hcaplayer
+
+go 1.24.4
+
+require (
+	github.com/WJQSERVER/hca v0.0.0
+	github.com/ebitengine/oto/v3 v3.3.2
+	github.com/gen2brain/malgo v0.11.26
+)
+
+require (
+	github.com/ebitengine/purego v0.8.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
+)
+
+replace github.com/WJQSERVER/hca => ../
@@ -0,0 +1,237 @@
+// Package malgoplayer plays HCA files through miniaudio (malgo) devices.
+// malgoplayer 通过 miniaudio (malgo) 的设备回调播放 HCA 文件. 解码 goroutine 将 PCM 写入无锁的环形缓冲区,
+// 设备回调只从缓冲区复制数据, 不会在音频线程中解码或加锁, 适合需要低延迟的预览工具.
+// 与 hcaplayer 不同, 每个 Player 使用自己的设备, 可以同时播放采样率不同的文件
+package malgoplayer
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WJQSERVER/hca"
+	"github.com/gen2brain/malgo"
+)
+
+const (
+	channels  = 2 // hca.PCMStream 总是立体声
+	frameSize = channels * 2
+
+	bufferTime = 200 * time.Millisecond // 环形缓冲区的长度
+)
+
+// Options is play options
+// Options 是播放选项
+type Options struct {
+	Key    uint64 // 64 位解密密钥, 0 使用 hca.NewDecoder 的默认密钥
+	Subkey uint16 // 子密钥
+	Loop   bool   // 按照文件中的循环点无限循环, 文件没有循环点时循环整个文件
+
+	// PeriodSize 是设备每次回调的帧数, 0 使用 miniaudio 的默认值. 越小延迟越低, 但更容易断音
+	PeriodSize uint32
+}
+
+// Player is a playing HCA file
+// Player 播放一个 HCA 文件, 可以暂停, 继续与跳转
+type Player struct {
+	ctx  *malgo.AllocatedContext
+	dev  *malgo.Device
+	ring *ring
+	rate int
+
+	mu  sync.Mutex // 保护 src 与 gen
+	src *hca.PCMStream
+	gen uint64 // 每次跳转加一, 解码 goroutine 丢弃跳转前读取的数据
+
+	flush  atomic.Bool // 跳转后由设备回调丢弃缓冲中的旧数据
+	ended  atomic.Bool // 解码 goroutine 已读取到结尾
+	played atomic.Int64
+
+	wake      chan struct{} // 设备回调取走数据后通知解码 goroutine
+	done      chan struct{} // 全部数据播放完毕时关闭
+	doneOnce  sync.Once
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// Play decode path and start playing
+// Play 解码 path 并开始播放
+func Play(path string, opts Options) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := New(f, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Resume(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// New decode r into memory and create a paused player
+// New 将 r 解码到内存中并创建暂停状态的 Player
+func New(r io.ReadSeeker, opts Options) (*Player, error) {
+	d := hca.NewDecoder()
+	if opts.Key != 0 {
+		d.SetKey(opts.Key)
+	}
+	d.Subkey = opts.Subkey
+	src, err := d.NewPCMStream(r)
+	if err != nil {
+		return nil, err
+	}
+	src.SetInfinite(opts.Loop)
+
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{
+		ctx:  ctx,
+		src:  src,
+		rate: src.SampleRate(),
+		ring: newRing(int(int64(src.SampleRate())*int64(bufferTime)/int64(time.Second)) * frameSize),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+
+	cfg := malgo.DefaultDeviceConfig(malgo.Playback)
+	cfg.Playback.Format = malgo.FormatS16
+	cfg.Playback.Channels = channels
+	cfg.SampleRate = uint32(p.rate)
+	cfg.PeriodSizeInFrames = opts.PeriodSize
+	p.dev, err = malgo.InitDevice(ctx.Context, cfg, malgo.DeviceCallbacks{Data: p.callback})
+	if err != nil {
+		ctx.Uninit()
+		ctx.Free()
+		return nil, err
+	}
+	go p.produce()
+	return p, nil
+}
+
+// produce 在后台读取 PCM 数据写入环形缓冲区
+func (p *Player) produce() {
+	chunk := make([]byte, 4096*frameSize)
+	var pending []byte
+	var gen uint64
+	for {
+		if len(pending) == 0 && !p.ended.Load() {
+			p.mu.Lock()
+			n, err := io.ReadFull(p.src, chunk)
+			gen = p.gen
+			p.mu.Unlock()
+			pending = chunk[:n/frameSize*frameSize]
+			if err != nil { // io.EOF 或 io.ErrUnexpectedEOF: 数据已读完
+				p.ended.Store(true)
+			}
+		}
+		p.mu.Lock()
+		if gen != p.gen {
+			pending = nil // 跳转前读取的数据
+		}
+		p.mu.Unlock()
+		if len(pending) > 0 {
+			pending = pending[p.ring.put(pending):]
+		}
+		if len(pending) == 0 && p.ended.Load() || len(pending) > 0 {
+			select { // 缓冲区已满或数据已读完, 等待回调取走数据或跳转
+			case <-p.wake:
+			case <-p.stop:
+				return
+			}
+		}
+	}
+}
+
+// callback 是设备的数据回调, 运行在音频线程中
+func (p *Player) callback(out, _ []byte, frames uint32) {
+	if p.flush.CompareAndSwap(true, false) {
+		p.ring.drop()
+	}
+	n := p.ring.get(out[:int(frames)*frameSize])
+	clear(out[n:]) // 缓冲区数据不足时输出静音
+	p.played.Add(int64(n))
+	if n == 0 && p.ended.Load() && p.ring.buffered() == 0 {
+		p.doneOnce.Do(func() { close(p.done) })
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pause pause playing
+// Pause 暂停播放
+func (p *Player) Pause() error {
+	return p.dev.Stop()
+}
+
+// Resume start or resume playing
+// Resume 开始或继续播放
+func (p *Player) Resume() error {
+	return p.dev.Start()
+}
+
+// IsPlaying report whether the device is running
+// IsPlaying 返回设备是否正在运行
+func (p *Player) IsPlaying() bool {
+	return p.dev.IsStarted()
+}
+
+// Seek move to position d
+// Seek 跳转到 d 的位置 (从文件开头计算, 不展开循环)
+func (p *Player) Seek(d time.Duration) error {
+	if d < 0 {
+		return errors.New("malgoplayer: negative position")
+	}
+	frame := int64(d) * int64(p.rate) / int64(time.Second)
+	p.mu.Lock()
+	_, err := p.src.Seek(frame*frameSize, io.SeekStart)
+	p.gen++
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	p.played.Store(frame * frameSize)
+	p.ended.Store(false)
+	p.flush.Store(true)
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Position return the played time
+// Position 返回已经送入设备的播放时长 (无限循环时继续累加)
+func (p *Player) Position() time.Duration {
+	return time.Duration(p.played.Load()/frameSize) * time.Second / time.Duration(p.rate)
+}
+
+// Done return a channel closed when playing ends
+// Done 返回播放结束时关闭的通道, 无限循环时不会关闭
+func (p *Player) Done() <-chan struct{} {
+	return p.done
+}
+
+// Close stop playing and release the device
+// Close 停止播放并释放设备
+func (p *Player) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		p.dev.Uninit()
+		p.ctx.Uninit()
+		p.ctx.Free()
+	})
+	return nil
+}
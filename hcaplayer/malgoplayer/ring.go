@@ -0,0 +1,59 @@
+package malgoplayer
+
+import "sync/atomic"
+
+// ring 是单生产者单消费者的无锁环形缓冲区: 解码 goroutine 写入, 设备回调读取.
+// 读写位置只增不减, 对容量取模得到下标, 容量必须是 2 的幂
+type ring struct {
+	buf   []byte
+	mask  uint64
+	read  atomic.Uint64
+	write atomic.Uint64
+}
+
+func newRing(size int) *ring {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &ring{buf: make([]byte, n), mask: uint64(n - 1)}
+}
+
+// free 返回可以写入的字节数 (只由生产者调用)
+func (r *ring) free() int {
+	return len(r.buf) - int(r.write.Load()-r.read.Load())
+}
+
+// buffered 返回可以读取的字节数
+func (r *ring) buffered() int {
+	return int(r.write.Load() - r.read.Load())
+}
+
+// put 写入 p 中能放下的部分, 返回写入的字节数 (只由生产者调用)
+func (r *ring) put(p []byte) int {
+	w := r.write.Load()
+	n := min(len(p), len(r.buf)-int(w-r.read.Load()))
+	for i := 0; i < n; {
+		off := int((w + uint64(i)) & r.mask)
+		i += copy(r.buf[off:], p[i:n])
+	}
+	r.write.Store(w + uint64(n))
+	return n
+}
+
+// get 读取最多 len(p) 字节, 返回读取的字节数 (只由消费者调用)
+func (r *ring) get(p []byte) int {
+	rd := r.read.Load()
+	n := min(len(p), int(r.write.Load()-rd))
+	for i := 0; i < n; {
+		off := int((rd + uint64(i)) & r.mask)
+		i += copy(p[i:n], r.buf[off:])
+	}
+	r.read.Store(rd + uint64(n))
+	return n
+}
+
+// drop 丢弃所有已写入的数据 (只由消费者调用)
+func (r *ring) drop() {
+	r.read.Store(r.write.Load())
+}
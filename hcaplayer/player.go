@@ -0,0 +1,189 @@
+// Package hcaplayer plays HCA files on the local audio device using oto.
+// Package hcaplayer 使用 oto 在本机的音频设备上播放 HCA 文件.
+//
+// oto 在一个进程中只能创建一个音频上下文, 因此第一个播放的文件决定采样率,
+// 之后播放采样率不同的文件会返回错误.
+package hcaplayer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/WJQSERVER/hca"
+	"github.com/ebitengine/oto/v3"
+)
+
+const (
+	channels  = 2 // hca.PCMStream 总是立体声
+	frameSize = channels * 2
+)
+
+// Options is play options
+// Options 是播放选项
+type Options struct {
+	Key    uint64  // 64 位解密密钥, 0 使用 hca.NewDecoder 的默认密钥
+	Subkey uint16  // 子密钥
+	Volume float64 // 音量, 0 表示 1.0
+	Loop   bool    // 按照文件中的循环点无限循环, 文件没有循环点时循环整个文件
+}
+
+var (
+	ctxMu   sync.Mutex
+	ctx     *oto.Context
+	ctxRate int
+)
+
+// audioContext 返回指定采样率的 oto 上下文, 第一次调用时创建
+func audioContext(rate int) (*oto.Context, error) {
+	ctxMu.Lock()
+	defer ctxMu.Unlock()
+	if ctx != nil {
+		if rate != ctxRate {
+			return nil, fmt.Errorf("hcaplayer: sample rate %d differs from the audio context (%d)", rate, ctxRate)
+		}
+		return ctx, nil
+	}
+	c, ready, err := oto.NewContext(&oto.NewContextOptions{
+		SampleRate:   rate,
+		ChannelCount: channels,
+		Format:       oto.FormatSignedInt16LE,
+	})
+	if err != nil {
+		return nil, err
+	}
+	<-ready
+	ctx, ctxRate = c, rate
+	return ctx, nil
+}
+
+// Player is a playing HCA file
+// Player 播放一个 HCA 文件, 可以暂停, 继续与跳转
+type Player struct {
+	player *oto.Player
+	src    *lockedStream
+	rate   int
+}
+
+// Play decode path and start playing
+// Play 解码 path 并开始播放
+func Play(path string, opts Options) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := New(f, opts)
+	if err != nil {
+		return nil, err
+	}
+	p.Resume()
+	return p, nil
+}
+
+// New decode r into memory and create a paused player
+// New 将 r 解码到内存中并创建暂停状态的 Player
+func New(r io.ReadSeeker, opts Options) (*Player, error) {
+	d := hca.NewDecoder()
+	if opts.Key != 0 {
+		d.SetKey(opts.Key)
+	}
+	d.Subkey = opts.Subkey
+	pcm, err := d.NewPCMStream(r)
+	if err != nil {
+		return nil, err
+	}
+	pcm.SetInfinite(opts.Loop)
+
+	c, err := audioContext(pcm.SampleRate())
+	if err != nil {
+		return nil, err
+	}
+	src := &lockedStream{s: pcm}
+	p := &Player{player: c.NewPlayer(src), src: src, rate: pcm.SampleRate()}
+	if opts.Volume != 0 {
+		p.player.SetVolume(opts.Volume)
+	}
+	return p, nil
+}
+
+// Pause pause playing
+// Pause 暂停播放
+func (p *Player) Pause() {
+	p.player.Pause()
+}
+
+// Resume start or resume playing
+// Resume 开始或继续播放
+func (p *Player) Resume() {
+	p.player.Play()
+}
+
+// IsPlaying report whether the player is playing
+// IsPlaying 返回是否正在播放, 播放到结尾后返回 false
+func (p *Player) IsPlaying() bool {
+	return p.player.IsPlaying()
+}
+
+// Seek move to position d
+// Seek 跳转到 d 的位置 (从文件开头计算, 不展开循环)
+func (p *Player) Seek(d time.Duration) error {
+	if d < 0 {
+		return errors.New("hcaplayer: negative position")
+	}
+	frame := int64(d) * int64(p.rate) / int64(time.Second)
+	_, err := p.player.Seek(frame*frameSize, io.SeekStart)
+	return err
+}
+
+// Position return the current play position
+// Position 返回当前的播放位置 (已送入设备但尚未播放的部分不计算在内)
+func (p *Player) Position() time.Duration {
+	pos := p.src.position() - int64(p.player.BufferedSize())
+	if pos < 0 {
+		pos = 0
+	}
+	return time.Duration(pos/frameSize) * time.Second / time.Duration(p.rate)
+}
+
+// Wait block until playing ends
+// Wait 等待播放结束, 无限循环时不会返回
+func (p *Player) Wait() {
+	for p.player.IsPlaying() {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Close stop playing and release the player
+// Close 停止播放并释放 Player
+func (p *Player) Close() error {
+	return p.player.Close()
+}
+
+// lockedStream 为 PCMStream 加锁, oto 在后台 goroutine 中读取数据
+type lockedStream struct {
+	mu sync.Mutex
+	s  *hca.PCMStream
+}
+
+func (l *lockedStream) Read(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.s.Read(b)
+}
+
+func (l *lockedStream) Seek(offset int64, whence int) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.s.Seek(offset, whence)
+}
+
+func (l *lockedStream) position() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pos, _ := l.s.Seek(0, io.SeekCurrent)
+	return pos
+}
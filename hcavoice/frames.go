@@ -0,0 +1,134 @@
+// Package hcavoice turns HCA files into 48 kHz stereo 20 ms frames for Opus voice connections.
+// hcavoice 将 HCA 文件重采样为 48 kHz 立体声, 并按 20 ms 分帧, 这是 Discord 与 WebRTC 的语音连接使用的格式.
+// 帧可以交给任何 Opus 编码器编码, 例如 gopkg.in/hraban/opus.v2 的 *opus.Encoder 满足 Encoder 接口
+package hcavoice
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/WJQSERVER/hca"
+)
+
+const (
+	SampleRate   = 48000                   // 输出采样率
+	Channels     = 2                       // 输出通道数
+	FrameSamples = SampleRate / 50         // 每帧 (20 ms) 每个通道的样本数
+	FrameSize    = FrameSamples * Channels // 每帧的样本数
+	pcmFrameSize = Channels * 2            // hca.PCMStream 一帧的字节数
+	readFrames   = 4096                    // 每次从 PCMStream 读取的帧数
+	maxOpusFrame = 4000                    // 一个 Opus 数据包的推荐最大字节数
+)
+
+// Encoder is an Opus encoder
+// Encoder 是 Opus 编码器, 将一帧交错的 16 位样本编码到 data 中, 返回写入的字节数
+type Encoder interface {
+	Encode(pcm []int16, data []byte) (int, error)
+}
+
+// Options is frame options
+// Options 是分帧的选项
+type Options struct {
+	Loop bool // 按照文件中的循环点无限循环 (背景音乐), 文件没有循环点时循环整个文件
+}
+
+// Frames yields 20 ms frames of a HCA file
+// Frames 依次返回 HCA 文件的 20 ms 帧
+type Frames struct {
+	src  *hca.PCMStream
+	rate int64
+
+	in   []int16 // 已读取但尚未使用的输入帧 (交错)
+	raw  []byte
+	pos  int64 // 下一个输出样本在 in 中的位置, 以 1/SampleRate 个输入帧为单位
+	eof  bool
+	out  []int16
+	opus []byte
+}
+
+// New decode r with d and return frames of it
+// New 使用 d 的密钥与音量等设置将 r 解码到内存中, 返回其帧序列
+func New(d *hca.Hca, r io.ReadSeeker, opts Options) (*Frames, error) {
+	src, err := d.NewPCMStream(r)
+	if err != nil {
+		return nil, err
+	}
+	src.SetInfinite(opts.Loop)
+	return &Frames{
+		src:  src,
+		rate: int64(src.SampleRate()),
+		raw:  make([]byte, readFrames*pcmFrameSize),
+		out:  make([]int16, FrameSize),
+	}, nil
+}
+
+// Next return the next frame
+// Next 返回下一帧 (FrameSize 个交错的样本), 返回的切片在下一次调用时被覆盖.
+// 最后一帧不足 20 ms 时以静音补齐, 之后返回 io.EOF
+func (f *Frames) Next() ([]int16, error) {
+	for i := 0; i < FrameSamples; i++ {
+		idx := f.pos / SampleRate
+		for idx+1 >= int64(len(f.in)/Channels) && !f.eof { // 插值需要下一个输入帧
+			if err := f.fill(); err != nil {
+				return nil, err
+			}
+			idx = f.pos / SampleRate
+		}
+		n := int64(len(f.in) / Channels)
+		if idx >= n { // 输入已用完
+			if i == 0 {
+				return nil, io.EOF
+			}
+			clear(f.out[i*Channels:])
+			break
+		}
+		frac := f.pos % SampleRate
+		for c := 0; c < Channels; c++ {
+			a := int64(f.in[idx*Channels+int64(c)])
+			b := a
+			if idx+1 < n {
+				b = int64(f.in[(idx+1)*Channels+int64(c)])
+			}
+			f.out[i*Channels+c] = int16(a + (b-a)*frac/SampleRate) // 线性插值
+		}
+		f.pos += f.rate
+	}
+	return f.out, nil
+}
+
+// fill 从 PCMStream 读取输入帧, 并丢弃已经用过的输入
+func (f *Frames) fill() error {
+	used := f.pos / SampleRate // 之前的输入帧不会再使用
+	f.in = f.in[used*Channels:]
+	f.pos -= used * SampleRate
+	n, err := io.ReadFull(f.src, f.raw)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		f.eof = true
+	} else if err != nil {
+		return err
+	}
+	in := make([]int16, len(f.in), len(f.in)+n/2)
+	copy(in, f.in)
+	for i := 0; i+2 <= n; i += 2 {
+		in = append(in, int16(binary.LittleEndian.Uint16(f.raw[i:])))
+	}
+	f.in = in
+	return nil
+}
+
+// NextOpus encode the next frame with enc
+// NextOpus 使用 enc 编码下一帧并返回 Opus 数据包, 返回的切片在下一次调用时被覆盖. 没有更多帧时返回 io.EOF
+func (f *Frames) NextOpus(enc Encoder) ([]byte, error) {
+	pcm, err := f.Next()
+	if err != nil {
+		return nil, err
+	}
+	if f.opus == nil {
+		f.opus = make([]byte, maxOpusFrame)
+	}
+	n, err := enc.Encode(pcm, f.opus)
+	if err != nil {
+		return nil, err
+	}
+	return f.opus[:n], nil
+}
@@ -0,0 +1,28 @@
+//go:build !purego && !tinygo
+
+package hca
+
+// useSIMD 在 CPU 支持 AVX2 时启用向量化的 IMDCT 与加窗
+var useSIMD = hasAVX2()
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// hasAVX2 检查 CPU 支持 AVX2 且操作系统保存了 YMM 寄存器状态
+func hasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const osxsave, avx = 1 << 27, 1 << 28
+	if ecx1&osxsave == 0 || ecx1&avx == 0 {
+		return false
+	}
+	if eax, _ := xgetbv(); eax&6 != 6 { // XMM 与 YMM 状态
+		return false
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	return ebx7&(1<<5) != 0
+}
@@ -0,0 +1,267 @@
+//go:build !purego && !tinygo
+
+#include "textflag.h"
+
+// func imdctButterfly(dst, src *[0x80]float32, h int)
+TEXT ·imdctButterfly(SB), NOSPLIT, $0-24
+	MOVQ dst+0(FP), DI
+	MOVQ src+8(FP), SI
+	MOVQ h+16(FP), DX
+	XORQ CX, CX // 当前的样本对序号
+
+	CMPQ DX, $8
+	JLT  bfSmall
+	MOVQ DX, R8
+	NEGQ R8 // ^(h-1)
+
+bfWide:
+	// Y2 = 8 对的和, Y3 = 8 对的差
+	VMOVUPS (SI), Y0
+	VMOVUPS 32(SI), Y1
+	VHADDPS Y1, Y0, Y2
+	VHSUBPS Y1, Y0, Y3
+	VPERMPD $0xD8, Y2, Y2
+	VPERMPD $0xD8, Y3, Y3
+
+	// 和写入左半, 差写入右半: left = p + p&^(h-1), right = left + h
+	MOVQ    CX, AX
+	ANDQ    R8, AX
+	ADDQ    CX, AX
+	VMOVUPS Y2, (DI)(AX*4)
+	ADDQ    DX, AX
+	VMOVUPS Y3, (DI)(AX*4)
+
+	ADDQ $64, SI
+	ADDQ $8, CX
+	CMPQ CX, $64
+	JLT  bfWide
+	VZEROUPPER
+	RET
+
+bfSmall:
+	// h < 8 时 8 对样本正好覆盖 16 个连续的输出
+	VMOVUPS (SI)(CX*8), Y0
+	VMOVUPS 32(SI)(CX*8), Y1
+	VHADDPS Y1, Y0, Y2
+	VHSUBPS Y1, Y0, Y3
+	VPERMPD $0xD8, Y2, Y2
+	VPERMPD $0xD8, Y3, Y3
+
+	CMPQ DX, $4
+	JEQ  bfHalf4
+	CMPQ DX, $2
+	JEQ  bfHalf2
+	VUNPCKLPS Y3, Y2, Y4 // h = 1: 和差交替
+	VUNPCKHPS Y3, Y2, Y5
+	JMP       bfStore
+
+bfHalf2:
+	VUNPCKLPD Y3, Y2, Y4 // h = 2: 每 2 个和后接 2 个差
+	VUNPCKHPD Y3, Y2, Y5
+
+bfStore:
+	VPERM2F128 $0x20, Y5, Y4, Y0
+	VPERM2F128 $0x31, Y5, Y4, Y1
+	JMP        bfNext
+
+bfHalf4:
+	VPERM2F128 $0x20, Y3, Y2, Y0 // h = 4: 每 4 个和后接 4 个差
+	VPERM2F128 $0x31, Y3, Y2, Y1
+
+bfNext:
+	VMOVUPS Y0, (DI)(CX*8)
+	VMOVUPS Y1, 32(DI)(CX*8)
+	ADDQ    $8, CX
+	CMPQ    CX, $64
+	JLT     bfSmall
+	VZEROUPPER
+	RET
+
+// func imdctTwiddle(dst, src *[0x80]float32, c, d *[0x40]float32, h int)
+TEXT ·imdctTwiddle(SB), NOSPLIT, $0-40
+	MOVQ dst+0(FP), DI
+	MOVQ src+8(FP), SI
+	MOVQ c+16(FP), R8
+	MOVQ d+24(FP), R9
+	MOVQ h+32(FP), DX
+	XORQ CX, CX // 当前的系数序号
+
+	CMPQ DX, $8
+	JLT  twSmall
+	LEAQ -1(DX), R10 // h-1
+
+twWide:
+	// base = 2*(s-k), left = base + k, right = left + h, rev = base + 2h - 8 - k
+	MOVQ CX, R11
+	ANDQ R10, R11
+	MOVQ CX, AX
+	SUBQ R11, AX
+	SHLQ $1, AX
+	LEAQ (AX)(R11*1), BX
+
+	VMOVUPS (SI)(BX*4), Y0
+	LEAQ    (BX)(DX*1), R12
+	VMOVUPS (SI)(R12*4), Y1
+	VMOVUPS (R8)(CX*4), Y2
+	VMOVUPS (R9)(CX*4), Y3
+
+	// Y4 = a*c - b*d, Y6 = a*d + b*c
+	VMULPS Y2, Y0, Y4
+	VMULPS Y3, Y1, Y5
+	VSUBPS Y5, Y4, Y4
+	VMULPS Y3, Y0, Y6
+	VMULPS Y2, Y1, Y7
+	VADDPS Y7, Y6, Y6
+
+	VMOVUPS Y4, (DI)(BX*4)
+
+	// a*d + b*c 倒序写入
+	VPERMILPS $0x1B, Y6, Y6
+	VPERMPD   $0x4E, Y6, Y6
+	LEAQ      -8(AX)(DX*2), AX
+	SUBQ      R11, AX
+	VMOVUPS   Y6, (DI)(AX*4)
+
+	ADDQ $8, CX
+	CMPQ CX, $64
+	JLT  twWide
+	VZEROUPPER
+	RET
+
+twSmall:
+	// h < 8 时 8 个系数正好对应 16 个连续的输入与输出
+	VMOVUPS (SI)(CX*8), Y0
+	VMOVUPS 32(SI)(CX*8), Y1
+
+	CMPQ DX, $4
+	JEQ  twLoad4
+	CMPQ DX, $2
+	JEQ  twLoad2
+	VSHUFPS $0x88, Y1, Y0, Y2 // h = 1: a, b 交替
+	VSHUFPS $0xDD, Y1, Y0, Y3
+	VPERMPD $0xD8, Y2, Y0
+	VPERMPD $0xD8, Y3, Y1
+	JMP     twCalc
+
+twLoad2:
+	VPERMPD $0xD8, Y0, Y0 // h = 2: 每 2 个 a 后接 2 个 b
+	VPERMPD $0xD8, Y1, Y1
+
+twLoad4:
+	VPERM2F128 $0x20, Y1, Y0, Y2 // h = 4: 每 4 个 a 后接 4 个 b
+	VPERM2F128 $0x31, Y1, Y0, Y3
+	VMOVUPS    Y2, Y0
+	VMOVUPS    Y3, Y1
+
+twCalc:
+	VMOVUPS (R8)(CX*4), Y2
+	VMOVUPS (R9)(CX*4), Y3
+	VMULPS  Y2, Y0, Y4
+	VMULPS  Y3, Y1, Y5
+	VSUBPS  Y5, Y4, Y4
+	VMULPS  Y3, Y0, Y6
+	VMULPS  Y2, Y1, Y7
+	VADDPS  Y7, Y6, Y6
+
+	CMPQ DX, $4
+	JEQ  twStore4
+	CMPQ DX, $2
+	JEQ  twStore2
+	VUNPCKLPS Y6, Y4, Y0 // h = 1: 输出交替
+	VUNPCKHPS Y6, Y4, Y1
+	JMP       twStore
+
+twStore2:
+	VPERMILPS $0xB1, Y6, Y6 // h = 2: 每 2 个 a*c - b*d 后接倒序的 2 个 a*d + b*c
+	VUNPCKLPD Y6, Y4, Y0
+	VUNPCKHPD Y6, Y4, Y1
+	JMP       twStore
+
+twStore4:
+	VPERMILPS $0x1B, Y6, Y6 // h = 4: 每 4 个 a*c - b*d 后接倒序的 4 个 a*d + b*c
+	VMOVUPS   Y4, Y0
+	VMOVUPS   Y6, Y1
+
+twStore:
+	VPERM2F128 $0x20, Y1, Y0, Y2
+	VPERM2F128 $0x31, Y1, Y0, Y3
+	VMOVUPS    Y2, (DI)(CX*8)
+	VMOVUPS    Y3, 32(DI)(CX*8)
+	ADDQ       $8, CX
+	CMPQ       CX, $64
+	JLT        twSmall
+	VZEROUPPER
+	RET
+
+// func imdctWindow(wave, block, stream *[0x80]float32, w0, w1 *[0x40]float32)
+TEXT ·imdctWindow(SB), NOSPLIT, $0-40
+	MOVQ wave+0(FP), DI
+	MOVQ block+8(FP), SI
+	MOVQ stream+16(FP), DX
+	MOVQ w0+24(FP), R8
+	MOVQ w1+32(FP), R9
+	XORQ CX, CX
+
+	// wave[i] = w0[i]*block[0x40+i] + stream[i]
+	// wave[0x40+i] = w1[i]*block[0x7F-i] - stream[0x40+i]
+winWave:
+	VMOVUPS (R8)(CX*4), Y0
+	VMULPS  0x100(SI)(CX*4), Y0, Y0
+	VADDPS  (DX)(CX*4), Y0, Y0
+	VMOVUPS Y0, (DI)(CX*4)
+
+	MOVQ      $0x78, AX
+	SUBQ      CX, AX
+	VPERMILPS $0x1B, (SI)(AX*4), Y1
+	VPERMPD   $0x4E, Y1, Y1
+	VMULPS    (R9)(CX*4), Y1, Y1
+	VSUBPS    0x100(DX)(CX*4), Y1, Y1
+	VMOVUPS   Y1, 0x100(DI)(CX*4)
+
+	ADDQ $8, CX
+	CMPQ CX, $0x40
+	JLT  winWave
+
+	// stream[0x3F-i] = w1[i]*block[i]
+	// stream[0x40+i] = w0[0x3F-i]*block[i]
+	XORQ CX, CX
+
+winStream:
+	VMOVUPS (SI)(CX*4), Y0
+	MOVQ    $0x38, AX
+	SUBQ    CX, AX
+
+	VMULPS    (R9)(CX*4), Y0, Y1
+	VPERMILPS $0x1B, Y1, Y1
+	VPERMPD   $0x4E, Y1, Y1
+	VMOVUPS   Y1, (DX)(AX*4)
+
+	VPERMILPS $0x1B, (R8)(AX*4), Y2
+	VPERMPD   $0x4E, Y2, Y2
+	VMULPS    Y2, Y0, Y2
+	VMOVUPS   Y2, 0x100(DX)(CX*4)
+
+	ADDQ $8, CX
+	CMPQ CX, $0x40
+	JLT  winStream
+	VZEROUPPER
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
@@ -0,0 +1,6 @@
+//go:build !purego && !tinygo
+
+package hca
+
+// useSIMD 启用 NEON 实现的 IMDCT 与加窗, ARMv8 总是支持 ASIMD
+var useSIMD = true
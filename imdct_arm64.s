@@ -0,0 +1,229 @@
+//go:build !purego && !tinygo
+
+#include "textflag.h"
+
+// func imdctButterfly(dst, src *[0x80]float32, h int)
+TEXT ·imdctButterfly(SB), NOSPLIT, $0-24
+	MOVD dst+0(FP), R0
+	MOVD src+8(FP), R1
+	MOVD h+16(FP), R2
+	MOVD $0, R3 // 当前的样本对序号
+
+	CMP $4, R2
+	BLT bfSmall
+	NEG R2, R4 // ^(h-1)
+
+bfWide:
+	// V2 = 4 对的和, V3 = 4 对的差
+	VLD2.P 32(R1), [V0.S4, V1.S4]
+	VFADD  V1.S4, V0.S4, V2.S4
+	VFSUB  V1.S4, V0.S4, V3.S4
+
+	// 和写入左半, 差写入右半: left = p + p&^(h-1), right = left + h
+	AND  R4, R3, R5
+	ADD  R3, R5, R5
+	ADD  R5<<2, R0, R6
+	VST1 [V2.S4], (R6)
+	ADD  R2<<2, R6, R6
+	VST1 [V3.S4], (R6)
+
+	ADD $4, R3
+	CMP $64, R3
+	BLT bfWide
+	RET
+
+bfSmall:
+	// h < 4 时 4 对样本正好覆盖 8 个连续的输出
+	CMP $2, R2
+	BEQ bfHalf2
+
+bfHalf1:
+	VLD2.P 32(R1), [V0.S4, V1.S4]
+	VFADD  V1.S4, V0.S4, V2.S4
+	VFSUB  V1.S4, V0.S4, V3.S4
+	VST2.P [V2.S4, V3.S4], 32(R0) // h = 1: 和差交替
+
+	ADD $4, R3
+	CMP $64, R3
+	BLT bfHalf1
+	RET
+
+bfHalf2:
+	VLD2.P 32(R1), [V0.S4, V1.S4]
+	VFADD  V1.S4, V0.S4, V2.S4
+	VFSUB  V1.S4, V0.S4, V3.S4
+	VZIP1  V3.D2, V2.D2, V4.D2 // h = 2: 每 2 个和后接 2 个差
+	VZIP2  V3.D2, V2.D2, V5.D2
+	VST1.P [V4.S4, V5.S4], 32(R0)
+
+	ADD $4, R3
+	CMP $64, R3
+	BLT bfHalf2
+	RET
+
+// func imdctTwiddle(dst, src *[0x80]float32, c, d *[0x40]float32, h int)
+TEXT ·imdctTwiddle(SB), NOSPLIT, $0-40
+	MOVD dst+0(FP), R0
+	MOVD src+8(FP), R1
+	MOVD c+16(FP), R2
+	MOVD d+24(FP), R3
+	MOVD h+32(FP), R4
+	MOVD $0, R5 // 当前的系数序号
+
+	CMP $4, R4
+	BLT twSmall
+	SUB $1, R4, R6 // h-1
+
+twWide:
+	// base = 2*(s-k), left = base + k, right = left + h, rev = base + 2h - 4 - k
+	AND R6, R5, R7
+	SUB R7, R5, R8
+	LSL $1, R8, R8
+	ADD R7, R8, R9
+
+	ADD    R9<<2, R1, R10
+	VLD1   (R10), [V0.S4]
+	ADD    R4<<2, R10, R10
+	VLD1   (R10), [V1.S4]
+	VLD1.P 16(R2), [V2.S4]
+	VLD1.P 16(R3), [V3.S4]
+
+	// V4 = a*c - b*d, V5 = a*d + b*c
+	VFMUL V2.S4, V0.S4, V4.S4
+	VFMUL V3.S4, V1.S4, V6.S4
+	VFSUB V6.S4, V4.S4, V4.S4
+	VFMUL V3.S4, V0.S4, V5.S4
+	VFMUL V2.S4, V1.S4, V7.S4
+	VFADD V7.S4, V5.S4, V5.S4
+
+	ADD  R9<<2, R0, R10
+	VST1 [V4.S4], (R10)
+
+	// a*d + b*c 倒序写入
+	VREV64 V5.S4, V5.S4
+	VEXT   $8, V5.B16, V5.B16, V5.B16
+	ADD    R4<<1, R8, R8
+	SUB    $4, R8, R8
+	SUB    R7, R8, R8
+	ADD    R8<<2, R0, R10
+	VST1   [V5.S4], (R10)
+
+	ADD $4, R5
+	CMP $64, R5
+	BLT twWide
+	RET
+
+twSmall:
+	// h < 4 时 4 个系数正好对应 8 个连续的输入与输出
+	CMP $2, R4
+	BEQ twHalf2
+
+twHalf1:
+	VLD2.P 32(R1), [V0.S4, V1.S4] // h = 1: a, b 交替
+	VLD1.P 16(R2), [V2.S4]
+	VLD1.P 16(R3), [V3.S4]
+	VFMUL  V2.S4, V0.S4, V4.S4
+	VFMUL  V3.S4, V1.S4, V6.S4
+	VFSUB  V6.S4, V4.S4, V4.S4
+	VFMUL  V3.S4, V0.S4, V5.S4
+	VFMUL  V2.S4, V1.S4, V7.S4
+	VFADD  V7.S4, V5.S4, V5.S4
+	VST2.P [V4.S4, V5.S4], 32(R0) // 输出交替
+
+	ADD $4, R5
+	CMP $64, R5
+	BLT twHalf1
+	RET
+
+twHalf2:
+	VLD1.P 32(R1), [V16.S4, V17.S4] // h = 2: 每 2 个 a 后接 2 个 b
+	VZIP1  V17.D2, V16.D2, V0.D2
+	VZIP2  V17.D2, V16.D2, V1.D2
+	VLD1.P 16(R2), [V2.S4]
+	VLD1.P 16(R3), [V3.S4]
+	VFMUL  V2.S4, V0.S4, V4.S4
+	VFMUL  V3.S4, V1.S4, V6.S4
+	VFSUB  V6.S4, V4.S4, V4.S4
+	VFMUL  V3.S4, V0.S4, V5.S4
+	VFMUL  V2.S4, V1.S4, V7.S4
+	VFADD  V7.S4, V5.S4, V5.S4
+
+	// 每 2 个 a*c - b*d 后接倒序的 2 个 a*d + b*c
+	VREV64 V5.S4, V5.S4
+	VZIP1  V5.D2, V4.D2, V16.D2
+	VZIP2  V5.D2, V4.D2, V17.D2
+	VST1.P [V16.S4, V17.S4], 32(R0)
+
+	ADD $4, R5
+	CMP $64, R5
+	BLT twHalf2
+	RET
+
+// func imdctWindow(wave, block, stream *[0x80]float32, w0, w1 *[0x40]float32)
+TEXT ·imdctWindow(SB), NOSPLIT, $0-40
+	MOVD wave+0(FP), R0
+	MOVD block+8(FP), R1
+	MOVD stream+16(FP), R2
+	MOVD w0+24(FP), R3
+	MOVD w1+32(FP), R4
+
+	// wave[i] = w0[i]*block[0x40+i] + stream[i]
+	// wave[0x40+i] = w1[i]*block[0x7F-i] - stream[0x40+i]
+	ADD  $0x100, R0, R5 // wave[0x40:]
+	ADD  $0x100, R1, R6 // block[0x40:]
+	ADD  $0x1F0, R1, R7 // block[0x7C:], 倒序
+	MOVD R2, R8         // stream
+	ADD  $0x100, R2, R9 // stream[0x40:]
+	MOVD R3, R10        // w0
+	MOVD R4, R11        // w1
+	MOVD $16, R12
+
+winWave:
+	VLD1.P 16(R10), [V0.S4]
+	VLD1.P 16(R6), [V1.S4]
+	VLD1.P 16(R8), [V2.S4]
+	VFMUL  V1.S4, V0.S4, V0.S4
+	VFADD  V2.S4, V0.S4, V0.S4
+	VST1.P [V0.S4], 16(R0)
+
+	VLD1   (R7), [V3.S4]
+	SUB    $16, R7
+	VREV64 V3.S4, V3.S4
+	VEXT   $8, V3.B16, V3.B16, V3.B16
+	VLD1.P 16(R11), [V4.S4]
+	VLD1.P 16(R9), [V5.S4]
+	VFMUL  V4.S4, V3.S4, V3.S4
+	VFSUB  V5.S4, V3.S4, V3.S4
+	VST1.P [V3.S4], 16(R5)
+
+	SUBS $1, R12
+	BNE  winWave
+
+	// stream[0x3F-i] = w1[i]*block[i]
+	// stream[0x40+i] = w0[0x3F-i]*block[i]
+	MOVD R1, R6          // block
+	ADD  $0xF0, R2, R7   // stream[0x3C:], 倒序
+	ADD  $0x100, R2, R8  // stream[0x40:]
+	ADD  $0xF0, R3, R10  // w0[0x3C:], 倒序
+	MOVD R4, R11         // w1
+	MOVD $16, R12
+
+winStream:
+	VLD1.P 16(R6), [V0.S4]
+	VLD1.P 16(R11), [V1.S4]
+	VFMUL  V1.S4, V0.S4, V1.S4
+	VREV64 V1.S4, V1.S4
+	VEXT   $8, V1.B16, V1.B16, V1.B16
+	VST1   [V1.S4], (R7)
+	SUB    $16, R7
+
+	VLD1   (R10), [V2.S4]
+	SUB    $16, R10
+	VREV64 V2.S4, V2.S4
+	VEXT   $8, V2.B16, V2.B16, V2.B16
+	VFMUL  V2.S4, V0.S4, V2.S4
+	VST1.P [V2.S4], 16(R8)
+
+	SUBS $1, R12
+	BNE  winStream
+	RET
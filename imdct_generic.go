@@ -0,0 +1,14 @@
+//go:build (!amd64 && !arm64) || purego || tinygo
+
+package hca
+
+// useSIMD 在没有汇编实现的平台上总是 false
+var useSIMD = false
+
+func calcBlockSIMD(b *[0x80]float32) {
+	panic("hca: no SIMD implementation")
+}
+
+func (ch *stChannel) buildWaveBytesSIMD(index int) {
+	panic("hca: no SIMD implementation")
+}
@@ -0,0 +1,40 @@
+//go:build (amd64 || arm64) && !purego && !tinygo
+
+package hca
+
+// 以下函数由 imdct_amd64.s 与 imdct_arm64.s 实现, 调用前需检查 useSIMD
+
+// imdctButterfly 完成 calcBlock 中一轮半长为 h 的蝶形运算
+//
+//go:noescape
+func imdctButterfly(dst, src *[0x80]float32, h int)
+
+// imdctTwiddle 完成 calcBlock 中一轮半长为 h 的旋转, c 与 d 为该轮的系数
+//
+//go:noescape
+func imdctTwiddle(dst, src *[0x80]float32, c, d *[0x40]float32, h int)
+
+// imdctWindow 与 buildWaveBytes 相同, 加窗并叠加 stream 得到 wave, 再由 block 更新 stream
+//
+//go:noescape
+func imdctWindow(wave, block, stream *[0x80]float32, w0, w1 *[0x40]float32)
+
+// calcBlockSIMD 与 calcBlock 的结果相同, 每一轮由向量指令完成
+func calcBlockSIMD(b *[0x80]float32) {
+	var tmp [0x80]float32
+	src, dst := b, &tmp
+	for h := 0x40; h > 0; h >>= 1 { // 蝶形运算, 共 7 轮, 结果在 tmp 中
+		imdctButterfly(dst, src, h)
+		src, dst = dst, src
+	}
+	for i, h := 0, 1; i < 7; i, h = i+1, h<<1 { // 旋转, 共 7 轮, 结果写回 b
+		imdctTwiddle(dst, src, (*[0x40]float32)(blockBaseFloats1[i]), (*[0x40]float32)(blockBaseFloats2[i]), h)
+		src, dst = dst, src
+	}
+}
+
+// buildWaveBytesSIMD 与 buildWaveBytes 的结果相同
+func (ch *stChannel) buildWaveBytesSIMD(index int) {
+	imdctWindow(&ch.wave[index], (*[0x80]float32)(ch.block), (*[0x80]float32)(ch.wavTmp),
+		(*[0x40]float32)(waveBaseFloats[0]), (*[0x40]float32)(waveBaseFloats[1]))
+}
@@ -0,0 +1,214 @@
+package hca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Info is header information of a HCA file
+// Info 是 HCA 文件头部的信息
+type Info struct {
+	Version      uint32 // 版本, 例如 0x0200
+	Channels     int    // 通道数量
+	SamplingRate int    // 采样率
+	Blocks       int    // 块总数
+	BlockSize    int    // 块大小 (字节)
+	Samples      int64  // 每个通道的样本数 (Probe 为解码输出的样本数, ParseInfo 去掉 Delay 与 Padding)
+	Delay        int    // fmt 块中开头的编码器延迟 (样本数), 解码输出仍然包含
+	Padding      int    // fmt 块中结尾补齐的样本数, 解码输出仍然包含
+
+	Loop      bool // 是否有 loop 块
+	LoopStart int  // 循环开始块索引
+	LoopEnd   int  // 循环结束块索引
+
+	CipherType int     // 密码类型 (0, 1, 56)
+	Volume     float32 // rva 块的相对音量
+	Comment    string  // comm 块的注释
+}
+
+// Duration return play time without loop
+// Duration 返回不展开循环时的播放时长
+func (i Info) Duration() time.Duration {
+	if i.SamplingRate == 0 {
+		return 0
+	}
+	return time.Duration(i.Samples) * time.Second / time.Duration(i.SamplingRate)
+}
+
+// Probe read only header of r
+// Probe 只读取 r 的头部并返回文件信息, 不会解码数据块, 也不会改变 h 的状态
+func (h *Hca) Probe(r io.Reader) (Info, error) {
+	if h.closed { // 解码器已关闭
+		return Info{}, ErrClosed
+	}
+	p := *h                   // 使用副本读取头部, 保留 h 最近一次解码的状态
+	p.fileState = fileState{} // 与解码时一样从空的状态开始
+	if err := p.loadHeader(r); err != nil {
+		return Info{}, err
+	}
+	return p.info(), nil
+}
+
+// ParseInfo read only header of r
+// ParseInfo 只解析 r 的头部块 (hca, fmt, comp/dec, vbr, ath, loop, ciph, rva, comm), 不初始化解码器也不解码任何数据块,
+// 适合为大量文件建立索引. 读取位置停在数据偏移量处. Samples 是去掉 fmt 块中编码器延迟与结尾补齐之后的实际样本数,
+// 时长由 Info.Duration 计算
+func ParseInfo(r io.Reader) (*Info, error) {
+	header, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Version: uint32(binary.BigEndian.Uint16(header[4:])), Volume: 1}
+	var hasFmt, hasComp bool
+	for chunks := header[8:]; len(chunks) >= 4; {
+		sig, body := binary.BigEndian.Uint32(chunks)&sigMask, chunks[4:]
+		size, name := 0, ""
+		switch sig {
+		case sigFMT:
+			size, name = 12, "fmt"
+		case sigCOMP:
+			size, name = 12, "comp"
+		case sigDEC:
+			size, name = 8, "dec"
+		case sigVBR:
+			size, name = 4, "vbr"
+		case sigATH:
+			size, name = 2, "ath"
+		case sigLOOP:
+			size, name = 12, "loop"
+		case sigCIPH:
+			size, name = 2, "ciph"
+		case sigRVA:
+			size, name = 4, "rva"
+		case sigCOMM: // 长度与以 0 结尾的字符串, 没有结尾的 0 时视为截断
+			size, name = len(body)+1, "comm"
+			if len(body) > 1 {
+				if i := bytes.IndexByte(body[1:], 0); i >= 0 {
+					size = i + 2
+				}
+			}
+		default: // pad 块, 头部校验和或无法识别的块
+			chunks = nil
+			continue
+		}
+		if len(body) < size {
+			return nil, fmt.Errorf("%w: %s chunk", ErrTruncated, name)
+		}
+		switch sig {
+		case sigFMT:
+			hasFmt = true
+			info.Channels = int(body[0])
+			info.SamplingRate = int(binary.BigEndian.Uint32(body) & 0xFFFFFF)
+			info.Blocks = int(binary.BigEndian.Uint32(body[4:]))
+			info.Delay = int(binary.BigEndian.Uint16(body[8:]))
+			info.Padding = int(binary.BigEndian.Uint16(body[10:]))
+		case sigCOMP, sigDEC:
+			hasComp = true
+			info.BlockSize = int(binary.BigEndian.Uint16(body))
+		case sigLOOP:
+			info.Loop = true
+			info.LoopStart = int(binary.BigEndian.Uint32(body))
+			info.LoopEnd = int(binary.BigEndian.Uint32(body[4:]))
+		case sigCIPH:
+			info.CipherType = int(binary.BigEndian.Uint16(body))
+		case sigRVA:
+			info.Volume = math.Float32frombits(binary.BigEndian.Uint32(body))
+		case sigCOMM:
+			comment := body[1 : size-1]
+			if len(comment) > int(body[0]) { // 与解码时一样截去超过长度字段的部分
+				comment = comment[:body[0]]
+			}
+			info.Comment = string(comment)
+		}
+		chunks = body[size:]
+	}
+
+	switch {
+	case !hasFmt:
+		return nil, fmt.Errorf("%w: missing fmt chunk", ErrInvalidHeader)
+	case !hasComp:
+		return nil, fmt.Errorf("%w: missing comp or dec chunk", ErrInvalidHeader)
+	case info.Channels < 1 || info.Channels > 16:
+		return nil, fmt.Errorf("%w: channel count %d", ErrInvalidHeader, info.Channels)
+	case info.SamplingRate < 1:
+		return nil, fmt.Errorf("%w: sampling rate %d", ErrInvalidHeader, info.SamplingRate)
+	case info.Loop && !(info.LoopStart <= info.LoopEnd && info.LoopEnd < info.Blocks):
+		return nil, fmt.Errorf("%w: loop %d-%d of %d blocks", ErrInvalidLoopRange, info.LoopStart, info.LoopEnd, info.Blocks)
+	case info.CipherType != 0 && info.CipherType != 1 && info.CipherType != 0x38:
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedCipher, info.CipherType)
+	}
+	info.Samples = max(int64(info.Blocks)*samplesPerBlock-int64(info.Delay)-int64(info.Padding), 0)
+	return info, nil
+}
+
+// Info return header information of the last decode
+// Info 返回最近一次解码的文件的头部信息
+func (h *Hca) Info() Info {
+	return h.info()
+}
+
+// info 返回已读取的头部信息
+func (h *Hca) info() Info {
+	return Info{
+		Version:      h.version,
+		Channels:     int(h.channelCount),
+		SamplingRate: int(h.samplingRate),
+		Blocks:       int(h.blockCount),
+		BlockSize:    int(h.blockSize),
+		Samples:      int64(h.blockCount) * 0x80 * 8,
+		Delay:        int(h.fmtR01),
+		Padding:      int(h.fmtR02),
+		Loop:         h.loopFlg,
+		LoopStart:    int(h.loopStart),
+		LoopEnd:      int(h.loopEnd),
+		CipherType:   int(h.ciphType),
+		Volume:       h.rvaVolume,
+		Comment:      h.commComment,
+	}
+}
+
+// PredictOutputSize return the number of bytes a decode of r will write
+// PredictOutputSize 只读取 r 的头部, 按照 h 当前的输出设置 (写入模式, 循环, 截取范围, 标签等)
+// 计算解码输出的字节数 (包括 WAV 头部). 数据块被丢弃或截断时实际输出会更短. 不会改变 h 的状态
+func (h *Hca) PredictOutputSize(r io.Reader) (int64, error) {
+	if h.closed { // 解码器已关闭
+		return 0, ErrClosed
+	}
+	if err := h.checkOptions(); err != nil {
+		return 0, err
+	}
+	if h.Format != "" { // 注册的输出格式的大小由 Sink 决定
+		return 0, fmt.Errorf("%w: output size of format %q is unknown", ErrInvalidOption, h.Format)
+	}
+	p := *h // 使用副本读取头部
+	p.fileState = fileState{}
+	if err := p.loadHeader(r); err != nil {
+		return 0, err
+	}
+	if err := p.checkChannelMap(); err != nil {
+		return 0, err
+	}
+	wavHeader, err := p.buildWaveHeader()
+	if err != nil {
+		return 0, err
+	}
+	size := int64(p.finalFrames()) * int64(wavHeader.Riff.fmtSamplingSize)
+	if !p.Headerless {
+		var n countWriter
+		wavHeader.Write(&n)
+		size += int64(n)
+	}
+	return size, nil
+}
+
+// countWriter 只统计写入的字节数
+type countWriter int64
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	*c += countWriter(len(b))
+	return len(b), nil
+}
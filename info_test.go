@@ -0,0 +1,72 @@
+package hca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseInfo 检查 ParseInfo 与读取头部的 Probe 结果一致, 样本数去掉 fmt 块中的延迟与补齐, 读取位置停在数据偏移量处
+func TestParseInfo(t *testing.T) {
+	files, _ := filepath.Glob("testdata/*.hca")
+	if len(files) == 0 {
+		t.Fatal("no test files")
+	}
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := NewDecoder().Probe(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bytes.NewReader(data)
+		got, err := ParseInfo(r)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if *got != want { // 测试文件没有延迟与补齐
+			t.Errorf("%s: got %+v, want %+v", name, *got, want)
+		}
+		if pos := r.Size() - int64(r.Len()); pos != int64(binary.BigEndian.Uint16(data[6:])) {
+			t.Errorf("%s: stopped at %d, want data offset", name, pos)
+		}
+	}
+
+	data, err := os.ReadFile("testdata/stereo.hca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Clone(data)
+	binary.BigEndian.PutUint16(data[0x14:], 960) // fmt 块的延迟与补齐
+	binary.BigEndian.PutUint16(data[0x16:], 100)
+	info, err := ParseInfo(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := int64(info.Blocks)*samplesPerBlock - 1060
+	if info.Delay != 960 || info.Padding != 100 || info.Samples != samples {
+		t.Errorf("delay %d, padding %d, samples %d, want 960, 100, %d", info.Delay, info.Padding, info.Samples, samples)
+	}
+	if d := time.Duration(samples) * time.Second / time.Duration(info.SamplingRate); info.Duration() != d {
+		t.Errorf("duration %v, want %v", info.Duration(), d)
+	}
+
+	for _, tc := range []struct {
+		data []byte
+		err  error
+	}{
+		{data[:0x14], ErrTruncated},
+		{[]byte("RIFF\x00\x00\x00\x00WAVE"), ErrInvalidSignature},
+		{append([]byte("HCA\x00\x02\x00\x00\x10pad\x00"), 0, 0, 0, 0), ErrInvalidHeader},
+	} {
+		if _, err := ParseInfo(bytes.NewReader(tc.data)); !errors.Is(err, tc.err) {
+			t.Errorf("% x: got %v, want %v", tc.data[:8], err, tc.err)
+		}
+	}
+}
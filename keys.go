@@ -0,0 +1,145 @@
+package hca
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// GameKey is a known decryption key of a game
+// GameKey 是一个已知游戏使用的 64 位解密密钥
+type GameKey struct {
+	Name    string   // 游戏名称
+	Aliases []string // 常用的简称
+	Key     uint64   // 64 位密钥, 高 32 位为 CiphKey2, 低 32 位为 CiphKey1
+}
+
+// KnownKeys is the embedded key database
+// KnownKeys 是内置的密钥数据库
+var KnownKeys = []GameKey{
+	{Name: "Phantasy Star Online 2", Aliases: []string{"pso2"}, Key: 0xCC55463930DBE1AB},
+	{Name: "THE iDOLM@STER Cinderella Girls Starlight Stage", Aliases: []string{"cgss", "deresute"}, Key: 59751358413602},
+	{Name: "THE iDOLM@STER Million Live! Theater Days", Aliases: []string{"mltd", "mirishita"}, Key: 765765765765765},
+	{Name: "Princess Connect! Re:Dive", Aliases: []string{"priconne", "pcr"}, Key: 3201512},
+	{Name: "Uma Musume Pretty Derby", Aliases: []string{"umamusume", "uma"}, Key: 75923756697503},
+	{Name: "Project Sekai: Colorful Stage! feat. Hatsune Miku", Aliases: []string{"pjsk", "prsk", "sekai"}, Key: 88888888},
+	{Name: "Dragalia Lost", Aliases: []string{"dl"}, Key: 2967411924141},
+	{Name: "Sonic Runners", Key: 19910623},
+}
+
+// Keys return CiphKey1 and CiphKey2 of the key
+// Keys 返回密钥对应的 CiphKey1 与 CiphKey2
+func (k GameKey) Keys() (key1, key2 uint32) {
+	return uint32(k.Key), uint32(k.Key >> 32)
+}
+
+// FindKeys return the best matching known keys
+// FindKeys 按名称或简称模糊查找 KnownKeys, 只返回匹配程度最高的结果.
+// 匹配程度依次为: 完全一致, 前缀, 包含, 按顺序包含所有字符. 比较时忽略大小写、空格与标点
+func FindKeys(query string) []GameKey {
+	q := normalizeName(query)
+	if q == "" {
+		return nil
+	}
+	var keys []GameKey
+	best := 0
+	for _, k := range KnownKeys {
+		rank := 0
+		for _, name := range append([]string{k.Name}, k.Aliases...) {
+			if r := matchRank(normalizeName(name), q); r > rank {
+				rank = r
+			}
+		}
+		switch {
+		case rank == 0 || rank < best:
+		case rank > best:
+			best = rank
+			keys = []GameKey{k}
+		default:
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// matchRank 返回 name 与 q 的匹配程度, 0 表示不匹配
+func matchRank(name, q string) int {
+	switch {
+	case name == q:
+		return 4
+	case strings.HasPrefix(name, q):
+		return 3
+	case strings.Contains(name, q):
+		return 2
+	}
+	// 按顺序包含 q 的所有字符, 例如 "cgstarlight" 匹配 "cinderellagirlsstarlightstage"
+	rest := q
+	for _, c := range name {
+		if rest == "" {
+			break
+		}
+		if r := []rune(rest)[0]; c == r {
+			rest = rest[len(string(r)):]
+		}
+	}
+	if rest == "" {
+		return 1
+	}
+	return 0
+}
+
+// normalizeName 转为小写并去除字母与数字以外的字符, "@" 视为 "a" (例如 iDOLM@STER)
+func normalizeName(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(s) {
+		if c == '@' {
+			c = 'a'
+		}
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// FindKey return the first candidate key that can decrypt r
+// FindKey 依次尝试 candidates 中的 64 位密钥 (与 Subkey 组合), 返回能够解码 r 开头所有校验和正确的数据块的第一个密钥.
+// 开头的块都已损坏而无法判断时不接受任何密钥. 文件没有使用密钥加密时返回 0, 没有找到时返回 ErrKeyNotFound. 不会改变 h 的状态
+func (h *Hca) FindKey(r io.ReadSeeker, candidates []uint64) (uint64, error) {
+	if h.closed { // 解码器已关闭
+		return 0, ErrClosed
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	p := *h // 使用副本, 保留 h 的密钥与最近一次解码的状态
+	p.fileState = fileState{}
+	header, err := readHeader(r)
+	if err != nil {
+		return 0, err
+	}
+	if err := p.loadHeader(bytes.NewReader(header)); err != nil {
+		return 0, err
+	}
+	if p.ciphType != 56 {
+		return 0, nil // 没有使用密钥加密
+	}
+
+	// 只读取一次检查密钥所需的数据块
+	data := make([]byte, int64(keyCheckBlocks)*int64(p.blockSize))
+	n, err := io.ReadFull(r, data)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, err
+	}
+	buf := bytes.NewReader(append(header, data[:n]...))
+	for _, key := range candidates {
+		p.SetKey(key)
+		key1, key2 := p.cipherKeys()
+		p.cipher.Init(56, key1, key2)
+		if c, err := p.checkKeyBlocks(buf); err == nil && c.verified() { // 跳过错误与无法判断的密钥
+			return key, nil
+		}
+	}
+	return 0, ErrKeyNotFound
+}
@@ -0,0 +1,117 @@
+package hca
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// cipherKey 是 testdata/cipher.hca 使用的密钥 (默认密钥)
+const cipherKey = 0xCC55463930DBE1AB
+
+// 对 testdata/cipher.hca 错误的密钥: 7 使开头的块都无法解码, 1 只使第 1 与第 2 个块无法解码, 2 只使第 0 个块无法解码
+const (
+	wrongKeyAll   = 7
+	wrongKeyLater = 1
+	wrongKeyFirst = 2
+)
+
+// readCipherFile 读取使用密钥加密的测试文件
+func readCipherFile(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "cipher.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestCheckKey 检查解码前的密钥检查: 开头的块都无法解码时报告 ErrWrongKey, 部分无法解码时交给块的处理策略
+func TestCheckKey(t *testing.T) {
+	data := readCipherFile(t)
+	tests := []struct {
+		key  uint64
+		want error
+	}{
+		{cipherKey, nil},
+		{wrongKeyAll, ErrWrongKey},
+		{wrongKeyLater, ErrInvalidBlockData}, // 第 0 个块碰巧能够解码, 之后的块按 MagicPolicy 处理
+	}
+	for _, tt := range tests {
+		h := NewDecoder()
+		h.SetKey(tt.key)
+		err := h.DecodeWithWriter(bytes.NewReader(data), io.Discard)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("key %#x: got %v, want %v", tt.key, err, tt.want)
+		}
+	}
+}
+
+// TestFindKey 检查 FindKey 只接受能够解码所有可以判断的块的密钥, 跳过无法判断的情况
+func TestFindKey(t *testing.T) {
+	data := readCipherFile(t)
+	h := NewDecoder()
+	h.SetKey(wrongKeyAll)
+	key, err := h.FindKey(bytes.NewReader(data), []uint64{wrongKeyLater, wrongKeyFirst, wrongKeyAll, cipherKey})
+	if err != nil || key != cipherKey {
+		t.Errorf("got %#x, %v, want %#x", key, err, uint64(cipherKey))
+	}
+	if h.CiphKey1 != wrongKeyAll || h.CiphKey2 != 0 { // 不设置找到的密钥
+		t.Errorf("FindKey changed the decoder key to %#x/%#x", h.CiphKey1, h.CiphKey2)
+	}
+	if _, err := h.FindKey(bytes.NewReader(data), []uint64{wrongKeyLater, wrongKeyFirst, wrongKeyAll}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("wrong keys: got %v, want ErrKeyNotFound", err)
+	}
+
+	// 开头的块的校验和都错误时无法判断, 正确的密钥也不接受
+	if err := h.loadHeader(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	damaged := bytes.Clone(data)
+	for i := uint32(0); i < h.blockCount; i++ {
+		damaged[h.blockAddress(i+1)-1] ^= 0xFF
+	}
+	if _, err := h.FindKey(bytes.NewReader(damaged), []uint64{cipherKey}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("damaged blocks: got %v, want ErrKeyNotFound", err)
+	}
+
+	plain, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := h.FindKey(bytes.NewReader(plain), []uint64{wrongKeyAll}); err != nil || key != 0 {
+		t.Errorf("unencrypted file: got %#x, %v, want 0", key, err)
+	}
+}
+
+// TestFindKeys 检查按名称与简称的模糊查找只返回匹配程度最高的结果
+func TestFindKeys(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"pso2", []string{"Phantasy Star Online 2"}},
+		{"UMA", []string{"Uma Musume Pretty Derby"}}, // 简称完全一致, 优先于 umamusume 的前缀
+		{"The iDOLM@STER", []string{"THE iDOLM@STER Cinderella Girls Starlight Stage", "THE iDOLM@STER Million Live! Theater Days"}},
+		{"theidolmaster million", []string{"THE iDOLM@STER Million Live! Theater Days"}}, // @ 视为 a
+		{"sonic", []string{"Sonic Runners"}},
+		{"Re:Dive", []string{"Princess Connect! Re:Dive"}},
+		{"cg starlight", []string{"THE iDOLM@STER Cinderella Girls Starlight Stage"}}, // 按顺序包含所有字符
+		{"", nil},
+		{"!!!", nil},
+		{"xyzzy", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, k := range FindKeys(tt.query) {
+			got = append(got, k.Name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
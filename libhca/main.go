@@ -0,0 +1,197 @@
+// Command libhca exports the HCA decoder as a C shared library.
+// libhca 将 HCA 解码器导出为 C 动态库, 供 C/C++/C#/Python 等工具使用, 构建方法:
+//
+//	go build -buildmode=c-shared -o libhca.so ./libhca
+//
+// 同时生成的 libhca.h 声明了下面的函数与 hca_info 结构体. 所有函数都从内存读取 HCA 数据,
+// 返回 HCA_OK (0) 或负数的错误码, 可以使用 hca_error_string 取得说明.
+// key 为 0 时使用默认密钥; hca_decode 输出的数据需要调用 hca_free 释放
+package main
+
+/*
+#include <stdlib.h>
+#include <string.h>
+
+#define HCA_OK              0
+#define HCA_ERR_ARGUMENT   -1
+#define HCA_ERR_HEADER     -2
+#define HCA_ERR_KEY        -3
+#define HCA_ERR_DATA       -4
+#define HCA_ERR_TRUNCATED  -5
+#define HCA_ERR_OPTION     -6
+#define HCA_ERR_TOO_LARGE  -7
+#define HCA_ERR_UNKNOWN    -99
+
+typedef struct {
+	unsigned int version;
+	int channels;
+	int sample_rate;
+	int blocks;
+	int block_size;
+	long long samples;
+	int loop;
+	int loop_start;
+	int loop_end;
+	int cipher_type;
+	float volume;
+} hca_info;
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"unsafe"
+
+	"github.com/WJQSERVER/hca"
+)
+
+func main() {}
+
+// errorCodes 将库的错误对应到 C 的错误码
+var errorCodes = []struct {
+	err  error
+	code C.int
+}{
+	{hca.ErrInvalidHeader, C.HCA_ERR_HEADER},
+	{hca.ErrEmpty, C.HCA_ERR_HEADER},
+	{hca.ErrWrongKey, C.HCA_ERR_KEY},
+	{hca.ErrKeyNotFound, C.HCA_ERR_KEY},
+	{hca.ErrChecksumMismatch, C.HCA_ERR_DATA},
+	{hca.ErrInvalidBlockMagic, C.HCA_ERR_DATA},
+	{hca.ErrInvalidBlockData, C.HCA_ERR_DATA},
+	{hca.ErrInvalidRVA, C.HCA_ERR_DATA},
+	{hca.ErrTruncated, C.HCA_ERR_TRUNCATED},
+	{hca.ErrInvalidOption, C.HCA_ERR_OPTION},
+	{hca.ErrOutputTooLarge, C.HCA_ERR_TOO_LARGE},
+}
+
+// errorCode 返回 err 对应的错误码
+func errorCode(err error) C.int {
+	if err == nil {
+		return C.HCA_OK
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return C.HCA_ERR_UNKNOWN
+}
+
+// errorStrings 是 hca_error_string 返回的静态字符串, 在 C 的内存中分配且不会释放
+var errorStrings = map[C.int]*C.char{
+	C.HCA_OK:            C.CString("ok"),
+	C.HCA_ERR_ARGUMENT:  C.CString("invalid argument"),
+	C.HCA_ERR_HEADER:    C.CString("invalid header"),
+	C.HCA_ERR_KEY:       C.CString("wrong decryption key"),
+	C.HCA_ERR_DATA:      C.CString("invalid block data"),
+	C.HCA_ERR_TRUNCATED: C.CString("data truncated"),
+	C.HCA_ERR_OPTION:    C.CString("invalid decode option"),
+	C.HCA_ERR_TOO_LARGE: C.CString("output exceeds WAV size limit"),
+	C.HCA_ERR_UNKNOWN:   C.CString("unknown error"),
+}
+
+// goBytes 将 C 的内存包装为 []byte (不复制, 只在调用期间使用)
+func goBytes(data unsafe.Pointer, size C.size_t) []byte {
+	return unsafe.Slice((*byte)(data), int(size))
+}
+
+// newDecoder 创建使用 key 与 subkey 的解码器
+func newDecoder(key C.ulonglong, subkey C.ushort) *hca.Hca {
+	d := hca.NewDecoder()
+	if key != 0 {
+		d.SetKey(uint64(key))
+	}
+	d.Subkey = uint16(subkey)
+	return d
+}
+
+// hca_error_string return description of code
+//
+//export hca_error_string
+func hca_error_string(code C.int) *C.char {
+	if s, ok := errorStrings[code]; ok {
+		return s
+	}
+	return errorStrings[C.HCA_ERR_UNKNOWN]
+}
+
+// hca_probe read header information into info
+//
+//export hca_probe
+func hca_probe(data unsafe.Pointer, size C.size_t, info *C.hca_info) C.int {
+	if data == nil || info == nil {
+		return C.HCA_ERR_ARGUMENT
+	}
+	i, err := hca.NewDecoder().Probe(bytes.NewReader(goBytes(data, size)))
+	if err != nil {
+		return errorCode(err)
+	}
+	*info = C.hca_info{
+		version:     C.uint(i.Version),
+		channels:    C.int(i.Channels),
+		sample_rate: C.int(i.SamplingRate),
+		blocks:      C.int(i.Blocks),
+		block_size:  C.int(i.BlockSize),
+		samples:     C.longlong(i.Samples),
+		loop:        boolInt(i.Loop),
+		loop_start:  C.int(i.LoopStart),
+		loop_end:    C.int(i.LoopEnd),
+		cipher_type: C.int(i.CipherType),
+		volume:      C.float(i.Volume),
+	}
+	return C.HCA_OK
+}
+
+// hca_test_key check whether key can decrypt the data, unencrypted data always returns HCA_OK
+//
+//export hca_test_key
+func hca_test_key(data unsafe.Pointer, size C.size_t, key C.ulonglong, subkey C.ushort) C.int {
+	if data == nil {
+		return C.HCA_ERR_ARGUMENT
+	}
+	d := newDecoder(0, subkey)
+	_, err := d.FindKey(bytes.NewReader(goBytes(data, size)), []uint64{uint64(key)})
+	return errorCode(err)
+}
+
+// hca_decode decode data, mode is 0 (float), 8, 16, 24 or 32; wav != 0 writes WAV header
+//
+//export hca_decode
+func hca_decode(data unsafe.Pointer, size C.size_t, key C.ulonglong, subkey C.ushort, mode C.int, wav C.int, out *unsafe.Pointer, outSize *C.size_t) C.int {
+	if data == nil || out == nil || outSize == nil {
+		return C.HCA_ERR_ARGUMENT
+	}
+	d := newDecoder(key, subkey)
+	d.Mode = int(mode)
+	d.Headerless = wav == 0
+	var buf bytes.Buffer
+	if err := d.DecodeWithWriter(bytes.NewReader(goBytes(data, size)), &buf); err != nil {
+		return errorCode(err)
+	}
+	p := C.malloc(C.size_t(max(buf.Len(), 1)))
+	if p == nil {
+		return C.HCA_ERR_UNKNOWN
+	}
+	if buf.Len() > 0 {
+		C.memcpy(p, unsafe.Pointer(&buf.Bytes()[0]), C.size_t(buf.Len()))
+	}
+	*out = p
+	*outSize = C.size_t(buf.Len())
+	return C.HCA_OK
+}
+
+// hca_free release memory returned by hca_decode
+//
+//export hca_free
+func hca_free(p unsafe.Pointer) {
+	C.free(p)
+}
+
+func boolInt(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package hca
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Limiter is soft-knee peak limiter applied after gain
+// Limiter 是在应用音量之后工作的软拐点峰值限幅器, 避免放大 (Volume > 1 或 GainDB > 0) 的输出被硬削波.
+// 没有预读, 超过阈值时立即降低增益, 之后按 Release 恢复
+type Limiter struct {
+	Threshold float64       // 输出峰值的上限 (dBFS, 不大于 0), 例如 -1
+	Knee      float64       // 软拐点的宽度 (dB), 从 Threshold-Knee/2 开始逐渐压缩, 0 表示硬拐点
+	Release   time.Duration // 增益恢复的时间常数, 0 使用 DefaultLimiterRelease
+}
+
+// DefaultLimiterRelease is default release time of Limiter
+// DefaultLimiterRelease 是 Limiter 默认的恢复时间
+const DefaultLimiterRelease = 100 * time.Millisecond
+
+// check 检查限幅器的参数
+func (l *Limiter) check() error {
+	if !(l.Threshold <= 0) || math.IsInf(l.Threshold, 0) || !(l.Knee >= 0) || math.IsInf(l.Knee, 0) || l.Release < 0 {
+		return fmt.Errorf("%w: limiter threshold %v dB knee %v dB release %v", ErrInvalidOption, l.Threshold, l.Knee, l.Release)
+	}
+	return nil
+}
+
+// limiterState 是一次解码中限幅器的状态, 所有通道使用同一个增益以保持声像
+type limiterState struct {
+	threshold, knee float64
+	release         float64 // 每个样本帧的恢复系数
+	gain            float64 // 当前的增益 (dB, 不大于 0)
+}
+
+// limit 对一个块的交错样本应用限幅器并削波到 -1 到 1 (修改 serial)
+func (h *Hca) limit(serial []float32) {
+	if h.Limiter == nil {
+		return
+	}
+	if h.limiter == nil {
+		release := h.Limiter.Release
+		if release == 0 {
+			release = DefaultLimiterRelease
+		}
+		h.limiter = &limiterState{
+			threshold: h.Limiter.Threshold,
+			knee:      h.Limiter.Knee,
+			release:   math.Exp(-1 / (release.Seconds() * float64(h.samplingRate))),
+		}
+	}
+	s := h.limiter
+	channels := int(h.outputChannels())
+	for i := 0; i+channels <= len(serial); i += channels {
+		frame := serial[i : i+channels]
+		var peak float64
+		for _, f := range frame {
+			peak = max(peak, math.Abs(float64(f)))
+		}
+		target := s.reduction(20 * math.Log10(peak))
+		if target < s.gain { // 立即压缩
+			s.gain = target
+		} else { // 按时间常数恢复
+			s.gain = target + (s.gain-target)*s.release
+		}
+		if s.gain == 0 {
+			continue
+		}
+		g := float32(math.Pow(10, s.gain/20))
+		for c, f := range frame {
+			frame[c] = max(-1, min(1, f*g))
+		}
+	}
+}
+
+// reduction 返回输入电平 x (dBFS) 需要的增益衰减 (dB, 不大于 0)
+func (s *limiterState) reduction(x float64) float64 {
+	lo := s.threshold - s.knee/2
+	switch {
+	case x <= lo:
+		return 0
+	case x < s.threshold+s.knee/2: // 拐点内按二次曲线过渡到阈值
+		d := x - lo
+		return -d * d / (2 * s.knee)
+	default:
+		return s.threshold - x
+	}
+}
@@ -0,0 +1,42 @@
+package hca
+
+import "fmt"
+
+// Limits is sanity bounds of header values
+// Limits 是头部数值的上限, 用于解码不可信的文件 (例如用户上传): 构造的头部不能导致巨大的内存分配
+// 或几乎不会结束的解码. 读取头部之后, 开始解码之前检查, 超过时返回 ErrLimitExceeded. 各项为 0 时不限制
+type Limits struct {
+	MaxBlockSize     int   // 数据块大小的上限 (字节)
+	MaxBlocks        int   // 数据块数的上限
+	MaxCommentLength int   // comm 块注释长度的上限 (字节)
+	MaxOutputBytes   int64 // 预计输出的 PCM 数据大小的上限 (按写入模式计算, 展开循环与重采样之后, 不包括 WAV 头部)
+}
+
+// UntrustedLimits is suggested limits for untrusted input
+// UntrustedLimits 是解码不可信的文件时建议的上限: 块大小 8 KiB, 约 6 小时 (48 kHz) 的数据块, 128 字节的注释与 2 GiB 的输出
+var UntrustedLimits = Limits{
+	MaxBlockSize:     0x2000,
+	MaxBlocks:        1 << 20,
+	MaxCommentLength: 0x80,
+	MaxOutputBytes:   2 << 30,
+}
+
+// checkLimits 检查头部数值与预计的输出大小是否超过 Limits
+func (h *Hca) checkLimits() error {
+	l := h.Limits
+	switch {
+	case l.MaxBlockSize > 0 && int(h.blockSize) > l.MaxBlockSize:
+		return fmt.Errorf("%w: block size %d > %d", ErrLimitExceeded, h.blockSize, l.MaxBlockSize)
+	case l.MaxBlocks > 0 && uint64(h.blockCount) > uint64(l.MaxBlocks):
+		return fmt.Errorf("%w: block count %d > %d", ErrLimitExceeded, h.blockCount, l.MaxBlocks)
+	case l.MaxCommentLength > 0 && int(h.commLen) > l.MaxCommentLength:
+		return fmt.Errorf("%w: comment length %d > %d", ErrLimitExceeded, h.commLen, l.MaxCommentLength)
+	}
+	if l.MaxOutputBytes > 0 {
+		frame := uint64(h.sampleSize()) * uint64(h.outputChannels())
+		if frames := h.finalFrames(); frame > 0 && frames > uint64(l.MaxOutputBytes)/frame {
+			return fmt.Errorf("%w: output %d frames of %d bytes > %d bytes", ErrLimitExceeded, frames, frame, l.MaxOutputBytes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package hca
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LevelTrace is slog level of per-block events
+// LevelTrace 是每个数据块的事件使用的日志级别, 低于 slog.LevelDebug
+const LevelTrace = slog.LevelDebug - 4
+
+// WithLogger set Logger and return h
+// WithLogger 设置 Logger 并返回 h, 便于在创建解码器时连写 (例如 hca.NewDecoder().WithLogger(logger))
+func (h *Hca) WithLogger(l *slog.Logger) *Hca {
+	h.Logger = l
+	return h
+}
+
+// logEnabled 判断 Logger 是否会记录 level 级别的事件
+func (h *Hca) logEnabled(level slog.Level) bool {
+	return h.Logger != nil && h.Logger.Enabled(context.Background(), level)
+}
+
+// log 在设置了 Logger 时记录一条事件
+func (h *Hca) log(level slog.Level, msg string, args ...any) {
+	if h.logEnabled(level) {
+		h.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
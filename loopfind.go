@@ -0,0 +1,261 @@
+package hca
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// LoopCandidate is a loop point suggested by FindLoops
+// LoopCandidate 是 FindLoops 根据音频内容推测的循环点
+type LoopCandidate struct {
+	Start int64   // 循环开始的样本帧
+	End   int64   // 循环结束的样本帧 (不包含), 播放到 End 时跳回 Start
+	Score float64 // 跳转处前后波形的相关系数 (-1 到 1), 越接近 1 衔接越自然
+}
+
+const (
+	loopBands       = 16   // 每个块的特征频段数 (按对数划分)
+	loopFloorDB     = 60   // 比最响的块低这么多的块视为静音
+	loopMatchDB     = 3    // 两个块各频段的平均差异不超过这个值时视为相同
+	loopSmooth      = 8    // 平滑差异的块数
+	loopMinSeconds  = 2    // 循环区间的最短时长
+	loopRunSeconds  = 3    // 判断为重复需要连续相似的最短时长
+	loopRefineFrame = 8192 // 精确对齐时比较的样本帧数
+)
+
+// FindLoops decode r and suggest loop points from repeated content
+// FindLoops 完整解码 r, 在音频中寻找重复的片段, 按可信程度返回最多 n 个循环点候选,
+// 用于为缺少 loop 块的文件 (例如录制或转换丢失了循环信息的 BGM) 恢复循环.
+// 先按块比较频谱找到重复的区间与大致的间隔, 再用波形的互相关精确到样本帧.
+// 忽略文件自身的 loop 块与 h 的输出设置, 不会改变 h 的状态. 没有找到时返回空的切片
+func (h *Hca) FindLoops(r io.ReadSeeker, n int) ([]LoopCandidate, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: loop candidates %d", ErrInvalidOption, n)
+	}
+	d := *h // 使用副本解码
+	d.Format, d.Headerless, d.Mode = "", true, ModeFloat
+	d.Volume, d.GainDB, d.TargetLoudness = 1, 0, 0
+	d.Loop, d.LoopCrossfade = 0, 0
+	d.StartSample, d.SampleCount, d.MaxDuration, d.FadeOut = 0, 0, 0, 0
+	d.SampleRate = 0
+	d.ChannelMap, d.ChannelGainDB = nil, nil
+	d.Limiter, d.Processors, d.Analyze = nil, nil, false
+	f := &loopFinder{}
+	d.SpectrumTap = f.addSpectrum
+	d.capture = func(_ io.Writer, info Info, _ SinkOptions) (Sink, error) {
+		f.channels, f.rate = info.Channels, info.SamplingRate
+		return f, nil
+	}
+	if err := d.DecodeWithWriter(r, io.Discard); err != nil && !d.recovered(err) {
+		return nil, err
+	}
+	return f.find(n), nil
+}
+
+// loopFinder 收集每个块的频谱特征与混合为单声道的波形
+type loopFinder struct {
+	channels int
+	rate     int
+	mono     []float32
+	features [][loopBands]float64 // 每个块各频段的能量
+}
+
+// addSpectrum 累积一个块各频段的能量, 按输出位置存放 (以静音替代的块保持为 0)
+func (f *loopFinder) addSpectrum(s *Spectrum) {
+	i := int(s.Position / samplesPerBlock)
+	for len(f.features) <= i {
+		f.features = append(f.features, [loopBands]float64{})
+	}
+	for _, ch := range s.Magnitudes {
+		for _, sub := range ch {
+			for b := 0; b < loopBands; b++ {
+				for k := loopBandEdges[b]; k < loopBandEdges[b+1]; k++ {
+					f.features[i][b] += float64(sub[k]) * float64(sub[k])
+				}
+			}
+		}
+	}
+}
+
+// loopBandEdges 是各频段的起始频率索引, 低频较窄, 高频较宽
+var loopBandEdges = func() (edges [loopBands + 1]int) {
+	for b := 1; b <= loopBands; b++ {
+		edges[b] = max(edges[b-1]+1, int(math.Round(math.Pow(SpectrumBins, float64(b)/loopBands))))
+	}
+	edges[loopBands] = SpectrumBins
+	return edges
+}()
+
+// WriteSamples 将交错样本混合为单声道
+func (f *loopFinder) WriteSamples(samples []float32) error {
+	for i := 0; i+f.channels <= len(samples); i += f.channels {
+		var sum float32
+		for _, v := range samples[i : i+f.channels] {
+			sum += v
+		}
+		f.mono = append(f.mono, sum/float32(f.channels))
+	}
+	return nil
+}
+
+// Close 不做任何事
+func (f *loopFinder) Close() error {
+	return nil
+}
+
+// loopLag 是一个块间隔的重复区间
+type loopLag struct {
+	lag, start, run int     // 间隔与重复区间的起点与长度 (块)
+	diff            float64 // 区间内的平均差异 (dB)
+}
+
+// find 返回最多 n 个候选
+func (f *loopFinder) find(n int) []LoopCandidate {
+	if f.rate == 0 || len(f.features) == 0 {
+		return []LoopCandidate{}
+	}
+	db, silent := f.levels()
+	blocks := len(db)
+	minLag := loopMinSeconds * f.rate / samplesPerBlock
+	minRun := loopRunSeconds * f.rate / samplesPerBlock
+
+	var lags []loopLag
+	diff := make([]float64, blocks)
+	for lag := max(minLag, 1); lag+minRun <= blocks; lag++ {
+		m := blocks - lag
+		for t := 0; t < m; t++ {
+			var sum float64
+			for b := range db[t] {
+				sum += math.Abs(db[t][b] - db[t+lag][b])
+			}
+			diff[t] = sum / loopBands
+		}
+		if c, ok := longestRun(diff[:m], silent, lag, minRun); ok {
+			lags = append(lags, c)
+		}
+	}
+	// 重复区间越长越可能是真正的循环, 同样长时差异小的优先
+	sort.Slice(lags, func(i, j int) bool {
+		if lags[i].run != lags[j].run {
+			return lags[i].run > lags[j].run
+		}
+		return lags[i].diff < lags[j].diff
+	})
+
+	result := []LoopCandidate{}
+	var chosen []int
+next:
+	for _, c := range lags {
+		if len(result) == n {
+			break
+		}
+		for _, l := range chosen { // 跳过与已选的间隔相近的候选
+			if abs(c.lag-l) <= loopSmooth {
+				continue next
+			}
+		}
+		if cand, ok := f.refine(c); ok {
+			chosen = append(chosen, c.lag)
+			result = append(result, cand)
+		}
+	}
+	return result
+}
+
+// levels 将特征换算为 dB, 低于静音门限的块限制在门限上并标记为静音
+func (f *loopFinder) levels() ([][loopBands]float64, []bool) {
+	db := make([][loopBands]float64, len(f.features))
+	silent := make([]bool, len(f.features))
+	var loudest float64
+	for _, v := range f.features {
+		var sum float64
+		for _, e := range v {
+			sum += e
+		}
+		loudest = max(loudest, sum)
+	}
+	floor := loudest * math.Pow(10, -loopFloorDB/10.0)
+	for i, v := range f.features {
+		var sum float64
+		for b, e := range v {
+			db[i][b] = 10 * math.Log10(max(e, floor/loopBands))
+			sum += e
+		}
+		silent[i] = sum <= floor
+	}
+	return db, silent
+}
+
+// longestRun 平滑差异后找到最长的一段相似区间, 两边都是静音的块不计入长度
+func longestRun(diff []float64, silent []bool, lag, minRun int) (loopLag, bool) {
+	best := loopLag{lag: lag}
+	var window float64
+	start, run := 0, 0
+	var total float64
+	for t := range diff {
+		window += diff[t]
+		if t >= loopSmooth {
+			window -= diff[t-loopSmooth]
+		}
+		if window/float64(min(t+1, loopSmooth)) > loopMatchDB {
+			start, run, total = t+1, 0, 0
+			continue
+		}
+		if !silent[t] || !silent[t+lag] {
+			run++
+			total += diff[t]
+		}
+		if run > best.run {
+			best.start, best.run, best.diff = start, run, total/float64(run)
+		}
+	}
+	return best, best.run >= minRun
+}
+
+// refine 用波形的互相关在块间隔附近找到精确的样本帧间隔
+func (f *loopFinder) refine(c loopLag) (LoopCandidate, bool) {
+	start := int64(c.start+loopSmooth) * samplesPerBlock // 进入重复区间一段距离, 避开平滑带来的误差
+	if c.run <= 2*loopSmooth {
+		start = int64(c.start) * samplesPerBlock
+	}
+	total := int64(len(f.mono))
+	length := min(int64(loopRefineFrame), int64(c.run)*samplesPerBlock)
+	best, score := int64(0), math.Inf(-1)
+	center := int64(c.lag) * samplesPerBlock
+	for lag := center - samplesPerBlock; lag <= center+samplesPerBlock; lag++ {
+		if lag <= 0 || start+lag+length > total {
+			continue
+		}
+		if s := correlate(f.mono[start:start+length], f.mono[start+lag:start+lag+length]); s > score {
+			best, score = lag, s
+		}
+	}
+	if best == 0 {
+		return LoopCandidate{}, false
+	}
+	return LoopCandidate{Start: start, End: start + best, Score: score}, true
+}
+
+// correlate 返回 a 与 b 的归一化互相关, 任一为静音时为 0
+func correlate(a, b []float32) float64 {
+	var ab, aa, bb float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		ab += x * y
+		aa += x * x
+		bb += y * y
+	}
+	if aa == 0 || bb == 0 {
+		return 0
+	}
+	return ab / math.Sqrt(aa*bb)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,16 @@
+//go:build tinygo || hca_lowmem
+
+package hca
+
+// lowMemory 在 TinyGo 或使用 hca_lowmem 构建标签时启用, 用于内存受限的设备:
+//   - 忽略 ChannelParallelism 与 ReadAhead, 不使用额外的 goroutine 与缓冲
+//
+// 数据块, 解密结果与输出样本在所有构建中都使用每个文件分配一次的缓冲区, 第一个块之后不再分配内存.
+//
+// 不使用 sync.Pool, 查找表是包级别的常量数据或在解码器之间共享 (不计入下面的上限). 启用后一个解码器在解码期间占用的内存上限约为
+//
+//	channels * 14 KiB + blockSize * 2 + 1 KiB
+//
+// (每个通道的解码状态约 6 KiB, 交错缓冲 4 KiB, 输出缓冲最多 4 KiB; 数据块与解密结果各一个块),
+// 例如立体声, 块大小 0x400 时约 30 KiB. 不包括调用方的 Writer 与 Loop 展开时 DecodeStream 保留的数据
+const lowMemory = true
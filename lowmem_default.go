@@ -0,0 +1,6 @@
+//go:build !tinygo && !hca_lowmem
+
+package hca
+
+// lowMemory 见 lowmem.go, 默认不启用
+const lowMemory = false
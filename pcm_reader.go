@@ -0,0 +1,178 @@
+package hca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// Reader is seekable decoded PCM of a HCA file
+// Reader 按需解码 HCA 文件, 以 io.ReadSeeker 的形式提供交错排列的 PCM 数据 (按 Mode 编码, 小端序, 没有 WAV 头部).
+// 与 PCMStream 不同, 不会把整个文件解码到内存中: Seek 之后从目标位置所在的块开始解码,
+// 先解码前一个块恢复解码器的重叠状态, 再丢弃目标位置之前的样本, 因此任意位置的数据与顺序解码的结果相同.
+// 只应用音量 (rva, Volume, GainDB), ChannelMap 与 ChannelGainDB; 不展开循环也不截取,
+// 忽略 Loop, StartSample, SampleCount, MaxDuration, FadeOut, LoopCrossfade, SampleRate, Processors, Limiter 与 Format.
+// 损坏的块按策略处理, 丢弃的块以静音代替, 保证样本位置不变. 不能并发使用
+type Reader struct {
+	dec *BlockDecoder
+	r   io.ReadSeeker
+
+	frameSize int   // 每个样本帧的字节数
+	size      int64 // PCM 数据的总字节数
+	pos       int64 // 读取位置 (字节)
+
+	buf   []byte // 当前块编码后的 PCM 数据
+	block int64  // buf 对应的块索引, -1 表示没有
+	next  int64  // 解码器状态对应的下一个块, 解码这个块时不需要先解码前一个块
+}
+
+// NewReader create Reader of r
+// NewReader 读取 r 的头部并返回 Reader, 使用 h 当前的密钥, 写入模式, 音量与容错设置, 之后修改 h 不影响 Reader
+func (h *Hca) NewReader(r io.ReadSeeker) (*Reader, error) {
+	dec, err := h.NewBlockDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &dec.d
+	if err := d.checkKey(r); err != nil {
+		return nil, err
+	}
+	frameSize := d.sampleSize() * int(d.outputChannels())
+	return &Reader{
+		dec:       dec,
+		r:         r,
+		frameSize: frameSize,
+		size:      int64(d.blockCount) * samplesPerBlock * int64(frameSize),
+		block:     -1,
+	}, nil
+}
+
+// Read reads PCM data
+// Read 读取 PCM 数据, 到达结尾时返回 io.EOF; 数据块无法读取或解码时返回错误 (宽松策略除外)
+func (pr *Reader) Read(p []byte) (int, error) {
+	if pr.pos >= pr.size {
+		return 0, io.EOF
+	}
+	n := 0
+	blockBytes := int64(samplesPerBlock * pr.frameSize)
+	for n < len(p) && pr.pos < pr.size {
+		block := pr.pos / blockBytes
+		if block != pr.block {
+			if err := pr.load(block); err != nil {
+				return n, err
+			}
+		}
+		m := copy(p[n:], pr.buf[pr.pos-block*blockBytes:])
+		n += m
+		pr.pos += int64(m)
+	}
+	return n, nil
+}
+
+// load 解码第 block 个块到 buf, 解码器状态不连续时先解码前一个块
+func (pr *Reader) load(block int64) error {
+	if block != pr.next {
+		pr.dec.Reset(int(block)) // 清除之前的重叠状态
+		if block > 0 {
+			if _, err := pr.decode(block - 1); err != nil {
+				return err
+			}
+		}
+	}
+	serial, err := pr.decode(block)
+	if err != nil {
+		return err
+	}
+	pr.buf = append(pr.buf[:0], pr.dec.d.encodeSamples(serial, binary.LittleEndian)...)
+	pr.block = block
+	return nil
+}
+
+// decode 读取并解码一个块, 返回交错排列的样本
+func (pr *Reader) decode(block int64) ([]float32, error) {
+	d := &pr.dec.d
+	pr.next = -1 // 解码失败时状态不确定
+	address := d.blockAddress(uint32(block))
+	if _, err := pr.r.Seek(address, io.SeekStart); err != nil {
+		return nil, d.blockError(address, err)
+	}
+	data, err := d.readBlock(pr.r)
+	if err != nil {
+		return nil, d.blockError(address, err)
+	}
+	serial, err := pr.dec.decodeAt(data, block)
+	if err != nil {
+		return nil, err
+	}
+	pr.next = block + 1
+	return serial, nil
+}
+
+// Seek sets the read position in bytes
+// Seek 设置读取位置 (字节), 位置按样本帧对齐, io.SeekEnd 相对于数据结尾. 超过结尾时之后的 Read 返回 io.EOF
+func (pr *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += pr.pos
+	case io.SeekEnd:
+		offset += pr.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	pr.pos = offset / int64(pr.frameSize) * int64(pr.frameSize)
+	return pr.pos, nil
+}
+
+// SeekSample sets the read position to sample frame n
+// SeekSample 将读取位置设置到第 n 个样本帧
+func (pr *Reader) SeekSample(n int64) (int64, error) {
+	return pr.Seek(n*int64(pr.frameSize), io.SeekStart)
+}
+
+// SeekTime sets the read position to time t
+// SeekTime 将读取位置设置到时间 t 所在的样本帧
+func (pr *Reader) SeekTime(t time.Duration) (int64, error) {
+	return pr.SeekSample(int64(t) * int64(pr.dec.d.samplingRate) / int64(time.Second))
+}
+
+// Info return header information
+// Info 返回文件的头部信息
+func (pr *Reader) Info() Info {
+	return pr.dec.Info()
+}
+
+// FrameSize return bytes per sample frame
+// FrameSize 返回每个样本帧的字节数
+func (pr *Reader) FrameSize() int {
+	return pr.frameSize
+}
+
+// Length return total PCM bytes
+// Length 返回 PCM 数据的总字节数
+func (pr *Reader) Length() int64 {
+	return pr.size
+}
+
+// WaveHeader return WAV header matching the PCM data
+// WaveHeader 返回与 PCM 数据对应的 WAV 头部, 头部之后接上全部 PCM 数据即为完整的 WAV 文件.
+// 与 Reader 一样忽略循环, 截取与采样率等选项, 仍然写出文件原有的循环区间, 注释与 Tags
+func (pr *Reader) WaveHeader() ([]byte, error) {
+	d := pr.dec.d // 使用副本, 清除 Reader 忽略的选项
+	d.Loop, d.StartSample, d.SampleCount, d.MaxDuration, d.SampleRate = 0, 0, 0, 0, 0
+	d.Headerless, d.UnknownSize = false, false
+	wavHeader, err := d.buildWaveHeader()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := wavHeader.Write(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
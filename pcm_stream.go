@@ -0,0 +1,148 @@
+package hca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PCMStream is decoded 16-bit stereo PCM, usable as Ebiten audio source
+// PCMStream 是解码后的 16 位立体声小端序 PCM 数据 (Ebiten 的 audio 包使用的格式).
+// 数据保存在内存中, 可以任意 Seek; 设置 Infinite 后按文件的循环点无限读取,
+// 也可以将 IntroLength 与 LoopLength 传给 audio.NewInfiniteLoopWithIntro
+type PCMStream struct {
+	data []byte
+	pos  int64
+	rate int
+
+	loopStart int64 // 循环开始位置 (字节)
+	loopEnd   int64 // 循环结束位置 (字节)
+	infinite  bool
+}
+
+// pcmFrameSize 是 PCMStream 一帧 (两个通道的 16 位样本) 的字节数
+const pcmFrameSize = 4
+
+// NewPCMStream decode r into memory as 16-bit stereo PCM
+// NewPCMStream 将 r 全部解码到内存中, 返回 16 位立体声 PCM 数据流.
+// 单声道文件会复制到两个通道, 超过两个通道的文件返回错误.
+// 不展开循环也不截取 (忽略 Loop, StartSample, SampleCount, MaxDuration 与 FadeOut), 采样率保持文件的采样率 (通常为 44.1 kHz 或 48 kHz),
+// 与音频上下文不一致时需要由调用方重采样
+func (h *Hca) NewPCMStream(r io.ReadSeeker) (*PCMStream, error) {
+	d := *h // 使用副本解码, 不修改 h 的输出设置
+	d.Mode = Mode16Bit
+	d.Headerless = true
+	d.Format = ""
+	d.SampleRate = 0
+	d.ChannelMap = nil
+	d.TargetLoudness = 0
+	d.Loop = 0
+	d.StartSample, d.SampleCount, d.MaxDuration, d.FadeOut = 0, 0, 0, 0 // 循环点以完整的数据为准
+	var pcm bytes.Buffer
+	err := d.DecodeWithWriter(r, &pcm)
+	h.fileState = d.fileState // 保留头部信息与统计, 供 Info 与 Stats 使用
+	if err != nil && !d.recovered(err) {
+		return nil, err
+	}
+
+	info := d.info()
+	data := pcm.Bytes()
+	switch info.Channels {
+	case 2:
+	case 1:
+		data = monoToStereo(data)
+	default:
+		return nil, fmt.Errorf("%d channels can not be converted to stereo", info.Channels)
+	}
+
+	s := &PCMStream{data: data, rate: info.SamplingRate, loopEnd: int64(len(data))}
+	if info.Loop {
+		blockBytes := int64(samplesPerBlock * pcmFrameSize)
+		s.loopStart = min(int64(info.LoopStart)*blockBytes, int64(len(data)))
+		s.loopEnd = min(int64(info.LoopEnd)*blockBytes, int64(len(data)))
+		if s.loopEnd <= s.loopStart { // 循环点超出实际数据 (例如截断的文件)
+			s.loopStart, s.loopEnd = 0, int64(len(data))
+		}
+	}
+	return s, nil
+}
+
+// monoToStereo 将 16 位单声道样本复制到两个通道
+func monoToStereo(pcm []byte) []byte {
+	out := make([]byte, len(pcm)*2)
+	for i := 0; i+2 <= len(pcm); i += 2 {
+		v := binary.LittleEndian.Uint16(pcm[i:])
+		binary.LittleEndian.PutUint16(out[i*2:], v)
+		binary.LittleEndian.PutUint16(out[i*2+2:], v)
+	}
+	return out
+}
+
+// Read reads PCM data
+// Read 读取 PCM 数据, Infinite 时到达循环结束位置后回到循环开始位置, 不会返回 io.EOF
+func (s *PCMStream) Read(p []byte) (int, error) {
+	end := int64(len(s.data))
+	if s.infinite {
+		if s.pos >= s.loopEnd {
+			s.pos = s.loopStart
+		}
+		end = s.loopEnd
+	}
+	if s.pos >= end {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data[s.pos:end])
+	s.pos += int64(n)
+	return n, nil
+}
+
+// Seek sets the read position in bytes
+// Seek 设置读取位置 (字节), 位置按帧对齐, io.SeekEnd 相对于数据结尾
+func (s *PCMStream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += int64(len(s.data))
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	s.pos = min(offset/pcmFrameSize*pcmFrameSize, int64(len(s.data)))
+	return s.pos, nil
+}
+
+// SetInfinite enable or disable loop reading
+// SetInfinite 设置是否按循环点无限读取, 文件没有 loop 块时循环整个文件
+func (s *PCMStream) SetInfinite(infinite bool) {
+	s.infinite = infinite
+}
+
+// Length return the size of the PCM data in bytes
+// Length 返回 PCM 数据的字节数
+func (s *PCMStream) Length() int64 {
+	return int64(len(s.data))
+}
+
+// SampleRate return the sampling rate
+// SampleRate 返回采样率
+func (s *PCMStream) SampleRate() int {
+	return s.rate
+}
+
+// IntroLength return bytes before the loop start
+// IntroLength 返回循环开始之前的字节数, 对应 audio.NewInfiniteLoopWithIntro 的 introLength
+func (s *PCMStream) IntroLength() int64 {
+	return s.loopStart
+}
+
+// LoopLength return bytes of the loop
+// LoopLength 返回循环部分的字节数, 对应 audio.NewInfiniteLoopWithIntro 的 loopLength
+func (s *PCMStream) LoopLength() int64 {
+	return s.loopEnd - s.loopStart
+}
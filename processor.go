@@ -0,0 +1,60 @@
+package hca
+
+import "math"
+
+// Processor is DSP effect applied to decoded samples
+// Processor 是作用于解码后样本的效果处理 (例如响度归一化, 均衡器),
+// 按顺序接收每个块交错排列的浮点样本并原地修改. samples 的长度不变, 在调用返回后会被复用
+type Processor interface {
+	Process(samples []float32, channels int)
+}
+
+// ProcessorFunc adapt function to Processor
+// ProcessorFunc 将函数用作 Processor
+type ProcessorFunc func(samples []float32, channels int)
+
+// Process call f
+// Process 调用 f
+func (f ProcessorFunc) Process(samples []float32, channels int) {
+	f(samples, channels)
+}
+
+// process 依次应用 Processors
+func (h *Hca) process(serial []float32) {
+	channels := int(h.outputChannels())
+	for _, p := range h.Processors {
+		p.Process(serial, channels)
+	}
+}
+
+// clampEarly 判断是否在序列化时直接削波: 之后的处理 (统计, 限幅, 效果) 需要削波之前的样本时为 false
+func (h *Hca) clampEarly() bool {
+	return !h.Analyze && h.Limiter == nil && len(h.Processors) == 0 && len(h.ChannelGainDB) == 0
+}
+
+// applyBalance 按 ChannelGainDB 调整各通道的增益
+func (h *Hca) applyBalance(serial []float32) {
+	if len(h.ChannelGainDB) == 0 {
+		return
+	}
+	channels := int(h.outputChannels())
+	if h.balance == nil {
+		h.balance = make([]float32, channels)
+		for c := range h.balance {
+			h.balance[c] = 1
+			if c < len(h.ChannelGainDB) {
+				h.balance[c] = float32(math.Pow(10, h.ChannelGainDB[c]/20))
+			}
+		}
+	}
+	for i := range serial {
+		serial[i] *= h.balance[i%channels]
+	}
+}
+
+// clamp 将样本限制在 -1 到 1 (修改 samples)
+func clamp(samples []float32) {
+	for i, f := range samples {
+		samples[i] = max(-1, min(1, f))
+	}
+}
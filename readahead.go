@@ -0,0 +1,108 @@
+package hca
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// errReadAheadStopped 表示解码已经结束, 后台 goroutine 停止预读
+var errReadAheadStopped = errors.New("hca: read-ahead stopped")
+
+// aheadBlock ReadAhead 时预读的一个数据块
+type aheadBlock struct {
+	data  []byte // 原始数据
+	mask  []byte // 解密后的数据
+	sum   uint16 // 原始数据的校验和, 完好的块为 0
+	n     int    // 实际读取的字节数
+	short bool   // 以零补齐的截断块 (PadTruncated)
+	err   error  // Seek 或读取的错误, 之后不再有块
+}
+
+// readAhead 在后台读取数据块的 goroutine 与它填充的两个缓冲: 一个正在解码与写出时, 另一个由后台 goroutine 填充
+type readAhead struct {
+	free chan *aheadBlock // 可以填充的缓冲
+	full chan *aheadBlock // 已填充的缓冲, 容量与缓冲数量相同, 发送不会阻塞
+	done chan struct{}    // 解码结束时关闭
+	wg   sync.WaitGroup
+}
+
+// startReadAhead 启动后台 goroutine, 按照与 decodeLoop 相同的顺序 (包括展开的循环) 读取, 校验并解密 r 中的数据块.
+// 一个文件只使用一个 goroutine 与两个缓冲, 循环的各段之间不重新分配. 解码结束后应调用 stop
+func (h *Hca) startReadAhead(r io.ReadSeeker) *readAhead {
+	ra := &readAhead{
+		free: make(chan *aheadBlock, 2),
+		full: make(chan *aheadBlock, 2),
+		done: make(chan struct{}),
+	}
+	for range 2 {
+		ra.free <- &aheadBlock{data: make([]byte, h.blockSize), mask: make([]byte, h.blockSize)}
+	}
+	ra.wg.Add(1)
+	go func() {
+		defer ra.wg.Done()
+		h.decodeSegments(func(address int64, count uint32) error { // 只读取配置与头部信息
+			return h.readBlocksAhead(ra, r, address, count)
+		})
+	}()
+	return ra
+}
+
+// stop 停止预读并等待后台 goroutine 退出, 之后 r 才能再次使用
+func (ra *readAhead) stop() {
+	close(ra.done)
+	ra.wg.Wait()
+}
+
+// readBlocksAhead 在后台 goroutine 中读取从 address 开始的 count 个块, 计算校验和并解密后交给 ra.full.
+// 只读取解码器的配置与密码表, 出错 (错误也交给 ra.full) 或解码结束时返回错误
+func (h *Hca) readBlocksAhead(ra *readAhead, r io.ReadSeeker, address int64, count uint32) error {
+	for l := uint32(0); l < count; l++ {
+		var b *aheadBlock
+		select {
+		case b = <-ra.free:
+		case <-ra.done:
+			return errReadAheadStopped
+		}
+		b.n, b.short, b.err = 0, false, nil
+		if l == 0 { // 将读取位置移动到这一段的开始处
+			_, b.err = r.Seek(address, io.SeekStart)
+		}
+		if b.err == nil {
+			b.n, b.short, b.err = h.fillBlock(r, b.data)
+		}
+		if b.err == nil {
+			b.sum = h.blockSum(b.data)
+			h.cipher.maskTo(b.mask, b.data)
+		}
+		ra.full <- b
+		if b.err != nil {
+			return b.err
+		}
+	}
+	return nil
+}
+
+// decodeBlocksAhead 与 decodeBlocks 相同, 但从 ra 取得已经读取并解密的块
+func (h *Hca) decodeBlocksAhead(ra *readAhead, w io.Writer, address int64, count uint32) error {
+	for l := uint32(0); l < count; l++ {
+		b := <-ra.full
+		h.stats.BytesRead += int64(b.n)
+		if b.err != nil {
+			return h.blockError(address, b.err) // 附带块索引与偏移量
+		}
+		h.shortBlock = b.short
+		emit, err := h.decodeMasked(b.sum, b.mask, address)
+		ra.free <- b // 解密后的数据已经解析完毕, 缓冲交给后台读取下一个块
+		if err != nil {
+			return err
+		}
+		if emit {
+			if err := h.writeBlock(w, address); err != nil {
+				return err
+			}
+		}
+		address += int64(h.blockSize)
+	}
+	return nil
+}
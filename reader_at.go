@@ -0,0 +1,120 @@
+package hca
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// readAtWindow 是解码时每次调用 ReadAt 读取的字节数, 将相邻数据块的读取合并为一次请求
+const readAtWindow = 256 << 10
+
+// DecodeReaderAt decode size bytes of r into w
+// DecodeReaderAt 解码 r 中前 size 字节的 HCA 数据并写入 w. 只读取需要的部分 (头部, 然后按顺序读取数据块),
+// 相邻的数据块合并为较大的 ReadAt 调用, 适用于 S3/GCS/HTTP Range 等远程数据
+func (h *Hca) DecodeReaderAt(r io.ReaderAt, size int64, w io.Writer) error {
+	return h.DecodeWithWriter(newAtReader(r, size, readAtWindow), w)
+}
+
+// ProbeReaderAt read only header of r
+// ProbeReaderAt 只读取 r 的头部并返回文件信息, 通常只需要一次 ReadAt
+func (h *Hca) ProbeReaderAt(r io.ReaderAt, size int64) (Info, error) {
+	return h.Probe(newAtReader(r, size, 0x10000)) // 头部大小是 16 位字段, 不会超过 64 KiB
+}
+
+// DecodeRangeAt decode count blocks of r starting at block first
+// DecodeRangeAt 解码 r 中从第 first 个块开始的 count 个数据块 (文件中的块索引, 不展开循环),
+// 返回交错排列的浮点样本 (count*1024 个样本帧). 只通过 ReadAt 读取, 先解码 first 的前一个块恢复重叠状态,
+// 结果与顺序解码的对应部分相同. 不修改 h 的状态, 可以在多个 goroutine 中同时调用,
+// 用于并行解码同一个文件 (例如内存映射的文件) 的不同部分. 与 BlockDecoder 一样只应用音量,
+// ChannelMap 与 ChannelGainDB, 不检查密钥是否正确
+func (h *Hca) DecodeRangeAt(r io.ReaderAt, size int64, first, count int) ([]float32, error) {
+	sr := io.NewSectionReader(r, 0, size) // 不读取 size 之后的数据
+	dec, err := h.NewBlockDecoder(sr)
+	if err != nil {
+		return nil, err
+	}
+	d := &dec.d
+	if first < 0 || count < 0 || int64(first)+int64(count) > int64(d.blockCount) {
+		return nil, fmt.Errorf("%w: blocks %d+%d of %d", ErrInvalidOption, first, count, d.blockCount)
+	}
+	out := make([]float32, 0, count*samplesPerBlock*int(d.outputChannels()))
+	for i := max(first-1, 0); i < first+count; i++ {
+		address := d.blockAddress(uint32(i))
+		data, err := d.readBlock(io.NewSectionReader(sr, address, int64(d.blockSize)))
+		if err != nil {
+			return nil, d.blockError(address, err)
+		}
+		samples, err := dec.decodeAt(data, int64(i))
+		if err != nil {
+			return nil, err
+		}
+		if i >= first { // 前一个块只用于恢复重叠状态
+			out = append(out, samples...)
+		}
+	}
+	return out, nil
+}
+
+// atReader 将 io.ReaderAt 包装为 io.ReadSeeker, 每次 ReadAt 读取 window 字节并缓存
+type atReader struct {
+	r      io.ReaderAt
+	size   int64
+	pos    int64
+	buf    []byte
+	bufOff int64 // buf 的数据在 r 中的偏移量
+	window int
+}
+
+func newAtReader(r io.ReaderAt, size int64, window int) *atReader {
+	return &atReader{r: r, size: size, window: window}
+}
+
+func (a *atReader) Read(p []byte) (int, error) {
+	if a.pos >= a.size {
+		return 0, io.EOF
+	}
+	if a.pos < a.bufOff || a.pos >= a.bufOff+int64(len(a.buf)) { // 不在缓存中
+		if err := a.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, a.buf[a.pos-a.bufOff:])
+	a.pos += int64(n)
+	return n, nil
+}
+
+// fill 从当前位置读取一个窗口
+func (a *atReader) fill() error {
+	if cap(a.buf) < a.window {
+		a.buf = make([]byte, a.window)
+	}
+	n := int(min(int64(a.window), a.size-a.pos))
+	m, err := a.r.ReadAt(a.buf[:n], a.pos)
+	if m < n {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF // size 大于实际数据
+		}
+		a.buf = a.buf[:0]
+		return err
+	}
+	a.buf, a.bufOff = a.buf[:n], a.pos
+	return nil
+}
+
+func (a *atReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += a.pos
+	case io.SeekEnd:
+		offset += a.size
+	default:
+		return 0, errors.New("hca: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("hca: negative position")
+	}
+	a.pos = offset
+	return offset, nil
+}
@@ -0,0 +1,177 @@
+package hca
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// ReplayGainReference is the loudness ReplayGain 2.0 normalizes to
+// ReplayGainReference 是 ReplayGain 2.0 的参考响度 (LUFS)
+const ReplayGainReference = -18
+
+// ReplayGain is result of loudness analysis
+// ReplayGain 是响度分析的结果
+type ReplayGain struct {
+	Loudness  float64 // 按 ITU-R BS.1770 (EBU R128) 计算的积分响度 (LUFS), 静音时为 -Inf
+	TrackGain float64 // 达到参考响度需要的增益 (dB), 静音时为 0
+	TrackPeak float64 // 样本峰值 (线性, 1 为满幅)
+}
+
+// Tags return ReplayGain tags in Vorbis comment form
+// Tags 返回 Vorbis comment (FLAC, Ogg) 形式的 ReplayGain 标签, 可以由 Sink 写入输出
+func (g ReplayGain) Tags() map[string]string {
+	return map[string]string{
+		"REPLAYGAIN_TRACK_GAIN":         fmt.Sprintf("%.2f dB", g.TrackGain),
+		"REPLAYGAIN_TRACK_PEAK":         fmt.Sprintf("%.6f", g.TrackPeak),
+		"REPLAYGAIN_REFERENCE_LOUDNESS": fmt.Sprintf("%d LUFS", ReplayGainReference),
+	}
+}
+
+// AnalyzeReplayGain decode r and measure its loudness
+// AnalyzeReplayGain 解码 r 并计算 ReplayGain 2.0 的音轨增益与峰值.
+// 使用 h 的循环, 截取范围, 淡出, 通道映射与 Processors 设置, 只应用 rva 音量 (不应用 Volume, GainDB 与 Limiter),
+// 所有通道的权重相同. 不会改变 h 的输出设置
+func (h *Hca) AnalyzeReplayGain(r io.ReadSeeker) (ReplayGain, error) {
+	d := *h // 使用副本解码
+	d.Format, d.Headerless, d.Mode = "", true, ModeFloat
+	d.Volume, d.GainDB, d.TargetLoudness = 1, 0, 0
+	d.SampleRate = 0
+	d.Limiter = nil
+	d.SpectrumTap = nil
+	var m *loudnessMeter
+	d.capture = func(_ io.Writer, info Info, _ SinkOptions) (Sink, error) {
+		m = newLoudnessMeter(info.Channels, info.SamplingRate)
+		return m, nil
+	}
+	if err := d.DecodeWithWriter(r, io.Discard); err != nil && !d.recovered(err) {
+		return ReplayGain{}, err
+	}
+	g := ReplayGain{Loudness: m.integrated(), TrackPeak: m.peak}
+	if !math.IsInf(g.Loudness, -1) {
+		g.TrackGain = ReplayGainReference - g.Loudness
+	}
+	return g, nil
+}
+
+// normalize 从头解码一遍 r 测量响度, 设置达到 TargetLoudness 需要的增益. 静音时增益为 1
+func (h *Hca) normalize(r io.ReadSeeker) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	d := *h
+	d.Observer = nil // 测量响度的解码不报告
+	g, err := d.AnalyzeReplayGain(r)
+	if err != nil {
+		return err
+	}
+	h.normGain = 1
+	if !math.IsInf(g.Loudness, -1) {
+		h.normGain = float32(math.Pow(10, (h.TargetLoudness-g.Loudness)/20))
+	}
+	return nil
+}
+
+// biquad 是二阶 IIR 滤波器 (直接 II 型)
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+// loudnessMeter 按 BS.1770 计算积分响度: K 计权滤波, 400 ms 的块 (重叠 75%), 绝对与相对门限
+type loudnessMeter struct {
+	channels int
+	filters  [2]biquad       // 高架滤波与高通滤波
+	state    [][2][2]float64 // 每个通道两级滤波器的状态
+
+	step   int       // 100 ms 的样本帧数
+	n      int       // 当前 100 ms 段已累积的样本帧数
+	sum    float64   // 当前段各通道平方和的累加
+	recent []float64 // 最近 4 段的平方和
+	blocks []float64 // 各 400 ms 块的均方值
+	peak   float64
+}
+
+func newLoudnessMeter(channels, rate int) *loudnessMeter {
+	fs := float64(rate)
+	m := &loudnessMeter{channels: channels, state: make([][2][2]float64, channels), step: max(rate/10, 1)}
+
+	// 高架滤波 (模拟头部的声学影响), 系数按采样率计算 (与 libebur128 相同)
+	f0, g, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	m.filters[0] = biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	// 高通滤波 (RLB 计权)
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	m.filters[1] = biquad{b0: 1, b1: -2, b2: 1, a1: 2 * (k*k - 1) / a0, a2: (1 - k/q + k*k) / a0}
+	return m
+}
+
+// WriteSamples 累积交错样本
+func (m *loudnessMeter) WriteSamples(samples []float32) error {
+	for i := 0; i+m.channels <= len(samples); i += m.channels {
+		for c := 0; c < m.channels; c++ {
+			x := float64(samples[i+c])
+			m.peak = max(m.peak, math.Abs(x))
+			for s := range m.filters {
+				f, st := &m.filters[s], &m.state[c][s]
+				w := x - f.a1*st[0] - f.a2*st[1]
+				x = f.b0*w + f.b1*st[0] + f.b2*st[1]
+				st[1], st[0] = st[0], w
+			}
+			m.sum += x * x
+		}
+		if m.n++; m.n == m.step { // 一段结束, 每 4 段组成一个块
+			m.recent = append(m.recent, m.sum)
+			if len(m.recent) > 4 {
+				m.recent = m.recent[1:]
+			}
+			if len(m.recent) == 4 {
+				m.blocks = append(m.blocks, (m.recent[0]+m.recent[1]+m.recent[2]+m.recent[3])/float64(4*m.step))
+			}
+			m.n, m.sum = 0, 0
+		}
+	}
+	return nil
+}
+
+// Close 不做任何事, 不满 400 ms 的部分不计入
+func (m *loudnessMeter) Close() error {
+	return nil
+}
+
+// integrated 返回门限处理后的积分响度
+func (m *loudnessMeter) integrated() float64 {
+	gated := func(threshold float64) (sum float64, n int) {
+		for _, z := range m.blocks {
+			if loudness(z) > threshold {
+				sum += z
+				n++
+			}
+		}
+		return sum, n
+	}
+	sum, n := gated(-70) // 绝对门限
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	sum, n = gated(max(loudness(sum/float64(n))-10, -70)) // 相对门限
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return loudness(sum / float64(n))
+}
+
+// loudness 将均方值换算为 LUFS
+func loudness(z float64) float64 {
+	return -0.691 + 10*math.Log10(z)
+}
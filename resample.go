@@ -0,0 +1,180 @@
+package hca
+
+import "math"
+
+// ResampleQuality is quality of the sample-rate converter
+// ResampleQuality 是重采样的质量
+type ResampleQuality int
+
+// ResampleQuality values
+// ResampleQuality 的取值
+const (
+	ResampleLinear ResampleQuality = iota // 线性插值, 速度快, 高频有混叠
+	ResampleSinc                          // 加窗 sinc 插值 (Blackman 窗, 16 个零点), 降采样时同时低通滤波
+)
+
+const (
+	sincZeros  = 16  // sinc 核每侧的零点数
+	sincPhases = 256 // 系数表的相位数, 相位之间线性插值
+)
+
+// outputRate 返回输出的采样率
+func (h *Hca) outputRate() uint32 {
+	if h.SampleRate > 0 {
+		return uint32(h.SampleRate)
+	}
+	return h.samplingRate
+}
+
+// finalFrames 返回重采样之后实际写出的样本帧数
+func (h *Hca) finalFrames() uint64 {
+	frames := h.outputFrames()
+	if rate := h.outputRate(); rate != h.samplingRate && h.samplingRate != 0 {
+		frames = (frames*uint64(rate) + uint64(h.samplingRate) - 1) / uint64(h.samplingRate)
+	}
+	return frames
+}
+
+// newResampler 在输出采样率与文件不同时创建重采样器, 否则返回 nil
+func (h *Hca) newResampler() *resampler {
+	if h.outputRate() == h.samplingRate {
+		return nil
+	}
+	return newResampler(int(h.outputChannels()), int64(h.samplingRate), int64(h.outputRate()), h.Resample)
+}
+
+// resampler 是流式的采样率转换器, 输入与输出都是交错排列的浮点样本.
+// 第 k 个输出帧位于输入的 k*in/out 处, N 个输入帧得到 ceil(N*out/in) 个输出帧,
+// 开头之前与结尾之后的输入视为静音
+type resampler struct {
+	channels int
+	in, out  int64 // 约分后的输入与输出采样率
+
+	k     int64     // 下一个输出帧的序号
+	buf   []float32 // 尚未用完的输入帧
+	start int64     // buf 第一帧的输入帧序号
+	total int64     // 已接收的输入帧数
+
+	back, reach int64       // 计算一个输出帧需要的输入帧: floor(t)-back 到 floor(t)+reach
+	table       [][]float32 // sinc 各相位的系数, 为 nil 时线性插值
+
+	result []float32 // process 的输出缓冲, 下次调用时复用
+}
+
+func newResampler(channels int, in, out int64, quality ResampleQuality) *resampler {
+	g := gcd(in, out)
+	r := &resampler{channels: channels, in: in / g, out: out / g, reach: 1}
+	if quality == ResampleSinc {
+		cutoff := min(1, float64(out)/float64(in)) // 降采样时将截止频率降到输出的奈奎斯特频率
+		half := int(math.Ceil(sincZeros / cutoff))
+		r.back, r.reach = int64(half-1), int64(half)
+		r.table = sincTables.get([2]int64{r.in, r.out}, func() [][]float32 { return newSincTable(cutoff, half) })
+	}
+	return r
+}
+
+// sincTables 按约分后的输入与输出采样率共享 sinc 系数表
+var sincTables tableCache[[2]int64, [][]float32]
+
+// newSincTable 生成 sinc 各相位的系数, 每个相位 2*half 个抽头
+func newSincTable(cutoff float64, half int) [][]float32 {
+	table := make([][]float32, sincPhases+1)
+	for p := range table {
+		taps := make([]float32, 2*half)
+		for i := range taps {
+			x := float64(i-half+1) - float64(p)/sincPhases // 抽头到插值位置的距离
+			taps[i] = float32(cutoff * sinc(cutoff*x) * blackman(x, float64(half)))
+		}
+		table[p] = taps
+	}
+	return table
+}
+
+// process 接收一段输入, 返回可以计算的输出帧
+func (r *resampler) process(in []float32) []float32 {
+	r.buf = append(r.buf, in...)
+	r.total += int64(len(in) / r.channels)
+	return r.run(false)
+}
+
+// flush 在输入结束后返回剩余的输出帧
+func (r *resampler) flush() []float32 {
+	return r.run(true)
+}
+
+func (r *resampler) run(final bool) []float32 {
+	out := r.result[:0]
+	for {
+		num := r.k * r.in
+		pos := num / r.out // 插值位置的整数部分
+		if final {
+			if num >= r.total*r.out { // 已输出 ceil(total*out/in) 帧
+				break
+			}
+		} else if pos+r.reach >= r.total { // 需要的输入还没有到达
+			break
+		}
+		frac := float64(num%r.out) / float64(r.out)
+		for c := 0; c < r.channels; c++ {
+			out = append(out, r.sample(pos, frac, c))
+		}
+		r.k++
+	}
+	// 丢弃之后不再需要的输入帧
+	if drop := min((r.k*r.in)/r.out-r.back, r.total) - r.start; drop > 0 {
+		n := copy(r.buf, r.buf[drop*int64(r.channels):])
+		r.buf = r.buf[:n]
+		r.start += drop
+	}
+	r.result = out
+	return out
+}
+
+// frame 返回第 i 个输入帧的通道 c, 范围之外为 0
+func (r *resampler) frame(i int64, c int) float32 {
+	if i < r.start || i >= r.total {
+		return 0
+	}
+	return r.buf[(i-r.start)*int64(r.channels)+int64(c)]
+}
+
+// sample 计算输入位置 pos+frac 处通道 c 的样本
+func (r *resampler) sample(pos int64, frac float64, c int) float32 {
+	if r.table == nil {
+		a, b := r.frame(pos, c), r.frame(pos+1, c)
+		return a + (b-a)*float32(frac)
+	}
+	p := frac * sincPhases
+	i := int(p)
+	w := float32(p - float64(i))
+	t0, t1 := r.table[i], r.table[min(i+1, sincPhases)]
+	var sum float32
+	for j := range t0 {
+		coef := t0[j] + (t1[j]-t0[j])*w
+		sum += coef * r.frame(pos-r.back+int64(j), c)
+	}
+	return max(-1, min(1, sum)) // 与 waveSerialize 一样限制在 -1 到 1
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman 返回宽度为 2*half 的 Blackman 窗在 x 处的值
+func blackman(x, half float64) float64 {
+	if math.Abs(x) >= half {
+		return 0
+	}
+	t := math.Pi * (x/half + 1) // 0 到 2π
+	return 0.42 - 0.5*math.Cos(t) + 0.08*math.Cos(2*t)
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
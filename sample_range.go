@@ -0,0 +1,95 @@
+package hca
+
+import (
+	"errors"
+	"time"
+)
+
+// samplesPerBlock 是每个数据块解码后每个通道的样本帧数
+const samplesPerBlock = 0x80 * 8
+
+// errRangeDone 表示已经写出 SampleCount (或 MaxDuration) 个样本帧, 剩余的数据块不需要解码
+var errRangeDone = errors.New("hca: sample range done")
+
+// hasRange 判断是否设置了输出范围
+func (h *Hca) hasRange() bool {
+	return h.StartSample > 0 || h.sampleCount() > 0
+}
+
+// sampleCount 返回 SampleCount 与 MaxDuration 中较短的限制 (样本帧), 0 表示不限制
+func (h *Hca) sampleCount() int64 {
+	n := h.SampleCount
+	if h.MaxDuration > 0 && h.samplingRate > 0 {
+		m := max(int64(h.MaxDuration)*int64(h.samplingRate)/int64(time.Second), 1)
+		if n == 0 || m < n {
+			n = m
+		}
+	}
+	return n
+}
+
+// outputFrames 返回应用 StartSample 与 SampleCount 之后实际输出的样本帧数
+func (h *Hca) outputFrames() uint64 {
+	frames := h.outputBlocks() * samplesPerBlock
+	start := uint64(h.StartSample)
+	if start >= frames {
+		return 0
+	}
+	frames -= start
+	if count := h.sampleCount(); count > 0 && uint64(count) < frames {
+		frames = uint64(count)
+	}
+	return frames
+}
+
+// window 返回一个块的交错样本中位于输出范围内的部分, 并将输出位置前进一个块
+func (h *Hca) window(serial []float32) []float32 {
+	channels := int64(h.outputChannels())
+	n := int64(len(serial)) / channels
+	pos := h.position
+	h.position += n
+	h.crossfade(serial, pos)
+	h.applyBalance(serial)
+	h.process(serial)
+	h.limit(serial)
+	if !h.hasRange() && h.FadeOut == 0 {
+		h.measure(serial)
+		return serial
+	}
+
+	lo := min(max(h.StartSample-pos, 0), n)
+	hi := n
+	if count := h.sampleCount(); count > 0 {
+		hi = min(max(h.StartSample+count-pos, lo), n)
+	}
+	out := serial[lo*channels : hi*channels]
+	h.measure(out)
+	h.fade(out, pos+lo-h.StartSample)
+	return out
+}
+
+// fade 对输出中从第 first 个样本帧开始的交错样本 out 应用淡出 (修改 out)
+func (h *Hca) fade(out []float32, first int64) {
+	if h.FadeOut == 0 {
+		return
+	}
+	total := int64(h.outputFrames())
+	length := min(h.FadeOut, total)
+	channels := int64(h.outputChannels())
+	for i := int64(0); i < int64(len(out))/channels; i++ {
+		left := total - (first + i) // 到输出结尾剩余的样本帧数 (包括当前帧)
+		if left > length {
+			continue
+		}
+		g := float32(left-1) / float32(length) // 最后一帧为 0
+		for c := int64(0); c < channels; c++ {
+			out[i*channels+c] *= g
+		}
+	}
+}
+
+// rangeDone 判断是否已经写出输出范围内的全部样本帧
+func (h *Hca) rangeDone() bool {
+	count := h.sampleCount()
+	return count > 0 && h.position >= h.StartSample+count
+}
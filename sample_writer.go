@@ -0,0 +1,62 @@
+package hca
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// writeSamples 将样本按写入模式编码到 h.outBuf 中并一次写出, 不分配内存
+func (h *Hca) writeSamples(base []float32, w io.Writer, endian binary.ByteOrder) error {
+	_, err := w.Write(h.encodeSamples(base, endian))
+	return err
+}
+
+// sampleSize 返回写入模式下每个样本的字节数
+func (h *Hca) sampleSize() int {
+	switch h.Mode {
+	case Mode8Bit:
+		return 1
+	case Mode16Bit:
+		return 2
+	case Mode24Bit:
+		return 3
+	}
+	return 4
+}
+
+// encodeSamples 将样本按写入模式编码到复用的 h.outBuf 中
+func (h *Hca) encodeSamples(base []float32, endian binary.ByteOrder) []byte {
+	size := h.sampleSize()
+	n := len(base) * size
+	if cap(h.outBuf) < n {
+		h.outBuf = make([]byte, n)
+	}
+	out := h.outBuf[:n]
+	for i, f := range base {
+		b := out[i*size:]
+		switch h.Mode {
+		case ModeFloat:
+			endian.PutUint32(b, math.Float32bits(f))
+		case Mode8Bit:
+			b[0] = uint8(scaleSample(f, 0x7F) + 0x80) // 8 位 PCM 为无符号数
+		case Mode16Bit:
+			endian.PutUint16(b, uint16(scaleSample(f, 0x7FFF)))
+		case Mode24Bit:
+			v := scaleSample(f, 0x7FFFFF)
+			b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16) // 与 mode24BitConvert 一致, 总是小端序
+		case Mode32Bit:
+			endian.PutUint32(b, uint32(scaleSample(f, 0x7FFFFFFF)))
+		}
+	}
+	return out
+}
+
+// maskTo 与 Mask 相同, 但写入 dst (长度至少为 len(data)) 而不分配内存
+func (ci *Cipher) maskTo(dst, data []byte) []byte {
+	dst = dst[:len(data)]
+	for i := range data {
+		dst[i] = ci.table[data[i]]
+	}
+	return dst
+}
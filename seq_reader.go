@@ -0,0 +1,69 @@
+package hca
+
+import (
+	"errors"
+	"io"
+)
+
+// seqReader 将只能顺序读取的 io.Reader 包装为 io.ReadSeeker,
+// 保留最近读取的 limit 字节以支持小范围的向后 Seek (limit < 0 时保留全部数据),
+// 向前 Seek 时丢弃中间的数据
+type seqReader struct {
+	r     io.Reader
+	pos   int64  // 当前读取位置
+	hist  []byte // 最近读取的数据
+	start int64  // hist[0] 在流中的位置
+	limit int
+}
+
+func newSeqReader(r io.Reader, limit int) *seqReader {
+	return &seqReader{r: r, limit: limit}
+}
+
+// end 返回已从底层读取的数据末尾位置
+func (s *seqReader) end() int64 {
+	return s.start + int64(len(s.hist))
+}
+
+func (s *seqReader) Read(p []byte) (int, error) {
+	if s.pos < s.end() { // 先返回保留的数据
+		n := copy(p, s.hist[s.pos-s.start:])
+		s.pos += int64(n)
+		return n, nil
+	}
+	n, err := s.r.Read(p)
+	s.keep(p[:n])
+	s.pos += int64(n)
+	return n, err
+}
+
+// keep 保存新读取的数据, 超出 limit 的旧数据被丢弃
+func (s *seqReader) keep(p []byte) {
+	s.hist = append(s.hist, p...)
+	if s.limit >= 0 && len(s.hist) > s.limit {
+		drop := len(s.hist) - s.limit
+		s.hist = append(s.hist[:0], s.hist[drop:]...)
+		s.start += int64(drop)
+	}
+}
+
+func (s *seqReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	default:
+		return s.pos, errors.New("hca: unsupported seek on sequential input")
+	}
+	if offset < s.start {
+		return s.pos, errors.New("hca: seek before retained data on sequential input")
+	}
+	if end := s.end(); offset > end { // 向前 Seek, 读取并丢弃中间的数据
+		s.pos = end
+		if _, err := io.CopyN(io.Discard, s, offset-end); err != nil {
+			return s.pos, err
+		}
+	}
+	s.pos = offset
+	return s.pos, nil
+}
@@ -0,0 +1,120 @@
+package hca
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Sink is output writer of a registered format
+// Sink 是注册的输出格式的写出器, 按顺序接收交错排列的浮点样本 (已应用音量, 截取范围与淡出, 范围 -1 到 1)
+type Sink interface {
+	WriteSamples(samples []float32) error // samples 在调用返回后会被复用, 需要保留时应复制
+	Close() error                         // 解码结束时调用一次, 用于写出尾部或回写头部, 不应关闭底层的 io.Writer
+}
+
+// SinkFactory create Sink writing to w
+// SinkFactory 在读取头部之后调用, 创建写入 w 的 Sink. info 是输入文件的头部信息, opts 是解码器上与输出格式有关的设置
+type SinkFactory func(w io.Writer, info Info, opts SinkOptions) (Sink, error)
+
+// SinkOptions is decoder settings passed to SinkFactory
+// SinkOptions 是传给 SinkFactory 的解码器设置
+type SinkOptions struct {
+	Options map[string]string // Hca.FormatOptions, 各格式只读取自己认识的键, 值无效时 SinkFactory 返回错误
+	Tags    Tags              // Hca.Tags, 支持元数据的格式写入输出, 例如 Tags.VorbisComments
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]SinkFactory{} // 按小写名称注册的输出格式
+)
+
+// RegisterFormat register output format, name is also used as file extension
+// RegisterFormat 注册一个输出格式, 名称 (不区分大小写) 同时作为输出文件的扩展名,
+// 设置 Hca.Format 或解码到该扩展名的文件时使用. 通常在插件包的 init 中调用.
+// 名称为空, 与内置的 wav/raw 冲突或重复注册时 panic
+func RegisterFormat(name string, factory SinkFactory) {
+	name = strings.ToLower(name)
+	if name == "" || strings.ContainsAny(name, "./\\") {
+		panic("hca: RegisterFormat invalid name " + name)
+	}
+	if name == "wav" || name == "raw" {
+		panic("hca: RegisterFormat built-in format " + name)
+	}
+	if factory == nil {
+		panic("hca: RegisterFormat factory is nil")
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if _, dup := formats[name]; dup {
+		panic("hca: RegisterFormat called twice for " + name)
+	}
+	formats[name] = factory
+}
+
+// Formats return registered format names
+// Formats 返回已注册的输出格式名称 (按字母顺序, 不包括内置的 wav/raw)
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsFormat report whether name is registered format
+// IsFormat 报告 name 是否是已注册的输出格式
+func IsFormat(name string) bool {
+	_, ok := lookupFormat(name)
+	return ok
+}
+
+// lookupFormat 返回已注册的输出格式
+func lookupFormat(name string) (SinkFactory, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	factory, ok := formats[strings.ToLower(name)]
+	return factory, ok
+}
+
+// formatForPath 按文件扩展名返回已注册的输出格式名称
+func formatForPath(path string) (string, bool) {
+	name := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if _, ok := lookupFormat(name); !ok {
+		return "", false
+	}
+	return name, true
+}
+
+// formatFor 返回写出到文件 path 时使用的输出格式: 设置了 Format 时使用 Format, 否则按扩展名选择已注册的格式, 都没有时为空 (WAV)
+func (h *Hca) formatFor(path string) string {
+	if h.Format != "" {
+		return h.Format
+	}
+	name, _ := formatForPath(path)
+	return name
+}
+
+// openSink 创建 format 格式的 Sink, format 为空时返回 nil
+func (h *Hca) openSink(w io.Writer, format string) (Sink, error) {
+	factory := h.capture
+	if factory == nil {
+		if format == "" {
+			return nil, nil
+		}
+		var ok bool
+		if factory, ok = lookupFormat(format); !ok {
+			return nil, fmt.Errorf("%w: format %q is not registered", ErrInvalidOption, format)
+		}
+	}
+	info := h.info()
+	info.SamplingRate = int(h.outputRate()) // Sink 接收重采样与通道映射之后的样本
+	info.Channels = int(h.outputChannels())
+	return factory(w, info, SinkOptions{Options: h.FormatOptions, Tags: h.Tags})
+}
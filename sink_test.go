@@ -0,0 +1,55 @@
+package hca
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testSink 结束时写出 "samples", 用于检查解码使用了注册的格式
+type testSink struct{ w io.Writer }
+
+func (s *testSink) WriteSamples([]float32) error { return nil }
+
+func (s *testSink) Close() error {
+	_, err := io.WriteString(s.w, "samples")
+	return err
+}
+
+// TestFormatForPath 检查 DecodeFile 与 DecodeFileFS 按输出文件的扩展名选择注册的格式, 且不修改 Format
+func TestFormatForPath(t *testing.T) {
+	var h *Hca
+	RegisterFormat("hcatest", func(w io.Writer, info Info, opts SinkOptions) (Sink, error) {
+		if h.Format != "" {
+			t.Errorf("Format is %q during decoding", h.Format)
+		}
+		return &testSink{w: w}, nil
+	})
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name, want string
+	}{
+		{"out.hcatest", "samples"},
+		{"OUT.HCATEST", "samples"},
+		{"out.wav", "RIFF"},
+	} {
+		for _, decode := range []func(dst string) error{
+			func(dst string) error { return h.DecodeFile(filepath.Join("testdata", "stereo.hca"), dst) },
+			func(dst string) error { return h.DecodeFileFS(os.DirFS("testdata"), "stereo.hca", dst) },
+		} {
+			h = NewDecoder()
+			dst := filepath.Join(dir, tc.name)
+			if err := decode(dst); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			out, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) < len(tc.want) || string(out[:len(tc.want)]) != tc.want {
+				t.Errorf("%s: output starts with %q, want %q", tc.name, out[:min(len(out), 8)], tc.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package hca
+
+// SpectrumBins is number of frequency bins per subframe
+// SpectrumBins 是每个子块 (128 个样本帧) 频谱的频段数
+const SpectrumBins = 0x80
+
+// Spectrum is magnitude spectrum of one decoded block
+// Spectrum 是一个解码块的幅度谱: 块分为 8 个子块, 每个子块是 128 个频段的 MDCT 系数的绝对值,
+// 第 k 个频段的中心频率为 (k+0.5)*采样率/256. 幅度未应用 rva 音量, Volume 与其他输出处理,
+// 按文件的通道顺序排列 (不受 ChannelMap 影响)
+type Spectrum struct {
+	Block        int   // 块在文件中的索引
+	Position     int64 // 块的第一个样本帧在展开循环后的位置 (与 StartSample 的计数相同)
+	SamplingRate int   // 文件的采样率
+
+	Magnitudes [][8][SpectrumBins]float32 // 按 [通道][子块][频段] 排列, 在回调返回后会被复用, 需要保留时应复制
+}
+
+// BinFrequency return center frequency of bin in Hz
+// BinFrequency 返回第 bin 个频段的中心频率 (Hz)
+func (s *Spectrum) BinFrequency(bin int) float64 {
+	return (float64(bin) + 0.5) * float64(s.SamplingRate) / (2 * SpectrumBins)
+}
+
+// tapSpectrum 以刚解码的块调用 SpectrumTap
+func (h *Hca) tapSpectrum(address int64) {
+	if h.SpectrumTap == nil || h.decoder.spectrum == nil {
+		return
+	}
+	h.spectrum = Spectrum{
+		Block:        int((address - int64(h.dataOffset)) / int64(h.blockSize)),
+		Position:     h.position,
+		SamplingRate: int(h.samplingRate),
+		Magnitudes:   h.decoder.spectrum,
+	}
+	h.SpectrumTap(&h.spectrum)
+}
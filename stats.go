@@ -0,0 +1,171 @@
+package hca
+
+import (
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// Stats is decode statistics
+// Stats 是解码统计信息
+type Stats struct {
+	Blocks    int   // 已解码并输出的块数 (包括被 StartSample 跳过的块)
+	Samples   int64 // 已写出的样本帧数
+	BadBlocks int   // 校验失败的块数
+	BadMagic  int   // 魔术数字错误的块数
+	BadData   int   // 内容无法解码的块数
+	Concealed []int // 以静音 (BlockMute) 或上一个块 (BlockRepeat) 代替的块索引, 按输出顺序, 展开循环时同一个块可能出现多次
+
+	BytesRead    int64         // 从输入读取的字节数 (头部与数据块, 包括检查密钥时读取的块)
+	BytesWritten int64         // 写出的字节数 (包括 WAV 头部)
+	Duration     time.Duration // 写出的音频时长
+	Elapsed      time.Duration // 解码用时
+
+	Gain float32 // 实际应用的音量 (校正后的 rva 音量 * Volume)
+
+	Channels []ChannelStats // Analyze 时每个输出通道的电平统计, 否则为 nil
+}
+
+// Throughput return input bytes decoded per second
+// Throughput 返回每秒解码的输入字节数, 没有用时时返回 0
+func (s Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.BytesRead) / s.Elapsed.Seconds()
+}
+
+// DecodeObserver receives statistics of every decode
+// DecodeObserver 在每次解码 (包括失败的解码) 结束时收到头部信息, 统计信息与解码的结果,
+// 用于向监控系统 (例如 Prometheus) 报告. 多个解码器共用时可能被并发调用
+type DecodeObserver interface {
+	ObserveDecode(info Info, stats Stats, err error)
+}
+
+// ChannelStats is level statistics of one output channel
+// ChannelStats 是一个输出通道的电平统计 (应用音量之后, 重采样与淡出之前)
+type ChannelStats struct {
+	Peak    float32 // 削波之前的峰值 (线性, 大于 1 表示输出被削波)
+	RMS     float64 // 削波之后的均方根电平 (线性)
+	Clipped int64   // 超出 -1 到 1 被削波的样本数
+}
+
+// PeakDB return peak in dBFS
+// PeakDB 返回以 dBFS 表示的峰值, 大于 0 表示削波
+func (c ChannelStats) PeakDB() float64 {
+	return 20 * math.Log10(float64(c.Peak))
+}
+
+// RMSDB return RMS level in dBFS
+// RMSDB 返回以 dBFS 表示的 RMS 电平
+func (c ChannelStats) RMSDB() float64 {
+	return 20 * math.Log10(c.RMS)
+}
+
+// channelLevel 累积一个通道的电平
+type channelLevel struct {
+	peak    float32
+	sumSq   float64
+	clipped int64
+	n       int64
+}
+
+// Stats return statistics of the last decode
+// Stats 返回最近一次解码的统计信息
+func (h *Hca) Stats() Stats {
+	s := h.stats
+	s.Concealed = slices.Clone(s.Concealed) // 之后的解码不影响返回的结果
+	if h.levels != nil {
+		s.Channels = make([]ChannelStats, len(h.levels))
+		for i, l := range h.levels {
+			s.Channels[i] = ChannelStats{Peak: l.peak, Clipped: l.clipped}
+			if l.n > 0 {
+				s.Channels[i].RMS = math.Sqrt(l.sumSq / float64(l.n))
+			}
+		}
+	}
+	return s
+}
+
+// measure 在 Analyze 时统计交错样本 out 的电平, 并在没有提前削波时削波到 -1 到 1 (修改 out)
+func (h *Hca) measure(out []float32) {
+	if !h.Analyze {
+		if !h.clampEarly() {
+			clamp(out)
+		}
+		return
+	}
+	channels := int(h.outputChannels())
+	if h.levels == nil {
+		h.levels = make([]channelLevel, channels)
+	}
+	for i, f := range out {
+		l := &h.levels[i%channels]
+		l.peak = max(l.peak, float32(math.Abs(float64(f))))
+		if f > 1 || f < -1 {
+			f = max(-1, min(1, f))
+			l.clipped++
+			out[i] = f
+		}
+		l.sumSq += float64(f) * float64(f)
+		l.n++
+	}
+}
+
+// finishStats 记录写出的字节数, 音频时长与解码用时, 并通知 Observer
+func (h *Hca) finishStats(start time.Time, written int64, err error) {
+	h.stats.BytesWritten = written
+	h.stats.Elapsed = time.Since(start)
+	if rate := h.outputRate(); rate > 0 {
+		h.stats.Duration = time.Duration(h.stats.Samples) * time.Second / time.Duration(rate)
+	}
+	if h.Observer != nil {
+		h.Observer.ObserveDecode(h.info(), h.Stats(), err)
+	}
+}
+
+// outputCounter 统计写出的字节数, 按写出位置的最大值计算, 回写头部不会重复计入
+type outputCounter struct {
+	w               io.Writer
+	start, pos, end int64
+}
+
+func (c *outputCounter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.pos += int64(n)
+	c.end = max(c.end, c.pos)
+	return n, err
+}
+
+// written 返回写出的字节数
+func (c *outputCounter) written() int64 {
+	return c.end - c.start
+}
+
+// outputSeeker 是输出可以 Seek 时的 outputCounter, 保留 Seek 以便回写头部
+type outputSeeker struct {
+	*outputCounter
+	s io.Seeker
+}
+
+func (c outputSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := c.s.Seek(offset, whence)
+	if err == nil {
+		c.pos = pos
+	}
+	return pos, err
+}
+
+// newOutputCounter 包装 w 以统计写出的字节数, w 可以 Seek 时返回的 Writer 同样可以 Seek
+func newOutputCounter(w io.Writer) (io.Writer, *outputCounter) {
+	c := &outputCounter{w: w}
+	ws, ok := w.(io.WriteSeeker)
+	if !ok {
+		return c, c
+	}
+	if pos, err := ws.Seek(0, io.SeekCurrent); err == nil {
+		c.start, c.pos, c.end = pos, pos, pos
+	}
+	return outputSeeker{c, ws}, c
+}
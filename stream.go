@@ -0,0 +1,95 @@
+package hca
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Stream is decoded WAV stream returned by Decoder
+// Stream 是 Decoder 返回的 WAV 数据流. 第一次 Read 时在后台 goroutine 中开始解码;
+// 在 Read 之前调用 WriteTo (例如 io.Copy) 时直接在调用方的 goroutine 中解码到目标 Writer, 不经过管道
+type Stream struct {
+	decode func(w io.Writer) error
+
+	startOnce sync.Once
+	pr        *io.PipeReader
+	done      chan struct{} // 后台解码结束时关闭, 没有开始时为 nil
+
+	closeOnce sync.Once
+	closed    atomic.Bool
+}
+
+// newStream 返回输出 decode 写入的数据的 Stream, decode 在第一次 Read 或 WriteTo 时才开始
+func newStream(decode func(w io.Writer) error) *Stream {
+	return &Stream{decode: decode}
+}
+
+// start 在后台 goroutine 中运行 decode, 并通过管道输出其写入的数据
+func (s *Stream) start() {
+	s.startOnce.Do(func() {
+		pr, pw := io.Pipe()
+		s.pr, s.done = pr, make(chan struct{})
+		go func() {
+			defer close(s.done)
+			pw.CloseWithError(s.decode(pw)) // 将解码错误传递给读取端
+		}()
+	})
+}
+
+// Read reads decoded WAV data
+// Read 读取解码后的 WAV 数据, 解码失败时返回解码错误
+func (s *Stream) Read(p []byte) (int, error) {
+	if s.closed.Load() {
+		return 0, ErrClosed
+	}
+	s.start()
+	if s.pr == nil { // 已经由 WriteTo 直接解码
+		return 0, ErrClosed
+	}
+	return s.pr.Read(p)
+}
+
+// WriteTo write all decoded WAV data to w
+// WriteTo 将解码后的全部 WAV 数据写入 w, 返回写入的字节数. 还没有 Read 时直接解码到 w,
+// 否则从管道中复制剩余的数据. 可以被 io.Copy 使用
+func (s *Stream) WriteTo(w io.Writer) (int64, error) {
+	if s.closed.Load() {
+		return 0, ErrClosed
+	}
+	direct := false
+	s.startOnce.Do(func() { direct = true }) // 之后的 Read 不会再启动后台解码
+	if !direct {
+		return io.Copy(w, struct{ io.Reader }{s}) // 隐藏 WriteTo, 避免 io.Copy 递归
+	}
+	sw := &streamWriter{w: w} // 隐藏 w 的 Seek, 输出与 Read 得到的数据相同
+	err := s.decode(sw)
+	s.Close()
+	return sw.n, err
+}
+
+// streamWriter 统计写入 w 的字节数
+type streamWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (s *streamWriter) Write(b []byte) (int, error) {
+	n, err := s.w.Write(b)
+	s.n += int64(n)
+	return n, err
+}
+
+// Close stop decoding and release the stream
+// Close 停止后台解码并等待其结束, 之后的 Read 返回 ErrClosed
+func (s *Stream) Close() error {
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		s.startOnce.Do(func() {}) // 没有开始时不再开始
+		if s.pr != nil {
+			s.pr.CloseWithError(ErrClosed) // 后台解码的写入随之失败
+			<-s.done
+		}
+	})
+	return nil
+}
@@ -0,0 +1,61 @@
+package hca
+
+// Strictness is parse strictness level
+// Strictness 是解析的严格程度, 统一控制各项容错设置
+type Strictness int
+
+// Strictness values
+// Strictness 的取值
+const (
+	// StrictnessDefault 使用 ChecksumPolicy, MagicPolicy 与 RecoverTruncated 各自的设置
+	StrictnessDefault Strictness = iota
+	// StrictnessStrict 要求文件完全有效: 校验头部的校验和, 拒绝未知的块,
+	// 任何损坏或截断的数据块都会使解码失败
+	StrictnessStrict
+	// StrictnessPermissive 尽可能恢复数据: 忽略未知的块与头部校验和, 头部在可选块中截断时
+	// 视为该块不存在, 以静音替代损坏块, 数据截断时保留已解码的部分
+	StrictnessPermissive
+)
+
+// checksumPolicy 返回按照严格程度生效的校验和错误处理策略
+func (h *Hca) checksumPolicy() BlockPolicy {
+	switch h.Strictness {
+	case StrictnessStrict:
+		return BlockStrict
+	case StrictnessPermissive:
+		return BlockMute
+	}
+	return h.ChecksumPolicy
+}
+
+// magicPolicy 返回按照严格程度生效的魔术数字错误处理策略
+func (h *Hca) magicPolicy() BlockPolicy {
+	switch h.Strictness {
+	case StrictnessStrict:
+		return BlockStrict
+	case StrictnessPermissive:
+		return BlockMute
+	}
+	return h.MagicPolicy
+}
+
+// recoverTruncated 返回按照严格程度是否保留截断前已解码的数据
+func (h *Hca) recoverTruncated() bool {
+	switch h.Strictness {
+	case StrictnessStrict:
+		return false
+	case StrictnessPermissive:
+		return true
+	}
+	return h.RecoverTruncated || h.PadTruncated
+}
+
+// skipChecksum 返回按照严格程度是否跳过数据块的校验和
+func (h *Hca) skipChecksum() bool {
+	return h.Strictness != StrictnessStrict && h.SkipChecksum
+}
+
+// padTruncated 返回按照严格程度是否以零补齐截断的块
+func (h *Hca) padTruncated() bool {
+	return h.Strictness != StrictnessStrict && h.PadTruncated
+}
@@ -0,0 +1,35 @@
+package hca
+
+import "sync"
+
+// tableCacheSize 是每种查找表最多共享的份数, 超出后新生成的表不再缓存, 避免不可信的头部参数使缓存无限增长
+const tableCacheSize = 64
+
+// tableCache 在解码器之间共享按参数生成的只读查找表, 可以并发使用
+type tableCache[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+}
+
+// get 返回 key 对应的表, 不存在时调用 build 生成. 返回的表是共享的, 不能修改
+func (c *tableCache[K, V]) get(key K, build func() V) V {
+	c.mu.RLock()
+	v, ok := c.m[key]
+	c.mu.RUnlock()
+	if ok {
+		return v
+	}
+	v = build()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.m[key]; ok { // 其他解码器同时生成了同一个表
+		return old
+	}
+	if c.m == nil {
+		c.m = make(map[K]V)
+	}
+	if len(c.m) < tableCacheSize {
+		c.m[key] = v
+	}
+	return v
+}
@@ -0,0 +1,57 @@
+package hca
+
+import "io"
+
+// Validation is result of Validate
+// Validation 是 Validate 的检查结果
+type Validation struct {
+	Info        Info
+	Checked     int   // 完整读取并检查的块数
+	BadChecksum []int // 校验和错误的块索引
+	BadMagic    []int // 校验和正确但魔术数字错误的块索引
+	Truncated   bool  // 数据在最后一个块之前结束, Checked 之后的块缺失
+}
+
+// OK report whether all blocks are present and valid
+// OK 返回是否所有数据块都存在且有效
+func (v *Validation) OK() bool {
+	return !v.Truncated && len(v.BadChecksum) == 0 && len(v.BadMagic) == 0
+}
+
+// Validate check integrity of every block of r without decoding
+// Validate 读取 r 的头部并依次检查每个数据块的 CRC16 校验和与魔术数字, 不进行 MDCT 解码也不写出数据,
+// 用于快速扫描大量文件是否损坏. 不检查密钥是否正确 (不需要密钥), 不会改变 h 的状态.
+// 只有头部无效或读取失败时返回错误, 损坏与截断的块记录在结果中
+func (h *Hca) Validate(r io.Reader) (*Validation, error) {
+	if h.closed { // 解码器已关闭
+		return nil, ErrClosed
+	}
+	p := *h                   // 使用副本读取头部, 保留 h 最近一次解码的状态
+	p.fileState = fileState{} // 与解码时一样从空的状态开始
+	if err := p.loadHeader(r); err != nil {
+		return nil, err
+	}
+	if err := p.checkStream(); err != nil { // 与解码时一样检查 Limits 与块大小
+		return nil, err
+	}
+	v := &Validation{Info: p.info()}
+	data := make([]byte, p.blockSize)
+	for i := 0; i < int(p.blockCount); i++ {
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				v.Truncated = true
+				return v, nil
+			}
+			return nil, p.blockError(p.blockAddress(uint32(i)), err)
+		}
+		v.Checked++
+		if checkSum(data, 0) != 0 {
+			v.BadChecksum = append(v.BadChecksum, i)
+			continue
+		}
+		if data[0] != 0xFF || data[1] != 0xFF { // 所有密码表都将 0xFF 映射为自身, 魔术数字不需要解密
+			v.BadMagic = append(v.BadMagic, i)
+		}
+	}
+	return v, nil
+}
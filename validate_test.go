@@ -0,0 +1,40 @@
+package hca
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestValidate 检查损坏与截断的块记录在结果中, 块大小为 0 的头部返回 ErrInvalidHeader
+func TestValidate(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "stereo.hca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewDecoder()
+	v, err := h.Validate(bytes.NewReader(data))
+	if err != nil || !v.OK() || v.Checked != int(v.Info.Blocks) {
+		t.Fatalf("got %+v, %v, want all %d blocks valid", v, err, v.Info.Blocks)
+	}
+
+	bad := bytes.Clone(data)
+	if err := h.loadHeader(bytes.NewReader(bad)); err != nil {
+		t.Fatal(err)
+	}
+	bad[h.blockAddress(1)+0x40] ^= 0xFF
+	bad = bad[:h.blockAddress(3)+0x10] // 3 个完整的块与第 4 个块的开头
+	v, err = NewDecoder().Validate(bytes.NewReader(bad))
+	if err != nil || v.OK() || v.Checked != 3 || !v.Truncated || !slices.Equal(v.BadChecksum, []int{1}) {
+		t.Errorf("damaged: got %+v, %v, want 3 blocks checked, block 1 bad, truncated", v, err)
+	}
+
+	zero := bytes.Clone(data)
+	zero[0x1C], zero[0x1D] = 0, 0 // comp 块的块大小
+	if _, err := NewDecoder().Validate(bytes.NewReader(zero)); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("zero block size: got %v, want ErrInvalidHeader", err)
+	}
+}
@@ -0,0 +1,94 @@
+//go:build js && wasm
+
+// Command wasm exports the HCA decoder to JavaScript.
+// wasm 将 HCA 解码器导出给 JavaScript 使用, 构建方法:
+//
+//	GOOS=js GOARCH=wasm go build -o hca.wasm ./wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// 加载后全局函数 decodeHCA(bytes, key, subkey) 返回 {pcm, sampleRate, channels}:
+// pcm 是交错的 Float32Array, 可以直接写入 Web Audio 的 AudioBuffer.
+// bytes 为 Uint8Array 或 ArrayBuffer; key 省略时使用默认密钥, 超过 2^53 的密钥应以
+// BigInt 或字符串 (例如 "0xCC55463930DBE1AB") 传入. 失败时返回 {error}
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"syscall/js"
+
+	"github.com/WJQSERVER/hca"
+)
+
+func main() {
+	js.Global().Set("decodeHCA", js.FuncOf(decodeHCA))
+	select {} // 保持运行, 等待 JavaScript 调用
+}
+
+// decodeHCA 是导出给 JavaScript 的解码函数
+func decodeHCA(this js.Value, args []js.Value) any {
+	res, err := decode(args)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return res
+}
+
+func decode(args []js.Value) (map[string]any, error) {
+	if len(args) < 1 {
+		return nil, errors.New("decodeHCA: missing bytes")
+	}
+	data, err := goBytes(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	d := hca.NewDecoder()
+	if len(args) > 1 && !isEmpty(args[1]) {
+		key, err := strconv.ParseUint(js.Global().Get("String").Invoke(args[1]).String(), 0, 64) // 通过 String 转换, 支持 BigInt
+		if err != nil {
+			return nil, errors.New("decodeHCA: invalid key")
+		}
+		d.SetKey(key)
+	}
+	if len(args) > 2 && !isEmpty(args[2]) {
+		d.Subkey = uint16(args[2].Int())
+	}
+	d.Mode = hca.ModeFloat
+	d.Headerless = true
+
+	var pcm bytes.Buffer
+	if err := d.DecodeWithWriter(bytes.NewReader(data), &pcm); err != nil {
+		return nil, err
+	}
+	info := d.Info()
+
+	// WebAssembly 为小端序, 与输出的 float32 字节序一致
+	u8 := js.Global().Get("Uint8Array").New(pcm.Len())
+	js.CopyBytesToJS(u8, pcm.Bytes())
+	f32 := js.Global().Get("Float32Array").New(u8.Get("buffer"))
+	return map[string]any{
+		"pcm":        f32,
+		"sampleRate": info.SamplingRate,
+		"channels":   info.Channels,
+	}, nil
+}
+
+// goBytes 将 Uint8Array 或 ArrayBuffer 复制为 []byte
+func goBytes(v js.Value) ([]byte, error) {
+	if v.InstanceOf(js.Global().Get("ArrayBuffer")) {
+		v = js.Global().Get("Uint8Array").New(v)
+	}
+	if !v.InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, errors.New("decodeHCA: bytes must be a Uint8Array or ArrayBuffer")
+	}
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+	return b, nil
+}
+
+// isEmpty 判断可选参数是否省略
+func isEmpty(v js.Value) bool {
+	return v.IsUndefined() || v.IsNull()
+}
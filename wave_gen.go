@@ -3,20 +3,22 @@ package hca
 import (
 	"encoding/binary"
 	"io"
-
-	"github.com/vazrupe/endibuf"
 )
 
 type stWaveHeader struct {
 	Riff *stWAVEriff
 	Smpl *stWAVEsmpl
 	Note *stWAVEnote
+	List *stWAVElist
 	Data *stWAVEdata
 
 	RiffOk bool
 	SmplOk bool
 	NoteOk bool
+	ListOk bool
 	DataOk bool
+
+	buf []byte // 编码缓冲
 }
 
 func newWaveHeader() *stWaveHeader {
@@ -24,43 +26,38 @@ func newWaveHeader() *stWaveHeader {
 		Riff: newWaveRiff(),
 		Smpl: newWaveSmpl(),
 		Note: newWaveNote(),
+		List: newWaveList(),
 		Data: newWaveData(),
 
 		RiffOk: true,
 		SmplOk: false,
 		NoteOk: false,
+		ListOk: false,
 		DataOk: true,
 	}
 }
 
-func (wv *stWaveHeader) Write(w *endibuf.Writer) {
+// Write 将各个块按小端序编码到复用的缓冲中, 一次写出
+func (wv *stWaveHeader) Write(w io.Writer) error {
+	b := wv.buf[:0]
 	if wv.RiffOk {
-		wv.Riff.Write(w)
+		b = wv.Riff.appendTo(b)
 	}
 	if wv.SmplOk {
-		wv.Smpl.Write(w)
+		b = wv.Smpl.appendTo(b)
 	}
 	if wv.NoteOk {
-		wv.Note.Write(w)
-	}
-	if wv.DataOk {
-		wv.Data.Write(w)
-	}
-}
-
-func (wv *stWaveHeader) NeoWrite(w io.Writer, endian binary.ByteOrder) {
-	if wv.RiffOk {
-		wv.Riff.NeoWrite(w, endian)
+		b = wv.Note.appendTo(b)
 	}
-	if wv.SmplOk {
-		wv.Smpl.NeoWrite(w, endian)
-	}
-	if wv.NoteOk {
-		wv.Note.NeoWrite(w, endian)
+	if wv.ListOk {
+		b = wv.List.appendTo(b)
 	}
 	if wv.DataOk {
-		wv.Data.NeoWrite(w, endian)
+		b = wv.Data.appendTo(b)
 	}
+	wv.buf = b // 修正头部时复用
+	_, err := w.Write(b)
+	return err
 }
 
 type stWAVEriff struct {
@@ -75,6 +72,66 @@ type stWAVEriff struct {
 	fmtSamplesPerSec uint32
 	fmtSamplingSize  uint16
 	fmtBitCount      uint16
+
+	// WAVE_FORMAT_EXTENSIBLE
+	fmtExtSize     uint16
+	fmtValidBits   uint16
+	fmtChannelMask uint32
+	fmtSubFormat   [16]byte
+}
+
+const (
+	waveFormatPCM        = 1
+	waveFormatFloat      = 3
+	waveFormatExtensible = 0xFFFE
+)
+
+// waveSubFormatGUID is KSDATAFORMAT_SUBTYPE_xxx for format tag
+func waveSubFormatGUID(format uint16) [16]byte {
+	return [16]byte{byte(format), byte(format >> 8), 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+}
+
+// waveChannelMask is default speaker layout for channel count
+func waveChannelMask(channels uint16) uint32 {
+	switch channels {
+	case 1:
+		return 0x4 // FC
+	case 2:
+		return 0x3 // FL FR
+	case 3:
+		return 0x7 // FL FR FC
+	case 4:
+		return 0x33 // FL FR BL BR
+	case 5:
+		return 0x37 // FL FR FC BL BR
+	case 6:
+		return 0x3F // FL FR FC LFE BL BR
+	case 7:
+		return 0x13F // FL FR FC LFE BL BR BC
+	case 8:
+		return 0x63F // FL FR FC LFE BL BR SL SR
+	}
+	return 0
+}
+
+// setFormat set format fields, if extensible is true,
+// extensible format is used for more than 16 bits or 2 channels
+func (h *stWAVEriff) setFormat(format, bitCount, channels uint16, samplingRate uint32, extensible bool) {
+	h.fmtType = format
+	h.fmtBitCount = bitCount
+	h.fmtChannelCount = channels
+	h.fmtSamplingRate = samplingRate
+	h.fmtSamplingSize = bitCount / 8 * channels
+	h.fmtSamplesPerSec = samplingRate * uint32(h.fmtSamplingSize)
+	h.fmtSize = 0x10
+	if extensible && (bitCount > 16 || channels > 2) {
+		h.fmtType = waveFormatExtensible
+		h.fmtSize = 0x28
+		h.fmtExtSize = 22
+		h.fmtValidBits = bitCount
+		h.fmtChannelMask = waveChannelMask(channels)
+		h.fmtSubFormat = waveSubFormatGUID(format)
+	}
 }
 
 func newWaveRiff() *stWAVEriff {
@@ -93,55 +150,26 @@ func newWaveRiff() *stWAVEriff {
 	}
 }
 
-func (h *stWAVEriff) Write(w *endibuf.Writer) {
-	endianSave := w.Endian
-
-	w.Endian = binary.BigEndian
-	w.WriteBytes(h.riff)
-
-	w.Endian = binary.LittleEndian
-	w.WriteUint32(h.riffSize)
-
-	w.Endian = binary.BigEndian
-	w.WriteBytes(h.wave)
-	w.WriteBytes(h.fmt)
-
-	w.Endian = binary.LittleEndian
-	w.WriteUint32(h.fmtSize)
-	w.WriteUint16(h.fmtType)
-	w.WriteUint16(h.fmtChannelCount)
-	w.WriteUint32(h.fmtSamplingRate)
-	w.WriteUint32(h.fmtSamplesPerSec)
-	w.WriteUint16(h.fmtSamplingSize)
-	w.WriteUint16(h.fmtBitCount)
-
-	w.Endian = endianSave
-}
-
-func (h *stWAVEriff) NeoWrite(w io.Writer, endian binary.ByteOrder) {
-	endianSave := endian
-	var wEndian binary.ByteOrder
-
-	wEndian = binary.BigEndian
-	binary.Write(w, wEndian, h.riff)
-
-	wEndian = binary.LittleEndian
-	binary.Write(w, wEndian, h.riffSize)
-
-	wEndian = binary.BigEndian
-	binary.Write(w, wEndian, h.wave)
-	binary.Write(w, wEndian, h.fmt)
-
-	wEndian = binary.LittleEndian
-	binary.Write(w, wEndian, h.fmtSize)
-	binary.Write(w, wEndian, h.fmtType)
-	binary.Write(w, wEndian, h.fmtChannelCount)
-	binary.Write(w, wEndian, h.fmtSamplingRate)
-	binary.Write(w, wEndian, h.fmtSamplesPerSec)
-	binary.Write(w, wEndian, h.fmtSamplingSize)
-	binary.Write(w, wEndian, h.fmtBitCount)
-
-	wEndian = endianSave
+func (h *stWAVEriff) appendTo(b []byte) []byte {
+	le := binary.LittleEndian
+	b = append(b, h.riff...)
+	b = le.AppendUint32(b, h.riffSize)
+	b = append(b, h.wave...)
+	b = append(b, h.fmt...)
+	b = le.AppendUint32(b, h.fmtSize)
+	b = le.AppendUint16(b, h.fmtType)
+	b = le.AppendUint16(b, h.fmtChannelCount)
+	b = le.AppendUint32(b, h.fmtSamplingRate)
+	b = le.AppendUint32(b, h.fmtSamplesPerSec)
+	b = le.AppendUint16(b, h.fmtSamplingSize)
+	b = le.AppendUint16(b, h.fmtBitCount)
+	if h.fmtType == waveFormatExtensible {
+		b = le.AppendUint16(b, h.fmtExtSize)
+		b = le.AppendUint16(b, h.fmtValidBits)
+		b = le.AppendUint32(b, h.fmtChannelMask)
+		b = append(b, h.fmtSubFormat[:]...)
+	}
+	return b
 }
 
 type stWAVEsmpl struct {
@@ -186,59 +214,18 @@ func newWaveSmpl() *stWAVEsmpl {
 	}
 }
 
-func (s *stWAVEsmpl) Write(w *endibuf.Writer) {
-	endianSave := w.Endian
-
-	w.Endian = binary.BigEndian
-	w.WriteBytes(s.smpl)
-
-	w.Endian = binary.LittleEndian
-	w.WriteUint32(s.smplSize)
-	w.WriteUint32(s.manufacturer)
-	w.WriteUint32(s.product)
-	w.WriteUint32(s.samplePeriod)
-	w.WriteUint32(s.MIDIUnityNote)
-	w.WriteUint32(s.MIDIPitchFraction)
-	w.WriteUint32(s.SMPTEFormat)
-	w.WriteUint32(s.SMPTEOffset)
-	w.WriteUint32(s.sampleLoops)
-	w.WriteUint32(s.samplerData)
-	w.WriteUint32(s.loopIdentifier)
-	w.WriteUint32(s.loopType)
-	w.WriteUint32(s.loopStart)
-	w.WriteUint32(s.loopEnd)
-	w.WriteUint32(s.loopFraction)
-	w.WriteUint32(s.loopPlayCount)
-
-	w.Endian = endianSave
-}
-
-func (s *stWAVEsmpl) NeoWrite(w io.Writer, endian binary.ByteOrder) {
-	endianSave := endian
-	var wEndian binary.ByteOrder
-
-	wEndian = binary.BigEndian
-	binary.Write(w, wEndian, s.smpl)
-
-	wEndian = binary.LittleEndian
-	binary.Write(w, wEndian, s.smplSize)
-	binary.Write(w, wEndian, s.manufacturer)
-	binary.Write(w, wEndian, s.product)
-	binary.Write(w, wEndian, s.samplePeriod)
-	binary.Write(w, wEndian, s.MIDIUnityNote)
-	binary.Write(w, wEndian, s.MIDIPitchFraction)
-	binary.Write(w, wEndian, s.SMPTEFormat)
-	binary.Write(w, wEndian, s.SMPTEOffset)
-	binary.Write(w, wEndian, s.sampleLoops)
-	binary.Write(w, wEndian, s.samplerData)
-	binary.Write(w, wEndian, s.loopIdentifier)
-	binary.Write(w, wEndian, s.loopType)
-	binary.Write(w, wEndian, s.loopStart)
-	binary.Write(w, wEndian, s.loopEnd)
-	binary.Write(w, wEndian, s.loopFraction)
-	binary.Write(w, wEndian, s.loopPlayCount)
-
-	wEndian = endianSave
+func (s *stWAVEsmpl) appendTo(b []byte) []byte {
+	le := binary.LittleEndian
+	b = append(b, s.smpl...)
+	for _, v := range [...]uint32{
+		s.smplSize, s.manufacturer, s.product, s.samplePeriod,
+		s.MIDIUnityNote, s.MIDIPitchFraction, s.SMPTEFormat, s.SMPTEOffset,
+		s.sampleLoops, s.samplerData, s.loopIdentifier, s.loopType,
+		s.loopStart, s.loopEnd, s.loopFraction, s.loopPlayCount,
+	} {
+		b = le.AppendUint32(b, v)
+	}
+	return b
 }
 
 type stWAVEnote struct {
@@ -256,34 +243,86 @@ func newWaveNote() *stWAVEnote {
 	}
 }
 
-func (n *stWAVEnote) Write(w *endibuf.Writer) {
-	endianSave := w.Endian
+// setComment set comment and chunk size padded to 4 bytes
+func (n *stWAVEnote) setComment(comm string) {
+	n.comm = comm
+	n.noteSize = 4 + uint32(len(comm)) + 1 // dwName + comment + zero byte
+	if (n.noteSize & 3) != 0 {
+		n.noteSize += 4 - (n.noteSize & 3)
+	}
+}
+
+// padding return zero bytes count after comment
+func (n *stWAVEnote) padding() int {
+	return int(n.noteSize) - 4 - len(n.comm) - 1
+}
 
-	w.Endian = binary.BigEndian
-	w.WriteBytes(n.note)
+func (n *stWAVEnote) appendTo(b []byte) []byte {
+	b = append(b, n.note...)
+	b = binary.LittleEndian.AppendUint32(b, n.noteSize)
+	b = binary.LittleEndian.AppendUint32(b, n.dwName)
+	b = append(b, n.comm...)
+	b = append(b, 0)
+	for range n.padding() {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// stWAVElist is LIST chunk of INFO tags
+type stWAVElist struct {
+	list     []byte
+	listSize uint32
+	info     []byte
+	items    []stWAVEinfo
+}
 
-	w.Endian = binary.LittleEndian
-	w.WriteUint32(n.noteSize)
-	w.WriteUint32(n.dwName)
-	w.WriteCString(n.comm)
+// stWAVEinfo is a sub chunk of INFO list, text is zero terminated and padded to 2 bytes
+type stWAVEinfo struct {
+	id   []byte
+	text string
+}
 
-	w.Endian = endianSave
+func newWaveList() *stWAVElist {
+	return &stWAVElist{
+		list:     []byte{'L', 'I', 'S', 'T'},
+		listSize: 4,
+		info:     []byte{'I', 'N', 'F', 'O'},
+	}
 }
 
-func (n *stWAVEnote) NeoWrite(w io.Writer, endian binary.ByteOrder) {
-	endianSave := endian
-	var wEndian binary.ByteOrder
+// add append a tag and update chunk size, empty text is ignored
+func (l *stWAVElist) add(id, text string) {
+	if text == "" {
+		return
+	}
+	item := stWAVEinfo{id: []byte(id), text: text}
+	l.items = append(l.items, item)
+	l.listSize += 8 + item.size() + uint32(item.padding())
+}
 
-	wEndian = binary.BigEndian
-	binary.Write(w, wEndian, n.note)
+func (i stWAVEinfo) size() uint32 {
+	return uint32(len(i.text)) + 1 // text + zero byte
+}
 
-	wEndian = binary.LittleEndian
-	binary.Write(w, wEndian, n.noteSize)
-	binary.Write(w, wEndian, n.dwName)
-	binary.Write(w, wEndian, []byte(n.comm))
-	binary.Write(w, wEndian, byte(0))
+func (i stWAVEinfo) padding() int {
+	return int(i.size() & 1)
+}
 
-	wEndian = endianSave
+func (l *stWAVElist) appendTo(b []byte) []byte {
+	b = append(b, l.list...)
+	b = binary.LittleEndian.AppendUint32(b, l.listSize)
+	b = append(b, l.info...)
+	for _, item := range l.items {
+		b = append(b, item.id...)
+		b = binary.LittleEndian.AppendUint32(b, item.size())
+		b = append(b, item.text...)
+		b = append(b, 0)
+		for range item.padding() {
+			b = append(b, 0)
+		}
+	}
+	return b
 }
 
 type stWAVEdata struct {
@@ -298,27 +337,7 @@ func newWaveData() *stWAVEdata {
 	}
 }
 
-func (d *stWAVEdata) Write(w *endibuf.Writer) {
-	endianSave := w.Endian
-
-	w.Endian = binary.BigEndian
-	w.WriteBytes(d.data)
-
-	w.Endian = binary.LittleEndian
-	w.WriteUint32(d.dataSize)
-
-	w.Endian = endianSave
-}
-
-func (d *stWAVEdata) NeoWrite(w io.Writer, endian binary.ByteOrder) {
-	endianSave := endian
-	var wEndian binary.ByteOrder
-
-	wEndian = binary.BigEndian
-	binary.Write(w, wEndian, d.data)
-
-	wEndian = binary.LittleEndian
-	binary.Write(w, wEndian, d.dataSize)
-
-	wEndian = endianSave
+func (d *stWAVEdata) appendTo(b []byte) []byte {
+	b = append(b, d.data...)
+	return binary.LittleEndian.AppendUint32(b, d.dataSize)
 }
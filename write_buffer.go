@@ -0,0 +1,50 @@
+package hca
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// DefaultWriteBufferSize 写出到文件时默认的输出缓冲大小 (字节), 见 Hca.WriteBufferSize
+const DefaultWriteBufferSize = 64 << 10
+
+// writeBufferSize 返回写出到 w 时使用的缓冲大小, 0 表示不缓冲
+func (h *Hca) writeBufferSize(w io.Writer) int {
+	switch {
+	case h.WriteBufferSize > 0:
+		return h.WriteBufferSize
+	case h.WriteBufferSize < 0 || lowMemory:
+		return 0
+	}
+	if _, ok := w.(*os.File); ok { // DecodeFile 等写出到文件的函数
+		return DefaultWriteBufferSize
+	}
+	return 0
+}
+
+// bufferOutput 以 size 字节的缓冲包装 w, 同时返回写出缓冲中全部数据的函数, size 为 0 时直接返回 w.
+// w 可以 Seek 时返回的 Writer 同样可以 Seek, Seek 之前先写出缓冲中的数据
+func bufferOutput(w io.Writer, size int) (io.Writer, func() error) {
+	if size <= 0 {
+		return w, func() error { return nil }
+	}
+	b := bufio.NewWriterSize(w, size)
+	if s, ok := w.(io.Seeker); ok {
+		return seekBuffer{b, s}, b.Flush
+	}
+	return b, b.Flush
+}
+
+// seekBuffer 是输出可以 Seek 时的缓冲, 保留 Seek 以便回写头部
+type seekBuffer struct {
+	*bufio.Writer
+	s io.Seeker
+}
+
+func (b seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	if err := b.Flush(); err != nil {
+		return 0, err
+	}
+	return b.s.Seek(offset, whence)
+}